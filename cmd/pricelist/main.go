@@ -1,26 +1,7 @@
 package main
 
-import (
-	"log"
-	"time"
-
-	"viking-reports/internal/config"
-	"viking-reports/internal/report"
-)
+import "viking-reports/internal/cli"
 
 func main() {
-	cfg, err := config.Load()
-	if err != nil {
-		log.Fatalf("Failed to load configuration: %v", err)
-	}
-
-	generator, err := report.NewReportGenerator("pricelist", cfg)
-	if err != nil {
-		log.Fatalf("Failed to create Credit report generator: %v", err)
-	}
-
-	if err := generator.Generate(); err != nil {
-		log.Fatalf("Failed to generate Credit report: %v", err)
-	}
-	time.Sleep(5 * time.Second) // {{ edit_2 }}
+	cli.RunReport("pricelist")
 }
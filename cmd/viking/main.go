@@ -0,0 +1,364 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"viking-reports/internal/cli"
+	"viking-reports/internal/config"
+	"viking-reports/internal/fixtures"
+	"viking-reports/internal/logging"
+	"viking-reports/internal/pipeline"
+	"viking-reports/internal/report"
+	"viking-reports/internal/schedule"
+	"viking-reports/internal/validate"
+	"viking-reports/internal/watch"
+)
+
+// command is a viking subcommand. run receives the arguments following the
+// subcommand name.
+type command struct {
+	description string
+	run         func(args []string) error
+}
+
+var commands = map[string]command{
+	"gen-fixtures": {"write synthetic input workbooks for testing and demos", genFixtures},
+	"history":      {"show the runs made by viking serve", history},
+	"list":         {"list the available reports, their inputs and dependencies", list},
+	"run":          {"generate reports, after the reports they depend on (default: all)", run},
+	"serve":        {"keep running and generate reports on a schedule or as their inputs arrive", serve},
+	"validate":     {"check the input files of the reports before a run (default: all)", validateInputs},
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	cmd, ok := commands[os.Args[1]]
+	if !ok {
+		fmt.Fprintf(os.Stderr, "unknown command %q\n\n", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err := cmd.run(os.Args[2:]); err != nil {
+		log.Fatalf("%s: %v", os.Args[1], err)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: viking <command> [flags]\n\ncommands:")
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fmt.Fprintf(os.Stderr, "  %-14s %s\n", name, commands[name].description)
+	}
+}
+
+func list(args []string) error {
+	fs := flag.NewFlagSet("list", flag.ExitOnError)
+	fs.Parse(args)
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	for _, def := range report.Definitions() {
+		fmt.Printf("%s\n  %s\n", def.Name, def.Description)
+		if len(def.DependsOn) > 0 {
+			fmt.Printf("  depends on: %s\n", strings.Join(def.DependsOn, ", "))
+		}
+		if def.Inputs == nil {
+			continue
+		}
+		missing := make(map[string]bool)
+		for _, in := range def.MissingInputs(cfg) {
+			missing[in.Path] = true
+		}
+		for _, in := range def.Inputs(cfg) {
+			status := "ok"
+			if missing[in.Path] {
+				status = "MISSING"
+			}
+			fmt.Printf("  input %-18s %-8s %s\n", in.Name, status, in.Path)
+		}
+	}
+	return nil
+}
+
+func run(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: viking run [flags] [report...]")
+		fs.PrintDefaults()
+	}
+	logFlags := logging.RegisterFlags(fs)
+	incremental := fs.Bool("incremental", false, "only regenerate reports whose inputs changed since they last ran today")
+	format := cli.FormatFlag(fs)
+	fs.Parse(args)
+
+	plan, err := planReports(fs.Args())
+	if err != nil {
+		return err
+	}
+	formats, err := cli.Formats(*format)
+	if err != nil {
+		return err
+	}
+
+	cfg, logger, closeLog := cli.Start(logFlags)
+	opts := []pipeline.Option{formats}
+	if *incremental {
+		opts = append(opts, pipeline.Incremental(filepath.Join(cfg.OutputDir, pipeline.StateDir)))
+	}
+	status := cli.Run(cfg, logger, plan, opts...)
+	closeLog()
+	if status != 0 {
+		os.Exit(status)
+	}
+	return nil
+}
+
+// planReports plans the named reports, or all reports if there are none.
+func planReports(names []string) ([]report.Definition, error) {
+	if len(names) == 0 {
+		for _, def := range report.Definitions() {
+			names = append(names, def.Name)
+		}
+	}
+	return report.Plan(names...)
+}
+
+func serve(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: viking serve [--schedule] [--watch] [flags] [report...]")
+		fs.PrintDefaults()
+	}
+	logFlags := logging.RegisterFlags(fs)
+	scheduled := fs.Bool("schedule", false, "generate the reports at the times of the configured Schedule")
+	watched := fs.Bool("watch", false, "regenerate the reports reading an input file when it changes in the data directory")
+	incremental := fs.Bool("incremental", false, "only regenerate reports whose inputs changed since they last ran today")
+	format := cli.FormatFlag(fs)
+	fs.Parse(args)
+	if !*scheduled && !*watched {
+		fs.Usage()
+		return errors.New("nothing to serve: pass --schedule, --watch or both")
+	}
+	formats, err := cli.Formats(*format)
+	if err != nil {
+		return err
+	}
+
+	cfg, logger, closeLog := cli.Start(logFlags)
+	defer closeLog()
+	names := fs.Args()
+	if len(names) == 0 {
+		names = cfg.Schedule.Reports
+	}
+	plan, err := planReports(names)
+	if err != nil {
+		return err
+	}
+	stateDir := pipeline.Incremental(filepath.Join(cfg.OutputDir, pipeline.StateDir))
+
+	// Scheduled and watched runs write the same workbooks, so they take
+	// turns.
+	var running sync.Mutex
+	generate := func(ctx context.Context, summary *report.Summary, plan []report.Definition, opts ...pipeline.Option) error {
+		running.Lock()
+		defer running.Unlock()
+		err := pipeline.Run(ctx, cfg, logger, summary, plan, opts...)
+		if err != nil {
+			logger.Error("Failed to generate reports", "err", err)
+		}
+		if err := cli.WriteSummary(cfg, logger, summary); err != nil {
+			logger.Error("Failed to write run summary", "err", err)
+		}
+		return err
+	}
+
+	var watcher *watch.Watcher
+	if *watched {
+		// Watched runs are always incremental: a changed file must not
+		// regenerate the reports it does not affect.
+		watcher, err = watch.New(cfg, plan, logger, func(ctx context.Context, plan []report.Definition) error {
+			return generate(ctx, &report.Summary{}, plan, formats, stateDir)
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	ctx, stop := cli.InterruptContext(logger)
+	defer stop()
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, 2)
+	)
+	if *scheduled {
+		opts := []pipeline.Option{formats}
+		if *incremental {
+			opts = append(opts, stateDir)
+		}
+		scheduler, err := schedule.New(cfg.Schedule, historyPath(cfg), logger, func(ctx context.Context, summary *report.Summary) error {
+			return generate(ctx, summary, plan, opts...)
+		})
+		if err != nil {
+			return err
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[0] = scheduler.Serve(ctx)
+		}()
+	}
+	if watcher != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[1] = watcher.Watch(ctx)
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
+
+func historyPath(cfg *config.Config) string {
+	return filepath.Join(cfg.OutputDir, pipeline.StateDir, schedule.HistoryFile)
+}
+
+func history(args []string) error {
+	fs := flag.NewFlagSet("history", flag.ExitOnError)
+	last := fs.Int("n", 20, "show the last n runs (0 shows all)")
+	asJSON := fs.Bool("json", false, "write the runs as JSON")
+	fs.Parse(args)
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	runs, err := schedule.ReadHistory(historyPath(cfg))
+	if err != nil {
+		return err
+	}
+	if *last > 0 && len(runs) > *last {
+		runs = runs[len(runs)-*last:]
+	}
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(runs)
+	}
+	if len(runs) == 0 {
+		fmt.Println("No scheduled runs yet")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SCHEDULED\tSTATUS\tATTEMPTS\tDURATION\tSUCCEEDED\tFAILED\tSKIPPED\tUNCHANGED\tERROR")
+	for _, run := range runs {
+		duration := ""
+		if !run.Started.IsZero() {
+			duration = run.Finished.Sub(run.Started).Round(time.Second).String()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%s\t%d\t%d\t%d\t%d\t%s\n", run.Scheduled.Format("2006-01-02 15:04 MST"), run.Status,
+			run.Attempts, duration, run.Succeeded, run.Failed, run.Skipped, run.Unchanged, run.Error)
+	}
+	return w.Flush()
+}
+
+func validateInputs(args []string) error {
+	fs := flag.NewFlagSet("validate", flag.ExitOnError)
+	fs.Usage = func() {
+		fmt.Fprintln(os.Stderr, "usage: viking validate [flags] [report...]")
+		fs.PrintDefaults()
+	}
+	maxAge := fs.Duration("max-age", 24*time.Hour, "inputs modified longer ago than this are stale (0 accepts any age)")
+	jsonPath := fs.String("json", "", "also write the results as JSON to this file (- for standard output instead of the checklist)")
+	fs.Parse(args)
+
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+	defs := report.Definitions()
+	if len(fs.Args()) > 0 {
+		if defs, err = report.Plan(fs.Args()...); err != nil {
+			return err
+		}
+	}
+
+	ctx, err := pipeline.WithPasswords(context.Background(), cfg, defs)
+	if err != nil {
+		return err
+	}
+	result, err := validate.Check(ctx, cfg, defs, *maxAge, time.Now())
+	if err != nil {
+		return err
+	}
+	switch *jsonPath {
+	case "-":
+		err = result.WriteJSON(os.Stdout)
+	case "":
+		err = result.WriteChecklist(os.Stdout)
+	default:
+		if err = result.WriteChecklist(os.Stdout); err == nil {
+			err = writeJSONFile(*jsonPath, result)
+		}
+	}
+	if err != nil {
+		return err
+	}
+	if !result.OK {
+		os.Exit(1)
+	}
+	return nil
+}
+
+func writeJSONFile(path string, result *validate.Report) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := result.WriteJSON(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func genFixtures(args []string) error {
+	cfg, err := config.Load()
+	if err != nil {
+		return fmt.Errorf("failed to load configuration: %w", err)
+	}
+
+	fs := flag.NewFlagSet("gen-fixtures", flag.ExitOnError)
+	out := fs.String("out", cfg.DataDir, "directory to write the input workbooks to")
+	retailers := fs.Int("retailers", 50, "number of retailers")
+	tses := fs.Int("tses", 4, "number of TSEs")
+	seed := fs.Int64("seed", 1, "random seed; the same seed always produces the same data")
+	fs.Parse(args)
+
+	opts := fixtures.Options{Retailers: *retailers, TSEs: *tses, Seed: *seed}
+	if err := fixtures.Generate(*out, opts); err != nil {
+		return err
+	}
+	fmt.Printf("Wrote synthetic input data for %d retailers and %d TSEs to %s\n", *retailers, *tses, *out)
+	return nil
+}
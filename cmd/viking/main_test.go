@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"viking-reports/internal/report"
+)
+
+func TestListEveryReport(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "viking.json")
+	body := `{"DataDir": "` + filepath.ToSlash(t.TempDir()) + `", "OutputDir": "` + filepath.ToSlash(t.TempDir()) + `"}`
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VIKING_CONFIG", path)
+
+	var noInputs bool
+	for _, def := range report.Definitions() {
+		noInputs = noInputs || def.Inputs == nil
+	}
+	if !noInputs {
+		t.Fatal("want a report without inputs, such as pack, to be listed")
+	}
+	stdout := os.Stdout
+	devNull, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer devNull.Close()
+	os.Stdout = devNull
+	defer func() { os.Stdout = stdout }()
+	if err := list(nil); err != nil {
+		t.Fatal(err)
+	}
+}
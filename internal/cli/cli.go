@@ -0,0 +1,132 @@
+// Package cli holds the start-up shared by the report binaries: flags,
+// configuration, logging, output formats, interrupt handling and the run
+// summary.
+package cli
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+
+	"viking-reports/internal/config"
+	"viking-reports/internal/logging"
+	"viking-reports/internal/pipeline"
+	"viking-reports/internal/report"
+	"viking-reports/pkg/output"
+)
+
+// RunReport is the main function of a single-report binary. It generates the
+// named report, after the reports it depends on, so that COGS finds the
+// credit workbooks of the same run, and exits with a non-zero status if
+// anything failed or the run was interrupted.
+func RunReport(name string) {
+	logFlags := logging.RegisterFlags(flag.CommandLine)
+	format := FormatFlag(flag.CommandLine)
+	flag.Parse()
+	formats, err := Formats(*format)
+	if err != nil {
+		log.Fatal(err)
+	}
+	plan, err := report.Plan(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	cfg, logger, closeLog := Start(logFlags)
+	status := Run(cfg, logger, plan, formats)
+	closeLog()
+	os.Exit(status)
+}
+
+// FormatFlag registers the --format flag, the formats to write the reports
+// in, on fs.
+func FormatFlag(fs *flag.FlagSet) *string {
+	return fs.String("format", output.XLSXFormat, "formats to write the reports in, comma-separated: xlsx, csv, json")
+}
+
+// Formats returns the pipeline option writing the reports in list, the value
+// of the --format flag.
+func Formats(list string) (pipeline.Option, error) {
+	formats, err := output.ParseFormats(list)
+	if err != nil {
+		return nil, err
+	}
+	return pipeline.Formats(formats...), nil
+}
+
+// Start loads the configuration and sets up logging once the flags are
+// parsed. It exits the process if either fails.
+func Start(logFlags *logging.Flags) (*config.Config, *slog.Logger, func() error) {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load configuration: %v", err)
+	}
+	logger, closeLog, err := logFlags.Setup(cfg.OutputDir)
+	if err != nil {
+		log.Fatalf("Failed to set up logging: %v", err)
+	}
+	return cfg, logger, closeLog
+}
+
+// Run generates the reports of plan until done or interrupted, writes the
+// run summary and returns the exit status of the process.
+func Run(cfg *config.Config, logger *slog.Logger, plan []report.Definition, opts ...pipeline.Option) int {
+	ctx, stop := InterruptContext(logger)
+	defer stop()
+
+	summary := &report.Summary{}
+	err := pipeline.Run(ctx, cfg, logger, summary, plan, opts...)
+	if err != nil {
+		logger.Error("Failed to generate reports", "err", err)
+	}
+	if err := WriteSummary(cfg, logger, summary); err != nil {
+		logger.Error("Failed to write run summary", "err", err)
+	}
+	if err != nil || summary.Count("", report.Failed) > 0 {
+		return 1
+	}
+	return 0
+}
+
+// InterruptContext returns a context that is cancelled on the first SIGINT or
+// SIGTERM so that the run stops after the current step. A second signal
+// terminates the process immediately. stop releases the signal handler.
+func InterruptContext(logger *slog.Logger) (ctx context.Context, stop func()) {
+	ctx, cancel := context.WithCancel(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	done := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-signals:
+			logger.Warn("interrupted, stopping after the current step; interrupt again to quit immediately", "signal", sig.String())
+			signal.Stop(signals)
+			cancel()
+		case <-done:
+		}
+	}()
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+	}
+}
+
+// WriteSummary logs the counts of succeeded, failed and skipped units and
+// writes the full summary to the logs folder of the output directory.
+func WriteSummary(cfg *config.Config, logger *slog.Logger, summary *report.Summary) error {
+	summary.Log(logger)
+	path := filepath.Join(cfg.OutputDir, "logs", fmt.Sprintf("summary_%s.json", time.Now().Format("2006-01-02_150405")))
+	if err := summary.WriteFile(path); err != nil {
+		return err
+	}
+	logger.Debug("run summary written", "file", path)
+	return nil
+}
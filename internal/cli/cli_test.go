@@ -0,0 +1,51 @@
+package cli
+
+import (
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"viking-reports/internal/config"
+	"viking-reports/internal/fixtures"
+	"viking-reports/internal/report"
+	"viking-reports/internal/utils"
+)
+
+func TestRunPlansCreditBeforeCOGS(t *testing.T) {
+	dataDir, outDir := t.TempDir(), t.TempDir()
+	if err := fixtures.Generate(dataDir, fixtures.Options{Retailers: 12, TSEs: 2, Seed: 3}); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(t.TempDir(), "viking.json")
+	body := `{"DataDir": "` + filepath.ToSlash(dataDir) + `", "OutputDir": "` + filepath.ToSlash(outDir) + `"}`
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VIKING_CONFIG", path)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Nothing has written credit workbooks yet, as when cogs_report runs in
+	// a fresh working directory.
+	plan, err := report.Plan("cogs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan) != 2 || plan[0].Name != "credit" || plan[1].Name != "cogs" {
+		t.Fatalf("planned %v, want credit then cogs", plan)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	if status := Run(cfg, logger, plan); status != 0 {
+		t.Fatalf("exit status %d, want 0", status)
+	}
+	for _, dir := range []string{"credit_reports", "inventory_report"} {
+		files, _ := filepath.Glob(filepath.Join(utils.GenerateOutputPath(cfg.OutputDir, dir), "*.xlsx"))
+		if len(files) == 0 {
+			t.Errorf("no workbooks written to %s", dir)
+		}
+	}
+}
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"encoding/json"
+	"fmt"
 	"os"
 	"path/filepath"
+	"time"
 )
 
 // Config holds the application configuration
@@ -11,6 +14,188 @@ type Config struct {
 	OutputDir   string
 	CommonFiles CommonFiles
 	ReportFiles ReportFiles
+	Timeouts    Timeouts
+	// Workers bounds how many input files a run loads at the same time; 0
+	// means one per CPU.
+	Workers int
+	// SecretsFile holds the passwords of protected input workbooks; see
+	// LoadSecrets. It defaults to DefaultSecretsFile.
+	SecretsFile string
+	Protection  Protection
+	Highlights  Highlights
+	// Charts places the charts of the growth, credit and salestarget
+	// reports, by report name. Reports without an entry draw their charts
+	// beside their tables.
+	Charts map[string]Chart
+	// Pivots are the reports, of growth, cogs, zso and credit, whose
+	// workbooks get a Pivot sheet rolling the report up by TSE.
+	Pivots    []string
+	Freshness Freshness
+	Schedule  Schedule
+	Watch     Watch
+}
+
+// Protection protects the workbooks of some reports, such as the per-TSE
+// credit and growth workbooks shared over WhatsApp. The passwords are kept in
+// the secrets file; see Secrets.
+type Protection struct {
+	// Reports are the reports whose workbooks are protected.
+	Reports []string
+	// Encrypt requires each per-TSE workbook to be opened with its TSE's
+	// password from Secrets.OutputPasswords. Reports must then list only
+	// reports written per TSE.
+	Encrypt bool
+	// LockSheets protects the sheets so that the computed columns cannot be
+	// edited, with Secrets.SheetPassword to unprotect them.
+	LockSheets bool
+	// Editable names, by header, the columns left editable in locked sheets.
+	Editable []string
+}
+
+// Protects reports whether the workbooks of the named report are protected.
+func (p Protection) Protects(report string) bool {
+	for _, r := range p.Reports {
+		if r == report {
+			return true
+		}
+	}
+	return false
+}
+
+// Encrypts reports whether the workbooks of the named report open only with
+// a password.
+func (p Protection) Encrypts(report string) bool {
+	return p.Encrypt && p.Protects(report)
+}
+
+// Highlights are the limits at which the reports colour their values. Excel
+// applies them as conditional formats, so the colours follow the values when
+// a TSE edits or sorts a sheet.
+type Highlights struct {
+	// GrowthDecline is the growth percentage, such as -60, below which the
+	// growth report colours a retailer's growth red; smaller declines are
+	// yellow and growth is green.
+	GrowthDecline float64
+	// OverdueCredit is the credit outstanding for 21 days or more from
+	// which the credit report colours it red. The default of 0 colours
+	// every such amount, as the credit report always has.
+	OverdueCredit float64
+	// Shortfall is the inventory shortfall below which the credit and COGS
+	// reports colour a retailer's amounts red.
+	Shortfall float64
+}
+
+// Chart is where a report draws its charts.
+type Chart struct {
+	// Off leaves the charts out of the report.
+	Off bool
+	// Sheet is the sheet to draw the charts on, such as "Charts"; empty
+	// draws them on the report's sheet.
+	Sheet string
+	// Cell is the top-left cell of the first chart, such as "N2"; a report
+	// with several charts draws the others below it. Empty places the
+	// charts to the right of the report's table.
+	Cell string
+	// Width and Height are the size of each chart in pixels; 0 sizes the
+	// charts to the number of rows charted.
+	Width, Height uint
+}
+
+// Freshness is how old each input file may be when a report is generated.
+type Freshness struct {
+	// Action is what a stale input does to the reports reading it; one of
+	// FreshnessBanner (the default, also when empty), FreshnessBlock or
+	// FreshnessOff.
+	Action string
+	// Inputs maps an input name, such as "Bills", to its rule. Inputs
+	// without a rule are not checked.
+	Inputs map[string]FreshnessRule
+}
+
+// Freshness actions.
+const (
+	// FreshnessBanner generates the reports with a STALE DATA sheet naming
+	// the stale inputs, opened first in Excel.
+	FreshnessBanner = "banner"
+	// FreshnessBlock fails the reports instead.
+	FreshnessBlock = "block"
+	// FreshnessOff does not check the inputs.
+	FreshnessOff = "off"
+)
+
+// FreshnessRule bounds the age of one input. A zero duration is not checked.
+type FreshnessRule struct {
+	// MaxAge is how long ago the file may have been modified.
+	MaxAge Duration
+	// MaxDataAge is how long ago the day of the latest transaction in the
+	// file may have ended. It applies to the inputs with dated rows: the
+	// bills and the MTD sales exports.
+	MaxDataAge Duration
+}
+
+// Watch is how `viking serve --watch` notices new input files.
+type Watch struct {
+	// Interval is how often the input files are checked for changes.
+	Interval Duration
+	// Settle is how long changed files must stay unchanged before the
+	// reports reading them are regenerated, so that a file still being
+	// copied, or a burst of several files, triggers a single run.
+	Settle Duration
+}
+
+// Schedule is when `viking serve --schedule` generates reports.
+type Schedule struct {
+	// Times are the times of day, such as "08:30", to run at in TimeZone.
+	Times    []string
+	TimeZone string
+	// Reports are the reports to generate; empty means all.
+	Reports []string
+	// Holidays is a text file listing the days not to run on, one
+	// YYYY-MM-DD date per line, optionally followed by the holiday's name.
+	Holidays string
+	// While an input file is missing, the run is retried every RetryEvery
+	// until RetryFor after the scheduled time.
+	RetryEvery Duration
+	RetryFor   Duration
+}
+
+// Timeouts bounds how long a report may run before it is cancelled. Reports
+// maps a report name (e.g. "credit") to its own limit; the others use
+// Default. A zero duration means no limit.
+type Timeouts struct {
+	Default Duration
+	Reports map[string]Duration
+}
+
+// For returns the timeout of the named report.
+func (t Timeouts) For(report string) time.Duration {
+	if d, ok := t.Reports[report]; ok {
+		return d.Duration
+	}
+	return t.Default.Duration
+}
+
+// Duration is a time.Duration written as a string such as "90s" or "5m" in
+// the configuration file.
+type Duration struct {
+	time.Duration
+}
+
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(d.String())
+}
+
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return fmt.Errorf("duration must be a string such as \"5m\": %w", err)
+	}
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+	d.Duration = parsed
+	return nil
 }
 
 // CommonFiles holds paths to common files used across reports
@@ -49,12 +234,53 @@ type GrowthReportFiles struct {
 	LMTDST string
 }
 
-// Load returns a new Config struct with default values
+// DefaultFile is the optional configuration file read from the working
+// directory. VIKING_CONFIG names a different file.
+const DefaultFile = "viking.json"
+
+// Load returns a new Config struct with default values, overridden by the
+// fields set in the configuration file if there is one. Input paths default
+// to the standard file names under DataDir.
 func Load() (*Config, error) {
-	dataDir := filepath.Join("..", "data")
-	outputDir := "."
+	path, explicit := os.LookupEnv("VIKING_CONFIG")
+	if !explicit {
+		path = DefaultFile
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if explicit || !os.IsNotExist(err) {
+			return nil, fmt.Errorf("failed to read configuration file: %w", err)
+		}
+	}
+
+	// The directories come first since the default input paths derive
+	// from them.
+	dirs := struct{ DataDir, OutputDir string }{filepath.Join("..", "data"), "."}
+	if data != nil {
+		if err := json.Unmarshal(data, &dirs); err != nil {
+			return nil, fmt.Errorf("failed to parse configuration file %s: %w", path, err)
+		}
+	}
+	config := defaults(dirs.DataDir, dirs.OutputDir)
+	if data != nil {
+		if err := json.Unmarshal(data, config); err != nil {
+			return nil, fmt.Errorf("failed to parse configuration file %s: %w", path, err)
+		}
+	}
+
+	// Ensure directories exist
+	if err := os.MkdirAll(config.DataDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(config.OutputDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	return config, nil
+}
 
-	config := &Config{
+func defaults(dataDir, outputDir string) *Config {
+	return &Config{
 		DataDir:   dataDir,
 		OutputDir: outputDir,
 		CommonFiles: CommonFiles{
@@ -80,15 +306,36 @@ func Load() (*Config, error) {
 			PriceListFile:   filepath.Join(dataDir, "ZD PRICE LIST.xlsx"),
 			SalesReport:     filepath.Join(dataDir, "Sales.xlsx"),
 		},
+		Timeouts: Timeouts{
+			Default: Duration{10 * time.Minute},
+		},
+		Freshness: Freshness{
+			Action: FreshnessBanner,
+			Inputs: map[string]FreshnessRule{
+				"Bills":           {MaxAge: Duration{24 * time.Hour}, MaxDataAge: Duration{72 * time.Hour}},
+				"Received":        {MaxAge: Duration{24 * time.Hour}},
+				"DealerInventory": {MaxAge: Duration{24 * time.Hour}},
+				"MTD-SO":          {MaxAge: Duration{24 * time.Hour}, MaxDataAge: Duration{72 * time.Hour}},
+				"MTD-ST":          {MaxAge: Duration{24 * time.Hour}, MaxDataAge: Duration{72 * time.Hour}},
+				"LMTD-SO":         {MaxAge: Duration{24 * time.Hour}},
+				"LMTD-ST":         {MaxAge: Duration{24 * time.Hour}},
+				"L2M-SO":          {MaxAge: Duration{24 * time.Hour}},
+				"Sales":           {MaxAge: Duration{24 * time.Hour}},
+			},
+		},
+		Highlights: Highlights{
+			GrowthDecline: -60,
+		},
+		Watch: Watch{
+			Interval: Duration{5 * time.Second},
+			Settle:   Duration{30 * time.Second},
+		},
+		Schedule: Schedule{
+			Times:      []string{"08:30"},
+			TimeZone:   "Asia/Kolkata",
+			Holidays:   filepath.Join(dataDir, "holidays.txt"),
+			RetryEvery: Duration{10 * time.Minute},
+			RetryFor:   Duration{3 * time.Hour},
+		},
 	}
-
-	// Ensure directories exist
-	if err := os.MkdirAll(config.DataDir, os.ModePerm); err != nil {
-		return nil, err
-	}
-	if err := os.MkdirAll(config.OutputDir, os.ModePerm); err != nil {
-		return nil, err
-	}
-
-	return config, nil
 }
@@ -0,0 +1,43 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadOverlay(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "viking.json")
+	data := `{
+		"DataDir": "` + filepath.ToSlash(filepath.Join(dir, "inputs")) + `",
+		"OutputDir": "` + filepath.ToSlash(filepath.Join(dir, "out")) + `",
+		"Timeouts": {"Default": "2m", "Reports": {"credit": "30s"}}
+	}`
+	if err := os.WriteFile(path, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VIKING_CONFIG", path)
+
+	cfg, err := Load()
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if want := filepath.Join(dir, "inputs", "Bills.xlsx"); cfg.ReportFiles.CreditReport.Bills != want {
+		t.Errorf("Bills = %q, want %q derived from DataDir", cfg.ReportFiles.CreditReport.Bills, want)
+	}
+	if got := cfg.Timeouts.For("credit"); got != 30*time.Second {
+		t.Errorf("credit timeout = %v, want 30s", got)
+	}
+	if got := cfg.Timeouts.For("growth"); got != 2*time.Minute {
+		t.Errorf("growth timeout = %v, want 2m", got)
+	}
+}
+
+func TestLoadMissingExplicitFile(t *testing.T) {
+	t.Setenv("VIKING_CONFIG", filepath.Join(t.TempDir(), "missing.json"))
+	if _, err := Load(); err == nil {
+		t.Error("expected an error for a missing VIKING_CONFIG file")
+	}
+}
@@ -0,0 +1,140 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"unicode"
+)
+
+// DefaultSecretsFile is the secrets file read from the working directory
+// unless SecretsFile names another.
+const DefaultSecretsFile = "viking.secrets.json"
+
+// PasswordEnvPrefix starts the environment variables holding input
+// passwords: VIKING_PASSWORD_BILLS holds the password of the "Bills" input.
+const PasswordEnvPrefix = "VIKING_PASSWORD_"
+
+// OutputPasswordEnvPrefix starts the environment variables holding the
+// passwords of the workbooks sent to each recipient, e.g.
+// VIKING_OUTPUT_PASSWORD_HARISH.
+const OutputPasswordEnvPrefix = "VIKING_OUTPUT_PASSWORD_"
+
+// AnyRecipient is the key of Secrets.OutputPasswords used for recipients
+// without their own password.
+const AnyRecipient = "*"
+
+// Secret is a password. It prints, logs and marshals as asterisks, so that
+// it cannot leak into a log or a manifest by accident.
+type Secret string
+
+func (Secret) String() string { return "******" }
+
+func (s Secret) LogValue() slog.Value { return slog.StringValue(s.String()) }
+
+func (s Secret) MarshalJSON() ([]byte, error) { return json.Marshal(s.String()) }
+
+func (s *Secret) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, (*string)(s))
+}
+
+// Reveal returns the password itself, to hand to the code that needs it.
+func (s Secret) Reveal() string { return string(s) }
+
+// Secrets are the passwords kept out of the configuration, so that they
+// never end up in manifests, logs or the run state.
+type Secrets struct {
+	// Passwords maps an input name, such as "Bills", to the password of
+	// the workbook.
+	Passwords map[string]Secret
+	// OutputPasswords maps a recipient, such as a TSE, to the password of
+	// the protected workbooks sent to them.
+	OutputPasswords map[string]Secret
+	// SheetPassword unprotects the locked sheets of protected workbooks.
+	SheetPassword Secret
+}
+
+// LoadSecrets reads the secrets file of cfg; a missing file has no secrets
+// unless SecretsFile was set explicitly.
+func LoadSecrets(cfg *Config) (*Secrets, error) {
+	path := cfg.SecretsFile
+	if path == "" {
+		path = DefaultSecretsFile
+	}
+	s := &Secrets{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && cfg.SecretsFile == "" {
+			return s, nil
+		}
+		return nil, fmt.Errorf("failed to read secrets file: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		// The error of a malformed file could quote a password.
+		return nil, fmt.Errorf("failed to parse secrets file %s: invalid JSON", path)
+	}
+	return s, nil
+}
+
+// Password returns the password of the named input: from its environment
+// variable if set, otherwise from the secrets file.
+func (s *Secrets) Password(input string) (Secret, bool) {
+	if pw, ok := os.LookupEnv(PasswordEnv(input)); ok {
+		return Secret(pw), true
+	}
+	pw, ok := s.Passwords[input]
+	return pw, ok
+}
+
+// OutputPassword returns the password of the workbooks sent to recipient:
+// from its environment variable if set, otherwise from the secrets file,
+// where AnyRecipient covers the recipients without their own.
+func (s *Secrets) OutputPassword(recipient string) (Secret, bool) {
+	if pw, ok := os.LookupEnv(OutputPasswordEnv(recipient)); ok {
+		return Secret(pw), true
+	}
+	if pw, ok := s.OutputPasswords[recipient]; ok {
+		return pw, true
+	}
+	pw, ok := s.OutputPasswords[AnyRecipient]
+	return pw, ok
+}
+
+// PasswordEnv returns the environment variable holding the password of the
+// named input: VIKING_PASSWORD_ followed by the name in upper case, with
+// every other character than letters and digits replaced by _.
+func PasswordEnv(input string) string {
+	return PasswordEnvPrefix + envName(input)
+}
+
+// OutputPasswordEnv returns the environment variable holding the password of
+// the workbooks sent to recipient, named like PasswordEnv.
+func OutputPasswordEnv(recipient string) string {
+	return OutputPasswordEnvPrefix + envName(recipient)
+}
+
+// AllOutputPasswords returns every recipient's password, from the
+// environment and the secrets file.
+func (s *Secrets) AllOutputPasswords() []Secret {
+	var all []Secret
+	for _, env := range os.Environ() {
+		if name, pw, ok := strings.Cut(env, "="); ok && strings.HasPrefix(name, OutputPasswordEnvPrefix) {
+			all = append(all, Secret(pw))
+		}
+	}
+	for _, pw := range s.OutputPasswords {
+		all = append(all, pw)
+	}
+	return all
+}
+
+func envName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			return unicode.ToUpper(r)
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,74 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSecrets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "secrets.json")
+	if err := os.WriteFile(path, []byte(`{"Passwords": {"Bills": "tally123", "Received": "rcpt"}}`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VIKING_PASSWORD_RECEIVED", "from-env")
+
+	secrets, err := LoadSecrets(&Config{SecretsFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pw, ok := secrets.Password("Bills"); !ok || pw.Reveal() != "tally123" {
+		t.Errorf("Bills: got %v", ok)
+	}
+	if pw, ok := secrets.Password("Received"); !ok || pw.Reveal() != "from-env" {
+		t.Errorf("Received: want the environment to override the file")
+	}
+	if _, ok := secrets.Password("DealerInventory"); ok {
+		t.Errorf("DealerInventory has no password")
+	}
+
+	// A password never shows in JSON or logs.
+	data, _ := json.Marshal(secrets)
+	var logged bytes.Buffer
+	slog.New(slog.NewTextHandler(&logged, nil)).Info("secrets", "password", secrets.Passwords["Bills"])
+	for _, out := range []string{string(data), logged.String()} {
+		if strings.Contains(out, "tally123") {
+			t.Errorf("password leaked: %s", out)
+		}
+	}
+
+	if _, err := LoadSecrets(&Config{SecretsFile: filepath.Join(t.TempDir(), "missing.json")}); err == nil {
+		t.Errorf("want an error for a missing secrets file set explicitly")
+	}
+}
+
+func TestPasswordEnv(t *testing.T) {
+	for input, want := range map[string]string{
+		"Bills":             "VIKING_PASSWORD_BILLS",
+		"Retailer Metadata": "VIKING_PASSWORD_RETAILER_METADATA",
+		"MTD-SO":            "VIKING_PASSWORD_MTD_SO",
+	} {
+		if got := PasswordEnv(input); got != want {
+			t.Errorf("PasswordEnv(%q) = %q, want %q", input, got, want)
+		}
+	}
+}
+
+func TestOutputPassword(t *testing.T) {
+	secrets := &Secrets{OutputPasswords: map[string]Secret{"Ravi Kumar": "ravi", AnyRecipient: "team"}}
+	t.Setenv("VIKING_OUTPUT_PASSWORD_ANIL", "anil")
+
+	for recipient, want := range map[string]string{"Ravi Kumar": "ravi", "Anil": "anil", "Suresh": "team"} {
+		if pw, ok := secrets.OutputPassword(recipient); !ok || pw.Reveal() != want {
+			t.Errorf("OutputPassword(%q) = %v, want %q", recipient, ok, want)
+		}
+	}
+	delete(secrets.OutputPasswords, AnyRecipient)
+	if _, ok := secrets.OutputPassword("Suresh"); ok {
+		t.Errorf("Suresh has no password without a default")
+	}
+}
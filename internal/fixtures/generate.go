@@ -0,0 +1,409 @@
+package fixtures
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+	"viking-reports/internal/repository"
+)
+
+// Options controls the size and randomness of a generated data set.
+type Options struct {
+	Retailers int
+	TSEs      int
+	Seed      int64
+	// Now is the day the data set is generated for. It defaults to today.
+	Now time.Time
+}
+
+// Input file names, as expected by config.Load.
+const (
+	RetailerMetadataFile = "Retailer Metadata.xlsx"
+	BillsFile            = "Bills.xlsx"
+	ReceivedFile         = "Received.xlsx"
+	MTDSOFile            = "MTD-SO.xlsx"
+	LMTDSOFile           = "LMTD-SO.xlsx"
+	L2MSOFile            = "L2M-SO.xlsx"
+	MTDSTFile            = "MTD-ST.xlsx"
+	LMTDSTFile           = "LMTD-ST.xlsx"
+	InventoryFile        = "DealerInventory.xlsx"
+	ProductPriceFile     = "ProductPriceList.xlsx"
+	ZDPriceListFile      = "ZD PRICE LIST.xlsx"
+	SalesFile            = "Sales.xlsx"
+)
+
+var (
+	tseNames      = []string{"Krishna", "Sathish", "Harish", "Manjunath", "Ravi", "Suresh", "Prakash", "Naveen", "Kiran", "Mahesh"}
+	shopPrefixes  = []string{"Sri", "Sai", "New", "Royal", "Star", "Galaxy", "Metro", "Lakshmi", "Balaji", "Ganesh", "Vinayaka", "Amman"}
+	shopSuffixes  = []string{"Mobiles", "Telecom", "Cellular", "Communications", "Mobile Point", "Electronics", "Mobile World"}
+	areas         = []string{"Yelahanka", "Hebbal", "Devanahalli", "Doddaballapur", "Jakkur", "Vidyaranyapura", "Sahakara Nagar", "Kodigehalli", "Hunasamaranahalli", "Bagalur"}
+	accessoryItem = []string{"ACCESSORIES Charger 33W", "ACCESSORIES Type-C Cable", "realme Buds T300", "realme Buds Air 6", "ACCESSORIES Back Cover"}
+	otherItems    = []string{"Screen Guard", "Tempered Glass", "Memory Card 64GB", "Selfie Stick"}
+)
+
+// catalogModel is one phone model as the DMS and the zonal distributor list it.
+type catalogModel struct {
+	spu      string   // DMS SPU name without the "realme" prefix
+	zdModel  string   // model as printed on the ZD price list
+	colours  []string // colours, printed on a single ZD row
+	variants []string // memory+storage
+	dlr      int      // dealer price of the first variant
+}
+
+var catalog = []catalogModel{
+	{"C61", "C61", []string{"SAFARI GREEN", "MARBLE BLACK"}, []string{"4GB+64GB", "4GB+128GB"}, 7200},
+	{"C63", "C63", []string{"LEATHER BLUE", "JADE GREEN"}, []string{"4GB+128GB"}, 8100},
+	{"C63 5G", "C63 5G", []string{"STARRY GOLD", "ETERNAL BLACK"}, []string{"4GB+128GB", "6GB+128GB"}, 9400},
+	{"C65 5G", "C65 5G", []string{"SPEED GREEN", "DARK PURPLE"}, []string{"4GB+64GB", "6GB+128GB"}, 9900},
+	{"13 5G", "13 5G", []string{"SPEED GREEN", "DARK PURPLE"}, []string{"8GB+128GB", "8GB+256GB"}, 14900},
+	{"13+ 5G", "13+ 5G", []string{"VICTORY GOLD", "SPEED GREEN", "DARK PURPLE"}, []string{"8GB+256GB"}, 19600},
+	{"13 Pro 5G", "13 PRO 5G", []string{"MONET GOLD", "EMERALD GREEN"}, []string{"8GB+128GB", "12GB+256GB"}, 21800},
+	{"13 Pro+ 5G", "13 PRO+ 5G", []string{"MONET GOLD", "MONET PURPLE", "EMERALD GREEN"}, []string{"12GB+256GB"}, 28500},
+	{"GT 6T", "GT 6T", []string{"FLUID SILVER", "RAZOR GREEN"}, []string{"8GB+128GB", "12GB+256GB"}, 27200},
+	{"GT6", "GT6", []string{"FLUID SILVER", "RAZOR GREEN"}, []string{"12GB+256GB"}, 36100},
+	{"P1 5G", "P1 5G", []string{"PHOENIX RED", "PEACOCK GREEN"}, []string{"6GB+128GB", "8GB+256GB"}, 13200},
+	{"P2 Pro", "P2 PRO", []string{"PARROT GREEN", "EAGLE GREY"}, []string{"8GB+128GB", "12GB+256GB"}, 19900},
+}
+
+// unseparatedColours are the colour runs the distributor sometimes prints
+// without any separator; the price list repository knows how to split them.
+var unseparatedColours = map[string]bool{
+	"SAFARI GREEN MARBLE BLACK":             true,
+	"SPEED GREEN DARK PURPLE":               true,
+	"VICTORY GOLD SPEED GREEN DARK PURPLE":  true,
+	"MONET GOLD EMERALD GREEN":              true,
+	"MONET GOLD MONET PURPLE EMERALD GREEN": true,
+	"FLUID SILVER RAZOR GREEN":              true,
+}
+
+// sku is one sellable colour/variant of a catalog model.
+type sku struct {
+	model        catalogModel
+	colour       string
+	variant      string
+	materialCode string
+	dlr          int
+}
+
+// Generate writes a complete, internally consistent set of fake input
+// workbooks to dir, using the file names config.Load expects.
+func Generate(dir string, opts Options) error {
+	if opts.Retailers <= 0 {
+		return fmt.Errorf("number of retailers must be positive, got %d", opts.Retailers)
+	}
+	if opts.TSEs <= 0 {
+		return fmt.Errorf("number of TSEs must be positive, got %d", opts.TSEs)
+	}
+	if opts.Now.IsZero() {
+		opts.Now = time.Now()
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return fmt.Errorf("error creating fixtures directory: %w", err)
+	}
+
+	g := &generator{rnd: rand.New(rand.NewSource(opts.Seed)), now: opts.Now}
+	g.buildCatalog()
+	g.buildRetailers(opts.Retailers, opts.TSEs)
+
+	path := func(name string) string { return filepath.Join(dir, name) }
+	thisMonth := time.Date(g.now.Year(), g.now.Month(), 1, 0, 0, 0, 0, g.now.Location())
+	lastMonth := thisMonth.AddDate(0, -1, 0)
+
+	writes := []struct {
+		name  string
+		write func() error
+	}{
+		{RetailerMetadataFile, func() error { return WriteRetailerMetadata(path(RetailerMetadataFile), g.retailers) }},
+		{BillsFile, func() error { return WriteBills(path(BillsFile), g.bills()) }},
+		{ReceivedFile, func() error { return WriteReceived(path(ReceivedFile), g.receipts()) }},
+		{MTDSOFile, func() error {
+			return WriteSaleEvents(path(MTDSOFile), SellOutLayout, g.saleNow(g.saleEvents(thisMonth, g.now.Day(), 6)))
+		}},
+		{LMTDSOFile, func() error {
+			return WriteSaleEvents(path(LMTDSOFile), SellOutLayout, g.saleEvents(lastMonth, daysIn(lastMonth), 6))
+		}},
+		{MTDSTFile, func() error {
+			return WriteSaleEvents(path(MTDSTFile), SellThroughLayout, g.saleNow(g.saleEvents(thisMonth, g.now.Day(), 8)))
+		}},
+		{LMTDSTFile, func() error {
+			return WriteSaleEvents(path(LMTDSTFile), SellThroughLayout, g.saleEvents(lastMonth, daysIn(lastMonth), 8))
+		}},
+		{L2MSOFile, func() error {
+			events := g.saleEvents(lastMonth.AddDate(0, -1, 0), daysIn(lastMonth.AddDate(0, -1, 0)), 6)
+			events = append(events, g.saleEvents(lastMonth, daysIn(lastMonth), 6)...)
+			return WriteSaleEvents(path(L2MSOFile), SellOutLayout, events)
+		}},
+		{InventoryFile, func() error { return WriteInventory(path(InventoryFile), g.inventory()) }},
+		{ProductPriceFile, func() error { return WriteProductPrices(path(ProductPriceFile), g.productPrices()) }},
+		{ZDPriceListFile, func() error { return WriteZDPriceList(path(ZDPriceListFile), g.zdPriceList()) }},
+		{SalesFile, func() error { return WriteTallySales(path(SalesFile), g.tallySales()) }},
+	}
+	for _, w := range writes {
+		if err := w.write(); err != nil {
+			return fmt.Errorf("error writing %s: %w", w.name, err)
+		}
+	}
+	return nil
+}
+
+type generator struct {
+	rnd       *rand.Rand
+	now       time.Time
+	skus      []sku
+	retailers []repository.Retailer
+}
+
+func (g *generator) buildCatalog() {
+	materialCode := 6001001
+	for _, model := range catalog {
+		for v, variant := range model.variants {
+			for _, colour := range model.colours {
+				g.skus = append(g.skus, sku{
+					model:        model,
+					colour:       colour,
+					variant:      variant,
+					materialCode: fmt.Sprint(materialCode),
+					dlr:          model.dlr + v*1500,
+				})
+				materialCode++
+			}
+		}
+	}
+}
+
+func (g *generator) buildRetailers(count, tses int) {
+	names := make([]string, tses)
+	for i := range names {
+		names[i] = tseNames[i%len(tseNames)]
+		if i >= len(tseNames) {
+			names[i] = fmt.Sprintf("%s %d", names[i], i/len(tseNames)+1)
+		}
+	}
+
+	used := make(map[string]bool)
+	for i := 0; i < count; i++ {
+		var name string
+		for attempt := 0; ; attempt++ {
+			name = fmt.Sprintf("%s %s %s", g.pick(shopPrefixes), g.pick(shopSuffixes), g.pick(areas))
+			if attempt > 20 {
+				name = fmt.Sprintf("%s %d", name, i)
+			}
+			if !used[name] {
+				break
+			}
+		}
+		used[name] = true
+
+		retailer := repository.Retailer{
+			Code:      fmt.Sprintf("RMKA%05d", 10001+i),
+			Name:      name,
+			TallyName: strings.ToUpper(name),
+			TSE:       names[g.rnd.Intn(len(names))],
+		}
+		// A few retailers are not assigned to any TSE yet.
+		if g.rnd.Intn(20) == 0 {
+			retailer.TSE = ""
+		}
+		if g.rnd.Intn(3) == 0 {
+			retailer.Type = "RA"
+			retailer.CountOfRA = fmt.Sprint(1 + g.rnd.Intn(3))
+		}
+		g.retailers = append(g.retailers, retailer)
+	}
+}
+
+func (g *generator) bills() []repository.Bill {
+	var bills []repository.Bill
+	ref := 1001
+	for _, retailer := range g.retailers {
+		for n := g.rnd.Intn(5); n > 0; n-- {
+			age := g.rnd.Intn(60)
+			date := g.now.AddDate(0, 0, -age)
+			bills = append(bills, repository.Bill{
+				Date:          date.Format("02-Jan-06"),
+				RefNo:         fmt.Sprintf("VK/%d", ref),
+				RetailerName:  retailer.TallyName,
+				PendingAmount: float64(2000 + g.rnd.Intn(60000)),
+				DueDate:       date.AddDate(0, 0, 7).Format("02-Jan-06"),
+				AgeOfBill:     age,
+			})
+			ref++
+		}
+	}
+	// Some retailer is billed every day, so the export is as recent as the
+	// freshness rules expect of a daily export.
+	retailer := g.retailers[g.rnd.Intn(len(g.retailers))]
+	bills = append(bills, repository.Bill{
+		Date:          g.now.Format("02-Jan-06"),
+		RefNo:         fmt.Sprintf("VK/%d", ref),
+		RetailerName:  retailer.TallyName,
+		PendingAmount: float64(2000 + g.rnd.Intn(60000)),
+		DueDate:       g.now.AddDate(0, 0, 7).Format("02-Jan-06"),
+	})
+	ref++
+	// Parties that are billed in Tally but missing from the retailer master.
+	bills = append(bills, repository.Bill{
+		Date:          g.now.AddDate(0, 0, -12).Format("02-Jan-06"),
+		RefNo:         fmt.Sprintf("VK/%d", ref),
+		RetailerName:  "CASH SALES",
+		PendingAmount: 1500,
+		DueDate:       g.now.AddDate(0, 0, -5).Format("02-Jan-06"),
+		AgeOfBill:     12,
+	})
+	return bills
+}
+
+func (g *generator) receipts() []repository.Receipt {
+	var receipts []repository.Receipt
+	for _, retailer := range g.retailers {
+		if g.rnd.Intn(4) == 0 {
+			receipts = append(receipts, repository.Receipt{
+				PartyName: retailer.TallyName,
+				Amount:    float64(500 * (1 + g.rnd.Intn(40))),
+			})
+		}
+	}
+	return receipts
+}
+
+// saleEvents spreads up to perDealer sales of each retailer over the first
+// days of month.
+func (g *generator) saleEvents(month time.Time, days, perDealer int) []repository.SaleEvent {
+	var events []repository.SaleEvent
+	for _, retailer := range g.retailers {
+		for n := g.rnd.Intn(perDealer + 1); n > 0; n-- {
+			s := g.skus[g.rnd.Intn(len(g.skus))]
+			at := month.AddDate(0, 0, g.rnd.Intn(days)).Add(time.Duration(9*60+g.rnd.Intn(12*60)) * time.Minute)
+			events = append(events, repository.SaleEvent{
+				DealerCode:   retailer.Code,
+				DealerName:   retailer.Name,
+				ActivateTime: at.Format("2006-01-02 15:04:05"),
+				SPUName:      "realme " + s.model.spu,
+				ProductType:  "mobile phone",
+			})
+		}
+	}
+	return events
+}
+
+// saleNow adds a sale made at now to events, so that an MTD export runs up
+// to today as the freshness rules expect of a daily export.
+func (g *generator) saleNow(events []repository.SaleEvent) []repository.SaleEvent {
+	retailer := g.retailers[g.rnd.Intn(len(g.retailers))]
+	s := g.skus[g.rnd.Intn(len(g.skus))]
+	return append(events, repository.SaleEvent{
+		DealerCode:   retailer.Code,
+		DealerName:   retailer.Name,
+		ActivateTime: g.now.Format("2006-01-02 15:04:05"),
+		SPUName:      "realme " + s.model.spu,
+		ProductType:  "mobile phone",
+	})
+}
+
+func (g *generator) inventory() []repository.InventoryUnit {
+	var units []repository.InventoryUnit
+	addUnits := func(code, name string, count int) {
+		for ; count > 0; count-- {
+			s := g.skus[g.rnd.Intn(len(g.skus))]
+			units = append(units, repository.InventoryUnit{
+				AreaName:     "North Bangalore",
+				DealerCode:   code,
+				DealerName:   name,
+				MaterialCode: s.materialCode,
+				SPUName:      "realme " + s.model.spu,
+				Color:        s.colour,
+				SKUSpec:      skuSpec(s.variant),
+				ProductType:  "mobile phone",
+			})
+		}
+	}
+	for _, retailer := range g.retailers {
+		addUnits(retailer.Code, retailer.Name, g.rnd.Intn(12))
+	}
+	// Stock still held by the distributor has no dealer.
+	addUnits("", "", 10+g.rnd.Intn(20))
+	return units
+}
+
+func (g *generator) productPrices() []ProductPrice {
+	prices := make([]ProductPrice, 0, len(g.skus))
+	for _, s := range g.skus {
+		prices = append(prices, ProductPrice{MaterialCode: s.materialCode, NLC: float64(s.dlr)})
+	}
+	return prices
+}
+
+// zdPriceList lists every variant of a model on one row, with its colours
+// joined the way the distributor prints them.
+func (g *generator) zdPriceList() []ZDPriceListEntry {
+	separators := []string{"/", "\n", ", "}
+	var entries []ZDPriceListEntry
+	for _, model := range catalog {
+		for v, variant := range model.variants {
+			dlr := model.dlr + v*1500
+			colours := strings.Join(model.colours, separators[g.rnd.Intn(len(separators))])
+			if unseparatedColours[strings.Join(model.colours, " ")] && g.rnd.Intn(2) == 0 {
+				colours = strings.Join(model.colours, " ")
+			}
+			entries = append(entries, ZDPriceListEntry{
+				Type:    "SMART PHONE",
+				Model:   model.zdModel,
+				Colours: colours,
+				Variant: variant,
+				DLR:     dlr,
+				MOP:     dlr + dlr/10,
+				MRP:     dlr + dlr/4,
+			})
+		}
+	}
+	entries = append(entries, ZDPriceListEntry{Type: "ACCESSORIES", Model: "Buds T300", Colours: "BLACK/WHITE", Variant: "NA", DLR: 1500, MOP: 1999, MRP: 2499})
+	return entries
+}
+
+func (g *generator) tallySales() []repository.SalesData {
+	var sales []repository.SalesData
+	for _, retailer := range g.retailers {
+		for n := g.rnd.Intn(8); n > 0; n-- {
+			var item string
+			var value int
+			switch g.rnd.Intn(4) {
+			case 0, 1:
+				s := g.skus[g.rnd.Intn(len(g.skus))]
+				item, value = "SMART PHONE realme "+s.model.spu, s.dlr
+			case 2:
+				item, value = g.pick(accessoryItem), 300+g.rnd.Intn(2500)
+			default:
+				item, value = g.pick(otherItems), 100+g.rnd.Intn(600)
+			}
+			sales = append(sales, repository.SalesData{
+				DealerCode: retailer.Code,
+				DealerName: retailer.TallyName,
+				ItemName:   item,
+				MTDS:       1,
+				Value:      value,
+			})
+		}
+	}
+	return sales
+}
+
+func (g *generator) pick(values []string) string {
+	return values[g.rnd.Intn(len(values))]
+}
+
+// skuSpec renders a "4GB+64GB" variant the way the DMS lists it ("64GB 4GB"),
+// which is also how the price list report keys material codes.
+func skuSpec(variant string) string {
+	parts := strings.SplitN(variant, "+", 2)
+	if len(parts) != 2 {
+		return variant
+	}
+	return parts[1] + " " + parts[0]
+}
+
+func daysIn(month time.Time) int {
+	return month.AddDate(0, 1, -1).Day()
+}
@@ -0,0 +1,117 @@
+package fixtures
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"viking-reports/internal/repository"
+)
+
+// TestGenerateReadable checks that the generated workbooks load through the
+// Excel repositories with the headers they look up.
+func TestGenerateReadable(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, time.September, 18, 0, 0, 0, 0, time.Local)
+	if err := Generate(dir, Options{Retailers: 25, TSEs: 3, Seed: 7, Now: now}); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+
+	tseMap, err := repository.NewExcelTSEMappingRepository(path(RetailerMetadataFile)).GetRetailerCodeToTSEMap(context.Background())
+	if err != nil {
+		t.Fatalf("retailer metadata: %v", err)
+	}
+	if len(tseMap) == 0 {
+		t.Error("no retailer is assigned to a TSE")
+	}
+
+	if bills, err := repository.NewExcelCreditRepository(path(BillsFile)).GetBills(context.Background()); err != nil || len(bills) == 0 {
+		t.Errorf("bills: got %d bills, err %v", len(bills), err)
+	}
+	if _, err := repository.NewExcelDebitRepository(path(ReceivedFile)).GetDebit(context.Background()); err != nil {
+		t.Errorf("received: %v", err)
+	}
+
+	sales := repository.NewExcelSalesRepository()
+	for _, name := range []string{MTDSOFile, LMTDSOFile, L2MSOFile, MTDSTFile, LMTDSTFile} {
+		if _, err := sales.GetSales(context.Background(), path(name)); err != nil {
+			t.Errorf("%s: %v", name, err)
+		}
+	}
+
+	prices := repository.NewExcelProductPriceRepository(path(ProductPriceFile))
+	tseRepo := repository.NewExcelTSEMappingRepository(path(RetailerMetadataFile))
+	inventory := repository.NewExcelInventoryRepository(path(InventoryFile), prices, tseRepo, dir)
+	if counts, err := inventory.ComputeMaterialModelCount(context.Background()); err != nil || len(counts) == 0 {
+		t.Errorf("inventory: got %d material codes, err %v", len(counts), err)
+	}
+
+	priceList := repository.NewExcelPriceListRepository(path(ZDPriceListFile), path(InventoryFile))
+	rows, err := priceList.GetPriceListData(context.Background())
+	if err != nil || len(rows) == 0 {
+		t.Fatalf("ZD price list: got %d rows, err %v", len(rows), err)
+	}
+	for _, row := range rows {
+		if row.Model == "" {
+			t.Errorf("price list row %+v lost its merged model", row)
+		}
+	}
+
+	tally, err := repository.NewExcelSalesTargetRepository().ReadSales(context.Background(), path(SalesFile), tseMap)
+	if err != nil || len(tally) == 0 {
+		t.Errorf("tally sales: got %d lines, err %v", len(tally), err)
+	}
+}
+
+// TestGenerateUpToDate checks that the dated exports run up to the day of
+// the data, so that demo runs pass the default freshness rules.
+func TestGenerateUpToDate(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Date(2024, time.September, 18, 10, 30, 0, 0, time.Local)
+	if err := Generate(dir, Options{Retailers: 5, TSEs: 2, Seed: 1, Now: now}); err != nil {
+		t.Fatalf("Generate: %v", err)
+	}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	for _, in := range []struct {
+		name   string
+		schema repository.Schema
+	}{
+		{BillsFile, repository.BillsSchema},
+		{MTDSOFile, repository.SalesExportSchema},
+		{MTDSTFile, repository.SalesExportSchema},
+	} {
+		latest, ok, err := repository.LatestDate(context.Background(), nil, filepath.Join(dir, in.name), in.schema, logger)
+		if err != nil || !ok {
+			t.Fatalf("%s: no latest date, err %v", in.name, err)
+		}
+		if got := latest.Format("2006-01-02"); got != "2024-09-18" {
+			t.Errorf("%s: latest transaction on %s, want the day of the data", in.name, got)
+		}
+	}
+}
+
+func TestGenerateDeterministic(t *testing.T) {
+	now := time.Date(2024, time.September, 18, 0, 0, 0, 0, time.Local)
+	retailers := func(seed int64) map[string]string {
+		dir := t.TempDir()
+		if err := Generate(dir, Options{Retailers: 10, TSEs: 2, Seed: seed, Now: now}); err != nil {
+			t.Fatalf("Generate: %v", err)
+		}
+		names, err := repository.NewExcelTSEMappingRepository(filepath.Join(dir, RetailerMetadataFile)).GetRetailerCodeToNameMap(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return names
+	}
+	if !reflect.DeepEqual(retailers(3), retailers(3)) {
+		t.Error("the same seed produced different retailer masters")
+	}
+	if reflect.DeepEqual(retailers(3), retailers(4)) {
+		t.Error("different seeds produced the same retailer master")
+	}
+}
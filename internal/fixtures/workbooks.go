@@ -0,0 +1,208 @@
+// Package fixtures writes input workbooks in the exact layouts the Excel
+// repositories read: the DMS exports, the Tally exports and the zonal
+// distributor price list.
+package fixtures
+
+import (
+	"fmt"
+	"viking-reports/internal/repository"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Sales export layouts. DMS sell-out exports use "Dealer Code" style headers
+// while sell-through exports use "toDealerCode" style ones.
+const (
+	SellOutLayout = iota
+	SellThroughLayout
+)
+
+// ProductPrice is one row of ProductPriceList.xlsx.
+type ProductPrice struct {
+	MaterialCode string
+	NLC          float64
+}
+
+// ZDPriceListEntry is one row of the zonal distributor price list as printed
+// by the distributor: several colours may share a row and consecutive rows of
+// the same type and model are merged.
+type ZDPriceListEntry struct {
+	Type    string
+	Model   string
+	Colours string
+	Variant string
+	DLR     int
+	MOP     int
+	MRP     int
+}
+
+// WriteRetailerMetadata writes the retailer master (Retailer Metadata.xlsx).
+func WriteRetailerMetadata(path string, retailers []repository.Retailer) error {
+	rows := [][]interface{}{{"Dealer Code", "Dealer Name", "Tally Name(Dealer Name)", "TSE Name", "Type", "Count of RA"}}
+	for _, r := range retailers {
+		rows = append(rows, []interface{}{r.Code, r.Name, r.TallyName, r.TSE, r.Type, r.CountOfRA})
+	}
+	return writeRows(path, rows)
+}
+
+// WriteBills writes the Tally bills receivable export (Bills.xlsx): eleven
+// title and header rows, one row per pending bill and a closing total row.
+func WriteBills(path string, bills []repository.Bill) error {
+	rows := [][]interface{}{
+		{"Viking's"},
+		{"North Bangalore"},
+		{},
+		{"Bills Receivable"},
+		{},
+		{},
+		{},
+		{},
+		{},
+		{"Date", "Ref. No.", "Party's Name", "Pending Amount", "Due on", "Overdue by days"},
+		{},
+	}
+	total := 0.0
+	for _, b := range bills {
+		rows = append(rows, []interface{}{b.Date, b.RefNo, b.RetailerName, b.PendingAmount, b.DueDate, b.AgeOfBill})
+		total += b.PendingAmount
+	}
+	rows = append(rows, []interface{}{"", "", "Total", total})
+	return writeRows(path, rows)
+}
+
+// WriteReceived writes the amounts received from retailers (Received.xlsx).
+func WriteReceived(path string, receipts []repository.Receipt) error {
+	rows := [][]interface{}{{"Party Name", "Amount"}}
+	for _, r := range receipts {
+		rows = append(rows, []interface{}{r.PartyName, r.Amount})
+	}
+	return writeRows(path, rows)
+}
+
+// WriteSaleEvents writes a DMS sales export (MTD/LMTD SO/ST, L2M-SO) in the
+// given layout.
+func WriteSaleEvents(path string, layout int, events []repository.SaleEvent) error {
+	header := []interface{}{"Dealer Code", "Dealer Name", "Activate Time", "SPU Name", "Product Type"}
+	if layout == SellThroughLayout {
+		header = []interface{}{"toDealerCode", "toDealerName", "activateTime", "SPU Name", "Product Type"}
+	}
+	rows := [][]interface{}{header}
+	for _, e := range events {
+		rows = append(rows, []interface{}{e.DealerCode, e.DealerName, e.ActivateTime, e.SPUName, e.ProductType})
+	}
+	return writeRows(path, rows)
+}
+
+// WriteInventory writes the DMS dealer inventory export (DealerInventory.xlsx),
+// one row per stock unit.
+func WriteInventory(path string, units []repository.InventoryUnit) error {
+	rows := [][]interface{}{{"Area Name", "Dealer Code", "Dealer Name", "Material Code", "SPU Name", "Color", "SKU Spec", "Product Type"}}
+	for _, u := range units {
+		rows = append(rows, []interface{}{u.AreaName, u.DealerCode, u.DealerName, u.MaterialCode, u.SPUName, u.Color, u.SKUSpec, u.ProductType})
+	}
+	return writeRows(path, rows)
+}
+
+// WriteProductPrices writes the net landing cost of each material code
+// (ProductPriceList.xlsx).
+func WriteProductPrices(path string, prices []ProductPrice) error {
+	rows := [][]interface{}{{"Material Code", "NLC"}}
+	for _, p := range prices {
+		rows = append(rows, []interface{}{p.MaterialCode, p.NLC})
+	}
+	return writeRows(path, rows)
+}
+
+// WriteZDPriceList writes the zonal distributor price list (ZD PRICE
+// LIST.xlsx): a title row, the header row, then the price rows with the TYPE
+// and Model cells of consecutive rows merged.
+func WriteZDPriceList(path string, entries []ZDPriceListEntry) error {
+	rows := [][]interface{}{
+		{"ZD PRICE LIST"},
+		{"TYPE", "Model", "COLOURS", "Variant", "DLR PRICE", "MOP", "MRP"},
+	}
+	for i, e := range entries {
+		typ, model := e.Type, e.Model
+		if i > 0 && entries[i-1].Type == e.Type {
+			typ = ""
+		}
+		if i > 0 && entries[i-1].Type == e.Type && entries[i-1].Model == e.Model {
+			model = ""
+		}
+		rows = append(rows, []interface{}{typ, model, e.Colours, e.Variant, e.DLR, e.MOP, e.MRP})
+	}
+
+	f := excelize.NewFile()
+	defer f.Close()
+	sheetName := f.GetSheetName(0)
+	if err := setRows(f, sheetName, rows); err != nil {
+		return err
+	}
+	if err := f.MergeCell(sheetName, "A1", "G1"); err != nil {
+		return err
+	}
+	// Merge runs of identical TYPE (column A) and Model (column B) cells.
+	for col, same := range []func(a, b ZDPriceListEntry) bool{
+		func(a, b ZDPriceListEntry) bool { return a.Type == b.Type },
+		func(a, b ZDPriceListEntry) bool { return a.Type == b.Type && a.Model == b.Model },
+	} {
+		for start := 0; start < len(entries); {
+			end := start
+			for end+1 < len(entries) && same(entries[start], entries[end+1]) {
+				end++
+			}
+			if end > start {
+				top, _ := excelize.CoordinatesToCellName(col+1, start+3)
+				bottom, _ := excelize.CoordinatesToCellName(col+1, end+3)
+				if err := f.MergeCell(sheetName, top, bottom); err != nil {
+					return err
+				}
+			}
+			start = end + 1
+		}
+	}
+	return f.SaveAs(path)
+}
+
+// WriteTallySales writes the Tally monthly sales register (Sales.xlsx) with
+// its header on the tenth row.
+func WriteTallySales(path string, sales []repository.SalesData) error {
+	rows := [][]interface{}{
+		{"Viking's"},
+		{"North Bangalore"},
+		{},
+		{"Sales Register"},
+		{},
+		{},
+		{},
+		{},
+		{},
+		{"Date", "Retailer Code", "Party Name", "Item Name", "Quantity", "Amount "},
+	}
+	for _, s := range sales {
+		rows = append(rows, []interface{}{"", s.DealerCode, s.DealerName, s.ItemName, s.MTDS, s.Value})
+	}
+	return writeRows(path, rows)
+}
+
+func writeRows(path string, rows [][]interface{}) error {
+	f := excelize.NewFile()
+	defer f.Close()
+	if err := setRows(f, f.GetSheetName(0), rows); err != nil {
+		return err
+	}
+	return f.SaveAs(path)
+}
+
+func setRows(f *excelize.File, sheetName string, rows [][]interface{}) error {
+	for i, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow(sheetName, cell, &row); err != nil {
+			return fmt.Errorf("failed to write row %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,146 @@
+// Package freshness checks that the input files of a report are recent
+// enough, by their modification time and, for inputs with dated rows, by
+// the date of their latest transaction, and marks the workbooks generated
+// from stale inputs.
+package freshness
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	"viking-reports/internal/config"
+	"viking-reports/internal/report"
+	"viking-reports/internal/repository"
+	"viking-reports/pkg/excel"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// BannerSheet is the sheet added to workbooks generated from stale inputs.
+const BannerSheet = "STALE DATA"
+
+// Stale is an input that breaks its freshness rule.
+type Stale struct {
+	Input  report.Input
+	Reason string
+}
+
+func (s Stale) String() string {
+	return fmt.Sprintf("%s (%s) %s", s.Input.Name, s.Input.Path, s.Reason)
+}
+
+// StaleInputsError is returned for a report blocked by stale inputs.
+type StaleInputsError struct {
+	Stale []Stale
+}
+
+func (e *StaleInputsError) Error() string {
+	reasons := make([]string, len(e.Stale))
+	for i, s := range e.Stale {
+		reasons[i] = s.String()
+	}
+	return "stale input files: " + strings.Join(reasons, "; ")
+}
+
+// Check returns the inputs breaking their rule in rules at now. The dated
+// rows are read through cache, which the reports then reuse. An input that
+// cannot be read is left to the report reading it.
+func Check(ctx context.Context, rules config.Freshness, cache *repository.Cache, inputs []report.Input, now time.Time, logger *slog.Logger) []Stale {
+	if rules.Action == config.FreshnessOff {
+		return nil
+	}
+	var stale []Stale
+	for _, in := range inputs {
+		rule, ok := rules.Inputs[in.Name]
+		if !ok {
+			continue
+		}
+		if maxAge := rule.MaxAge.Duration; maxAge > 0 {
+			info, err := os.Stat(in.Path)
+			if err != nil {
+				continue
+			}
+			if age := now.Sub(info.ModTime()); age > maxAge {
+				stale = append(stale, Stale{in, fmt.Sprintf("was modified %s ago, more than %s", age.Round(time.Minute), maxAge)})
+				continue
+			}
+		}
+		if maxAge := rule.MaxDataAge.Duration; maxAge > 0 {
+			latest, dated, err := repository.LatestDate(ctx, cache, in.Path, in.Schema, logger)
+			if err != nil || !dated {
+				continue
+			}
+			if age := now.Sub(latest.AddDate(0, 0, 1)); age > maxAge {
+				stale = append(stale, Stale{in, fmt.Sprintf("has no transaction after %s, more than %s ago", latest.Format("02-Jan-2006"), maxAge)})
+			}
+		}
+	}
+	return stale
+}
+
+// Banner returns an excel.BeforeSave hook that adds a STALE DATA sheet
+// listing the stale inputs to every workbook, and opens the workbook on it.
+// The sheets of the report are left as they are, so that programs reading
+// them are not affected.
+func Banner(stale []Stale, checked time.Time) excel.BeforeSave {
+	return func(f *excelize.File, path string) error {
+		if idx, _ := f.GetSheetIndex(BannerSheet); idx >= 0 {
+			if err := f.DeleteSheet(BannerSheet); err != nil {
+				return err
+			}
+		}
+		idx, err := f.NewSheet(BannerSheet)
+		if err != nil {
+			return err
+		}
+		title, err := f.NewStyle(&excelize.Style{
+			Font: &excelize.Font{Bold: true, Size: 20, Color: "FFFFFF"},
+			Fill: excelize.Fill{Type: "pattern", Color: []string{"C00000"}, Pattern: 1},
+		})
+		if err != nil {
+			return err
+		}
+		warning, err := f.NewStyle(&excelize.Style{Font: &excelize.Font{Color: "C00000"}})
+		if err != nil {
+			return err
+		}
+
+		rows := [][]interface{}{
+			{"STALE DATA: this report was generated from out-of-date input files"},
+			{"Checked " + checked.Format("02-Jan-2006 15:04")},
+			{},
+		}
+		for _, s := range stale {
+			rows = append(rows, []interface{}{s.String()})
+		}
+		for i, row := range rows {
+			if len(row) == 0 {
+				continue
+			}
+			cell, _ := excelize.CoordinatesToCellName(1, i+1)
+			if err := f.SetSheetRow(BannerSheet, cell, &row); err != nil {
+				return err
+			}
+		}
+		if err := f.SetCellStyle(BannerSheet, "A1", "L1", title); err != nil {
+			return err
+		}
+		last, _ := excelize.CoordinatesToCellName(1, len(rows))
+		if err := f.SetCellStyle(BannerSheet, "A4", last, warning); err != nil {
+			return err
+		}
+		if err := f.SetSheetProps(BannerSheet, &excelize.SheetPropsOptions{TabColorRGB: strPtr("C00000")}); err != nil {
+			return err
+		}
+		f.SetActiveSheet(idx)
+		return nil
+	}
+}
+
+func strPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,92 @@
+package freshness
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"viking-reports/internal/config"
+	"viking-reports/internal/fixtures"
+	"viking-reports/internal/report"
+	"viking-reports/internal/repository"
+	"viking-reports/pkg/excel"
+)
+
+func TestCheck(t *testing.T) {
+	dir := t.TempDir()
+	y, m, d := time.Now().Date()
+	now := time.Date(y, m, d, 9, 0, 0, 0, time.Local)
+	// The exports hold the transactions up to ten days ago.
+	if err := fixtures.Generate(dir, fixtures.Options{Retailers: 10, TSEs: 2, Seed: 3, Now: now.AddDate(0, 0, -10)}); err != nil {
+		t.Fatal(err)
+	}
+	inputs := []report.Input{
+		{Name: "Bills", Path: filepath.Join(dir, fixtures.BillsFile), Schema: repository.BillsSchema},
+		{Name: "Received", Path: filepath.Join(dir, fixtures.ReceivedFile), Schema: repository.ReceivedSchema},
+		{Name: "MTD-SO", Path: filepath.Join(dir, fixtures.MTDSOFile), Schema: repository.SalesExportSchema},
+		{Name: "Retailer Metadata", Path: filepath.Join(dir, fixtures.RetailerMetadataFile), Schema: repository.RetailerMetadataSchema},
+	}
+	for _, in := range inputs {
+		os.Chtimes(in.Path, now.Add(-time.Hour), now.Add(-time.Hour))
+	}
+	day := config.Duration{Duration: 24 * time.Hour}
+	rules := config.Freshness{Inputs: map[string]config.FreshnessRule{
+		"Bills":    {MaxAge: day, MaxDataAge: config.Duration{Duration: 72 * time.Hour}},
+		"Received": {MaxAge: day},
+		"MTD-SO":   {MaxDataAge: config.Duration{Duration: 30 * 24 * time.Hour}},
+	}}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+	names := func(stale []Stale) []string {
+		var names []string
+		for _, s := range stale {
+			names = append(names, s.Input.Name)
+		}
+		return names
+	}
+
+	cache := repository.NewCache("")
+	if got := names(Check(context.Background(), rules, cache, inputs, now, logger)); !reflect.DeepEqual(got, []string{"Bills"}) {
+		t.Errorf("got stale %v, want the bills by their dates", got)
+	}
+
+	os.Chtimes(inputs[1].Path, now.Add(-50*time.Hour), now.Add(-50*time.Hour))
+	if got := names(Check(context.Background(), rules, cache, inputs, now, logger)); !reflect.DeepEqual(got, []string{"Bills", "Received"}) {
+		t.Errorf("got stale %v, want the receipts by their age too", got)
+	}
+
+	rules.Action = config.FreshnessOff
+	if got := Check(context.Background(), rules, cache, inputs, now, logger); len(got) != 0 {
+		t.Errorf("got stale %v with the check off", got)
+	}
+}
+
+func TestBanner(t *testing.T) {
+	f := excel.NewFile()
+	f.SetCellValue("Sheet1", "A1", "Dealer Code")
+	stale := []Stale{{Input: report.Input{Name: "Bills", Path: "Bills.xlsx"}, Reason: "was modified 72h0m0s ago, more than 24h0m0s"}}
+
+	hook := Banner(stale, time.Now())
+	// Saving twice, as a report updating its workbook would, keeps one banner.
+	for i := 0; i < 2; i++ {
+		if err := hook(f, "report.xlsx"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got := f.GetSheetList(); !reflect.DeepEqual(got, []string{"Sheet1", BannerSheet}) {
+		t.Fatalf("got sheets %v", got)
+	}
+	if f.GetSheetName(f.GetActiveSheetIndex()) != BannerSheet {
+		t.Errorf("the workbook does not open on the banner")
+	}
+	if v, _ := f.GetCellValue("Sheet1", "A1"); v != "Dealer Code" {
+		t.Errorf("report sheet changed: A1 = %q", v)
+	}
+	if v, _ := f.GetCellValue(BannerSheet, "A4"); v != stale[0].String() {
+		t.Errorf("banner lists %q, want %q", v, stale[0].String())
+	}
+}
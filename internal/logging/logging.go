@@ -0,0 +1,113 @@
+// Package logging sets up the structured, leveled logger shared by the report
+// binaries: human-readable records on the console and every record, including
+// debug ones, as JSON in a per-run log file.
+package logging
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Flags holds the console verbosity switches of a binary.
+type Flags struct {
+	Quiet   bool
+	Verbose bool
+}
+
+// RegisterFlags adds -quiet and -verbose (also accepted as --quiet and
+// --verbose) to fs.
+func RegisterFlags(fs *flag.FlagSet) *Flags {
+	f := &Flags{}
+	fs.BoolVar(&f.Quiet, "quiet", false, "only log warnings and errors to the console")
+	fs.BoolVar(&f.Verbose, "verbose", false, "also log debug records to the console")
+	return f
+}
+
+// ConsoleLevel is the lowest level written to the console.
+func (f *Flags) ConsoleLevel() slog.Level {
+	switch {
+	case f.Verbose:
+		return slog.LevelDebug
+	case f.Quiet:
+		return slog.LevelWarn
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// Setup creates the run log file under outputDir/logs and returns a logger
+// writing to it and to stderr, installed as the slog default. close flushes
+// and closes the log file.
+func (f *Flags) Setup(outputDir string) (logger *slog.Logger, close func() error, err error) {
+	if f.Quiet && f.Verbose {
+		return nil, nil, errors.New("-quiet and -verbose are mutually exclusive")
+	}
+	logDir := filepath.Join(outputDir, "logs")
+	if err := os.MkdirAll(logDir, os.ModePerm); err != nil {
+		return nil, nil, fmt.Errorf("error creating log directory: %w", err)
+	}
+	path := filepath.Join(logDir, fmt.Sprintf("run_%s.json", time.Now().Format("2006-01-02_150405")))
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, nil, fmt.Errorf("error creating run log: %w", err)
+	}
+
+	logger = New(os.Stderr, f.ConsoleLevel(), file)
+	slog.SetDefault(logger)
+	logger.Debug("run log created", "file", path)
+	return logger, file.Close, nil
+}
+
+// New returns a logger that writes records at or above consoleLevel as text
+// to console and every record as JSON to runLog.
+func New(console io.Writer, consoleLevel slog.Level, runLog io.Writer) *slog.Logger {
+	return slog.New(fanout{
+		slog.NewTextHandler(console, &slog.HandlerOptions{Level: consoleLevel}),
+		slog.NewJSONHandler(runLog, &slog.HandlerOptions{Level: slog.LevelDebug}),
+	})
+}
+
+// fanout sends each record to every handler that accepts its level.
+type fanout []slog.Handler
+
+func (h fanout) Enabled(ctx context.Context, level slog.Level) bool {
+	for _, handler := range h {
+		if handler.Enabled(ctx, level) {
+			return true
+		}
+	}
+	return false
+}
+
+func (h fanout) Handle(ctx context.Context, record slog.Record) error {
+	var errs []error
+	for _, handler := range h {
+		if handler.Enabled(ctx, record.Level) {
+			errs = append(errs, handler.Handle(ctx, record.Clone()))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (h fanout) WithAttrs(attrs []slog.Attr) slog.Handler {
+	handlers := make(fanout, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithAttrs(attrs)
+	}
+	return handlers
+}
+
+func (h fanout) WithGroup(name string) slog.Handler {
+	handlers := make(fanout, len(h))
+	for i, handler := range h {
+		handlers[i] = handler.WithGroup(name)
+	}
+	return handlers
+}
@@ -0,0 +1,50 @@
+package logging
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewSplitsByLevel(t *testing.T) {
+	var console, runLog bytes.Buffer
+	logger := New(&console, slog.LevelWarn, &runLog).With("report", "growth")
+	logger.Debug("reading", "file", "MTD-SO.xlsx")
+	logger.Warn("no credit reports found")
+
+	if strings.Contains(console.String(), "reading") {
+		t.Errorf("debug record written to a quiet console:\n%s", console.String())
+	}
+	if !strings.Contains(console.String(), "no credit reports found") {
+		t.Errorf("warning missing from console:\n%s", console.String())
+	}
+
+	lines := strings.Split(strings.TrimSpace(runLog.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("run log has %d records, want 2:\n%s", len(lines), runLog.String())
+	}
+	var record map[string]interface{}
+	if err := json.Unmarshal([]byte(lines[0]), &record); err != nil {
+		t.Fatalf("run log record is not JSON: %v", err)
+	}
+	if record["level"] != "DEBUG" || record["report"] != "growth" || record["file"] != "MTD-SO.xlsx" {
+		t.Errorf("unexpected run log record %v", record)
+	}
+}
+
+func TestConsoleLevel(t *testing.T) {
+	for _, tc := range []struct {
+		flags Flags
+		want  slog.Level
+	}{
+		{Flags{}, slog.LevelInfo},
+		{Flags{Quiet: true}, slog.LevelWarn},
+		{Flags{Verbose: true}, slog.LevelDebug},
+	} {
+		if got := tc.flags.ConsoleLevel(); got != tc.want {
+			t.Errorf("%+v: got %v, want %v", tc.flags, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,310 @@
+// Package manifest records the lineage of generated reports: the inputs they
+// were generated from, with their SHA-256 and modification time, the
+// configuration and tool version used, how long each report took and the
+// workbooks it wrote. Each dated output folder gets a manifest.json, and each
+// workbook a hidden "Sources" sheet with the same lineage.
+package manifest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"sort"
+	"sync"
+	"time"
+	"viking-reports/internal/config"
+	"viking-reports/internal/report"
+	"viking-reports/internal/repository"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// FileName is the name of the manifest written to each output folder.
+const FileName = "manifest.json"
+
+// SourcesSheet is the hidden sheet added to every workbook.
+const SourcesSheet = "Sources"
+
+// Version is the tool version recorded in manifests. Release builds set it
+// with -ldflags "-X viking-reports/internal/manifest.Version=v1.2.3";
+// otherwise the VCS revision of the build is used when known.
+var Version = "dev"
+
+// ToolVersion returns Version, followed by the VCS revision when the binary
+// was built from a git checkout.
+func ToolVersion() string {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return Version
+	}
+	var revision, modified string
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			revision = s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				modified = "+dirty"
+			}
+		}
+	}
+	if revision == "" {
+		return Version
+	}
+	if len(revision) > 12 {
+		revision = revision[:12]
+	}
+	return fmt.Sprintf("%s (%s%s)", Version, revision, modified)
+}
+
+// Input is the fingerprint of one input file.
+type Input struct {
+	Name     string    `json:"name"`
+	Path     string    `json:"path"`
+	SHA256   string    `json:"sha256"`
+	Size     int64     `json:"size"`
+	Modified time.Time `json:"modified"`
+	Rows     int       `json:"rows"`
+}
+
+// Fingerprint hashes the input file and counts its data rows, opening it
+// with its password in ctx if protected. If one of known is the fingerprint
+// of the same file with the same size and modification time, it is returned
+// instead of reading the file again.
+func Fingerprint(ctx context.Context, in report.Input, known ...Input) (Input, error) {
+	fp := Input{Name: in.Name, Path: in.Path}
+	file, err := os.Open(in.Path)
+	if err != nil {
+		return fp, err
+	}
+	defer file.Close()
+	info, err := file.Stat()
+	if err != nil {
+		return fp, err
+	}
+	fp.Size = info.Size()
+	fp.Modified = info.ModTime()
+	for _, k := range known {
+		if k.Path == fp.Path && k.Size == fp.Size && k.Modified.Equal(fp.Modified) && k.SHA256 != "" {
+			k.Name = in.Name
+			return k, nil
+		}
+	}
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return fp, fmt.Errorf("failed to hash %s: %w", in.Path, err)
+	}
+	fp.SHA256 = hex.EncodeToString(h.Sum(nil))
+
+	f, err := repository.OpenWorkbook(ctx, in.Path)
+	if errors.Is(err, repository.ErrPasswordRequired) || errors.Is(err, repository.ErrWrongPassword) {
+		return fp, fmt.Errorf("failed to open %s: %w; set its password in %s or the secrets file", in.Path, err, config.PasswordEnv(in.Name))
+	}
+	if err != nil {
+		return fp, fmt.Errorf("failed to open %s: %w", in.Path, err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows(f.GetSheetName(0))
+	if err != nil {
+		return fp, fmt.Errorf("failed to get rows of %s: %w", in.Path, err)
+	}
+	fp.Rows = len(in.Schema.DataRows(rows))
+	return fp, nil
+}
+
+// Report is the lineage of one run of a report.
+type Report struct {
+	Started  time.Time       `json:"started"`
+	Duration config.Duration `json:"duration"`
+	Status   report.Status   `json:"status"`
+	Error    string          `json:"error,omitempty"`
+	Inputs   []Input         `json:"inputs"`
+	Outputs  []string        `json:"outputs"`
+}
+
+// Manifest is the content of manifest.json: the latest run of each report
+// that wrote to the folder.
+type Manifest struct {
+	ToolVersion string             `json:"tool_version"`
+	Config      *config.Config     `json:"config"`
+	Reports     map[string]*Report `json:"reports"`
+}
+
+// Recorder collects the lineage of one report while it runs. Its Stamp
+// method is an excel.BeforeSave hook.
+type Recorder struct {
+	report  string
+	cfg     *config.Config
+	started time.Time
+
+	mu     sync.Mutex
+	inputs []Input
+	out    []string
+}
+
+// NewRecorder fingerprints the inputs of the report, reusing the known
+// fingerprints of unchanged files. It is called once the inputs are known to
+// exist.
+func NewRecorder(ctx context.Context, cfg *config.Config, def report.Definition, started time.Time, known ...Input) (*Recorder, error) {
+	r := &Recorder{report: def.Name, cfg: cfg, started: started}
+	if def.Inputs == nil {
+		return r, nil
+	}
+	for _, in := range def.Inputs(cfg) {
+		fp, err := Fingerprint(ctx, in, known...)
+		if err != nil {
+			return nil, err
+		}
+		r.inputs = append(r.inputs, fp)
+	}
+	return r, nil
+}
+
+// Stamp adds the hidden Sources sheet to a workbook about to be saved to path
+// and records path as an output of the report.
+func (r *Recorder) Stamp(f *excelize.File, path string) error {
+	if err := r.writeSources(f); err != nil {
+		return err
+	}
+	r.Record(path)
+	return nil
+}
+
+// Record records path as an output of the report. It is an output.Written
+// hook, for the files of every format.
+func (r *Recorder) Record(path string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, p := range r.out {
+		if p == path {
+			return
+		}
+	}
+	r.out = append(r.out, path)
+}
+
+func (r *Recorder) writeSources(f *excelize.File) error {
+	if idx, _ := f.GetSheetIndex(SourcesSheet); idx >= 0 {
+		if err := f.DeleteSheet(SourcesSheet); err != nil {
+			return err
+		}
+	}
+	if _, err := f.NewSheet(SourcesSheet); err != nil {
+		return err
+	}
+	rows := [][]interface{}{
+		{"Report", r.report},
+		{"Generated", r.started.Format(time.RFC3339)},
+		{"Tool Version", ToolVersion()},
+		{},
+		{"Input", "Path", "SHA-256", "Modified", "Rows"},
+	}
+	for _, in := range r.inputs {
+		rows = append(rows, []interface{}{in.Name, in.Path, in.SHA256, in.Modified.Format(time.RFC3339), in.Rows})
+	}
+	for i, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		if err := f.SetSheetRow(SourcesSheet, cell, &row); err != nil {
+			return err
+		}
+	}
+	return f.SetSheetVisible(SourcesSheet, false)
+}
+
+// Inputs returns the fingerprints of the report's inputs.
+func (r *Recorder) Inputs() []Input {
+	return r.inputs
+}
+
+// Outputs returns the files recorded so far.
+func (r *Recorder) Outputs() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]string(nil), r.out...)
+}
+
+// Write records the finished run, with its outcome err, in the manifest of
+// every folder the report wrote to. Entries of other reports in those
+// manifests are kept.
+func (r *Recorder) Write(finished time.Time, err error) error {
+	entry := &Report{
+		Started:  r.started,
+		Duration: config.Duration{Duration: finished.Sub(r.started).Round(time.Millisecond)},
+		Status:   report.Succeeded,
+		Inputs:   r.inputs,
+	}
+	if err != nil {
+		entry.Status = report.Failed
+		entry.Error = err.Error()
+	}
+
+	byDir := make(map[string][]string)
+	for _, path := range r.Outputs() {
+		dir := filepath.Dir(path)
+		byDir[dir] = append(byDir[dir], filepath.Base(path))
+	}
+	dirs := make([]string, 0, len(byDir))
+	for dir := range byDir {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		e := *entry
+		e.Outputs = byDir[dir]
+		sort.Strings(e.Outputs)
+		if err := update(filepath.Join(dir, FileName), r.cfg, r.report, &e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// update sets the entry of the report in the manifest at path.
+func update(path string, cfg *config.Config, name string, entry *Report) error {
+	m, err := Read(path)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if m == nil {
+		m = &Manifest{}
+	}
+	if m.Reports == nil {
+		m.Reports = make(map[string]*Report)
+	}
+	m.ToolVersion = ToolVersion()
+	m.Config = cfg
+	m.Reports[name] = entry
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// Read reads the manifest at path.
+func Read(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var m Manifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	return &m, nil
+}
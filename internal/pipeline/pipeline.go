@@ -0,0 +1,443 @@
+// Package pipeline runs registered reports in dependency order, after
+// checking that their inputs exist.
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"viking-reports/internal/config"
+	"viking-reports/internal/freshness"
+	"viking-reports/internal/manifest"
+	"viking-reports/internal/report"
+	"viking-reports/internal/repository"
+	"viking-reports/pkg/excel"
+	"viking-reports/pkg/output"
+)
+
+// MissingInputsError lists the inputs that do not exist, per report.
+type MissingInputsError struct {
+	Reports []string
+	Missing map[string][]report.Input
+}
+
+func (e *MissingInputsError) Error() string {
+	var parts []string
+	for _, name := range e.Reports {
+		var paths []string
+		for _, in := range e.Missing[name] {
+			paths = append(paths, in.Path)
+		}
+		parts = append(parts, fmt.Sprintf("%s needs %s", name, strings.Join(paths, ", ")))
+	}
+	return "missing input files: " + strings.Join(parts, "; ")
+}
+
+// CheckInputs returns a *MissingInputsError if any report of plan lacks an
+// input file.
+func CheckInputs(cfg *config.Config, plan []report.Definition) error {
+	missing := &MissingInputsError{Missing: make(map[string][]report.Input)}
+	for _, def := range plan {
+		if inputs := def.MissingInputs(cfg); len(inputs) > 0 {
+			missing.Reports = append(missing.Reports, def.Name)
+			missing.Missing[def.Name] = inputs
+		}
+	}
+	if len(missing.Reports) > 0 {
+		return missing
+	}
+	return nil
+}
+
+// WithPasswords returns a context under which the protected inputs of plan
+// are opened with their passwords, from the environment or the secrets file
+// of cfg.
+func WithPasswords(ctx context.Context, cfg *config.Config, plan []report.Definition) (context.Context, error) {
+	secrets, err := config.LoadSecrets(cfg)
+	if err != nil {
+		return nil, err
+	}
+	return withPasswords(ctx, cfg, secrets, plan), nil
+}
+
+func withPasswords(ctx context.Context, cfg *config.Config, secrets *config.Secrets, plan []report.Definition) context.Context {
+	var fallbacks []string
+	for _, pw := range secrets.AllOutputPasswords() {
+		fallbacks = append(fallbacks, pw.Reveal())
+	}
+	if len(fallbacks) > 0 {
+		ctx = repository.WithFallbackPasswords(ctx, fallbacks)
+	}
+
+	passwords := make(map[string]string)
+	for _, def := range plan {
+		if def.Inputs == nil {
+			continue
+		}
+		for _, in := range def.Inputs(cfg) {
+			if pw, ok := secrets.Password(in.Name); ok {
+				passwords[in.Path] = pw.Reveal()
+			}
+		}
+	}
+	if len(passwords) == 0 {
+		return ctx
+	}
+	return repository.WithPasswords(ctx, passwords)
+}
+
+// protect returns how the workbooks of the report are protected, or nil if
+// they are not.
+func protect(cfg *config.Config, secrets *config.Secrets, def report.Definition) excel.Protect {
+	p := cfg.Protection
+	if !protected(p, def.Name) {
+		return nil
+	}
+	return func(recipient string) (*excel.Protection, error) {
+		protection := &excel.Protection{LockSheets: p.LockSheets, SheetPassword: secrets.SheetPassword.Reveal(), Editable: p.Editable}
+		if p.Encrypt {
+			pw, ok := secrets.OutputPassword(recipient)
+			if !ok || pw == "" {
+				return nil, fmt.Errorf("no password for %q; set it in the secrets file or %s", recipient, config.OutputPasswordEnv(recipient))
+			}
+			protection.Password = pw.Reveal()
+		}
+		return protection, nil
+	}
+}
+
+// protected reports whether p protects the workbooks of the named report.
+func protected(p config.Protection, name string) bool {
+	return p.Protects(name) && (p.Encrypt || p.LockSheets)
+}
+
+// StateDir is the directory, under the output directory, where the viking
+// command keeps the state of incremental runs.
+const StateDir = ".viking"
+
+// cacheMaxAge is how long an incremental run keeps parsed records that no
+// run has used.
+const cacheMaxAge = 14 * 24 * time.Hour
+
+type runOptions struct {
+	incrementalDir string
+	formats        []string
+}
+
+// Option customises a Run.
+type Option func(*runOptions)
+
+// Incremental makes Run regenerate only the reports whose inputs,
+// configuration or dependencies changed since they last succeeded on the
+// same day. The state of the earlier runs and the records parsed from their
+// inputs are kept in dir.
+func Incremental(dir string) Option {
+	return func(o *runOptions) {
+		o.incrementalDir = dir
+	}
+}
+
+// Formats makes Run write the reports in formats, names of output.Writers,
+// instead of xlsx alone.
+func Formats(formats ...string) Option {
+	return func(o *runOptions) {
+		o.formats = formats
+	}
+}
+
+// CheckFormats returns an error if a report of plan reads back the workbooks
+// of another report of plan that formats leave unwritten, or if a report
+// whose workbooks cfg protects would also be written in a format that cannot
+// be protected, which would leave its figures readable by anyone.
+func CheckFormats(cfg *config.Config, plan []report.Definition, formats []string) error {
+	for _, format := range formats {
+		if format == output.XLSXFormat {
+			continue
+		}
+		for _, def := range plan {
+			if protected(cfg.Protection, def.Name) {
+				return fmt.Errorf("%s is protected, which %s files cannot be: write it as xlsx only", def.Name, format)
+			}
+		}
+	}
+	if len(formats) == 0 || slices.Contains(formats, output.XLSXFormat) {
+		return nil
+	}
+	planned := make(map[string]bool, len(plan))
+	for _, def := range plan {
+		planned[def.Name] = true
+	}
+	for _, def := range plan {
+		for _, dep := range def.DependsOn {
+			if planned[dep] {
+				return fmt.Errorf("%s reads the workbooks of %s, which are not written without xlsx in the output formats", def.Name, dep)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckProtection returns an error if cfg encrypts the workbooks of a report
+// that is not written per TSE, since Protection.Encrypt opens each workbook
+// with its TSE's password and such a report has no TSE to take it from.
+func CheckProtection(cfg *config.Config) error {
+	if !cfg.Protection.Encrypt {
+		return nil
+	}
+	for _, name := range cfg.Protection.Reports {
+		if def, ok := report.Lookup(name); ok && !def.PerTSE {
+			return fmt.Errorf("%s is not written per TSE, so Protection.Encrypt has no TSE password to open its workbooks with: remove it from Protection.Reports or turn Encrypt off", name)
+		}
+	}
+	return nil
+}
+
+// Run checks the inputs of every report of plan and, if none is missing,
+// generates the reports in order, recording their outcome in summary. A
+// report whose dependency failed is skipped; the others still run. Plan
+// comes from report.Plan.
+func Run(ctx context.Context, cfg *config.Config, logger *slog.Logger, summary *report.Summary, plan []report.Definition, opts ...Option) error {
+	var o runOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if err := CheckInputs(cfg, plan); err != nil {
+		return err
+	}
+	if err := CheckProtection(cfg); err != nil {
+		return err
+	}
+	if err := CheckFormats(cfg, plan, o.formats); err != nil {
+		return err
+	}
+	if len(o.formats) > 0 {
+		ctx = output.WithFormats(ctx, o.formats)
+	}
+	secrets, err := config.LoadSecrets(cfg)
+	if err != nil {
+		return err
+	}
+	ctx = withPasswords(ctx, cfg, secrets, plan)
+	switch cfg.Freshness.Action {
+	case "", config.FreshnessBanner, config.FreshnessBlock, config.FreshnessOff:
+	default:
+		return fmt.Errorf("unknown freshness action %q, want %q, %q or %q", cfg.Freshness.Action,
+			config.FreshnessBanner, config.FreshnessBlock, config.FreshnessOff)
+	}
+
+	var (
+		state  *State
+		cfgSum string
+		cache  = repository.NewCache("")
+	)
+	if o.incrementalDir != "" {
+		var err error
+		if state, err = LoadState(o.incrementalDir); err != nil {
+			return err
+		}
+		if cfgSum, err = configSum(cfg, o.formats); err != nil {
+			return err
+		}
+		cache = repository.NewCache(filepath.Join(o.incrementalDir, "cache"))
+	}
+	extraOpts := []report.Option{report.WithCache(cache)}
+	Preload(ctx, cfg, logger, cache, plan)
+
+	failed := make(map[string]bool)
+	regenerated := make(map[string]bool)
+	var errs []error
+	for _, def := range plan {
+		if err := ctx.Err(); err != nil {
+			summary.Skip(def.Name, def.Name, err.Error())
+			continue
+		}
+		if dep := failedDependency(def, failed); dep != "" {
+			logger.Warn("skipping report, a report it depends on failed", "report", def.Name, "dependency", dep)
+			summary.Skip(def.Name, def.Name, "dependency "+dep+" failed")
+			failed[def.Name] = true
+			continue
+		}
+
+		reportCtx := ctx
+		if protect := protect(cfg, secrets, def); protect != nil {
+			reportCtx = excel.WithProtection(reportCtx, protect)
+		}
+		if def.Inputs != nil {
+			if stale := freshness.Check(ctx, cfg.Freshness, cache, def.Inputs(cfg), time.Now(), logger); len(stale) > 0 {
+				err := &freshness.StaleInputsError{Stale: stale}
+				if cfg.Freshness.Action == config.FreshnessBlock {
+					logger.Error("not generating report from stale inputs", "report", def.Name, "err", err)
+					summary.Record(def.Name, def.Name, "", err)
+					failed[def.Name] = true
+					errs = append(errs, fmt.Errorf("%s: %w", def.Name, err))
+					continue
+				}
+				logger.Warn("generating report from stale inputs, marking its results STALE DATA", "report", def.Name, "err", err)
+				reportCtx = excel.WithBeforeSave(reportCtx, freshness.Banner(stale, time.Now()))
+				reasons := make([]string, len(stale))
+				for i, s := range stale {
+					reasons[i] = s.String()
+				}
+				reportCtx = output.WithStale(reportCtx, reasons)
+			}
+		}
+
+		var known []manifest.Input
+		if state != nil {
+			known = state.known()
+		}
+		started := time.Now()
+		recorder, err := manifest.NewRecorder(ctx, cfg, def, started, known...)
+		if err != nil {
+			err = fmt.Errorf("failed to fingerprint inputs: %w", err)
+			summary.Record(def.Name, def.Name, "", err)
+			failed[def.Name] = true
+			errs = append(errs, fmt.Errorf("%s: %w", def.Name, err))
+			continue
+		}
+		day := started.Format("2006-01-02")
+		if state != nil {
+			reason := state.changed(def, cfgSum, day, recorder.Inputs(), regenerated)
+			if reason == "" {
+				logger.Info("report is up to date, not regenerating", "report", def.Name)
+				summary.Unchanged(def.Name)
+				continue
+			}
+			logger.Info("regenerating report", "report", def.Name, "reason", reason)
+		}
+
+		regenerated[def.Name] = true
+		err = generate(reportCtx, cfg, logger, summary, def, recorder, extraOpts...)
+		if err != nil {
+			failed[def.Name] = true
+			errs = append(errs, fmt.Errorf("%s: %w", def.Name, err))
+		}
+		if state != nil {
+			delete(state.Reports, def.Name)
+			if err == nil {
+				state.Reports[def.Name] = &ReportState{Date: day, Config: cfgSum, Inputs: recorder.Inputs(), Outputs: recorder.Outputs()}
+			}
+			if err := state.Save(); err != nil {
+				logger.Error("failed to save incremental state", "err", err)
+			}
+		}
+	}
+	if err := cache.Prune(cacheMaxAge); err != nil {
+		logger.Warn("failed to prune the cache of parsed inputs", "err", err)
+	}
+	return errors.Join(errs...)
+}
+
+// Preload parses every distinct input file of plan into cache, cfg.Workers
+// files at a time, so that the reports then share the parsed records instead
+// of each reading its files in turn. A file that fails to load is left to the
+// reports reading it, which then report the error.
+func Preload(ctx context.Context, cfg *config.Config, logger *slog.Logger, cache *repository.Cache, plan []report.Definition) {
+	var inputs []report.Input
+	seen := make(map[string]bool)
+	for _, def := range plan {
+		if def.Inputs == nil {
+			continue
+		}
+		for _, in := range def.Inputs(cfg) {
+			if !seen[in.Path] {
+				seen[in.Path] = true
+				inputs = append(inputs, in)
+			}
+		}
+	}
+	workers := cfg.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(inputs) {
+		workers = len(inputs)
+	}
+
+	started := time.Now()
+	queue := make(chan report.Input)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for in := range queue {
+				if err := repository.Warm(ctx, cache, in.Path, in.Schema, logger); err != nil {
+					logger.Debug("failed to preload input", "input", in.Name, "file", in.Path, "err", err)
+				}
+			}
+		}()
+	}
+	for _, in := range inputs {
+		if ctx.Err() != nil {
+			break
+		}
+		queue <- in
+	}
+	close(queue)
+	wg.Wait()
+	logger.Info("loaded input files", "files", len(inputs), "workers", workers, "duration", time.Since(started).Round(time.Millisecond))
+}
+
+func failedDependency(def report.Definition, failed map[string]bool) string {
+	for _, dep := range def.DependsOn {
+		if failed[dep] {
+			return dep
+		}
+	}
+	return ""
+}
+
+// generate runs one report under its configured timeout and records its
+// outcome in summary. Reports that do not record their own units are
+// recorded as a single unit. Every workbook the report saves gets a hidden
+// Sources sheet, and the folders it wrote to, in any format, a manifest of
+// the run.
+func generate(ctx context.Context, cfg *config.Config, logger *slog.Logger, summary *report.Summary, def report.Definition, recorder *manifest.Recorder, opts ...report.Option) (err error) {
+	opts = append([]report.Option{report.WithLogger(logger), report.WithSummary(summary)}, opts...)
+	generator := def.New(cfg, opts...)
+	before := len(summary.Results())
+	defer func() {
+		if len(summary.Results()) > before {
+			return
+		}
+		if errors.Is(err, context.Canceled) {
+			summary.Skip(def.Name, def.Name, err.Error())
+			return
+		}
+		summary.Record(def.Name, def.Name, "", err)
+	}()
+
+	ctx = excel.WithBeforeSave(ctx, recorder.Stamp)
+	ctx = output.WithWritten(ctx, recorder.Record)
+	defer func() {
+		if merr := recorder.Write(time.Now(), err); merr != nil {
+			logger.Error("failed to write manifest", "report", def.Name, "err", merr)
+			err = errors.Join(err, fmt.Errorf("failed to write manifest: %w", merr))
+		}
+	}()
+
+	timeout := cfg.Timeouts.For(def.Name)
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	err = generator.Generate(ctx)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("timed out after %s: %w", timeout, err)
+	case errors.Is(err, context.Canceled):
+		return fmt.Errorf("interrupted: %w", err)
+	}
+	return err
+}
@@ -0,0 +1,259 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"viking-reports/internal/config"
+	"viking-reports/internal/fixtures"
+	"viking-reports/internal/manifest"
+	"viking-reports/internal/report"
+	"viking-reports/internal/repository"
+	"viking-reports/pkg/excel"
+
+	"github.com/xuri/excelize/v2"
+)
+
+type fakeGenerator struct {
+	err  error
+	runs *[]string
+	name string
+}
+
+func (g fakeGenerator) Generate(context.Context) error {
+	*g.runs = append(*g.runs, g.name)
+	return g.err
+}
+
+func fake(name string, err error, runs *[]string, inputs []report.Input, dependsOn ...string) report.Definition {
+	return report.Definition{
+		Name:      name,
+		DependsOn: dependsOn,
+		Inputs:    func(*config.Config) []report.Input { return inputs },
+		New: func(*config.Config, ...report.Option) report.ReportGenerator {
+			return fakeGenerator{err: err, runs: runs, name: name}
+		},
+	}
+}
+
+var discard = slog.New(slog.NewTextHandler(io.Discard, nil))
+
+func TestRunSkipsDependentsOfFailedReport(t *testing.T) {
+	var runs []string
+	plan := []report.Definition{
+		fake("base", errors.New("bills sheet missing"), &runs, nil),
+		fake("dependent", nil, &runs, nil, "base"),
+		fake("other", nil, &runs, nil),
+	}
+	summary := &report.Summary{}
+	err := Run(context.Background(), &config.Config{}, discard, summary, plan)
+	if err == nil {
+		t.Fatal("expected the failure of base to be returned")
+	}
+	if len(runs) != 2 || runs[0] != "base" || runs[1] != "other" {
+		t.Errorf("ran %v, want [base other]", runs)
+	}
+	if summary.Count("dependent", report.Skipped) != 1 || summary.Count("base", report.Failed) != 1 || summary.Count("other", report.Succeeded) != 1 {
+		t.Errorf("unexpected summary %+v", summary.Results())
+	}
+}
+
+func TestRunChecksInputsFirst(t *testing.T) {
+	var runs []string
+	missing := report.Input{Name: "Bills", Path: filepath.Join(t.TempDir(), "Bills.xlsx")}
+	plan := []report.Definition{
+		fake("first", nil, &runs, nil),
+		fake("second", nil, &runs, []report.Input{missing}),
+	}
+	err := Run(context.Background(), &config.Config{}, discard, &report.Summary{}, plan)
+
+	var missingErr *MissingInputsError
+	if !errors.As(err, &missingErr) || len(missingErr.Missing["second"]) != 1 {
+		t.Fatalf("got %v, want a MissingInputsError for second", err)
+	}
+	if len(runs) != 0 {
+		t.Errorf("reports ran despite a missing input: %v", runs)
+	}
+}
+
+func TestRunChecksFormats(t *testing.T) {
+	var runs []string
+	plan := []report.Definition{
+		fake("credit", nil, &runs, nil),
+		fake("cogs", nil, &runs, nil, "credit"),
+	}
+	err := Run(context.Background(), &config.Config{}, discard, &report.Summary{}, plan, Formats("csv"))
+	if err == nil || len(runs) != 0 {
+		t.Fatalf("got %v after running %v, want an error before running cogs without the credit workbooks", err, runs)
+	}
+	cfg := &config.Config{}
+	if err := CheckFormats(cfg, plan[1:], []string{"csv"}); err != nil {
+		t.Errorf("cogs alone reads earlier workbooks, got %v", err)
+	}
+	if err := CheckFormats(cfg, plan, []string{"csv", "xlsx"}); err != nil {
+		t.Errorf("got %v with xlsx written", err)
+	}
+
+	// The dues of protected workbooks must not be written in plain text too.
+	cfg.Protection = config.Protection{Reports: []string{"credit"}, Encrypt: true}
+	if err := CheckFormats(cfg, plan, []string{"xlsx", "json"}); err == nil || !strings.Contains(err.Error(), "credit is protected") {
+		t.Errorf("got %v, want protected credit refused as json", err)
+	}
+	if err := CheckFormats(cfg, plan, []string{"xlsx"}); err != nil {
+		t.Errorf("got %v with xlsx alone", err)
+	}
+}
+
+func TestCheckProtection(t *testing.T) {
+	for _, tc := range []struct {
+		protection config.Protection
+		refused    string
+	}{
+		{config.Protection{Reports: []string{"credit", "growth", "digest"}, Encrypt: true}, ""},
+		{config.Protection{Reports: []string{"credit", "pack"}, Encrypt: true}, "pack"},
+		{config.Protection{Reports: []string{"cogs"}, Encrypt: true}, "cogs"},
+		// Locked sheets need no TSE password.
+		{config.Protection{Reports: []string{"credit", "pack"}, LockSheets: true}, ""},
+	} {
+		err := CheckProtection(&config.Config{Protection: tc.protection})
+		switch {
+		case tc.refused == "" && err != nil:
+			t.Errorf("%+v: got %v", tc.protection, err)
+		case tc.refused != "" && (err == nil || !strings.HasPrefix(err.Error(), tc.refused+" is not written per TSE")):
+			t.Errorf("%+v: got %v, want %s refused", tc.protection, err, tc.refused)
+		}
+	}
+}
+
+type workbookGenerator struct {
+	path string
+	runs *[]string
+}
+
+func (g workbookGenerator) Generate(ctx context.Context) error {
+	if g.runs != nil {
+		*g.runs = append(*g.runs, strings.TrimSuffix(filepath.Base(g.path), ".xlsx"))
+	}
+	f := excel.NewFile()
+	f.SetCellValue("Sheet1", "A1", "Dealer Code")
+	return excel.Save(ctx, f, g.path)
+}
+
+func TestRunWritesManifest(t *testing.T) {
+	dir := t.TempDir()
+	input := report.Input{Name: "Received", Path: filepath.Join(dir, "Received.xlsx"), Schema: repository.ReceivedSchema}
+	if err := fixtures.WriteReceived(input.Path, []repository.Receipt{{PartyName: "One", Amount: 10}, {PartyName: "Two", Amount: 20}}); err != nil {
+		t.Fatal(err)
+	}
+	outDir := filepath.Join(dir, "out_2024-01-31")
+	if err := os.Mkdir(outDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	output := filepath.Join(outDir, "report.xlsx")
+	def := report.Definition{
+		Name:   "lineage",
+		Inputs: func(*config.Config) []report.Input { return []report.Input{input} },
+		New: func(*config.Config, ...report.Option) report.ReportGenerator {
+			return workbookGenerator{path: output}
+		},
+	}
+
+	if err := Run(context.Background(), &config.Config{}, discard, &report.Summary{}, []report.Definition{def}); err != nil {
+		t.Fatal(err)
+	}
+
+	m, err := manifest.Read(filepath.Join(outDir, manifest.FileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	entry := m.Reports["lineage"]
+	if entry == nil || entry.Status != report.Succeeded || len(entry.Outputs) != 1 || entry.Outputs[0] != "report.xlsx" {
+		t.Fatalf("unexpected manifest entry %+v", entry)
+	}
+	if len(entry.Inputs) != 1 || entry.Inputs[0].Rows != 2 || len(entry.Inputs[0].SHA256) != 64 {
+		t.Errorf("unexpected input fingerprint %+v", entry.Inputs)
+	}
+
+	f, err := excelize.OpenFile(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if visible, err := f.GetSheetVisible(manifest.SourcesSheet); err != nil || visible {
+		t.Errorf("Sources sheet visible=%v err=%v, want hidden", visible, err)
+	}
+	if sha, _ := f.GetCellValue(manifest.SourcesSheet, "C6"); sha != entry.Inputs[0].SHA256 {
+		t.Errorf("Sources sheet SHA-256 = %q, want %q", sha, entry.Inputs[0].SHA256)
+	}
+	if first := f.GetSheetName(0); first != "Sheet1" {
+		t.Errorf("first sheet = %q, the report's own sheet must stay first", first)
+	}
+}
+
+func TestIncrementalRunRegeneratesChangedReports(t *testing.T) {
+	dir := t.TempDir()
+	input := report.Input{Name: "Received", Path: filepath.Join(dir, "Received.xlsx"), Schema: repository.ReceivedSchema}
+	other := report.Input{Name: "Prices", Path: filepath.Join(dir, "Prices.xlsx"), Schema: repository.ProductPriceSchema}
+	if err := fixtures.WriteReceived(input.Path, []repository.Receipt{{PartyName: "One", Amount: 10}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := fixtures.WriteProductPrices(other.Path, []fixtures.ProductPrice{{MaterialCode: "M1", NLC: 100}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var runs []string
+	def := func(name string, in report.Input, dependsOn ...string) report.Definition {
+		return report.Definition{
+			Name:      name,
+			DependsOn: dependsOn,
+			Inputs:    func(*config.Config) []report.Input { return []report.Input{in} },
+			New: func(*config.Config, ...report.Option) report.ReportGenerator {
+				return workbookGenerator{path: filepath.Join(dir, name+".xlsx"), runs: &runs}
+			},
+		}
+	}
+	plan := []report.Definition{
+		def("receipts", input),
+		def("dependent", other, "receipts"),
+		def("prices", other),
+	}
+	run := func() []string {
+		t.Helper()
+		runs = nil
+		err := Run(context.Background(), &config.Config{}, discard, &report.Summary{}, plan, Incremental(filepath.Join(dir, StateDir)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return runs
+	}
+
+	if got := run(); len(got) != 3 {
+		t.Fatalf("first run generated %v, want every report", got)
+	}
+	if got := run(); len(got) != 0 {
+		t.Fatalf("second run generated %v, want nothing", got)
+	}
+	if err := fixtures.WriteReceived(input.Path, []repository.Receipt{{PartyName: "One", Amount: 25}}); err != nil {
+		t.Fatal(err)
+	}
+	later := time.Now().Add(time.Minute)
+	if err := os.Chtimes(input.Path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if got := run(); len(got) != 2 || got[0] != "receipts" || got[1] != "dependent" {
+		t.Fatalf("after changing Received generated %v, want [receipts dependent]", got)
+	}
+	if err := os.Remove(filepath.Join(dir, "prices.xlsx")); err != nil {
+		t.Fatal(err)
+	}
+	if got := run(); len(got) != 1 || got[0] != "prices" {
+		t.Fatalf("after deleting an output generated %v, want [prices]", got)
+	}
+}
@@ -0,0 +1,130 @@
+package pipeline
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"viking-reports/internal/config"
+	"viking-reports/internal/manifest"
+	"viking-reports/internal/report"
+	"viking-reports/pkg/output"
+)
+
+// State records what each report was last generated from, so that an
+// incremental run regenerates only the reports whose inputs changed. It is
+// kept in state.json of the incremental directory.
+type State struct {
+	path    string
+	Reports map[string]*ReportState `json:"reports"`
+}
+
+// ReportState is the last successful run of a report.
+type ReportState struct {
+	Date    string           `json:"date"`   // YYYY-MM-DD of the run
+	Config  string           `json:"config"` // SHA-256 of the configuration
+	Inputs  []manifest.Input `json:"inputs"`
+	Outputs []string         `json:"outputs"`
+}
+
+// LoadState reads the state kept in dir; a missing state is empty.
+func LoadState(dir string) (*State, error) {
+	s := &State{path: filepath.Join(dir, "state.json"), Reports: make(map[string]*ReportState)}
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read incremental state: %w", err)
+	}
+	if err := json.Unmarshal(data, s); err != nil {
+		return nil, fmt.Errorf("failed to parse incremental state %s: %w", s.path, err)
+	}
+	if s.Reports == nil {
+		s.Reports = make(map[string]*ReportState)
+	}
+	return s, nil
+}
+
+// Save writes the state back to its directory.
+func (s *State) Save() error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), os.ModePerm); err != nil {
+		return err
+	}
+	tmp := s.path + ".tmp"
+	if err := os.WriteFile(tmp, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("failed to write incremental state: %w", err)
+	}
+	return os.Rename(tmp, s.path)
+}
+
+// known returns every input fingerprint of the state, to skip hashing the
+// files that have not changed since.
+func (s *State) known() []manifest.Input {
+	var known []manifest.Input
+	for _, r := range s.Reports {
+		known = append(known, r.Inputs...)
+	}
+	return known
+}
+
+// changed returns why the report must be regenerated, or "" if its last run
+// is still current: same day, same configuration, same input contents, all
+// its workbooks still there and none of its dependencies regenerated.
+func (s *State) changed(def report.Definition, configSum, day string, inputs []manifest.Input, regenerated map[string]bool) string {
+	last := s.Reports[def.Name]
+	switch {
+	case last == nil:
+		return "no earlier run"
+	case last.Date != day:
+		return "last run on " + last.Date
+	case last.Config != configSum:
+		return "configuration changed"
+	}
+	for _, dep := range def.DependsOn {
+		if regenerated[dep] {
+			return "dependency " + dep + " regenerated"
+		}
+	}
+	previous := make(map[string]string, len(last.Inputs))
+	for _, in := range last.Inputs {
+		previous[in.Path] = in.SHA256
+	}
+	for _, in := range inputs {
+		if previous[in.Path] != in.SHA256 {
+			return "input " + in.Name + " changed"
+		}
+	}
+	if len(inputs) != len(last.Inputs) {
+		return "inputs changed"
+	}
+	for _, out := range last.Outputs {
+		if _, err := os.Stat(out); err != nil {
+			return "output " + out + " missing"
+		}
+	}
+	return ""
+}
+
+// configSum returns the SHA-256 of the configuration and the output
+// formats, so that a run in other formats regenerates the reports.
+func configSum(cfg *config.Config, formats []string) (string, error) {
+	data, err := json.Marshal(cfg)
+	if err != nil {
+		return "", err
+	}
+	if len(formats) > 0 && !slices.Equal(formats, []string{output.XLSXFormat}) {
+		data = append(data, strings.Join(formats, ",")...)
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
@@ -0,0 +1,41 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateCancelled(t *testing.T) {
+	cfg := writeInputs(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	for _, name := range []string{"credit", "growth", "zso"} {
+		generator, err := NewReportGenerator(name, cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := generator.Generate(ctx); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: got %v, want context.Canceled", name, err)
+		}
+	}
+	if files, _ := filepath.Glob(filepath.Join(cfg.OutputDir, "*", "*")); len(files) != 0 {
+		t.Errorf("cancelled run left files behind: %v", files)
+	}
+}
+
+// COGS must not compute shortfalls against no credit at all when the credit
+// report has not run.
+func TestCOGSWithoutCreditReports(t *testing.T) {
+	cfg := writeInputs(t)
+	generator, err := NewReportGenerator("cogs", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := generator.Generate(context.Background()); err == nil || !strings.Contains(err.Error(), "no credit reports") {
+		t.Errorf("got %v, want an error naming the missing credit reports", err)
+	}
+}
@@ -1,7 +1,9 @@
 package report
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
@@ -9,64 +11,78 @@ import (
 	"viking-reports/internal/repository"
 	"viking-reports/internal/utils"
 	"viking-reports/pkg/excel"
-
-	"github.com/xuri/excelize/v2"
+	"viking-reports/pkg/output"
 )
 
-type COGSReportGenerator struct {
-	cfg              *config.Config
-	inventoryRepo    repository.InventoryRepository
-	creditRepo       repository.CreditRepository
-	tseMappingRepo   repository.TSEMappingRepository
-	productPriceRepo repository.ProductPriceRepository
+func init() {
+	Register(Definition{
+		Name:        "cogs",
+		Description: "Inventory cost against credit due per retailer, and stock count per material code",
+		Inputs: func(cfg *config.Config) []Input {
+			return []Input{
+				{"DealerInventory", cfg.ReportFiles.InventoryReport, repository.InventorySchema},
+				{"ProductPriceList", cfg.CommonFiles.PriceList, repository.ProductPriceSchema},
+				{"Retailer Metadata", cfg.CommonFiles.TSEMapping, repository.RetailerMetadataSchema},
+			}
+		},
+		// Credit dues are read back from today's credit reports.
+		DependsOn: []string{"credit"},
+		New: func(cfg *config.Config, opts ...Option) ReportGenerator {
+			return NewCOGSReportGenerator(cfg, opts...)
+		},
+	})
 }
 
-func NewCOGSReportGenerator(cfg *config.Config) *COGSReportGenerator {
-	priceRepo := repository.NewExcelProductPriceRepository(cfg.CommonFiles.PriceList)
-	tseMappingRepo := repository.NewExcelTSEMappingRepository(cfg.CommonFiles.TSEMapping)
-
-	priceData, _ := priceRepo.GetProductPrices()
-	tseMapping, _ := tseMappingRepo.GetRetailerCodeToTSEMap()
+type COGSReportGenerator struct {
+	cfg           *config.Config
+	inventoryRepo repository.InventoryRepository
+	logger        *slog.Logger
+}
 
+func NewCOGSReportGenerator(cfg *config.Config, opts ...Option) *COGSReportGenerator {
+	o := newOptions(cfg, "cogs", opts)
 	return &COGSReportGenerator{
-		cfg:              cfg,
-		inventoryRepo:    repository.NewExcelInventoryRepository(cfg.ReportFiles.InventoryReport, priceData, tseMapping),
-		creditRepo:       repository.NewExcelCreditRepository(cfg.DataDir),
-		tseMappingRepo:   tseMappingRepo,
-		productPriceRepo: priceRepo,
+		cfg:           cfg,
+		inventoryRepo: o.repos.Inventory,
+		logger:        o.logger,
 	}
 }
 
-func (g *COGSReportGenerator) Generate() error {
-	fmt.Println("Generating COGS report...")
+func (g *COGSReportGenerator) Generate(ctx context.Context) error {
+	g.logger.Info("generating COGS report")
 
-	inventoryShortFall, err := g.inventoryRepo.ComputeInventoryShortFall()
+	inventoryShortFall, err := g.inventoryRepo.ComputeInventoryShortFall(ctx)
 	if err != nil {
 		return fmt.Errorf("error computing inventory short fall report. error: %w", err)
 	}
 
 	//Compute material model count
-	materialModelCount, err := g.inventoryRepo.ComputeMaterialModelCount()
+	materialModelCount, err := g.inventoryRepo.ComputeMaterialModelCount(ctx)
 	if err != nil {
 		return fmt.Errorf("error computing material model count: %w", err)
 	}
 
 	// Use priceData, tseMapping, and creditData in your COGS calculation logic here
-	reportFile := excel.NewFile()
+	result := output.NewResult()
 
 	outputDir := utils.GenerateOutputPath(g.cfg.OutputDir, "inventory_report")
-	if err := g.writeInventoryReport(reportFile, outputDir, inventoryShortFall); err != nil {
+	if err := g.writeInventoryReport(result, outputDir, inventoryShortFall); err != nil {
 		return fmt.Errorf("error writing inventory report: %w", err)
 	}
 
-	if err := g.writeMaterialModelCountReport(reportFile, outputDir, materialModelCount); err != nil {
+	if err := g.writeMaterialModelCountReport(result, materialModelCount); err != nil {
 		return fmt.Errorf("error writing inventory report: %w", err)
 	}
-	fmt.Printf("COGS report generated successfully: %s\n", outputDir)
+	// Both sheets are in one workbook.
+	if err := output.Save(ctx, result, filepath.Join(outputDir, "inventory_report.xlsx")); err != nil {
+		return fmt.Errorf("error writing inventory report: %w", err)
+	}
+	g.logger.Info("COGS report generated", "output", outputDir)
 	return nil
 }
 
-func (g *COGSReportGenerator) writeInventoryReport(f *excelize.File, outputDir string, inventoryShortFallData map[string]*repository.InventoryShortFallRepo) error {
+func (g *COGSReportGenerator) writeInventoryReport(result *output.Result, outputDir string, inventoryShortFallData map[string]*repository.InventoryShortFallRepo) error {
+	f := result.Workbook
 	inventoryShortFallSheet := "Inventory ShortFall"
 	// Create a new sheet
 	if _, err := f.NewSheet(inventoryShortFallSheet); err != nil {
@@ -78,37 +94,6 @@ func (g *COGSReportGenerator) writeInventoryReport(f *excelize.File, outputDir s
 		return fmt.Errorf("error creating output directory: %w", err)
 	}
 
-	headers := []string{"Dealer Code", "Dealer Name", "TSE", "Total Inventory Cost(₹)", "Total Credit Due(₹)", "Inventory Shortfall (₹)"}
-	if err := excel.WriteHeaders(f, inventoryShortFallSheet, headers); err != nil {
-		return err
-	}
-	// Custom number format for Indian numbering
-	inrFormat := "#,##,##0"
-	numberStyle, _ := f.NewStyle(&excelize.Style{
-		CustomNumFmt: &inrFormat, // Custom number format for Indian numbering
-		Border: []excelize.Border{
-			{Type: "left", Color: "000000", Style: 1},
-			{Type: "top", Color: "000000", Style: 1},
-			{Type: "bottom", Color: "000000", Style: 1},
-			{Type: "right", Color: "000000", Style: 1},
-		},
-	})
-
-	// Create a new redStyle
-	redStyle, _ := f.NewStyle(&excelize.Style{
-		Fill:         excelize.Fill{Type: "pattern", Color: []string{"FF9999"}, Pattern: 1}, // Light red background
-		CustomNumFmt: &inrFormat,                                                            // Custom number format for Indian numbering
-		Border: []excelize.Border{
-			{Type: "left", Color: "000000", Style: 1},
-			{Type: "top", Color: "000000", Style: 1},
-			{Type: "bottom", Color: "000000", Style: 1},
-			{Type: "right", Color: "000000", Style: 1},
-		},
-		Font: &excelize.Font{
-			Bold: true, // Set text to bold
-		},
-	})
-
 	// Convert map to slice for sorting
 	inventorySlice := make([]*repository.InventoryShortFallRepo, 0, len(inventoryShortFallData))
 	for _, data := range inventoryShortFallData {
@@ -118,108 +103,109 @@ func (g *COGSReportGenerator) writeInventoryReport(f *excelize.File, outputDir s
 	// Sort by TSE and then by Cost-Credit Difference
 	sort.Slice(inventorySlice, func(i, j int) bool {
 		if inventorySlice[i].TSE == inventorySlice[j].TSE {
+			if inventorySlice[i].InventoryShortfall == inventorySlice[j].InventoryShortfall {
+				return inventorySlice[i].DealerCode < inventorySlice[j].DealerCode
+			}
 			return inventorySlice[i].InventoryShortfall < inventorySlice[j].InventoryShortfall
 		}
 		return inventorySlice[i].TSE < inventorySlice[j].TSE
 	})
 
-	row := 2
+	// Amounts of retailers whose credit exceeds their inventory are in red
+	negative := []excel.Rule{{Of: "Inventory Shortfall (₹)", Op: "<", Value: g.cfg.Highlights.Shortfall, Highlight: excel.Highlight{Fill: "FF9999", Bold: true}}}
+	table := excel.Table{
+		Sheet: inventoryShortFallSheet,
+		Columns: []excel.Column{
+			{Header: "Dealer Code"},
+			{Header: "Dealer Name"},
+			{Header: "TSE"},
+			{Header: "Total Inventory Cost(₹)", Type: excel.Number, NumFmt: excel.INR, Rules: negative, Sum: true},
+			{Header: "Total Credit Due(₹)", Type: excel.Number, NumFmt: excel.INR, Rules: negative, Sum: true},
+			{Header: "Inventory Shortfall (₹)", Type: excel.Number, NumFmt: excel.INR, Rules: negative, Sum: true},
+		},
+		FreezeHeader: true,
+		AutoFilter:   true,
+		GroupBy:      "TSE",
+		Totals:       true,
+	}
+	rows := make([][]interface{}, 0, len(inventorySlice))
 	for _, data := range inventorySlice {
-		cellData := []interface{}{
+		rows = append(rows, []interface{}{
 			data.DealerCode,
 			data.DealerName,
 			data.TSE,
 			data.TotalInventoryCost,
 			data.TotalCreditDue,
 			data.InventoryShortfall,
-		}
-		if err := excel.WriteRow(f, inventoryShortFallSheet, row, cellData); err != nil {
-			return err
-		}
-
-		// Apply number style to numeric columns (0-7 Days to Total Credit)
-		for col := 3; col <= 5; col++ { // Columns C (3) to I (5)
-			cell := fmt.Sprintf("%s%d", string('A'+col), row) // Convert column index to letter
-			var style int
-			if data.InventoryShortfall < 0 { // Check if InventoryShortfall is negative
-				style = redStyle // Use redStyle for negative values
-			} else {
-				style = numberStyle // Use numberStyle for non-negative values
-			}
-			if err := f.SetCellStyle(inventoryShortFallSheet, cell, cell, style); err != nil {
-				return fmt.Errorf("error setting style for cell %s: %w", cell, err)
-			}
-		}
-		row++
+		})
 	}
-	excel.AdjustColumnWidths(f, inventoryShortFallSheet)
-	fileName := "inventory_report.xlsx"
-	outputPath := filepath.Join(outputDir, fileName)
-	return f.SaveAs(outputPath)
+	return result.Add(inventoryShortFallSheet, &table, rows)
 }
 
-func (g *COGSReportGenerator) writeMaterialModelCountReport(f *excelize.File, outputDir string, materialModelCount map[string]*repository.ModelCountRepo) error {
+func (g *COGSReportGenerator) writeMaterialModelCountReport(result *output.Result, materialModelCount map[string]*repository.ModelCountRepo) error {
+	f := result.Workbook
 	sheetName := "Material Model Count"
 	if _, err := f.NewSheet(sheetName); err != nil {
 		return fmt.Errorf("error creating new sheet for material model count: %w", err)
 	}
 	f.DeleteSheet("Sheet1")
 
-	headers := []string{"Dealer Code", "Dealer Name", "Material Code", "SPU Name", "Color", "SKU Spec", "Product Type", "Count"}
-	if err := excel.WriteHeaders(f, sheetName, headers); err != nil {
-		return err
-	}
-
 	// Convert map to slice for sorting
 	materialSlice := make([]*repository.ModelCountRepo, 0, len(materialModelCount))
 	for _, data := range materialModelCount {
 		materialSlice = append(materialSlice, data)
 	}
 
-	// Sort by Count in descending order
+	// Sort by Count in descending order, then by Material Code
 	sort.Slice(materialSlice, func(i, j int) bool {
+		if materialSlice[i].Count == materialSlice[j].Count {
+			return materialSlice[i].MaterialCode < materialSlice[j].MaterialCode
+		}
 		return materialSlice[i].Count > materialSlice[j].Count
 	})
 
-	// Custom number format for Indian numbering
-	numberFormat := "0"
-	numberStyle, _ := f.NewStyle(&excelize.Style{
-		CustomNumFmt: &numberFormat, // Custom number format for Indian numbering
-		Border: []excelize.Border{
-			{Type: "left", Color: "000000", Style: 1},
-			{Type: "top", Color: "000000", Style: 1},
-			{Type: "bottom", Color: "000000", Style: 1},
-			{Type: "right", Color: "000000", Style: 1},
+	table := excel.Table{
+		Sheet: sheetName,
+		Columns: []excel.Column{
+			{Header: "Dealer Code"},
+			{Header: "Dealer Name"},
+			{Header: "TSE"},
+			{Header: "Material Code", Type: excel.Number, NumFmt: "0"},
+			{Header: "SPU Name"},
+			{Header: "Color"},
+			{Header: "SKU Spec"},
+			{Header: "Product Type"},
+			{Header: "Count", Type: excel.Number, Sum: true},
 		},
-	})
-
-	row := 2
+		FreezeHeader: true,
+		AutoFilter:   true,
+		Totals:       true,
+		// One row per retailer and material code, which runs long.
+		Stream: true,
+	}
+	rows := make([][]interface{}, 0, len(materialSlice))
 	for _, data := range materialSlice {
-		cellData := []interface{}{
+		rows = append(rows, []interface{}{
 			data.DealerCode,
 			data.DealerName,
+			data.TSE,
 			data.MaterialCode,
 			data.SPUName,
 			data.Color,
 			data.SKUSpec,
 			data.ProductType,
-			data.Count, // Count
-		}
-		if err := excel.WriteRow(f, sheetName, row, cellData); err != nil {
+			data.Count,
+		})
+	}
+	if err := result.Add(sheetName, &table, rows); err != nil {
+		return err
+	}
+	// The count of each model by TSE.
+	if wantsPivot(g.cfg, "cogs") {
+		pivot := excel.Pivot{Sheet: pivotSheet, Rows: []string{"TSE"}, Columns: []string{"SPU Name"}, Data: []string{"Count"}}
+		if err := table.AddPivot(f, len(rows), pivot); err != nil {
 			return err
 		}
-
-		// Apply number style to numeric columns (only for Material Code)
-		cell := fmt.Sprintf("%s%d", "C", row) // Column C for Material Code
-		if err := f.SetCellStyle(sheetName, cell, cell, numberStyle); err != nil {
-			return fmt.Errorf("error setting style for cell %s: %w", cell, err)
-		}
-
-		row++
 	}
-
-	excel.AdjustColumnWidths(f, sheetName)
-	fileName := "inventory_report.xlsx"
-	outputPath := filepath.Join(outputDir, fileName)
-	return f.SaveAs(outputPath)
+	return nil
 }
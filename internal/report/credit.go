@@ -1,7 +1,9 @@
 package report
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
@@ -9,77 +11,96 @@ import (
 	"viking-reports/internal/repository"
 	"viking-reports/internal/utils"
 	"viking-reports/pkg/excel"
+	"viking-reports/pkg/output"
 
 	"github.com/xuri/excelize/v2"
 )
 
+func init() {
+	Register(Definition{
+		Name:        "credit",
+		Description: "Outstanding credit by age bucket against inventory cost, one workbook per TSE",
+		Inputs: func(cfg *config.Config) []Input {
+			return []Input{
+				{"Bills", cfg.ReportFiles.CreditReport.Bills, repository.BillsSchema},
+				{"Received", cfg.ReportFiles.DebitReport.Debits, repository.ReceivedSchema},
+				{"Retailer Metadata", cfg.CommonFiles.TSEMapping, repository.RetailerMetadataSchema},
+				{"DealerInventory", cfg.ReportFiles.InventoryReport, repository.InventorySchema},
+				{"ProductPriceList", cfg.CommonFiles.PriceList, repository.ProductPriceSchema},
+			}
+		},
+		PerTSE: true,
+		New: func(cfg *config.Config, opts ...Option) ReportGenerator {
+			return NewCreditReportGenerator(cfg, opts...)
+		},
+	})
+}
+
 type CreditReportGenerator struct {
 	cfg            *config.Config
 	creditRepo     repository.CreditRepository
 	debitRepo      repository.DebitRepository
 	inventoryRepo  repository.InventoryRepository
 	tseMappingRepo repository.TSEMappingRepository
+	logger         *slog.Logger
+	summary        *Summary
 }
 
-func NewCreditReportGenerator(cfg *config.Config) *CreditReportGenerator {
-	priceRepo := repository.NewExcelProductPriceRepository(cfg.CommonFiles.PriceList)
-	tseMappingRepo := repository.NewExcelTSEMappingRepository(cfg.CommonFiles.TSEMapping)
-
-	priceData, _ := priceRepo.GetProductPrices()
-	tseMapping, _ := tseMappingRepo.GetRetailerCodeToTSEMap()
-
+func NewCreditReportGenerator(cfg *config.Config, opts ...Option) *CreditReportGenerator {
+	o := newOptions(cfg, "credit", opts)
 	return &CreditReportGenerator{
 		cfg:            cfg,
-		creditRepo:     repository.NewExcelCreditRepository(cfg.ReportFiles.CreditReport.Bills),
-		debitRepo:      repository.NewExcelDebitRepository(cfg.ReportFiles.DebitReport.Debits),
-		inventoryRepo:  repository.NewExcelInventoryRepository(cfg.ReportFiles.InventoryReport, priceData, tseMapping),
-		tseMappingRepo: repository.NewExcelTSEMappingRepository(cfg.CommonFiles.TSEMapping),
+		creditRepo:     o.repos.Credit,
+		debitRepo:      o.repos.Debit,
+		inventoryRepo:  o.repos.Inventory,
+		tseMappingRepo: o.repos.TSEMapping,
+		logger:         o.logger,
+		summary:        o.summary,
 	}
 }
 
-func (g *CreditReportGenerator) Generate() error {
+func (g *CreditReportGenerator) Generate(ctx context.Context) error {
+	g.logger.Info("generating credit report")
 
-	bills, err := g.creditRepo.GetBills()
+	bills, err := g.creditRepo.GetBills(ctx)
 	if err != nil {
 		return fmt.Errorf("error reading bills: %w", err)
 	}
 
-	tseMapping, err := g.tseMappingRepo.GetRetailerNameToTSEMap("Tally Name(Dealer Name)")
+	tseMapping, err := g.tseMappingRepo.GetRetailerNameToTSEMap(ctx, "Tally Name(Dealer Name)")
 	if err != nil {
 		return fmt.Errorf("error reading TSE mapping: %w", err)
 	}
 
-	retailerNameToCodeMap, err := g.tseMappingRepo.GetRetailerNameToCodeMap()
+	retailerNameToCodeMap, err := g.tseMappingRepo.GetRetailerNameToCodeMap(ctx)
 	if err != nil {
 		return fmt.Errorf("error reading Name to Code mapping: %w", err)
 	}
 
-	retailerNameToDebitMap, err := g.debitRepo.GetDebit()
+	retailerNameToDebitMap, err := g.debitRepo.GetDebit(ctx)
 	if err != nil {
 		return fmt.Errorf("error reading Name to debit mapping: %w", err)
 	}
-	fmt.Println("** Input: Fetching the stock inventory of retailers from DMS portal  **")
 
-	fmt.Println("\n== Begin processing! ==")
 	retailerCredit := g.creditRepo.AggregateCreditByRetailer(bills, tseMapping, retailerNameToCodeMap)
 
-	inventoryData, err := g.inventoryRepo.ComputeInventoryShortFall()
-	if err != nil { // Check for error
-		return fmt.Errorf("error computing inventory shortfall: %w", err) // Handle the error
+	// The credit reports' own shortfall is against the credit computed here,
+	// so only the cost of the stock is needed.
+	inventoryCost, err := g.inventoryRepo.ComputeInventoryCost(ctx)
+	if err != nil {
+		return fmt.Errorf("error computing inventory cost: %w", err)
 	}
 
 	outputDir := utils.GenerateOutputPath(g.cfg.OutputDir, "credit_reports")
-	if err := g.writeCreditReports(outputDir, retailerCredit, inventoryData, retailerNameToDebitMap); err != nil {
-		return fmt.Errorf("error writing credit reports: %w", err)
+	if err := g.writeCreditReports(ctx, outputDir, retailerCredit, inventoryCost, retailerNameToDebitMap); err != nil {
+		return err
 	}
-	fmt.Println("== End processing! ==")
-	fmt.Println()
-	fmt.Printf("** Output: Credit reports generated successfully in: %s ** \n", outputDir)
+	g.logger.Info("credit reports generated", "output", outputDir)
 	return nil
 }
 
-func (g *CreditReportGenerator) writeCreditReports(outputDir string, retailerCredit map[string]map[string]interface{},
-	inventoryData map[string]*repository.InventoryShortFallRepo, retailerNameToDebitMap map[string]float64) error {
+func (g *CreditReportGenerator) writeCreditReports(ctx context.Context, outputDir string, retailerCredit map[string]map[string]interface{},
+	inventoryCost map[string]float64, retailerNameToDebitMap map[string]float64) error {
 	totalDealerCreditWithTSE := make(map[string]map[string]map[string]interface{})
 	totalDealerCreditMissingTSE := make(map[string]map[string]interface{})
 
@@ -94,27 +115,38 @@ func (g *CreditReportGenerator) writeCreditReports(outputDir string, retailerCre
 			totalDealerCreditWithTSE[tseName][retailerName] = credit
 		}
 	}
-	for tseName, retailerCredit := range totalDealerCreditWithTSE {
-		fmt.Printf("Generating total credit report for %d retailers assigned to %s \n", len(retailerCredit), tseName)
-		fileName := fmt.Sprintf("%s_credit_report.xlsx", tseName)
-		if err := g.writeCreditReport(outputDir, fileName, retailerCredit, inventoryData, retailerNameToDebitMap); err != nil {
-			return fmt.Errorf("error writing file for TSE %s: %w", tseName, err)
-		}
+	// One workbook per TSE, plus TSE_MISSING for retailers without one. A
+	// workbook that fails does not stop the others.
+	units := make([]string, 0, len(totalDealerCreditWithTSE)+1)
+	for tseName := range totalDealerCreditWithTSE {
+		units = append(units, tseName)
 	}
-
+	sort.Strings(units)
 	if len(totalDealerCreditMissingTSE) > 0 {
-		fmt.Printf("Generating total credit report for %d retailers for which TSE's are *not* assigned!  \n", len(totalDealerCreditMissingTSE))
-		if err := g.writeCreditReport(outputDir, "TSE_MISSING_credit_report.xlsx", totalDealerCreditMissingTSE, inventoryData, retailerNameToDebitMap); err != nil {
-			return fmt.Errorf("error writing TSE_MISSING file: %w", err)
-		}
+		units = append(units, tseMissing)
 	}
 
-	return nil
+	return writeUnits(ctx, g.summary, g.logger, "credit", units, func(ctx context.Context, unit string) (string, error) {
+		data := totalDealerCreditWithTSE[unit]
+		if unit == tseMissing {
+			data = totalDealerCreditMissingTSE
+			g.logger.Warn("writing credit report of retailers without a TSE", "retailers", len(data))
+		} else {
+			g.logger.Debug("writing credit report", "tse", unit, "retailers", len(data))
+		}
+		fileName := fmt.Sprintf("%s_credit_report.xlsx", unit)
+		return filepath.Join(outputDir, fileName), g.writeCreditReport(ctx, outputDir, fileName, data, inventoryCost, retailerNameToDebitMap)
+	})
 }
 
-func (g *CreditReportGenerator) writeCreditReport(outputDir, fileName string, data map[string]map[string]interface{},
-	inventoryData map[string]*repository.InventoryShortFallRepo, retailerNameToDebitMap map[string]float64) error {
-	f := excel.NewFile()
+// tseMissing is the unit, and file name prefix, of the credit report of
+// retailers without a TSE.
+const tseMissing = "TSE_MISSING"
+
+func (g *CreditReportGenerator) writeCreditReport(ctx context.Context, outputDir, fileName string, data map[string]map[string]interface{},
+	inventoryCost map[string]float64, retailerNameToDebitMap map[string]float64) error {
+	result := output.NewResult()
+	f := result.Workbook
 	sheetName := "Credit Report"
 	// Create a new sheet
 	if _, err := f.NewSheet(sheetName); err != nil {
@@ -126,40 +158,46 @@ func (g *CreditReportGenerator) writeCreditReport(outputDir, fileName string, da
 		return fmt.Errorf("error creating output directory: %w", err)
 	}
 
-	// Custom number format for Indian numbering
-	inrFormat := "#,##,##0"
-	numberStyle, err := f.NewStyle(&excelize.Style{
-		CustomNumFmt: &inrFormat, // Custom number format for Indian numbering
-		Border: []excelize.Border{
-			{Type: "left", Color: "000000", Style: 1},
-			{Type: "top", Color: "000000", Style: 1},
-			{Type: "bottom", Color: "000000", Style: 1},
-			{Type: "right", Color: "000000", Style: 1},
-		},
-	})
-	if err != nil {
-		return fmt.Errorf("failed to create number style: %w", err)
+	red := excel.Highlight{Fill: "FF9999", Bold: true} // Light red background
+	overdue := []excel.Rule{{Op: ">=", Value: g.cfg.Highlights.OverdueCredit, Highlight: red}}
+	amount := func(header string) excel.Column {
+		return excel.Column{Header: header, Type: excel.Number, NumFmt: excel.INR, Sum: true}
 	}
-
-	headers := []string{"Retailer Code", "Retailer Name", "Received: Last 1 days (₹)", "Credit: 0-7 Days(₹)", "Credit: 8-14 Days(₹)", "Credit: 15-20 Days(₹)", "Credit: 21-30 Days(₹)", "Credit: 31+ Days(₹)", "Total Credit(₹)", "Total Inventory Cost(₹)", "Inventory Shortfall (₹)", "TSE"}
-	if err := excel.WriteHeaders(f, sheetName, headers); err != nil {
-		return err
+	table := excel.Table{
+		Sheet: sheetName,
+		Columns: []excel.Column{
+			{Header: "Retailer Code"},
+			{Header: "Retailer Name"},
+			amount("Received: Last 1 days (₹)"),
+			amount("Credit: 0-7 Days(₹)"),
+			amount("Credit: 8-14 Days(₹)"),
+			amount("Credit: 15-20 Days(₹)"),
+			{Header: "Credit: 21-30 Days(₹)", Type: excel.Number, NumFmt: excel.INR, Rules: overdue, Sum: true},
+			{Header: "Credit: 31+ Days(₹)", Type: excel.Number, NumFmt: excel.INR, Rules: overdue, Sum: true},
+			amount("Total Credit(₹)"),
+			amount("Total Inventory Cost(₹)"),
+			{Header: "Inventory Shortfall (₹)", Type: excel.Number, NumFmt: excel.INR, Sum: true, Rules: []excel.Rule{
+				{Op: "<", Value: g.cfg.Highlights.Shortfall, Highlight: red},
+			}},
+			{Header: "TSE"},
+		},
+		FreezeHeader:  true,
+		FreezeColumns: 2,
+		AutoFilter:    true,
+		Totals:        true,
 	}
 
-	row := 2
 	inventoryShortfalls := make([]struct {
 		Credit    map[string]interface{}
 		Shortfall float64
 	}, 0)
 
 	for _, retailerCredit := range data {
-		inventoryCost := 0.0
-		if dealerData, exists := inventoryData[retailerCredit["Retailer Code"].(string)]; exists { // Fetch inventory cost using retailer code
-			inventoryCost = dealerData.TotalInventoryCost
-		} else {
-			fmt.Printf("Inventory Cost missing for dealer '%s' !\n", retailerCredit["Retailer Code"])
+		cost, exists := inventoryCost[retailerCredit["Retailer Code"].(string)] // Fetch inventory cost using retailer code
+		if !exists {
+			g.logger.Debug("inventory cost missing", "retailer", retailerCredit["Retailer Code"])
 		}
-		inventoryShortFall := inventoryCost - retailerCredit["Total Credit"].(float64)
+		inventoryShortFall := cost - retailerCredit["Total Credit"].(float64)
 
 		// Store the retailer credit and its shortfall
 		inventoryShortfalls = append(inventoryShortfalls, struct {
@@ -168,22 +206,20 @@ func (g *CreditReportGenerator) writeCreditReport(outputDir, fileName string, da
 		}{Credit: retailerCredit, Shortfall: inventoryShortFall})
 	}
 
-	// Sort by inventoryShortFall (ascending)
+	// Sort by inventoryShortFall (ascending), then by retailer name
 	sort.Slice(inventoryShortfalls, func(i, j int) bool {
+		if inventoryShortfalls[i].Shortfall == inventoryShortfalls[j].Shortfall {
+			return inventoryShortfalls[i].Credit["Retailer Name"].(string) < inventoryShortfalls[j].Credit["Retailer Name"].(string)
+		}
 		return inventoryShortfalls[i].Shortfall < inventoryShortfalls[j].Shortfall
 	})
 
 	// Write sorted data to the sheet
-	var totalDebitLastDay = 0.0
+	rows := make([][]interface{}, 0, len(inventoryShortfalls))
 	for _, item := range inventoryShortfalls {
 		retailerCredit := item.Credit
 		inventoryShortFall := item.Shortfall
-		inventoryCost := 0.0
-		if dealerData, exists := inventoryData[retailerCredit["Retailer Code"].(string)]; exists {
-			inventoryCost = dealerData.TotalInventoryCost
-		}
-		totalDebitLastDay = totalDebitLastDay + retailerNameToDebitMap[retailerCredit["Retailer Name"].(string)]
-		cellData := []interface{}{
+		rows = append(rows, []interface{}{
 			retailerCredit["Retailer Code"],
 			retailerCredit["Retailer Name"],
 			retailerNameToDebitMap[retailerCredit["Retailer Name"].(string)],
@@ -193,107 +229,118 @@ func (g *CreditReportGenerator) writeCreditReport(outputDir, fileName string, da
 			retailerCredit["21-30 Days"],
 			retailerCredit["31+ Days"],
 			retailerCredit["Total Credit"],
-			inventoryCost, // Ensure inventoryCost is defined before use
+			inventoryCost[retailerCredit["Retailer Code"].(string)],
 			inventoryShortFall,
 			retailerCredit["TSE"],
-		}
+		})
+	}
 
-		if err := excel.WriteRow(f, sheetName, row, cellData); err != nil {
+	if err := result.Add(sheetName, &table, rows); err != nil {
+		return err
+	}
+	// A bar per TSE, stacked by the age of its retailers' credit.
+	if at, ok := chartPlacement(g.cfg, "credit", 640, 260); ok {
+		if err := g.addCreditChart(f, &table, rows, at); err != nil {
+			return err
+		}
+	}
+	// The credit of each TSE by age.
+	if wantsPivot(g.cfg, "credit") {
+		pivot := excel.Pivot{
+			Sheet: pivotSheet,
+			Rows:  []string{"TSE"},
+			Data:  append(append([]string{}, creditBuckets...), "Total Credit(₹)"),
+		}
+		if err := table.AddPivot(f, len(rows), pivot); err != nil {
 			return err
 		}
+	}
 
-		//Apply all kinds of styles
+	outputPath := filepath.Join(outputDir, fileName)
+	return output.Save(ctx, result, outputPath)
+}
 
-		// Ensure numeric values are of type float64 to all numeric columns
-		for i := 2; i <= 9; i++ { // Columns C (2) to I (7)
-			if val, ok := retailerCredit[headers[i]].(float64); ok {
-				cellData[i-2] = val // Replace with float64 value
-			}
-		}
+// creditBuckets are the headers of the age buckets of the credit reports.
+var creditBuckets = []string{
+	"Credit: 0-7 Days(₹)",
+	"Credit: 8-14 Days(₹)",
+	"Credit: 15-20 Days(₹)",
+	"Credit: 21-30 Days(₹)",
+	"Credit: 31+ Days(₹)",
+}
 
-		// Apply number style to all numeric columns
-		for col := 2; col <= 9; col++ { // Columns C (3) to I (8)
-			cell := fmt.Sprintf("%s%d", string('A'+col), row) // Convert column index to letter
-			if err := f.SetCellStyle(sheetName, cell, cell, numberStyle); err != nil {
-				return fmt.Errorf("error setting style for cell %s: %w", cell, err)
+// creditChartSheet is the hidden sheet of the credit of each TSE by age that
+// the credit chart is drawn from.
+const creditChartSheet = "Credit by TSE"
+
+// addCreditChart sums the age buckets of rows, the rows of table, by TSE on
+// a hidden sheet and draws them at at as a bar per TSE, stacked by age.
+func (g *CreditReportGenerator) addCreditChart(f *excelize.File, table *excel.Table, rows [][]interface{}, at excel.Placement) error {
+	index := func(header string) int {
+		for i, column := range table.Columns {
+			if column.Header == header {
+				return i
 			}
 		}
-
-		// Create a red Style
-		redStyle, err := f.NewStyle(&excelize.Style{
-			Fill:         excelize.Fill{Type: "pattern", Color: []string{"FF9999"}, Pattern: 1}, // Light red background
-			CustomNumFmt: &inrFormat,                                                            // Custom number format for Indian numbering
-			Border: []excelize.Border{
-				{Type: "left", Color: "000000", Style: 1},
-				{Type: "top", Color: "000000", Style: 1},
-				{Type: "bottom", Color: "000000", Style: 1},
-				{Type: "right", Color: "000000", Style: 1},
-			},
-			Font: &excelize.Font{
-				Bold: true, // Set text to bold
-			},
-		})
-		if err != nil {
-			return fmt.Errorf("failed to create background style: %w", err)
+		return -1
+	}
+	tseCol, bucketCols := index("TSE"), make([]int, len(creditBuckets))
+	for i, header := range creditBuckets {
+		bucketCols[i] = index(header)
+	}
+	var tses []string
+	sums := make(map[string][]float64)
+	for _, row := range rows {
+		tse, _ := row[tseCol].(string)
+		if tse == "" {
+			tse = tseMissing
 		}
-
-		// Apply backgroundStyle style to 22-30 Days(₹)	and 31+ Days(₹)
-		for col := 6; col <= 7; col++ {
-			cell := fmt.Sprintf("%s%d", string('A'+col), row) // Convert column index to letter
-			if err := f.SetCellStyle(sheetName, cell, cell, redStyle); err != nil {
-				return fmt.Errorf("error setting style for cell %s: %w", cell, err)
-			}
+		if sums[tse] == nil {
+			tses = append(tses, tse)
+			sums[tse] = make([]float64, len(creditBuckets))
 		}
-
-		// Apply redStyle to Inventory Shortfall cells if negative
-		if inventoryShortFall < 0 {
-			cell := fmt.Sprintf("K%d", row) // Column J for Inventory Shortfall
-			if err := f.SetCellStyle(sheetName, cell, cell, redStyle); err != nil {
-				return fmt.Errorf("error setting style for cell %s: %w", cell, err)
-			}
+		for i, col := range bucketCols {
+			v, _ := row[col].(float64)
+			sums[tse][i] += v
 		}
-		row++
 	}
+	sort.Strings(tses)
 
-	// Calculate totals
-	total := make([]float64, 8) // For columns 0-7 Days to Total Credit
-	for _, item := range inventoryShortfalls {
-		retailerCredit := item.Credit
-		total[0] += retailerCredit["0-7 Days"].(float64)
-		total[1] += retailerCredit["8-14 Days"].(float64)
-		total[2] += retailerCredit["15-20 Days"].(float64)
-		total[3] += retailerCredit["21-30 Days"].(float64)
-		total[4] += retailerCredit["31+ Days"].(float64)
-		total[5] += retailerCredit["Total Credit"].(float64)
-
-		inventoryCost := 0.0
-		if dealerData, exists := inventoryData[retailerCredit["Retailer Code"].(string)]; exists {
-			inventoryCost = dealerData.TotalInventoryCost
+	byTSE := excel.Table{Sheet: creditChartSheet, Columns: []excel.Column{{Header: "TSE"}}}
+	for _, header := range creditBuckets {
+		byTSE.Columns = append(byTSE.Columns, excel.Column{Header: header, Type: excel.Number, NumFmt: excel.INR})
+	}
+	data := make([][]interface{}, 0, len(tses))
+	for _, tse := range tses {
+		row := []interface{}{tse}
+		for _, v := range sums[tse] {
+			row = append(row, v)
 		}
-		total[6] += inventoryCost                                            // Total Inventory Cost
-		total[7] += retailerCredit["Total Credit"].(float64) - inventoryCost // Total Inventory Shortfall
+		data = append(data, row)
 	}
-
-	// Write totals to the last row
-	totalCellData := []interface{}{
-		"Total", // Label for the total row
-		"",      // Retailer Name
-		totalDebitLastDay,
-		total[0], total[1], total[2], total[3], total[4], total[5], total[6], total[7], "",
+	if _, err := f.NewSheet(creditChartSheet); err != nil {
+		return fmt.Errorf("error creating new sheet: %w", err)
+	}
+	if err := byTSE.Write(f, data); err != nil {
+		return err
 	}
-	if err := excel.WriteRow(f, sheetName, row, totalCellData); err != nil {
+	if err := f.SetSheetVisible(creditChartSheet, false); err != nil {
 		return err
 	}
 
-	// Apply number style to total row
-	for col := 2; col <= 10; col++ { // Columns C (4) to I (9)
-		cell := fmt.Sprintf("%s%d", string('A'+col), row) // Convert column index to letter
-		if err := f.SetCellStyle(sheetName, cell, cell, numberStyle); err != nil {
-			return fmt.Errorf("error setting style for cell %s: %w", cell, err)
+	// Drawn beside the credit table unless placed elsewhere.
+	if at.Sheet == "" {
+		at.Sheet = table.Sheet
+		if at.Cell == "" {
+			at.Cell, _ = excelize.CoordinatesToCellName(len(table.Columns)+2, 1)
 		}
 	}
-
-	excel.AdjustColumnWidths(f, sheetName)
-	outputPath := filepath.Join(outputDir, fileName)
-	return f.SaveAs(outputPath)
+	chart := excel.Chart{
+		Title:      "Credit by Age",
+		Categories: "TSE",
+		Series:     creditBuckets,
+		Horizontal: true,
+		Stacked:    true,
+	}
+	return byTSE.AddChart(f, len(data), chart, at)
 }
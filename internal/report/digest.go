@@ -0,0 +1,233 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"viking-reports/internal/config"
+	"viking-reports/internal/repository"
+	"viking-reports/internal/utils"
+	"viking-reports/pkg/output"
+)
+
+func init() {
+	Register(Definition{
+		Name:        "digest",
+		Description: "Daily digest of every report's rows for one TSE's retailers, one workbook per TSE",
+		Inputs: func(cfg *config.Config) []Input {
+			return []Input{
+				{"Retailer Metadata", cfg.CommonFiles.TSEMapping, repository.RetailerMetadataSchema},
+			}
+		},
+		DependsOn: []string{"growth", "credit", "cogs", "zso", "ranorms", "salestarget"},
+		PerTSE:    true,
+		New: func(cfg *config.Config, opts ...Option) ReportGenerator {
+			return NewDigestGenerator(cfg, opts...)
+		},
+	})
+}
+
+// tseAssignment assigns retailers to TSEs as the retailer master does,
+// whatever TSE the reports' rows name.
+type tseAssignment struct {
+	byCode map[string]string
+	// byName and byTallyName are keyed by upper-case name, since the
+	// reports do not all keep the master's case.
+	byName, byTallyName map[string]string
+	// tses are the TSEs the master assigns retailers to.
+	tses map[string]bool
+}
+
+// retailer returns the TSE of the retailer with code, or without one, with
+// name in names.
+func (a tseAssignment) retailer(code, name string, names map[string]string) string {
+	if tse := a.byCode[code]; code != "" && tse != "" {
+		return tse
+	}
+	return names[strings.ToUpper(strings.TrimSpace(name))]
+}
+
+// digestSheets are the sheets of a digest after its summary, in order, each
+// the rows of a pack section whose retailers tse assigns to the TSE.
+var digestSheets = []struct {
+	sheet string
+	tse   func(a tseAssignment, record map[string]string) string
+}{
+	{"Credit", func(a tseAssignment, r map[string]string) string {
+		return a.retailer(r["Retailer Code"], r["Retailer Name"], a.byTallyName)
+	}},
+	{"Growth", func(a tseAssignment, r map[string]string) string {
+		return a.retailer(r["Dealer Code"], r["Dealer Name"], a.byName)
+	}},
+	{"ZSO", func(a tseAssignment, r map[string]string) string {
+		return a.retailer("", r["Dealer Name"], a.byName)
+	}},
+	{"RA Norms", func(a tseAssignment, r map[string]string) string {
+		return a.retailer("", r["Dealer Name"], a.byName)
+	}},
+	// The sales target rows are TSEs' totals.
+	{"Sales Target", func(a tseAssignment, r map[string]string) string {
+		if a.tses[r["TSE"]] {
+			return r["TSE"]
+		}
+		return ""
+	}},
+	{"COGS", func(a tseAssignment, r map[string]string) string {
+		return a.retailer(r["Dealer Code"], r["Dealer Name"], a.byName)
+	}},
+}
+
+type DigestGenerator struct {
+	cfg            *config.Config
+	tseMappingRepo repository.TSEMappingRepository
+	logger         *slog.Logger
+	summary        *Summary
+}
+
+func NewDigestGenerator(cfg *config.Config, opts ...Option) *DigestGenerator {
+	o := newOptions(cfg, "digest", opts)
+	return &DigestGenerator{
+		cfg:            cfg,
+		tseMappingRepo: o.repos.TSEMapping,
+		logger:         o.logger,
+		summary:        o.summary,
+	}
+}
+
+func (g *DigestGenerator) Generate(ctx context.Context) error {
+	g.logger.Info("generating TSE digests")
+
+	assignment, err := g.assignment(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The headers and rows of each sheet, the rows by TSE.
+	headers := make(map[string][]string)
+	rows := make(map[string]map[string][]map[string]string)
+	for _, digest := range digestSheets {
+		section, _ := packSectionOf(digest.sheet)
+		if section.withheld(g.cfg, "digest") {
+			g.logger.Warn("leaving out the sheet of an encrypted report, the digests are not encrypted", "sheet", digest.sheet, "source", section.report)
+			continue
+		}
+		h, records, err := readPackSection(ctx, g.cfg, section)
+		if err != nil {
+			return err
+		}
+		headers[digest.sheet] = h
+		rows[digest.sheet] = make(map[string][]map[string]string)
+		unassigned := 0
+		for _, record := range records {
+			tse := digest.tse(assignment, record)
+			if tse == "" {
+				unassigned++
+				continue
+			}
+			rows[digest.sheet][tse] = append(rows[digest.sheet][tse], record)
+		}
+		if unassigned > 0 {
+			g.logger.Warn("leaving out rows of retailers without a TSE in the retailer master", "sheet", digest.sheet, "rows", unassigned)
+		}
+	}
+
+	tses := make([]string, 0, len(assignment.tses))
+	for tse := range assignment.tses {
+		tses = append(tses, tse)
+	}
+	sort.Strings(tses)
+	outputDir := utils.GenerateOutputPath(g.cfg.OutputDir, "tse_digest")
+	err = writeUnits(ctx, g.summary, g.logger, "digest", tses, func(ctx context.Context, tse string) (string, error) {
+		records := make(map[string][]map[string]string, len(digestSheets))
+		for sheet, byTSE := range rows {
+			records[sheet] = byTSE[tse]
+		}
+		g.logger.Debug("writing TSE digest", "tse", tse)
+		outputPath := filepath.Join(outputDir, fmt.Sprintf("%s_digest.xlsx", tse))
+		return outputPath, g.writeDigest(ctx, outputPath, headers, records)
+	})
+	if err != nil {
+		return err
+	}
+	g.logger.Info("TSE digests generated", "output", outputDir)
+	return nil
+}
+
+func (g *DigestGenerator) assignment(ctx context.Context) (tseAssignment, error) {
+	byCode, err := g.tseMappingRepo.GetRetailerCodeToTSEMap(ctx)
+	if err != nil {
+		return tseAssignment{}, fmt.Errorf("error reading TSE mapping: %w", err)
+	}
+	byName, err := g.tseMappingRepo.GetRetailerNameToTSEMap(ctx, "Dealer Name")
+	if err != nil {
+		return tseAssignment{}, fmt.Errorf("error reading TSE mapping: %w", err)
+	}
+	byTallyName, err := g.tseMappingRepo.GetRetailerNameToTSEMap(ctx, "Tally Name(Dealer Name)")
+	if err != nil {
+		return tseAssignment{}, fmt.Errorf("error reading TSE mapping: %w", err)
+	}
+	a := tseAssignment{byCode: byCode, byName: upperKeys(byName), byTallyName: upperKeys(byTallyName), tses: make(map[string]bool)}
+	for _, tse := range byCode {
+		if tse != "" {
+			a.tses[tse] = true
+		}
+	}
+	return a, nil
+}
+
+func upperKeys(m map[string]string) map[string]string {
+	upper := make(map[string]string, len(m))
+	for k, v := range m {
+		upper[strings.ToUpper(strings.TrimSpace(k))] = v
+	}
+	return upper
+}
+
+// writeDigest writes the digest of one TSE, records being the TSE's rows by
+// sheet: a summary of the TSE's KPIs, then a sheet per report.
+func (g *DigestGenerator) writeDigest(ctx context.Context, outputPath string, headers map[string][]string, records map[string][]map[string]string) error {
+	result := output.NewResult()
+	f := result.Workbook
+	if err := f.SetSheetName("Sheet1", summarySheet); err != nil {
+		return err
+	}
+	if err := writeKPIs(result, records); err != nil {
+		return err
+	}
+	for _, digest := range digestSheets {
+		if _, ok := records[digest.sheet]; !ok {
+			continue
+		}
+		if _, err := f.NewSheet(digest.sheet); err != nil {
+			return fmt.Errorf("error creating new sheet: %w", err)
+		}
+		// Columns left empty for the TSE, such as the models none of
+		// its retailers is out of, are left out.
+		used := headers[digest.sheet]
+		if len(records[digest.sheet]) > 0 {
+			used = nil
+		}
+		for _, header := range headers[digest.sheet] {
+			for _, record := range records[digest.sheet] {
+				if record[header] != "" {
+					used = append(used, header)
+					break
+				}
+			}
+		}
+		section, _ := packSectionOf(digest.sheet)
+		table, values := packTable(section, used, records[digest.sheet], false)
+		if err := result.Add(digest.sheet, &table, values); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+	return output.Save(ctx, result, outputPath)
+}
@@ -1,31 +1,183 @@
 package report
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"slices"
 	"viking-reports/internal/config"
+	"viking-reports/internal/repository"
+	"viking-reports/pkg/excel"
 )
 
 type ReportGenerator interface {
-	Generate() error
-}
-
-func NewReportGenerator(reportType string, cfg *config.Config) (ReportGenerator, error) {
-	switch reportType {
-	case "cogs":
-		return NewCOGSReportGenerator(cfg), nil
-	case "credit":
-		return NewCreditReportGenerator(cfg), nil
-	case "growth":
-		return NewGrowthReportGenerator(cfg), nil
-	case "pricelist":
-		return NewPriceListGenerator(cfg), nil
-	case "salestarget":
-		return NewSalesTargetGenerator(cfg), nil
-	case "zso":
-		return NewZSOReportGenerator(cfg), nil
-	case "ranorms":
-		return NewRANormsReportGenerator(cfg), nil
-	default:
+	Generate(ctx context.Context) error
+}
+
+// Repositories bundles the data sources report generators read from.
+type Repositories struct {
+	TSEMapping   repository.TSEMappingRepository
+	ProductPrice repository.ProductPriceRepository
+	Inventory    repository.InventoryRepository
+	Credit       repository.CreditRepository
+	Debit        repository.DebitRepository
+	Sales        repository.SalesRepository
+	PriceList    repository.PriceListRepository
+	SalesTarget  repository.SalesTargetRepository
+}
+
+// NewExcelRepositories wires the Excel-backed repositories for the input files
+// named in cfg. No file is opened until a generator asks for its data.
+func NewExcelRepositories(cfg *config.Config) Repositories {
+	tseMappingRepo := repository.NewExcelTSEMappingRepository(cfg.CommonFiles.TSEMapping)
+	priceRepo := repository.NewExcelProductPriceRepository(cfg.CommonFiles.PriceList)
+
+	return Repositories{
+		TSEMapping:   tseMappingRepo,
+		ProductPrice: priceRepo,
+		Inventory:    repository.NewExcelInventoryRepository(cfg.ReportFiles.InventoryReport, priceRepo, tseMappingRepo, cfg.OutputDir),
+		Credit:       repository.NewExcelCreditRepository(cfg.ReportFiles.CreditReport.Bills),
+		Debit:        repository.NewExcelDebitRepository(cfg.ReportFiles.DebitReport.Debits),
+		Sales:        repository.NewExcelSalesRepository(),
+		PriceList:    repository.NewExcelPriceListRepository(cfg.ReportFiles.PriceListFile, cfg.ReportFiles.InventoryReport),
+		SalesTarget:  repository.NewExcelSalesTargetRepository(),
+	}
+}
+
+func (r Repositories) all() []interface{} {
+	return []interface{}{r.TSEMapping, r.ProductPrice, r.Inventory, r.Credit, r.Debit, r.Sales, r.PriceList, r.SalesTarget}
+}
+
+// setLogger hands logger to every repository that logs, so that their
+// records name the report they were read for.
+func (r Repositories) setLogger(logger *slog.Logger) {
+	for _, repo := range r.all() {
+		if l, ok := repo.(interface{ SetLogger(*slog.Logger) }); ok {
+			l.SetLogger(logger)
+		}
+	}
+}
+
+// setCache hands cache to every repository that can keep its parsed records
+// in one.
+func (r Repositories) setCache(cache *repository.Cache) {
+	for _, repo := range r.all() {
+		if c, ok := repo.(interface{ SetCache(*repository.Cache) }); ok {
+			c.SetCache(cache)
+		}
+	}
+}
+
+type options struct {
+	repos   Repositories
+	logger  *slog.Logger
+	summary *Summary
+	cache   *repository.Cache
+}
+
+// Option customises how a report generator is built.
+type Option func(*options)
+
+// WithRepositories replaces the default Excel repositories with the non-nil
+// fields of repos, e.g. the in-memory repositories in tests.
+func WithRepositories(repos Repositories) Option {
+	return func(o *options) {
+		if repos.TSEMapping != nil {
+			o.repos.TSEMapping = repos.TSEMapping
+		}
+		if repos.ProductPrice != nil {
+			o.repos.ProductPrice = repos.ProductPrice
+		}
+		if repos.Inventory != nil {
+			o.repos.Inventory = repos.Inventory
+		}
+		if repos.Credit != nil {
+			o.repos.Credit = repos.Credit
+		}
+		if repos.Debit != nil {
+			o.repos.Debit = repos.Debit
+		}
+		if repos.Sales != nil {
+			o.repos.Sales = repos.Sales
+		}
+		if repos.PriceList != nil {
+			o.repos.PriceList = repos.PriceList
+		}
+		if repos.SalesTarget != nil {
+			o.repos.SalesTarget = repos.SalesTarget
+		}
+	}
+}
+
+// WithLogger sets the logger of the generator and its repositories. It
+// defaults to slog.Default().
+func WithLogger(logger *slog.Logger) Option {
+	return func(o *options) {
+		o.logger = logger
+	}
+}
+
+// WithSummary records the outcome of each unit of the report, e.g. each
+// TSE's workbook, in summary.
+func WithSummary(summary *Summary) Option {
+	return func(o *options) {
+		o.summary = summary
+	}
+}
+
+// WithCache keeps the records the repositories parse from their input files
+// in cache, and reuses the records of unchanged files from earlier runs.
+func WithCache(cache *repository.Cache) Option {
+	return func(o *options) {
+		o.cache = cache
+	}
+}
+
+func newOptions(cfg *config.Config, report string, opts []Option) options {
+	o := options{repos: NewExcelRepositories(cfg), logger: slog.Default(), summary: &Summary{}}
+	for _, opt := range opts {
+		opt(&o)
+	}
+	o.logger = o.logger.With("report", report)
+	o.repos.setLogger(o.logger)
+	if o.cache != nil {
+		o.repos.setCache(o.cache)
+	}
+	return o
+}
+
+// NewReportGenerator builds the generator of a registered report.
+func NewReportGenerator(reportType string, cfg *config.Config, opts ...Option) (ReportGenerator, error) {
+	def, ok := Lookup(reportType)
+	if !ok {
 		return nil, fmt.Errorf("unknown report type: %s", reportType)
 	}
+	return def.New(cfg, opts...), nil
+}
+
+// chartPlacement returns where the configuration places the charts of
+// report, sized width by height unless it sets their size, and false if
+// the report's charts are turned off.
+func chartPlacement(cfg *config.Config, report string, width, height uint) (excel.Placement, bool) {
+	chart := cfg.Charts[report]
+	if chart.Off {
+		return excel.Placement{}, false
+	}
+	at := excel.Placement{Sheet: chart.Sheet, Cell: chart.Cell, Width: width, Height: height}
+	if chart.Width > 0 {
+		at.Width = chart.Width
+	}
+	if chart.Height > 0 {
+		at.Height = chart.Height
+	}
+	return at, true
+}
+
+// pivotSheet is the sheet of the pivot table of the reports with one.
+const pivotSheet = "Pivot"
+
+// wantsPivot reports whether the configuration adds a pivot sheet to the
+// workbooks of report.
+func wantsPivot(cfg *config.Config, report string) bool {
+	return slices.Contains(cfg.Pivots, report)
 }
@@ -0,0 +1,243 @@
+package report
+
+import (
+	"archive/zip"
+	"context"
+	"flag"
+	"fmt"
+	"html"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden snapshots in testdata/golden")
+
+// TestReportsGolden runs every report against the workbooks built by
+// writeInputs and compares the generated workbooks with the snapshots in
+// testdata/golden. Run `go test ./internal/report -update` after an intended
+// change to the output and review the snapshot diff.
+func TestReportsGolden(t *testing.T) {
+	for _, tc := range []struct {
+		report string
+		folder string
+	}{
+		{"credit", "credit_reports"},
+		{"cogs", "inventory_report"},
+		{"growth", "growth_report"},
+		{"pricelist", "price_list"},
+		{"salestarget", "sales_report"},
+		{"zso", "zso_report"},
+		{"ranorms", "ranorms_report"},
+		{"pack", "daily_pack"},
+		{"digest", "tse_digest"},
+	} {
+		t.Run(tc.report, func(t *testing.T) {
+			// Each report runs on inputs and in an output folder of its own,
+			// after the reports whose workbooks it reads, such as credit for
+			// COGS, so that it can run alone and in any order.
+			cfg := writeInputs(t)
+			plan, err := Plan(tc.report)
+			if err != nil {
+				t.Fatalf("Plan: %v", err)
+			}
+			for _, def := range plan {
+				if err := def.New(cfg).Generate(context.Background()); err != nil {
+					t.Fatalf("Generate %s: %v", def.Name, err)
+				}
+			}
+
+			dirs, _ := filepath.Glob(filepath.Join(cfg.OutputDir, tc.folder+"_*"))
+			if len(dirs) != 1 {
+				t.Fatalf("expected one %s_* output folder, found %v", tc.folder, dirs)
+			}
+			compareGolden(t, tc.report, snapshotFolder(t, dirs[0]))
+		})
+	}
+}
+
+// snapshotFolder renders every workbook in dir as text: sheet names, the raw
+// cell values of each row, or their formulas, the fill colour of highlighted
+// cells, the conditional formats, and the charts and pivot tables.
+func snapshotFolder(t *testing.T, dir string) string {
+	t.Helper()
+	files, err := filepath.Glob(filepath.Join(dir, "*.xlsx"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sort.Strings(files)
+
+	var b strings.Builder
+	for _, file := range files {
+		fmt.Fprintf(&b, "== %s\n", filepath.Base(file))
+		b.WriteString(snapshotWorkbook(t, file))
+		b.WriteString(snapshotParts(t, file))
+	}
+	return b.String()
+}
+
+// chartRef matches the cell references of a chart's series; pivotPart
+// matches the source, location and data fields of a pivot table.
+var (
+	chartRef  = regexp.MustCompile(`<f>([^<]*)</f>`)
+	pivotPart = regexp.MustCompile(`<(worksheetSource|location|dataField) (?:ref|name)="([^"]*)"(?: sheet="([^"]*)")?`)
+)
+
+// snapshotParts renders the charts and pivot tables of the workbook at path,
+// which excelize cannot read back, as the cells each chart references and
+// the source, location and data fields of each pivot.
+func snapshotParts(t *testing.T, path string) string {
+	t.Helper()
+	r, err := zip.OpenReader(path)
+	if err != nil {
+		t.Fatalf("opening %s: %v", path, err)
+	}
+	defer r.Close()
+
+	var b strings.Builder
+	for _, file := range r.File {
+		var re *regexp.Regexp
+		switch {
+		case strings.HasPrefix(file.Name, "xl/charts/chart"):
+			re = chartRef
+		case strings.HasPrefix(file.Name, "xl/pivotCache/pivotCacheDefinition"),
+			strings.HasPrefix(file.Name, "xl/pivotTables/pivotTable"):
+			re = pivotPart
+		default:
+			continue
+		}
+		rc, err := file.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var parts []string
+		for _, m := range re.FindAllStringSubmatch(string(data), -1) {
+			part := strings.Join(m[1:], " ")
+			if re == chartRef {
+				part = m[1]
+			}
+			parts = append(parts, html.UnescapeString(strings.TrimSpace(part)))
+		}
+		fmt.Fprintf(&b, "%s: %s\n", strings.TrimPrefix(file.Name, "xl/"), strings.Join(parts, " | "))
+	}
+	return b.String()
+}
+
+func snapshotWorkbook(t *testing.T, path string) string {
+	t.Helper()
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatalf("opening %s: %v", path, err)
+	}
+	defer f.Close()
+
+	var b strings.Builder
+	for _, sheet := range f.GetSheetList() {
+		fmt.Fprintf(&b, "-- sheet %q\n", sheet)
+		rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+		if err != nil {
+			t.Fatalf("reading %s/%s: %v", path, sheet, err)
+		}
+
+		var highlighted []string
+		for r, row := range rows {
+			for c := range row {
+				cell, _ := excelize.CoordinatesToCellName(c+1, r+1)
+				if formula, _ := f.GetCellFormula(sheet, cell); formula != "" {
+					row[c] = "=" + formula
+				}
+			}
+			if strings.Join(row, "") != "" {
+				fmt.Fprintf(&b, "%d: %s\n", r+1, strings.Join(row, " | "))
+			}
+			for c := range row {
+				cell, _ := excelize.CoordinatesToCellName(c+1, r+1)
+				if fill := cellFill(f, sheet, cell); fill != "" {
+					highlighted = append(highlighted, cell+"="+fill)
+				}
+			}
+		}
+		if len(highlighted) > 0 {
+			fmt.Fprintf(&b, "highlighted: %s\n", strings.Join(highlighted, " "))
+		}
+
+		formats, err := f.GetConditionalFormats(sheet)
+		if err != nil {
+			t.Fatalf("reading conditional formats of %s/%s: %v", path, sheet, err)
+		}
+		ranges := make([]string, 0, len(formats))
+		for ref := range formats {
+			ranges = append(ranges, ref)
+		}
+		sort.Strings(ranges)
+		for _, ref := range ranges {
+			for _, format := range formats[ref] {
+				style, err := f.GetConditionalStyle(format.Format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				fmt.Fprintf(&b, "conditional: %s %s => %s\n", ref, format.Criteria, strings.Join(style.Fill.Color, ","))
+			}
+		}
+	}
+	return b.String()
+}
+
+// cellFill returns the solid fill colour of a cell, or "" when it has none.
+func cellFill(f *excelize.File, sheet, cell string) string {
+	styleID, err := f.GetCellStyle(sheet, cell)
+	if err != nil || styleID == 0 {
+		return ""
+	}
+	style, err := f.GetStyle(styleID)
+	if err != nil || style.Fill.Pattern != 1 || len(style.Fill.Color) == 0 {
+		return ""
+	}
+	return strings.ToUpper(strings.Join(style.Fill.Color, ","))
+}
+
+func compareGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("reading golden file (run with -update to create it): %v", err)
+	}
+	if got == string(want) {
+		return
+	}
+	gotLines, wantLines := strings.Split(got, "\n"), strings.Split(string(want), "\n")
+	for i := 0; i < len(gotLines) || i < len(wantLines); i++ {
+		var g, w string
+		if i < len(gotLines) {
+			g = gotLines[i]
+		}
+		if i < len(wantLines) {
+			w = wantLines[i]
+		}
+		if g != w {
+			t.Fatalf("%s differs from %s at line %d:\n got: %s\nwant: %s\n(run with -update to accept the new output)", name, path, i+1, g, w)
+		}
+	}
+}
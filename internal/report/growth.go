@@ -1,7 +1,9 @@
 package report
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
@@ -9,59 +11,78 @@ import (
 	"viking-reports/internal/repository"
 	"viking-reports/internal/utils"
 	"viking-reports/pkg/excel"
-
-	"github.com/xuri/excelize/v2"
+	"viking-reports/pkg/output"
 )
 
+func init() {
+	Register(Definition{
+		Name:        "growth",
+		Description: "MTD against LMTD sell-out and sell-through growth per retailer, one workbook per TSE",
+		Inputs: func(cfg *config.Config) []Input {
+			return []Input{
+				{"MTD-SO", cfg.ReportFiles.GrowthReport.MTDSO, repository.SalesExportSchema},
+				{"LMTD-SO", cfg.ReportFiles.GrowthReport.LMTDSO, repository.SalesExportSchema},
+				{"MTD-ST", cfg.ReportFiles.GrowthReport.MTDST, repository.SalesExportSchema},
+				{"LMTD-ST", cfg.ReportFiles.GrowthReport.LMTDST, repository.SalesExportSchema},
+				{"Retailer Metadata", cfg.CommonFiles.TSEMapping, repository.RetailerMetadataSchema},
+			}
+		},
+		PerTSE: true,
+		New: func(cfg *config.Config, opts ...Option) ReportGenerator {
+			return NewGrowthReportGenerator(cfg, opts...)
+		},
+	})
+}
+
 type GrowthReportGenerator struct {
 	cfg            *config.Config
 	salesRepo      repository.SalesRepository
 	tseMappingRepo repository.TSEMappingRepository
+	logger         *slog.Logger
+	summary        *Summary
 }
 
-func NewGrowthReportGenerator(cfg *config.Config) *GrowthReportGenerator {
+func NewGrowthReportGenerator(cfg *config.Config, opts ...Option) *GrowthReportGenerator {
+	o := newOptions(cfg, "growth", opts)
 	return &GrowthReportGenerator{
 		cfg:            cfg,
-		salesRepo:      repository.NewExcelSalesRepository(), // Use one of the file paths
-		tseMappingRepo: repository.NewExcelTSEMappingRepository(cfg.CommonFiles.TSEMapping),
+		salesRepo:      o.repos.Sales,
+		tseMappingRepo: o.repos.TSEMapping,
+		logger:         o.logger,
+		summary:        o.summary,
 	}
 }
 
-func (g *GrowthReportGenerator) Generate() error {
-	fmt.Println("Generating Growth report...")
+func (g *GrowthReportGenerator) Generate(ctx context.Context) error {
+	g.logger.Info("generating growth report")
 
-	fmt.Print("Input: Fetching month to today's date sell out report")
-	mtdSOData, err := g.salesRepo.GetSales(g.cfg.ReportFiles.GrowthReport.MTDSO)
+	mtdSOData, err := g.salesRepo.GetSales(ctx, g.cfg.ReportFiles.GrowthReport.MTDSO)
 	if err != nil {
 		return fmt.Errorf("error reading MTD SO data: %w", err)
 	}
 
-	fmt.Print("Input: Fetching last month to today's day sell out report")
-	lmtdSOData, err := g.salesRepo.GetSales(g.cfg.ReportFiles.GrowthReport.LMTDSO)
+	lmtdSOData, err := g.salesRepo.GetSales(ctx, g.cfg.ReportFiles.GrowthReport.LMTDSO)
 	if err != nil {
 		return fmt.Errorf("error reading LMTD SO data: %w", err)
 	}
 
-	fmt.Print("Input: Fetching month to today's date sell through report")
-	mtdSTData, err := g.salesRepo.GetSales(g.cfg.ReportFiles.GrowthReport.MTDST)
+	mtdSTData, err := g.salesRepo.GetSales(ctx, g.cfg.ReportFiles.GrowthReport.MTDST)
 	if err != nil {
 		return fmt.Errorf("error reading MTD ST data: %w", err)
 	}
 
-	fmt.Print("Input: Fetching last month to today's day sell through report")
-	lmtdSTData, err := g.salesRepo.GetSales(g.cfg.ReportFiles.GrowthReport.LMTDST)
+	lmtdSTData, err := g.salesRepo.GetSales(ctx, g.cfg.ReportFiles.GrowthReport.LMTDST)
 	if err != nil {
 		return fmt.Errorf("error reading LMTD ST data: %w", err)
 	}
 
-	tseMapping, err := g.tseMappingRepo.GetRetailerCodeToTSEMap()
+	tseMapping, err := g.tseMappingRepo.GetRetailerCodeToTSEMap(ctx)
 	if err != nil {
 		return fmt.Errorf("error reading TSE mapping: %w", err)
 	}
 
-	fmt.Println("Start computation of growth report.")
 	report := g.generateGrowthReport(mtdSOData, lmtdSOData, mtdSTData, lmtdSTData)
-	fmt.Println("Growth report computed for all retailers.")
+	g.logger.Info("growth computed for all retailers", "retailers", len(report))
 
 	// New: Aggregate report by TSE
 	tseReports := make(map[string][]repository.GrowthData)
@@ -70,16 +91,23 @@ func (g *GrowthReportGenerator) Generate() error {
 		tseReports[tse] = append(tseReports[tse], entry)
 	}
 
-	// New: Write separate reports for each TSE
+	// Write separate reports for each TSE. A TSE whose workbook fails does
+	// not stop the others.
 	outputDir := utils.GenerateOutputPath(g.cfg.OutputDir, "growth_report")
-	for tse, reportData := range tseReports {
-		fmt.Println("Write growth report for ", tse)
-		if err := g.writeGrowthReport(outputDir, tse, reportData, tseMapping); err != nil {
-			return fmt.Errorf("error writing growth report for TSE %s: %w", tse, err)
-		}
+	tses := make([]string, 0, len(tseReports))
+	for tse := range tseReports {
+		tses = append(tses, tse)
+	}
+	sort.Strings(tses)
+	err = writeUnits(ctx, g.summary, g.logger, "growth", tses, func(ctx context.Context, tse string) (string, error) {
+		g.logger.Debug("writing growth report", "tse", tse, "retailers", len(tseReports[tse]))
+		return g.writeGrowthReport(ctx, outputDir, tse, tseReports[tse], tseMapping)
+	})
+	if err != nil {
+		return err
 	}
 
-	fmt.Printf("Growth report generated successfully: %s\n", outputDir)
+	g.logger.Info("growth report generated", "output", outputDir)
 	return nil
 }
 
@@ -107,6 +135,9 @@ func (g *GrowthReportGenerator) generateGrowthReport(mtdSOData, lmtdSOData, mtdS
 	}
 
 	sort.Slice(report, func(i, j int) bool {
+		if report[i].GrowthSOPct == report[j].GrowthSOPct {
+			return report[i].DealerCode < report[j].DealerCode
+		}
 		return report[i].GrowthSOPct > report[j].GrowthSOPct
 	})
 	return report
@@ -119,112 +150,100 @@ func (g *GrowthReportGenerator) getOrCreateSellData(data map[string]*repository.
 	return &repository.SellData{DealerCode: dealerCode, DealerName: "", MTDS: 0}
 }
 
-func (g *GrowthReportGenerator) writeGrowthReport(outputDir string, tse string, report []repository.GrowthData, tseMapping map[string]string) error {
-	f := excel.NewFile()
+// writeGrowthReport writes the workbook of one TSE and returns its path.
+func (g *GrowthReportGenerator) writeGrowthReport(ctx context.Context, outputDir string, tse string, report []repository.GrowthData, tseMapping map[string]string) (string, error) {
+	result := output.NewResult()
+	f := result.Workbook
 	sheetName := "Growth Report"
 
 	// Create a new sheet
 	if _, err := f.NewSheet(sheetName); err != nil {
-		return fmt.Errorf("error creating new sheet: %w", err)
+		return "", fmt.Errorf("error creating new sheet: %w", err)
 	}
 	f.DeleteSheet("Sheet1")
 
 	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
-		return fmt.Errorf("error creating output directory: %w", err)
+		return "", fmt.Errorf("error creating output directory: %w", err)
 	}
 
-	headers := []string{"TSE", "Dealer Code", "Dealer Name", "MTD SO", "LMTD SO", "Growth SO %", "MTD ST", "LMTD ST", "Growth ST %"}
-	if err := excel.WriteHeaders(f, sheetName, headers); err != nil {
-		return err
+	growth := g.growthRules()
+	table := excel.Table{
+		Sheet: sheetName,
+		Columns: []excel.Column{
+			{Header: "TSE"},
+			{Header: "Dealer Code"},
+			{Header: "Dealer Name"},
+			{Header: "MTD SO", Type: excel.Number, Sum: true},
+			{Header: "LMTD SO", Type: excel.Number, Sum: true},
+			{Header: "Growth SO %", Type: excel.Percent, Rules: growth},
+			{Header: "MTD ST", Type: excel.Number, Sum: true},
+			{Header: "LMTD ST", Type: excel.Number, Sum: true},
+			{Header: "Growth ST %", Type: excel.Percent, Rules: growth},
+		},
+		FreezeHeader: true,
+		AutoFilter:   true,
+		Totals:       true,
 	}
 
 	// New: Sort report by Growth SO % with negatives first
 	sort.Slice(report, func(i, j int) bool {
+		if report[i].GrowthSOPct == report[j].GrowthSOPct {
+			return report[i].DealerCode < report[j].DealerCode
+		}
 		return report[i].GrowthSOPct < report[j].GrowthSOPct // Negatives first
 	})
 
-	row := 2
+	rows := make([][]interface{}, 0, len(report))
 	for _, entry := range report {
-		cellData := []interface{}{
+		rows = append(rows, []interface{}{
 			tseMapping[entry.DealerCode],
 			entry.DealerCode,
 			entry.DealerName,
 			entry.MTDSO,
 			entry.LMTDSO,
-			fmt.Sprintf("%d%%", entry.GrowthSOPct),
+			float64(entry.GrowthSOPct) / 100,
 			entry.MTDST,
 			entry.LMTDST,
-			fmt.Sprintf("%d%%", entry.GrowthSTPct),
+			float64(entry.GrowthSTPct) / 100,
+		})
+	}
+	if err := result.Add(sheetName, &table, rows); err != nil {
+		return "", err
+	}
+	// Four bars per dealer, wide enough to keep the dealers' names legible.
+	if at, ok := chartPlacement(g.cfg, "growth", uint(max(480, 48*len(rows))), 320); ok {
+		chart := excel.Chart{
+			Title:      tse + ": MTD vs LMTD",
+			Categories: "Dealer Name",
+			Series:     []string{"MTD SO", "LMTD SO", "MTD ST", "LMTD ST"},
 		}
-		if err := excel.WriteRow(f, sheetName, row, cellData); err != nil {
-			return err
+		if err := table.AddChart(f, len(rows), chart, at); err != nil {
+			return "", err
 		}
-
-		//Apply all kinds of styles
-		// Create a new style that inherits from numberStyle and adds background fill
-		redStyle, _ := f.NewStyle(&excelize.Style{
-			Fill: excelize.Fill{Type: "pattern", Color: []string{"FF9999"}, Pattern: 1}, // Light red background
-			Border: []excelize.Border{
-				{Type: "left", Color: "000000", Style: 1},
-				{Type: "top", Color: "000000", Style: 1},
-				{Type: "bottom", Color: "000000", Style: 1},
-				{Type: "right", Color: "000000", Style: 1},
-			},
-			Font: &excelize.Font{
-				Bold: true, // Set text to bold
-			},
-		})
-		// Create a new style that inherits from numberStyle and adds background fill
-		orangeStyle, _ := f.NewStyle(&excelize.Style{
-			Fill: excelize.Fill{Type: "pattern", Color: []string{"FFFF00"}, Pattern: 1}, // Light red background
-			Border: []excelize.Border{
-				{Type: "left", Color: "000000", Style: 1},
-				{Type: "top", Color: "000000", Style: 1},
-				{Type: "bottom", Color: "000000", Style: 1},
-				{Type: "right", Color: "000000", Style: 1},
-			},
-			Font: &excelize.Font{
-				Bold: true, // Set text to bold
-			},
-		})
-		// Create a new style that inherits from numberStyle and adds background fill
-		greenStyle, _ := f.NewStyle(&excelize.Style{
-			Fill: excelize.Fill{Type: "pattern", Color: []string{"00FF00"}, Pattern: 1}, // Light red background
-			Border: []excelize.Border{
-				{Type: "left", Color: "000000", Style: 1},
-				{Type: "top", Color: "000000", Style: 1},
-				{Type: "bottom", Color: "000000", Style: 1},
-				{Type: "right", Color: "000000", Style: 1},
-			},
-			Font: &excelize.Font{
-				Bold: true, // Set text to bold
-			},
-		})
-
-		// Apply background color based on GrowthSOPct
-		if entry.GrowthSOPct < -60 {
-			f.SetCellStyle(sheetName, fmt.Sprintf("F%d", row), fmt.Sprintf("F%d", row), redStyle) // Assuming redStyle is defined
-		} else if entry.GrowthSOPct >= -59 && entry.GrowthSOPct < 0 {
-			f.SetCellStyle(sheetName, fmt.Sprintf("F%d", row), fmt.Sprintf("F%d", row), orangeStyle) // Assuming orangeStyle is defined
-		} else if entry.GrowthSOPct > 0 {
-			f.SetCellStyle(sheetName, fmt.Sprintf("F%d", row), fmt.Sprintf("F%d", row), greenStyle) // Assuming greenStyle is defined
+	}
+	if wantsPivot(g.cfg, "growth") {
+		pivot := excel.Pivot{
+			Sheet: pivotSheet,
+			Rows:  []string{"TSE", "Dealer Name"},
+			Data:  []string{"MTD SO", "LMTD SO", "MTD ST", "LMTD ST"},
 		}
-
-		// Apply background color based on GrowthSTPct
-		if entry.GrowthSTPct < -60 {
-			f.SetCellStyle(sheetName, fmt.Sprintf("I%d", row), fmt.Sprintf("I%d", row), redStyle) // Assuming redStyle is defined
-		} else if entry.GrowthSTPct >= -59 && entry.GrowthSTPct < 0 {
-			f.SetCellStyle(sheetName, fmt.Sprintf("I%d", row), fmt.Sprintf("I%d", row), orangeStyle) // Assuming orangeStyle is defined
-		} else if entry.GrowthSTPct > 0 {
-			f.SetCellStyle(sheetName, fmt.Sprintf("I%d", row), fmt.Sprintf("I%d", row), greenStyle) // Assuming greenStyle is defined
+		if err := table.AddPivot(f, len(rows), pivot); err != nil {
+			return "", err
 		}
-
-		row++
 	}
 
 	// Ensure the output path has a valid extension
 	fileName := fmt.Sprintf("%s_growth_report.xlsx", tse) // New: Use TSE name in file name
 	outputPath := filepath.Join(outputDir, fileName)
-	excel.AdjustColumnWidths(f, sheetName)
-	return f.SaveAs(outputPath)
+	return outputPath, output.Save(ctx, result, outputPath)
+}
+
+// growthRules colour growth percentages: red below the configured decline,
+// yellow for smaller declines and green for growth.
+func (g *GrowthReportGenerator) growthRules() []excel.Rule {
+	return []excel.Rule{
+		{Op: "<", Value: g.cfg.Highlights.GrowthDecline / 100, Highlight: excel.Highlight{Fill: "FF9999", Bold: true}},
+		{Op: "<", Value: 0, Highlight: excel.Highlight{Fill: "FFFF00", Bold: true}},
+		{Op: ">", Value: 0, Highlight: excel.Highlight{Fill: "00FF00", Bold: true}},
+	}
 }
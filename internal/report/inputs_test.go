@@ -0,0 +1,162 @@
+package report
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+	"viking-reports/internal/config"
+	"viking-reports/internal/fixtures"
+	"viking-reports/internal/repository"
+)
+
+// writeInputs builds a small but complete set of input workbooks in a
+// temporary data directory and returns a config that points at them.
+func writeInputs(t *testing.T) *config.Config {
+	t.Helper()
+	dataDir := t.TempDir()
+	outputDir := t.TempDir()
+	path := func(name string) string { return filepath.Join(dataDir, name) }
+
+	cfg := &config.Config{
+		DataDir:    dataDir,
+		OutputDir:  outputDir,
+		Highlights: config.Highlights{GrowthDecline: -60},
+		Pivots:     []string{"growth", "cogs", "zso", "credit"},
+		CommonFiles: config.CommonFiles{
+			DealerInfo: path("Retailer Metadata.xlsx"),
+			TSEMapping: path("Retailer Metadata.xlsx"),
+			PriceList:  path("ProductPriceList.xlsx"),
+		},
+		ReportFiles: config.ReportFiles{
+			CreditReport: config.CreditReportFiles{Bills: path("Bills.xlsx")},
+			DebitReport:  config.DebitReportFiles{Debits: path("Received.xlsx")},
+			GrowthReport: config.GrowthReportFiles{
+				MTDSO:  path("MTD-SO.xlsx"),
+				LMTDSO: path("LMTD-SO.xlsx"),
+				L2MSO:  path("L2M-SO.xlsx"),
+				MTDST:  path("MTD-ST.xlsx"),
+				LMTDST: path("LMTD-ST.xlsx"),
+			},
+			InventoryReport: path("DealerInventory.xlsx"),
+			PriceListFile:   path("ZD PRICE LIST.xlsx"),
+			SalesReport:     path("Sales.xlsx"),
+		},
+	}
+
+	must := func(err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("writing inputs: %v", err)
+		}
+	}
+
+	must(fixtures.WriteRetailerMetadata(cfg.CommonFiles.TSEMapping, []repository.Retailer{
+		{Code: "R001", Name: "Alpha Mobiles", TallyName: "ALPHA MOBILES", TSE: "Krishna", Type: "RA", CountOfRA: "2"},
+		{Code: "R002", Name: "Bharat Telecom", TallyName: "BHARAT TELECOM", TSE: "Krishna", Type: "RA", CountOfRA: "1"},
+		{Code: "R003", Name: "City Cellular", TallyName: "CITY CELLULAR", TSE: "Sathish"},
+		{Code: "R004", Name: "Deccan Phones", TallyName: "DECCAN PHONES", TSE: "Sathish", Type: "RA", CountOfRA: "x"},
+		{Code: "R005", Name: "Elite Mobiles", TallyName: "ELITE MOBILES", TSE: "Harish"},
+		{Code: "R006", Name: "Fortune Comm", TallyName: "FORTUNE COMM"},
+	}))
+
+	must(fixtures.WriteBills(cfg.ReportFiles.CreditReport.Bills, []repository.Bill{
+		{Date: "01-Sep-24", RefNo: "VK/101", RetailerName: "ALPHA MOBILES", PendingAmount: 12000, DueDate: "08-Sep-24", AgeOfBill: 3},
+		{Date: "02-Sep-24", RefNo: "VK/102", RetailerName: "ALPHA MOBILES", PendingAmount: 8000, DueDate: "09-Sep-24", AgeOfBill: 25},
+		{Date: "03-Sep-24", RefNo: "VK/103", RetailerName: "BHARAT TELECOM", PendingAmount: 4500.5, DueDate: "10-Sep-24", AgeOfBill: 10},
+		{Date: "04-Sep-24", RefNo: "VK/104", RetailerName: "CITY CELLULAR", PendingAmount: 30000, DueDate: "11-Sep-24", AgeOfBill: 45},
+		{Date: "05-Sep-24", RefNo: "VK/105", RetailerName: "CITY CELLULAR", PendingAmount: 2000, DueDate: "12-Sep-24", AgeOfBill: 17},
+		{Date: "06-Sep-24", RefNo: "VK/106", RetailerName: "ELITE MOBILES", PendingAmount: 9999, DueDate: "13-Sep-24", AgeOfBill: 7},
+		{Date: "07-Sep-24", RefNo: "VK/107", RetailerName: "FORTUNE COMM", PendingAmount: 1500, DueDate: "14-Sep-24", AgeOfBill: 31},
+		{Date: "08-Sep-24", RefNo: "VK/108", RetailerName: "GHOST TRADERS", PendingAmount: 700, DueDate: "15-Sep-24", AgeOfBill: 14},
+	}))
+
+	must(fixtures.WriteReceived(cfg.ReportFiles.DebitReport.Debits, []repository.Receipt{
+		{PartyName: "ALPHA MOBILES", Amount: 5000},
+		{PartyName: "CITY CELLULAR", Amount: 2500.5},
+		{PartyName: "ALPHA MOBILES", Amount: 1000},
+	}))
+
+	// Sales are dated on the first of the month so that they always fall
+	// inside the month-to-date window.
+	now := time.Now()
+	thisMonth := time.Date(now.Year(), now.Month(), 1, 10, 30, 0, 0, time.Local).Format("2006-01-02 15:04:05")
+	lastMonth := time.Date(now.Year(), now.Month()-1, 1, 10, 30, 0, 0, time.Local).Format("2006-01-02 15:04:05")
+	sales := func(when string, counts ...interface{}) []repository.SaleEvent {
+		var events []repository.SaleEvent
+		for i := 0; i < len(counts); i += 3 {
+			for n := 0; n < counts[i+2].(int); n++ {
+				events = append(events, repository.SaleEvent{
+					DealerCode:   counts[i].(string),
+					DealerName:   counts[i+1].(string),
+					ActivateTime: when,
+					SPUName:      "realme C61",
+					ProductType:  "mobile phone",
+				})
+			}
+		}
+		return events
+	}
+	must(fixtures.WriteSaleEvents(cfg.ReportFiles.GrowthReport.MTDSO, fixtures.SellOutLayout, sales(thisMonth,
+		"R001", "Alpha Mobiles", 3, "R002", "Bharat Telecom", 2, "R003", "City Cellular", 1, "R005", "Elite Mobiles", 4)))
+	must(fixtures.WriteSaleEvents(cfg.ReportFiles.GrowthReport.LMTDSO, fixtures.SellOutLayout, sales(lastMonth,
+		"R001", "Alpha Mobiles", 2, "R002", "Bharat Telecom", 2, "R003", "City Cellular", 4, "R004", "Deccan Phones", 2, "R005", "Elite Mobiles", 1)))
+	must(fixtures.WriteSaleEvents(cfg.ReportFiles.GrowthReport.MTDST, fixtures.SellThroughLayout, sales(thisMonth,
+		"R001", "Alpha Mobiles", 5, "R003", "City Cellular", 1)))
+	must(fixtures.WriteSaleEvents(cfg.ReportFiles.GrowthReport.LMTDST, fixtures.SellThroughLayout, sales(lastMonth,
+		"R001", "Alpha Mobiles", 1, "R003", "City Cellular", 3, "R005", "Elite Mobiles", 2)))
+
+	must(fixtures.WriteSaleEvents(cfg.ReportFiles.GrowthReport.L2MSO, fixtures.SellOutLayout, []repository.SaleEvent{
+		{DealerCode: "R001", DealerName: "Alpha Mobiles", ActivateTime: lastMonth, SPUName: "realme C61", ProductType: "mobile phone"},
+		{DealerCode: "R001", DealerName: "Alpha Mobiles", ActivateTime: lastMonth, SPUName: "realme 13 5G", ProductType: "mobile phone"},
+		{DealerCode: "R001", DealerName: "Alpha Mobiles", ActivateTime: lastMonth, SPUName: "realme 13 5G", ProductType: "mobile phone"},
+		{DealerCode: "R002", DealerName: "Bharat Telecom", ActivateTime: lastMonth, SPUName: "realme C63", ProductType: "mobile phone"},
+		{DealerCode: "R003", DealerName: "City Cellular", ActivateTime: lastMonth, SPUName: "realme C61", ProductType: "mobile phone"},
+		{DealerCode: "R005", DealerName: "Elite Mobiles", ActivateTime: lastMonth, SPUName: "realme GT6", ProductType: "mobile phone"},
+		{DealerCode: "R005", DealerName: "Elite Mobiles", ActivateTime: lastMonth, SPUName: "realme Buds T300", ProductType: "accessory"},
+		{DealerCode: "R006", DealerName: "Fortune Comm", ActivateTime: lastMonth, SPUName: "realme P2 Pro", ProductType: "mobile phone"},
+		{DealerCode: "R006", DealerName: "Fortune Comm", ActivateTime: lastMonth, SPUName: "realme Note 60", ProductType: "mobile phone"},
+	}))
+
+	unit := func(code, name, material, spu, color, spec string) repository.InventoryUnit {
+		return repository.InventoryUnit{
+			AreaName: "North Bangalore", DealerCode: code, DealerName: name, MaterialCode: material,
+			SPUName: spu, Color: color, SKUSpec: spec, ProductType: "mobile phone",
+		}
+	}
+	must(fixtures.WriteInventory(cfg.ReportFiles.InventoryReport, []repository.InventoryUnit{
+		unit("R001", "Alpha Mobiles", "6001", "realme C61", "Dark Green", "64GB 4GB"),
+		unit("R001", "Alpha Mobiles", "6001", "realme C61", "Dark Green", "64GB 4GB"),
+		unit("R002", "Bharat Telecom", "6101", "realme 13 5G", "Safari Green", "128GB 8GB"),
+		unit("R003", "City Cellular", "6002", "realme C61", "Safari Green", "64GB 4GB"),
+		unit("R005", "Elite Mobiles", "6201", "realme GT6", "Fluid Silver", "256GB 12GB"),
+		unit("R005", "Elite Mobiles", "6201", "realme GT6", "Fluid Silver", "256GB 12GB"),
+		unit("", "", "6001", "realme C61", "Dark Green", "64GB 4GB"),
+	}))
+
+	must(fixtures.WriteProductPrices(cfg.CommonFiles.PriceList, []fixtures.ProductPrice{
+		{MaterialCode: "6001", NLC: 8999},
+		{MaterialCode: "6002", NLC: 8999},
+		{MaterialCode: "6101", NLC: 15999},
+		{MaterialCode: "6201", NLC: 24999},
+	}))
+
+	must(fixtures.WriteZDPriceList(cfg.ReportFiles.PriceListFile, []fixtures.ZDPriceListEntry{
+		{Type: "SMART PHONE", Model: "C61", Colours: "DARK GREEN/SAFARI GREEN", Variant: "4GB+64GB", DLR: 7500, MOP: 8999, MRP: 9999},
+		{Type: "SMART PHONE", Model: "C61", Colours: "DARK GREEN", Variant: "6GB+128GB", DLR: 8200, MOP: 9999, MRP: 10999},
+		{Type: "SMART PHONE", Model: "REALME C 65 5G", Colours: "SPEED GREEN DARK PURPLE", Variant: "4GB+128GB", DLR: 10500, MOP: 11999, MRP: 13999},
+		{Type: "SMART PHONE", Model: "13 5G", Colours: "SAFARI GREEN MARBLE BLACK", Variant: "8GB+128GB", DLR: 14000, MOP: 15999, MRP: 17999},
+		{Type: "ACCESSORIES", Model: "Buds T300", Colours: "BLACK\nWHITE", Variant: "NA", DLR: 1500, MOP: 1999, MRP: 2499},
+	}))
+
+	must(fixtures.WriteTallySales(cfg.ReportFiles.SalesReport, []repository.SalesData{
+		{DealerCode: "R001", DealerName: "ALPHA MOBILES", ItemName: "SMART PHONE realme C61", MTDS: 1, Value: 8999},
+		{DealerCode: "R001", DealerName: "ALPHA MOBILES", ItemName: "SMART PHONE realme 13 5G", MTDS: 1, Value: 15999},
+		{DealerCode: "R002", DealerName: "BHARAT TELECOM", ItemName: "ACCESSORIES Charger", MTDS: 1, Value: 499},
+		{DealerCode: "R003", DealerName: "CITY CELLULAR", ItemName: "SMART PHONE realme C61", MTDS: 1, Value: 8999},
+		{DealerCode: "R003", DealerName: "CITY CELLULAR", ItemName: "realme Buds T300", MTDS: 1, Value: 1999},
+		{DealerCode: "R005", DealerName: "ELITE MOBILES", ItemName: "Screen Guard", MTDS: 1, Value: 199},
+		{DealerCode: "", DealerName: "CASH", ItemName: "SMART PHONE realme C61", MTDS: 1, Value: 8999},
+	}))
+
+	return cfg
+}
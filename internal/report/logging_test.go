@@ -0,0 +1,54 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+// TestLogRecordsNameSource checks that repository records carry the report
+// they were read for, the repository and the input file.
+func TestLogRecordsNameSource(t *testing.T) {
+	cfg := writeInputs(t)
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug}))
+
+	generator, err := NewReportGenerator("growth", cfg, WithLogger(logger))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := generator.Generate(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	files := make(map[string]bool)
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			t.Fatalf("invalid record %q: %v", line, err)
+		}
+		if record["report"] != "growth" {
+			t.Errorf("record without report: %s", line)
+		}
+		if repo, ok := record["repository"]; ok {
+			files[repo.(string)+" "+record["file"].(string)] = true
+		}
+	}
+	for _, want := range []string{
+		"sales " + cfg.ReportFiles.GrowthReport.MTDSO,
+		"sales " + cfg.ReportFiles.GrowthReport.LMTDST,
+		"tse_mapping " + cfg.CommonFiles.TSEMapping,
+	} {
+		if !files[want] {
+			t.Errorf("no record from %s; got %v", want, files)
+		}
+	}
+}
+
+func discardLogger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(io.Discard, nil))
+}
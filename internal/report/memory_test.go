@@ -0,0 +1,200 @@
+package report
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/xuri/excelize/v2"
+
+	"viking-reports/internal/config"
+	"viking-reports/internal/repository"
+)
+
+// memoryRepositories returns a small data set on the in-memory repositories:
+// two TSEs with one retailer each, plus a retailer without a TSE.
+func memoryRepositories(today time.Time) Repositories {
+	retailers := []repository.Retailer{
+		{Code: "R1", Name: "Alpha Mobiles", TallyName: "ALPHA MOBILES", TSE: "Krishna", Type: "RA", CountOfRA: "2"},
+		{Code: "R2", Name: "Bharat Telecom", TallyName: "BHARAT TELECOM", TSE: "Ravi"},
+		{Code: "R3", Name: "City Cellular", TallyName: "CITY CELLULAR"},
+	}
+	at := func(t time.Time) string { return t.Format("2006-01-02 15:04:05") }
+	sale := func(code, name, spu string, on time.Time) repository.SaleEvent {
+		return repository.SaleEvent{DealerCode: code, DealerName: name, ActivateTime: at(on), SPUName: spu, ProductType: "mobile phone"}
+	}
+	lastMonth := today.AddDate(0, -1, 0)
+	unit := func(code, name, material, spu string) repository.InventoryUnit {
+		return repository.InventoryUnit{DealerCode: code, DealerName: name, MaterialCode: material, SPUName: spu, ProductType: "mobile phone"}
+	}
+
+	return Repositories{
+		TSEMapping:   repository.NewMemoryTSEMappingRepository(retailers),
+		ProductPrice: repository.NewMemoryProductPriceRepository(map[string]float64{"1001": 8000, "1002": 12000}),
+		Inventory: &repository.MemoryInventoryRepository{
+			Units: []repository.InventoryUnit{
+				unit("R1", "Alpha Mobiles", "1001", "realme C61"),
+				unit("R1", "Alpha Mobiles", "1001", "realme C61"),
+				unit("R2", "Bharat Telecom", "1002", "realme C63"),
+			},
+			Prices:     map[string]float64{"1001": 8000, "1002": 12000},
+			TSEMapping: map[string]string{"R1": "Krishna", "R2": "Ravi"},
+			CreditDue:  map[string]float64{"R1": 5000},
+		},
+		Credit: repository.NewMemoryCreditRepository([]repository.Bill{
+			{RetailerName: "ALPHA MOBILES", PendingAmount: 3000, AgeOfBill: 2},
+			{RetailerName: "ALPHA MOBILES", PendingAmount: 2000, AgeOfBill: 25},
+			{RetailerName: "BHARAT TELECOM", PendingAmount: 1500, AgeOfBill: 10},
+			{RetailerName: "CITY CELLULAR", PendingAmount: 700, AgeOfBill: 45},
+		}),
+		Debit: repository.NewMemoryDebitRepository(map[string]float64{"ALPHA MOBILES": 1200}),
+		Sales: &repository.MemorySalesRepository{
+			Events: map[string][]repository.SaleEvent{
+				"mtd-so": {
+					sale("R1", "Alpha Mobiles", "realme C61", today),
+					sale("R1", "Alpha Mobiles", "realme C61", today),
+					sale("R2", "Bharat Telecom", "realme C63", today),
+				},
+				// The sale after today's day of the month is left out.
+				"lmtd-so": {
+					sale("R1", "Alpha Mobiles", "realme C61", lastMonth),
+					sale("R2", "Bharat Telecom", "realme C63", lastMonth.AddDate(0, 0, 1)),
+				},
+				"mtd-st":  {sale("R2", "Bharat Telecom", "realme C63", today)},
+				"lmtd-st": {sale("R2", "Bharat Telecom", "realme C63", lastMonth)},
+				// Alpha Mobiles sold C63s but holds none, Bharat Telecom still does.
+				"l2m-so": {
+					sale("R1", "Alpha Mobiles", "realme C63", lastMonth),
+					sale("R2", "Bharat Telecom", "realme C63", lastMonth),
+				},
+			},
+			Today: today,
+		},
+		PriceList: repository.NewMemoryPriceListRepository([]repository.PriceListRow{
+			{Type: "SMART PHONE", Model: "realme c61", Color: "DARK GREEN", Memory: "4GB", Storage: "64GB", NLC: 7500, Mop: 8999, Mrp: 9999},
+			{Type: "SMART PHONE", Model: "realme c63", Color: "JADE GREEN", Memory: "8GB", Storage: "128GB", NLC: 11000, Mop: 12499, Mrp: 13999},
+		}, map[string]int{"realme c61|dark green|64gb 4gb": 1001}),
+		SalesTarget: repository.NewMemorySalesTargetRepository(map[string][]repository.SalesData{
+			"sales": {
+				{DealerCode: "R1", DealerName: "Alpha Mobiles", MTDS: 2, Value: 16000, ItemName: "SMART PHONE C61"},
+				{DealerCode: "R1", DealerName: "Alpha Mobiles", MTDS: 3, Value: 900, ItemName: "Buds T110"},
+				{DealerName: "Counter sale", MTDS: 1, Value: 500, ItemName: "SMART PHONE C63"},
+			},
+		}),
+	}
+}
+
+// TestReportsOnMemoryRepositories runs each report on the in-memory
+// repositories and checks the rows it writes.
+func TestReportsOnMemoryRepositories(t *testing.T) {
+	// Mid-month, so that last month's sales a day later are after today.
+	today := time.Date(2024, 3, 15, 12, 0, 0, 0, time.Local)
+
+	creditHeader := "Retailer Code | Retailer Name | Received: Last 1 days (₹) | Credit: 0-7 Days(₹) | Credit: 8-14 Days(₹) | Credit: 15-20 Days(₹) | " +
+		"Credit: 21-30 Days(₹) | Credit: 31+ Days(₹) | Total Credit(₹) | Total Inventory Cost(₹) | Inventory Shortfall (₹) | TSE"
+	salesTargetHeader := "TSE | Target: Overall | Achieved | Balance | Balance %"
+	for _, tc := range []struct {
+		name   string
+		report string
+		file   string
+		sheet  string
+		want   []string // the raw cell values of each row, joined by " | "
+	}{
+		{"credit by age with receipts and inventory cost", "credit", "credit_reports_*/Krishna_credit_report.xlsx", "Credit Report", []string{
+			creditHeader,
+			"R1 | ALPHA MOBILES | 1200 | 3000 | 0 | 0 | 2000 | 0 | 5000 | 16000 | 11000 | Krishna",
+			"Total |  |  |  |  |  |  |  |  |  | ",
+		}},
+		{"credit of retailers without a TSE", "credit", "credit_reports_*/TSE_MISSING_credit_report.xlsx", "Credit Report", []string{
+			creditHeader,
+			"R3 | CITY CELLULAR | 0 | 0 | 0 | 0 | 0 | 700 | 700 | 0 | -700",
+			"Total |  |  |  |  |  |  |  |  |  | ",
+		}},
+		{"inventory shortfall against credit due", "cogs", "inventory_report_*/inventory_report.xlsx", "Inventory ShortFall", []string{
+			"Dealer Code | Dealer Name | TSE | Total Inventory Cost(₹) | Total Credit Due(₹) | Inventory Shortfall (₹)",
+			"R1 | Alpha Mobiles | Krishna | 16000 | 5000 | 11000",
+			" |  | Krishna Total |  |  | ",
+			"R2 | Bharat Telecom | Ravi | 12000 | 0 | 12000",
+			" |  | Ravi Total |  |  | ",
+			" |  | Grand Total |  |  | ",
+		}},
+		{"material model count", "cogs", "inventory_report_*/inventory_report.xlsx", "Material Model Count", []string{
+			"Dealer Code | Dealer Name | TSE | Material Code | SPU Name | Color | SKU Spec | Product Type | Count",
+			"R1 | Alpha Mobiles | Krishna | 1001 | realme C61 |  |  | mobile phone | 2",
+			"R2 | Bharat Telecom | Ravi | 1002 | realme C63 |  |  | mobile phone | 1",
+			"Total |  |  |  |  |  |  |  | ",
+		}},
+		{"growth up to today's day of the month", "growth", "growth_report_*/Ravi_growth_report.xlsx", "Growth Report", []string{
+			"TSE | Dealer Code | Dealer Name | MTD SO | LMTD SO | Growth SO % | MTD ST | LMTD ST | Growth ST %",
+			"Ravi | R2 | Bharat Telecom | 1 | 0 | 0 | 1 | 1 | 0",
+			"Total |  |  |  |  |  |  | ",
+		}},
+		{"zero stock of models sold", "zso", "zso_report_*/zso_report.xlsx", "ZSO Report", []string{
+			"TSE | Dealer Name | C63 | Total ZSO",
+			"Krishna | Alpha Mobiles | ZSO | 1",
+			"Krishna Total |  |  | ",
+			"Grand Total |  |  | ",
+		}},
+		{"RA refill against stock", "ranorms", "ranorms_report_*/ra_norms_report.xlsx", "RA Norms Report", []string{
+			"TSE | Dealer Name | 13 5G | 13 Pro 5G | 13 Pro+ 5G | 13+ 5G | C61 | C63 | C63 5G | C65 5G | GT 6T | GT6 | Total Refill",
+			"Krishna | Alpha Mobiles | 6 | 6 | 6 | 6 | 4 | 6 | 6 | 6 | 6 | 6 | 58",
+			"Krishna Total |  |  |  |  |  |  |  |  |  |  |  | ",
+			"Grand Total |  |  |  |  |  |  |  |  |  |  |  | ",
+		}},
+		{"price list with material codes", "pricelist", "price_list_*/price_list.xlsx", "Price List", []string{
+			"Type | Model | Color | Variant | NLC | MOP | MRP | Material Code",
+			"SMART PHONE | realme c61 | DARK GREEN | 64GB 4GB | 7500 | 8999 | 9999 | 1001",
+			"SMART PHONE | realme c63 | JADE GREEN | 128GB 8GB | 11000 | 12499 | 13999 | 0",
+		}},
+		{"sales target by category", "salestarget", "sales_report_*/sales_report.xlsx", "Sales Target", []string{
+			"SMART PHONES", salesTargetHeader, "Krishna | 2490 | 2 | 2488 | 99.91967871485944", "", "", "", "",
+			"ACCESSORIES", salesTargetHeader, "Krishna | 1000 | 3 | 997 | 99.7", "", "", "", "",
+			"OTHERS", salesTargetHeader,
+		}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cfg := &config.Config{OutputDir: t.TempDir()}
+			cfg.ReportFiles.GrowthReport = config.GrowthReportFiles{MTDSO: "mtd-so", LMTDSO: "lmtd-so", MTDST: "mtd-st", LMTDST: "lmtd-st", L2MSO: "l2m-so"}
+			cfg.ReportFiles.SalesReport = "sales"
+
+			generator, err := NewReportGenerator(tc.report, cfg, WithRepositories(memoryRepositories(today)), WithLogger(discardLogger()))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := generator.Generate(context.Background()); err != nil {
+				t.Fatalf("Generate: %v", err)
+			}
+
+			files, _ := filepath.Glob(filepath.Join(cfg.OutputDir, tc.file))
+			if len(files) != 1 {
+				t.Fatalf("expected one %s, found %v", tc.file, files)
+			}
+			if got := sheetRows(t, files[0], tc.sheet); !reflect.DeepEqual(got, tc.want) {
+				t.Errorf("%s rows:\n%s\nwant:\n%s", tc.sheet, strings.Join(got, "\n"), strings.Join(tc.want, "\n"))
+			}
+		})
+	}
+}
+
+// sheetRows returns the raw cell values of each row of sheet in the workbook
+// at path, joined by " | ".
+func sheetRows(t *testing.T, path, sheet string) []string {
+	t.Helper()
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := make([]string, len(rows))
+	for i, row := range rows {
+		lines[i] = strings.Join(row, " | ")
+	}
+	return lines
+}
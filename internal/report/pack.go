@@ -0,0 +1,408 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"viking-reports/internal/config"
+	"viking-reports/internal/repository"
+	"viking-reports/internal/utils"
+	"viking-reports/pkg/excel"
+	"viking-reports/pkg/output"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func init() {
+	Register(Definition{
+		Name:        "pack",
+		Description: "Daily management pack: a summary of KPIs followed by every report, in one workbook",
+		DependsOn:   []string{"growth", "credit", "cogs", "zso", "ranorms", "salestarget", "pricelist"},
+		New: func(cfg *config.Config, opts ...Option) ReportGenerator {
+			return NewPackGenerator(cfg, opts...)
+		},
+	})
+}
+
+// packSection is a sheet of the daily pack holding the rows of one report,
+// read back from the report's workbooks of the day.
+type packSection struct {
+	sheet string
+	// report is the name of the report the rows are read from.
+	report string
+	// folder is the prefix of the report's dated output folder, dated by
+	// month if monthly.
+	folder  string
+	monthly bool
+	// source is the sheet read from each of the report's workbooks.
+	source string
+	// percent are the columns of fractions, shown as percentages.
+	percent []string
+	// totals totals the numeric columns by TSE.
+	totals bool
+	// read reads the sheet of a workbook, if the sheet is not a table.
+	read func(f *excelize.File, sheet string) ([]string, []map[string]string, error)
+}
+
+// packSections are the sheets following the summary, in order.
+var packSections = []packSection{
+	{sheet: "Growth", report: "growth", folder: "growth_report", source: "Growth Report", percent: []string{"Growth SO %", "Growth ST %"}, totals: true},
+	{sheet: "Credit", report: "credit", folder: "credit_reports", source: "Credit Report", totals: true},
+	{sheet: "COGS", report: "cogs", folder: "inventory_report", source: "Inventory ShortFall", totals: true},
+	{sheet: "ZSO", report: "zso", folder: "zso_report", source: "ZSO Report", totals: true},
+	{sheet: "RA Norms", report: "ranorms", folder: "ranorms_report", source: "RA Norms Report", totals: true},
+	{sheet: "Sales Target", report: "salestarget", folder: "sales_report", source: "Sales Target", read: readSalesTarget},
+	{sheet: "Price List", report: "pricelist", folder: "price_list", monthly: true, source: "Price List"},
+}
+
+// withheld reports whether the rows of the section are left out of the
+// output of the reader report: they are when the section's workbooks are
+// encrypted and the reader's are not, which would leave them readable
+// without the TSEs' passwords.
+func (s packSection) withheld(cfg *config.Config, reader string) bool {
+	return cfg.Protection.Encrypts(s.report) && !cfg.Protection.Encrypts(reader)
+}
+
+// summarySheet is the first sheet of the pack, of the KPIs.
+const summarySheet = "Summary"
+
+type PackGenerator struct {
+	cfg    *config.Config
+	logger *slog.Logger
+}
+
+func NewPackGenerator(cfg *config.Config, opts ...Option) *PackGenerator {
+	o := newOptions(cfg, "pack", opts)
+	return &PackGenerator{cfg: cfg, logger: o.logger}
+}
+
+func (g *PackGenerator) Generate(ctx context.Context) error {
+	g.logger.Info("generating daily pack")
+
+	headers := make(map[string][]string)
+	records := make(map[string][]map[string]string)
+	var sections []packSection
+	for _, section := range packSections {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		if section.withheld(g.cfg, "pack") {
+			g.logger.Warn("leaving out the sheet of an encrypted report, the pack is not encrypted", "sheet", section.sheet, "source", section.report)
+			continue
+		}
+		sections = append(sections, section)
+		h, rows, err := readPackSection(ctx, g.cfg, section)
+		if err != nil {
+			return err
+		}
+		g.logger.Debug("adding report to daily pack", "sheet", section.sheet, "rows", len(rows))
+		headers[section.sheet] = h
+		records[section.sheet] = rows
+	}
+
+	result := output.NewResult()
+	f := result.Workbook
+	if err := f.SetSheetName("Sheet1", summarySheet); err != nil {
+		return err
+	}
+	if err := writeKPIs(result, records); err != nil {
+		return err
+	}
+	for _, section := range sections {
+		if _, err := f.NewSheet(section.sheet); err != nil {
+			return fmt.Errorf("error creating new sheet: %w", err)
+		}
+		table, values := packTable(section, headers[section.sheet], records[section.sheet], true)
+		if err := result.Add(section.sheet, &table, values); err != nil {
+			return err
+		}
+	}
+
+	outputDir := utils.GenerateOutputPath(g.cfg.OutputDir, "daily_pack")
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+	if err := output.Save(ctx, result, filepath.Join(outputDir, "daily_pack.xlsx")); err != nil {
+		return err
+	}
+	g.logger.Info("daily pack generated", "output", outputDir)
+	return nil
+}
+
+// packSectionOf returns the pack section of sheet.
+func packSectionOf(sheet string) (packSection, bool) {
+	for _, section := range packSections {
+		if section.sheet == sheet {
+			return section, true
+		}
+	}
+	return packSection{}, false
+}
+
+// writeKPIs writes the KPIs of records, the rows of the pack sections by
+// sheet, to the summary sheet of result.
+func writeKPIs(result *output.Result, records map[string][]map[string]string) error {
+	summary := excel.Table{
+		Sheet: summarySheet,
+		Columns: []excel.Column{
+			{Header: "Area"},
+			{Header: "KPI"},
+			{Header: "Value", Type: excel.Number, NumFmt: excel.INR},
+			{Header: "Compared To", Type: excel.Number, NumFmt: excel.INR},
+			{Header: "%", Type: excel.Percent},
+		},
+		FreezeHeader: true,
+	}
+	return result.Add(summarySheet, &summary, packKPIs(records))
+}
+
+// readPackSection returns the headers and rows of the section's sheet in
+// every workbook its report wrote today, in file name order; for the per-TSE
+// reports, that is TSE order.
+func readPackSection(ctx context.Context, cfg *config.Config, section packSection) ([]string, []map[string]string, error) {
+	dir := utils.GenerateOutputPath(cfg.OutputDir, section.folder)
+	if section.monthly {
+		dir = utils.GenerateMonthlyOutputPath(cfg.OutputDir, section.folder)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.xlsx"))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(paths) == 0 {
+		return nil, nil, fmt.Errorf("no workbooks in %s for the %s sheet", dir, section.sheet)
+	}
+	sort.Strings(paths)
+
+	var headers []string
+	var rows []map[string]string
+	for _, path := range paths {
+		f, err := repository.OpenWorkbook(ctx, path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error opening %s: %w", path, err)
+		}
+		h, r, err := readPackSheet(f, section)
+		f.Close()
+		if err != nil {
+			return nil, nil, fmt.Errorf("error reading %s: %w", path, err)
+		}
+		if headers == nil {
+			headers = h
+		}
+		rows = append(rows, r...)
+	}
+	return headers, rows, nil
+}
+
+func readPackSheet(f *excelize.File, section packSection) ([]string, []map[string]string, error) {
+	if section.read != nil {
+		return section.read(f, section.source)
+	}
+	raw := excelize.Options{RawCellValue: true}
+	rows, err := f.Rows(section.source)
+	if err != nil {
+		return nil, nil, err
+	}
+	var headers []string
+	if rows.Next() {
+		if headers, err = rows.Columns(raw); err != nil {
+			return nil, nil, err
+		}
+	}
+	rows.Close()
+	records, err := excel.Records(f, section.source, raw)
+	return headers, records, err
+}
+
+// readSalesTarget reads the tables of the sales target sheet, one per
+// category titled in the row above its headers, as rows with the category
+// in a column of their own.
+func readSalesTarget(f *excelize.File, sheet string) ([]string, []map[string]string, error) {
+	rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return nil, nil, err
+	}
+	var headers []string
+	var records []map[string]string
+	for r := 1; r < len(rows); r++ {
+		if len(rows[r]) == 0 || rows[r][0] != "TSE" || len(rows[r-1]) == 0 {
+			continue
+		}
+		category, columns := rows[r-1][0], rows[r]
+		headers = append([]string{"Category"}, columns...)
+		for r++; r < len(rows) && strings.Join(rows[r], "") != ""; r++ {
+			record := map[string]string{"Category": category}
+			for c, header := range columns {
+				if c < len(rows[r]) {
+					record[header] = rows[r][c]
+				}
+			}
+			records = append(records, record)
+		}
+	}
+	return headers, records, nil
+}
+
+// packTable returns the table of a section and its rows. Columns whose
+// values are all numbers are written as numbers; with totals they are
+// totalled, by TSE if byTSE and the section has a TSE column.
+func packTable(section packSection, headers []string, records []map[string]string, byTSE bool) (excel.Table, [][]interface{}) {
+	table := excel.Table{Sheet: section.sheet, FreezeHeader: true, AutoFilter: true}
+	for _, header := range headers {
+		col := excel.Column{Header: header}
+		if numeric(header, records) {
+			col.Type = excel.Number
+			switch {
+			case slices.Contains(section.percent, header):
+				col.Type = excel.Percent
+			case strings.Contains(header, "₹"):
+				col.NumFmt = excel.INR
+			case strings.HasSuffix(header, "%"):
+				col.NumFmt = "0.00"
+			}
+			col.Sum = section.totals && col.Type == excel.Number && !strings.HasSuffix(header, "%")
+		}
+		table.Columns = append(table.Columns, col)
+	}
+	if section.totals {
+		table.Totals = true
+		if byTSE && slices.Contains(headers, "TSE") {
+			table.GroupBy = "TSE"
+			// The rows of each TSE are together in the reports grouped by
+			// TSE and in the per-TSE workbooks, but not across the
+			// workbooks of TSEs sharing a name prefix; keep them in order.
+			sort.SliceStable(records, func(i, j int) bool { return records[i]["TSE"] < records[j]["TSE"] })
+		}
+	}
+
+	values := make([][]interface{}, 0, len(records))
+	for _, record := range records {
+		row := make([]interface{}, len(table.Columns))
+		for c, col := range table.Columns {
+			v := record[col.Header]
+			if col.Type == excel.Text || v == "" {
+				row[c] = v
+				continue
+			}
+			row[c], _ = strconv.ParseFloat(v, 64)
+		}
+		values = append(values, row)
+	}
+	return table, values
+}
+
+// numeric reports whether the values of the column with header are all
+// numbers, and there is at least one.
+func numeric(header string, records []map[string]string) bool {
+	found := false
+	for _, record := range records {
+		v := record[header]
+		if v == "" {
+			continue
+		}
+		if _, err := strconv.ParseFloat(v, 64); err != nil {
+			return false
+		}
+		found = true
+	}
+	return found
+}
+
+// packKPIs returns the rows of the summary sheet from the records of the
+// sections, by sheet: each KPI's value, what it is compared to, if
+// anything, and the percentage of the two.
+func packKPIs(records map[string][]map[string]string) [][]interface{} {
+	sum := func(sheet, header string, keep func(float64) bool) (total float64, n int) {
+		for _, record := range records[sheet] {
+			v, err := strconv.ParseFloat(record[header], 64)
+			if err == nil && (keep == nil || keep(v)) {
+				total += v
+				n++
+			}
+		}
+		return total, n
+	}
+	var kpis [][]interface{}
+	add := func(area, kpi string, value float64) {
+		kpis = append(kpis, []interface{}{area, kpi, value})
+	}
+	// compare adds a KPI compared to another value, with the percentage of
+	// part of whole, left blank if whole is 0.
+	compare := func(area, kpi string, value, against, part, whole float64) {
+		row := []interface{}{area, kpi, value, against, nil}
+		if whole != 0 {
+			row[4] = part / whole
+		}
+		kpis = append(kpis, row)
+	}
+
+	// Only the KPIs of the sheets read are given, not zeros for sheets left
+	// out.
+	has := func(sheet string) bool {
+		_, ok := records[sheet]
+		return ok
+	}
+
+	if has("Growth") {
+		mtdSO, _ := sum("Growth", "MTD SO", nil)
+		lmtdSO, _ := sum("Growth", "LMTD SO", nil)
+		compare("Sales", "Sell-out: MTD against LMTD, growth", mtdSO, lmtdSO, mtdSO-lmtdSO, lmtdSO)
+		mtdST, _ := sum("Growth", "MTD ST", nil)
+		lmtdST, _ := sum("Growth", "LMTD ST", nil)
+		compare("Sales", "Sell-through: MTD against LMTD, growth", mtdST, lmtdST, mtdST-lmtdST, lmtdST)
+	}
+
+	if has("Credit") {
+		total, _ := sum("Credit", "Total Credit(₹)", nil)
+		for _, bucket := range []string{"0-7", "8-14", "15-20", "21-30", "31+"} {
+			credit, _ := sum("Credit", "Credit: "+bucket+" Days(₹)", nil)
+			compare("Receivables", "Credit: "+bucket+" days, share of total", credit, total, credit, total)
+		}
+		add("Receivables", "Total credit", total)
+	}
+
+	if has("COGS") {
+		cost, _ := sum("COGS", "Total Inventory Cost(₹)", nil)
+		add("Inventory", "Total inventory value", cost)
+		shortfall, short := sum("COGS", "Inventory Shortfall (₹)", func(v float64) bool { return v < 0 })
+		add("Inventory", "Inventory shortfall", shortfall)
+		add("Inventory", "Retailers short of inventory", float64(short))
+	}
+
+	if has("ZSO") {
+		// Total ZSO counts a dealer's models out of stock, so the outlets
+		// are the dealers with any.
+		zso, outlets := sum("ZSO", "Total ZSO", func(v float64) bool { return v > 0 })
+		add("Stock", "Zero stock outlets (ZSO)", float64(outlets))
+		add("Stock", "Zero-stock dealer/model lines", zso)
+	}
+	if has("RA Norms") {
+		refill, _ := sum("RA Norms", "Total Refill", nil)
+		add("Stock", "RA refill units", refill)
+	}
+
+	var categories []string
+	for _, record := range records["Sales Target"] {
+		if !slices.Contains(categories, record["Category"]) {
+			categories = append(categories, record["Category"])
+		}
+	}
+	for _, category := range categories {
+		var target, achieved float64
+		for _, record := range records["Sales Target"] {
+			if record["Category"] != category {
+				continue
+			}
+			t, _ := strconv.ParseFloat(record["Target: Overall"], 64)
+			a, _ := strconv.ParseFloat(record["Achieved"], 64)
+			target, achieved = target+t, achieved+a
+		}
+		compare("Targets", category+": achieved against target", achieved, target, achieved, target)
+	}
+	return kpis
+}
@@ -0,0 +1,34 @@
+package report
+
+import "testing"
+
+func TestPackKPIsCountZSOOutlets(t *testing.T) {
+	records := map[string][]map[string]string{
+		"ZSO": {
+			{"Dealer Name": "Alpha Mobiles", "Total ZSO": "3"},
+			{"Dealer Name": "Bharat Telecom", "Total ZSO": "1"},
+			{"Dealer Name": "Fortune Comm", "Total ZSO": "0"},
+		},
+	}
+	got := make(map[string]interface{})
+	for _, kpi := range packKPIs(records) {
+		got[kpi[1].(string)] = kpi[2]
+	}
+	if v := got["Zero stock outlets (ZSO)"]; v != 2.0 {
+		t.Errorf("zero stock outlets = %v, want the 2 dealers with any", v)
+	}
+	if v := got["Zero-stock dealer/model lines"]; v != 4.0 {
+		t.Errorf("zero-stock lines = %v, want 4", v)
+	}
+}
+
+func TestPackKPIsLeaveOutSheetsNotRead(t *testing.T) {
+	records := map[string][]map[string]string{
+		"Growth": {{"MTD SO": "3", "LMTD SO": "2"}},
+	}
+	for _, kpi := range packKPIs(records) {
+		if area := kpi[0]; area != "Sales" {
+			t.Errorf("got %v KPI %v without its sheet", area, kpi[1])
+		}
+	}
+}
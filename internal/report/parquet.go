@@ -0,0 +1,446 @@
+package report
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+	"viking-reports/internal/config"
+	"viking-reports/internal/repository"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+func init() {
+	Register(Definition{
+		Name:        "parquet",
+		Description: "Parquet export of the day's bills, receipts, sales, inventory and price list, and the growth, credit and ZSO tables",
+		Inputs: func(cfg *config.Config) []Input {
+			return []Input{
+				{"Bills", cfg.ReportFiles.CreditReport.Bills, repository.BillsSchema},
+				{"Received", cfg.ReportFiles.DebitReport.Debits, repository.ReceivedSchema},
+				{"MTD-SO", cfg.ReportFiles.GrowthReport.MTDSO, repository.SalesExportSchema},
+				{"LMTD-SO", cfg.ReportFiles.GrowthReport.LMTDSO, repository.SalesExportSchema},
+				{"MTD-ST", cfg.ReportFiles.GrowthReport.MTDST, repository.SalesExportSchema},
+				{"LMTD-ST", cfg.ReportFiles.GrowthReport.LMTDST, repository.SalesExportSchema},
+				{"L2M-SO", cfg.ReportFiles.GrowthReport.L2MSO, repository.SalesExportSchema},
+				{"DealerInventory", cfg.ReportFiles.InventoryReport, repository.InventorySchema},
+				{"ZD PRICE LIST", cfg.ReportFiles.PriceListFile, repository.ZDPriceListSchema},
+			}
+		},
+		DependsOn: []string{"growth", "credit", "zso"},
+		New: func(cfg *config.Config, opts ...Option) ReportGenerator {
+			return NewParquetGenerator(cfg, opts...)
+		},
+	})
+}
+
+// The rows of the exported datasets. Their columns are the datasets'
+// schemas: columns may be added, but are never renamed, retyped or removed,
+// so that a month of partitions reads as one table.
+
+type billRow struct {
+	BillDate      *int32  `parquet:"name=bill_date, type=INT32, convertedtype=DATE, repetitiontype=OPTIONAL"`
+	RefNo         string  `parquet:"name=ref_no, type=BYTE_ARRAY, convertedtype=UTF8"`
+	RetailerName  string  `parquet:"name=retailer_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	PendingAmount float64 `parquet:"name=pending_amount, type=DOUBLE"`
+	DueDate       *int32  `parquet:"name=due_date, type=INT32, convertedtype=DATE, repetitiontype=OPTIONAL"`
+	AgeDays       int32   `parquet:"name=age_days, type=INT32"`
+}
+
+type receiptRow struct {
+	PartyName string  `parquet:"name=party_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Amount    float64 `parquet:"name=amount, type=DOUBLE"`
+}
+
+type saleEventRow struct {
+	// Flow is SO, sell-out (activations), or ST, sell-through (dispatches).
+	Flow string `parquet:"name=flow, type=BYTE_ARRAY, convertedtype=UTF8"`
+	// Period is the span of the export: MTD, LMTD or L2M.
+	Period       string `parquet:"name=period, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DealerCode   string `parquet:"name=dealer_code, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DealerName   string `parquet:"name=dealer_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ActivateTime *int64 `parquet:"name=activate_time, type=INT64, convertedtype=TIMESTAMP_MILLIS, repetitiontype=OPTIONAL"`
+	SPUName      string `parquet:"name=spu_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ProductType  string `parquet:"name=product_type, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type inventoryUnitRow struct {
+	DealerCode   string `parquet:"name=dealer_code, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DealerName   string `parquet:"name=dealer_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	AreaName     string `parquet:"name=area_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	MaterialCode string `parquet:"name=material_code, type=BYTE_ARRAY, convertedtype=UTF8"`
+	SPUName      string `parquet:"name=spu_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Color        string `parquet:"name=color, type=BYTE_ARRAY, convertedtype=UTF8"`
+	SKUSpec      string `parquet:"name=sku_spec, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ProductType  string `parquet:"name=product_type, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+type priceListRow struct {
+	Type    string `parquet:"name=type, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Model   string `parquet:"name=model, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Color   string `parquet:"name=color, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Memory  string `parquet:"name=memory, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Storage string `parquet:"name=storage, type=BYTE_ARRAY, convertedtype=UTF8"`
+	NLC     int64  `parquet:"name=nlc, type=INT64"`
+	MOP     int64  `parquet:"name=mop, type=INT64"`
+	MRP     int64  `parquet:"name=mrp, type=INT64"`
+}
+
+type growthRow struct {
+	TSE        string `parquet:"name=tse, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DealerCode string `parquet:"name=dealer_code, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DealerName string `parquet:"name=dealer_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	MTDSO      int64  `parquet:"name=mtd_so, type=INT64"`
+	LMTDSO     int64  `parquet:"name=lmtd_so, type=INT64"`
+	// GrowthSO and GrowthST are fractions, 0.5 for 50% growth.
+	GrowthSO float64 `parquet:"name=growth_so, type=DOUBLE"`
+	MTDST    int64   `parquet:"name=mtd_st, type=INT64"`
+	LMTDST   int64   `parquet:"name=lmtd_st, type=INT64"`
+	GrowthST float64 `parquet:"name=growth_st, type=DOUBLE"`
+}
+
+type creditRow struct {
+	RetailerCode       string  `parquet:"name=retailer_code, type=BYTE_ARRAY, convertedtype=UTF8"`
+	RetailerName       string  `parquet:"name=retailer_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	TSE                string  `parquet:"name=tse, type=BYTE_ARRAY, convertedtype=UTF8"`
+	ReceivedLastDay    float64 `parquet:"name=received_last_day, type=DOUBLE"`
+	Credit0To7Days     float64 `parquet:"name=credit_0_7_days, type=DOUBLE"`
+	Credit8To14Days    float64 `parquet:"name=credit_8_14_days, type=DOUBLE"`
+	Credit15To20Days   float64 `parquet:"name=credit_15_20_days, type=DOUBLE"`
+	Credit21To30Days   float64 `parquet:"name=credit_21_30_days, type=DOUBLE"`
+	Credit31PlusDays   float64 `parquet:"name=credit_31_plus_days, type=DOUBLE"`
+	TotalCredit        float64 `parquet:"name=total_credit, type=DOUBLE"`
+	TotalInventoryCost float64 `parquet:"name=total_inventory_cost, type=DOUBLE"`
+	InventoryShortfall float64 `parquet:"name=inventory_shortfall, type=DOUBLE"`
+}
+
+// zsoRow is a model a retailer is out of; the ZSO report's column per model
+// is a row per model here.
+type zsoRow struct {
+	TSE        string `parquet:"name=tse, type=BYTE_ARRAY, convertedtype=UTF8"`
+	DealerName string `parquet:"name=dealer_name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Model      string `parquet:"name=model, type=BYTE_ARRAY, convertedtype=UTF8"`
+}
+
+// parquetDatasets are the exported datasets, in order, each written to
+// path by write. sheet is the pack section the computed tables are read
+// back from.
+var parquetDatasets = []struct {
+	name  string
+	sheet string
+	write func(g *ParquetGenerator, ctx context.Context, path string) error
+}{
+	{"bills", "", (*ParquetGenerator).writeBills},
+	{"receipts", "", (*ParquetGenerator).writeReceipts},
+	{"sale_events", "", (*ParquetGenerator).writeSaleEvents},
+	{"inventory_units", "", (*ParquetGenerator).writeInventoryUnits},
+	{"price_list", "", (*ParquetGenerator).writePriceList},
+	{"growth", "Growth", (*ParquetGenerator).writeGrowth},
+	{"credit", "Credit", (*ParquetGenerator).writeCredit},
+	{"zso", "ZSO", (*ParquetGenerator).writeZSO},
+}
+
+// parquetDir is the folder of the export under the output directory.
+const parquetDir = "parquet"
+
+type ParquetGenerator struct {
+	cfg     *config.Config
+	cache   *repository.Cache
+	logger  *slog.Logger
+	summary *Summary
+	// day is the report date the datasets are partitioned by.
+	day time.Time
+}
+
+func NewParquetGenerator(cfg *config.Config, opts ...Option) *ParquetGenerator {
+	o := newOptions(cfg, "parquet", opts)
+	return &ParquetGenerator{
+		cfg:     cfg,
+		cache:   o.cache,
+		logger:  o.logger,
+		summary: o.summary,
+		day:     time.Now(),
+	}
+}
+
+func (g *ParquetGenerator) Generate(ctx context.Context) error {
+	g.logger.Info("exporting datasets to Parquet")
+
+	var names []string
+	for _, dataset := range parquetDatasets {
+		// Parquet files cannot be encrypted, so the tables of encrypted
+		// reports are not exported.
+		if section, ok := packSectionOf(dataset.sheet); ok && section.withheld(g.cfg, "parquet") {
+			g.logger.Warn("not exporting the table of an encrypted report", "dataset", dataset.name, "source", section.report)
+			g.summary.Skip("parquet", dataset.name, section.report+" is encrypted")
+			continue
+		}
+		names = append(names, dataset.name)
+	}
+	err := writeUnits(ctx, g.summary, g.logger, "parquet", names, func(ctx context.Context, name string) (string, error) {
+		for _, dataset := range parquetDatasets {
+			if dataset.name == name {
+				path := g.partition(name)
+				return path, dataset.write(g, ctx, path)
+			}
+		}
+		return "", fmt.Errorf("unknown dataset %s", name)
+	})
+	if err != nil {
+		return err
+	}
+	g.logger.Info("datasets exported to Parquet", "output", filepath.Join(g.cfg.OutputDir, parquetDir))
+	return nil
+}
+
+// partition returns the file of the dataset's partition of the report date,
+// laid out as Hive does, e.g. parquet/bills/report_date=2024-03-05/bills.parquet,
+// so that a folder of partitions reads as one table with a report_date
+// column.
+func (g *ParquetGenerator) partition(dataset string) string {
+	return filepath.Join(g.cfg.OutputDir, parquetDir, dataset, "report_date="+g.day.Format("2006-01-02"), dataset+".parquet")
+}
+
+func (g *ParquetGenerator) writeBills(ctx context.Context, path string) error {
+	bills, err := repository.Bills(ctx, g.cache, g.cfg.ReportFiles.CreditReport.Bills, g.logger)
+	if err != nil {
+		return fmt.Errorf("error reading bills: %w", err)
+	}
+	rows := make([]billRow, 0, len(bills))
+	for _, bill := range bills {
+		rows = append(rows, billRow{
+			BillDate:      parquetDate(repository.ParseBillDate(bill.Date)),
+			RefNo:         bill.RefNo,
+			RetailerName:  bill.RetailerName,
+			PendingAmount: bill.PendingAmount,
+			DueDate:       parquetDate(repository.ParseBillDate(bill.DueDate)),
+			AgeDays:       int32(bill.AgeOfBill),
+		})
+	}
+	return writeParquet(ctx, path, rows)
+}
+
+func (g *ParquetGenerator) writeReceipts(ctx context.Context, path string) error {
+	receipts, err := repository.Receipts(ctx, g.cache, g.cfg.ReportFiles.DebitReport.Debits)
+	if err != nil {
+		return fmt.Errorf("error reading receipts: %w", err)
+	}
+	rows := make([]receiptRow, 0, len(receipts))
+	for _, receipt := range receipts {
+		rows = append(rows, receiptRow{PartyName: receipt.PartyName, Amount: receipt.Amount})
+	}
+	return writeParquet(ctx, path, rows)
+}
+
+func (g *ParquetGenerator) writeSaleEvents(ctx context.Context, path string) error {
+	files := g.cfg.ReportFiles.GrowthReport
+	exports := []struct{ flow, period, path string }{
+		{"SO", "MTD", files.MTDSO},
+		{"SO", "LMTD", files.LMTDSO},
+		{"ST", "MTD", files.MTDST},
+		{"ST", "LMTD", files.LMTDST},
+		{"SO", "L2M", files.L2MSO},
+	}
+	var rows []saleEventRow
+	for _, export := range exports {
+		events, err := repository.SaleEvents(ctx, g.cache, export.path)
+		if err != nil {
+			return fmt.Errorf("error reading %s %s sales: %w", export.period, export.flow, err)
+		}
+		for _, event := range events {
+			row := saleEventRow{
+				Flow:        export.flow,
+				Period:      export.period,
+				DealerCode:  event.DealerCode,
+				DealerName:  event.DealerName,
+				SPUName:     event.SPUName,
+				ProductType: event.ProductType,
+			}
+			if t, ok := repository.ParseSaleTime(event.ActivateTime); ok {
+				millis := t.UnixMilli()
+				row.ActivateTime = &millis
+			}
+			rows = append(rows, row)
+		}
+	}
+	return writeParquet(ctx, path, rows)
+}
+
+func (g *ParquetGenerator) writeInventoryUnits(ctx context.Context, path string) error {
+	units, err := repository.InventoryUnits(ctx, g.cache, g.cfg.ReportFiles.InventoryReport)
+	if err != nil {
+		return fmt.Errorf("error reading inventory: %w", err)
+	}
+	rows := make([]inventoryUnitRow, 0, len(units))
+	for _, unit := range units {
+		rows = append(rows, inventoryUnitRow(unit))
+	}
+	return writeParquet(ctx, path, rows)
+}
+
+func (g *ParquetGenerator) writePriceList(ctx context.Context, path string) error {
+	prices, err := repository.PriceList(ctx, g.cache, g.cfg.ReportFiles.PriceListFile, g.logger)
+	if err != nil {
+		return fmt.Errorf("error reading price list: %w", err)
+	}
+	rows := make([]priceListRow, 0, len(prices))
+	for _, price := range prices {
+		rows = append(rows, priceListRow{
+			Type:    price.Type,
+			Model:   price.Model,
+			Color:   price.Color,
+			Memory:  price.Memory,
+			Storage: price.Storage,
+			NLC:     int64(price.NLC),
+			MOP:     int64(price.Mop),
+			MRP:     int64(price.Mrp),
+		})
+	}
+	return writeParquet(ctx, path, rows)
+}
+
+// The computed tables are read back from the reports' workbooks of the day,
+// as the daily pack does, so that they hold what the reports show.
+
+func (g *ParquetGenerator) writeGrowth(ctx context.Context, path string) error {
+	records, err := g.readReport(ctx, "Growth")
+	if err != nil {
+		return err
+	}
+	rows := make([]growthRow, 0, len(records))
+	for _, r := range records {
+		rows = append(rows, growthRow{
+			TSE:        r["TSE"],
+			DealerCode: r["Dealer Code"],
+			DealerName: r["Dealer Name"],
+			MTDSO:      int64(parseNumber(r["MTD SO"])),
+			LMTDSO:     int64(parseNumber(r["LMTD SO"])),
+			GrowthSO:   parseNumber(r["Growth SO %"]),
+			MTDST:      int64(parseNumber(r["MTD ST"])),
+			LMTDST:     int64(parseNumber(r["LMTD ST"])),
+			GrowthST:   parseNumber(r["Growth ST %"]),
+		})
+	}
+	return writeParquet(ctx, path, rows)
+}
+
+func (g *ParquetGenerator) writeCredit(ctx context.Context, path string) error {
+	records, err := g.readReport(ctx, "Credit")
+	if err != nil {
+		return err
+	}
+	rows := make([]creditRow, 0, len(records))
+	for _, r := range records {
+		rows = append(rows, creditRow{
+			RetailerCode:       r["Retailer Code"],
+			RetailerName:       r["Retailer Name"],
+			TSE:                r["TSE"],
+			ReceivedLastDay:    parseNumber(r["Received: Last 1 days (₹)"]),
+			Credit0To7Days:     parseNumber(r["Credit: 0-7 Days(₹)"]),
+			Credit8To14Days:    parseNumber(r["Credit: 8-14 Days(₹)"]),
+			Credit15To20Days:   parseNumber(r["Credit: 15-20 Days(₹)"]),
+			Credit21To30Days:   parseNumber(r["Credit: 21-30 Days(₹)"]),
+			Credit31PlusDays:   parseNumber(r["Credit: 31+ Days(₹)"]),
+			TotalCredit:        parseNumber(r["Total Credit(₹)"]),
+			TotalInventoryCost: parseNumber(r["Total Inventory Cost(₹)"]),
+			InventoryShortfall: parseNumber(r["Inventory Shortfall (₹)"]),
+		})
+	}
+	return writeParquet(ctx, path, rows)
+}
+
+func (g *ParquetGenerator) writeZSO(ctx context.Context, path string) error {
+	section, _ := packSectionOf("ZSO")
+	headers, records, err := readPackSection(ctx, g.cfg, section)
+	if err != nil {
+		return err
+	}
+	var rows []zsoRow
+	for _, r := range records {
+		for _, model := range headers {
+			if r[model] == "ZSO" {
+				rows = append(rows, zsoRow{TSE: r["TSE"], DealerName: r["Dealer Name"], Model: model})
+			}
+		}
+	}
+	return writeParquet(ctx, path, rows)
+}
+
+// readReport returns the rows of the report of the pack section sheet.
+func (g *ParquetGenerator) readReport(ctx context.Context, sheet string) ([]map[string]string, error) {
+	section, _ := packSectionOf(sheet)
+	_, records, err := readPackSection(ctx, g.cfg, section)
+	return records, err
+}
+
+// parseNumber returns the number of a cell read back from a workbook, 0 if
+// it is blank.
+func parseNumber(s string) float64 {
+	v, _ := strconv.ParseFloat(s, 64)
+	return v
+}
+
+// parquetDate returns the Parquet DATE, days since the Unix epoch, of the day
+// of t, or nil if ok is false.
+func parquetDate(t time.Time, ok bool) *int32 {
+	if !ok {
+		return nil
+	}
+	y, m, d := t.Date()
+	days := int32(time.Date(y, m, d, 0, 0, 0, 0, time.UTC).Unix() / 86400)
+	return &days
+}
+
+// writeParquet writes rows to the Parquet file at path, compressed with
+// Snappy. As workbooks are, the file is written next to path and renamed
+// into place once complete.
+func writeParquet[T any](ctx context.Context, path string, rows []T) error {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return fmt.Errorf("error creating output directory: %w", err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	pw, err := writer.NewParquetWriterFromWriter(tmp, new(T), 1)
+	if err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	pw.CompressionType = parquet.CompressionCodec_SNAPPY
+	for _, row := range rows {
+		if err := ctx.Err(); err != nil {
+			tmp.Close()
+			return err
+		}
+		if err := pw.Write(row); err != nil {
+			tmp.Close()
+			return fmt.Errorf("failed to write %s: %w", path, err)
+		}
+	}
+	if err := pw.WriteStop(); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	return nil
+}
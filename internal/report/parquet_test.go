@@ -0,0 +1,162 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/xitongsys/parquet-go/reader"
+	"github.com/xitongsys/parquet-go/source"
+
+	"viking-reports/internal/config"
+	"viking-reports/internal/repository"
+)
+
+// memFile is a Parquet file read from memory; each Open starts a new reader
+// over the same bytes, as the Parquet reader opens one per column.
+type memFile struct {
+	data []byte
+	*bytes.Reader
+}
+
+func (f *memFile) Open(string) (source.ParquetFile, error) {
+	return &memFile{f.data, bytes.NewReader(f.data)}, nil
+}
+func (f *memFile) Create(string) (source.ParquetFile, error) { return f.Open("") }
+func (f *memFile) Write([]byte) (int, error)                 { return 0, os.ErrPermission }
+func (f *memFile) Close() error                              { return nil }
+
+// readParquet returns the rows of the Parquet file at path.
+func readParquet[T any](t *testing.T, path string) []T {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, _ := (&memFile{data: data}).Open("")
+	pr, err := reader.NewParquetReader(file, new(T), 1)
+	if err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	defer pr.ReadStop()
+	rows := make([]T, pr.GetNumRows())
+	if err := pr.Read(&rows); err != nil {
+		t.Fatalf("reading %s: %v", path, err)
+	}
+	return rows
+}
+
+func TestParquetExport(t *testing.T) {
+	cfg := writeInputs(t)
+	ctx := context.Background()
+	for _, name := range []string{"credit", "growth", "zso", "parquet"} {
+		generator, err := NewReportGenerator(name, cfg)
+		if err != nil {
+			t.Fatalf("NewReportGenerator(%s): %v", name, err)
+		}
+		if err := generator.Generate(ctx); err != nil {
+			t.Fatalf("%s: Generate: %v", name, err)
+		}
+	}
+
+	partition := func(dataset string) string {
+		return filepath.Join(cfg.OutputDir, "parquet", dataset, "report_date="+time.Now().Format("2006-01-02"), dataset+".parquet")
+	}
+	for _, dataset := range parquetDatasets {
+		if _, err := os.Stat(partition(dataset.name)); err != nil {
+			t.Errorf("dataset %s not written: %v", dataset.name, err)
+		}
+	}
+
+	bills, err := repository.Bills(ctx, nil, cfg.ReportFiles.CreditReport.Bills, discardLogger())
+	if err != nil {
+		t.Fatal(err)
+	}
+	billRows := readParquet[billRow](t, partition("bills"))
+	if len(billRows) != len(bills) {
+		t.Fatalf("bills: got %d rows, want %d", len(billRows), len(bills))
+	}
+	for i, bill := range bills {
+		row := billRows[i]
+		if row.RefNo != bill.RefNo || row.PendingAmount != bill.PendingAmount || int(row.AgeDays) != bill.AgeOfBill {
+			t.Errorf("bills row %d = %+v, want bill %+v", i, row, bill)
+		}
+		if date, ok := repository.ParseBillDate(bill.Date); ok != (row.BillDate != nil) ||
+			ok && time.Unix(int64(*row.BillDate)*86400, 0).UTC().Format("2006-01-02") != date.Format("2006-01-02") {
+			t.Errorf("bills row %d: bill_date %v does not match %q", i, row.BillDate, bill.Date)
+		}
+	}
+
+	// The computed tables match the rows of their workbooks.
+	_, growth, err := readPackSection(ctx, cfg, mustPackSection(t, "Growth"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	growthRows := readParquet[growthRow](t, partition("growth"))
+	if len(growthRows) != len(growth) || len(growthRows) == 0 {
+		t.Fatalf("growth: got %d rows, want %d", len(growthRows), len(growth))
+	}
+	for i, r := range growth {
+		row := growthRows[i]
+		if row.DealerCode != r["Dealer Code"] || row.MTDSO != int64(parseNumber(r["MTD SO"])) || row.GrowthSO != parseNumber(r["Growth SO %"]) {
+			t.Errorf("growth row %d = %+v, want %v", i, row, r)
+		}
+	}
+
+	// A retailer missing from the TSE mapping has no TSE.
+	zso := readParquet[zsoRow](t, partition("zso"))
+	if len(zso) == 0 {
+		t.Fatal("zso: no rows")
+	}
+	for _, row := range zso {
+		if row.DealerName == "" || row.Model == "" {
+			t.Errorf("zso row with blank fields: %+v", row)
+		}
+	}
+}
+
+func TestParquetExportWithholdsEncryptedReports(t *testing.T) {
+	cfg := writeInputs(t)
+	ctx := context.Background()
+	for _, name := range []string{"credit", "growth", "zso"} {
+		generator, err := NewReportGenerator(name, cfg)
+		if err != nil {
+			t.Fatalf("NewReportGenerator(%s): %v", name, err)
+		}
+		if err := generator.Generate(ctx); err != nil {
+			t.Fatalf("%s: Generate: %v", name, err)
+		}
+	}
+
+	cfg.Protection = config.Protection{Reports: []string{"credit"}, Encrypt: true}
+	summary := &Summary{}
+	generator, err := NewReportGenerator("parquet", cfg, WithSummary(summary))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := generator.Generate(ctx); err != nil {
+		t.Fatal(err)
+	}
+	day := "report_date=" + time.Now().Format("2006-01-02")
+	if _, err := os.Stat(filepath.Join(cfg.OutputDir, "parquet", "credit", day, "credit.parquet")); !os.IsNotExist(err) {
+		t.Errorf("credit dues exported unencrypted (stat err %v)", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.OutputDir, "parquet", "growth", day, "growth.parquet")); err != nil {
+		t.Errorf("growth not exported: %v", err)
+	}
+	if summary.Count("parquet", Skipped) != 1 {
+		t.Errorf("want the credit dataset recorded as skipped, got %+v", summary.Results())
+	}
+}
+
+func mustPackSection(t *testing.T, sheet string) packSection {
+	t.Helper()
+	section, ok := packSectionOf(sheet)
+	if !ok {
+		t.Fatalf("no pack section %s", sheet)
+	}
+	return section
+}
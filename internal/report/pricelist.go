@@ -1,7 +1,9 @@
 package report
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"strings"
@@ -10,46 +12,66 @@ import (
 	"viking-reports/internal/repository"
 	"viking-reports/internal/utils"
 	"viking-reports/pkg/excel"
-
-	"github.com/xuri/excelize/v2"
+	"viking-reports/pkg/output"
 )
 
+func init() {
+	Register(Definition{
+		Name:        "pricelist",
+		Description: "Flat monthly price list of SKUs with their material codes",
+		Inputs: func(cfg *config.Config) []Input {
+			return []Input{
+				{"ZD PRICE LIST", cfg.ReportFiles.PriceListFile, repository.ZDPriceListSchema},
+				{"DealerInventory", cfg.ReportFiles.InventoryReport, repository.InventorySchema},
+			}
+		},
+		New: func(cfg *config.Config, opts ...Option) ReportGenerator {
+			return NewPriceListGenerator(cfg, opts...)
+		},
+	})
+}
+
 type PriceListGenerator struct {
 	cfg           *config.Config
 	priceListRepo repository.PriceListRepository
+	logger        *slog.Logger
 }
 
-func NewPriceListGenerator(cfg *config.Config) *PriceListGenerator {
+func NewPriceListGenerator(cfg *config.Config, opts ...Option) *PriceListGenerator {
+	o := newOptions(cfg, "pricelist", opts)
 	return &PriceListGenerator{
 		cfg:           cfg,
-		priceListRepo: repository.NewExcelPriceListRepository(cfg.ReportFiles.PriceListFile, cfg.ReportFiles.InventoryReport),
+		priceListRepo: o.repos.PriceList,
+		logger:        o.logger,
 	}
 }
 
-func (p *PriceListGenerator) Generate() error {
+func (p *PriceListGenerator) Generate(ctx context.Context) error {
 	currentMonthYear := time.Now().Format("January 2006")
-	fmt.Printf("\nGenerating flat price list of SKUs for the month of %s \n", currentMonthYear)
-	priceData, err := p.priceListRepo.GetPriceListData()
+	p.logger.Info("generating flat price list of SKUs", "month", currentMonthYear)
+	priceData, err := p.priceListRepo.GetPriceListData(ctx)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("Price list generated successfully, total SKUs: %d\n", len(priceData))
-	fmt.Printf("Read inventory data to generate material code for the month of %s \n", currentMonthYear)
+	p.logger.Info("price list read", "skus", len(priceData))
 
-	materialCodeMap, err := p.priceListRepo.GetMaterialCodeMap()
+	materialCodeMap, err := p.priceListRepo.GetMaterialCodeMap(ctx)
 	if err != nil {
 		return err
 	}
-	fmt.Printf("Material code map generated successfully, Size: %d\n", len(materialCodeMap))
+	p.logger.Info("material code map generated", "material_codes", len(materialCodeMap))
 
 	outputDir := utils.GenerateMonthlyOutputPath(p.cfg.OutputDir, "price_list")
-	p.writePriceList(outputDir, priceData, materialCodeMap)
-	fmt.Printf("\nPrice list written successfully in: %s\n", outputDir)
+	if err := p.writePriceList(ctx, outputDir, priceData, materialCodeMap); err != nil {
+		return fmt.Errorf("error writing price list: %w", err)
+	}
+	p.logger.Info("price list generated", "output", outputDir)
 	return nil
 }
 
-func (p *PriceListGenerator) writePriceList(outputDir string, priceData []repository.PriceListRow, materialCodeMap map[string]int) error {
-	f := excel.NewFile()
+func (p *PriceListGenerator) writePriceList(ctx context.Context, outputDir string, priceData []repository.PriceListRow, materialCodeMap map[string]int) error {
+	result := output.NewResult()
+	f := result.Workbook
 	sheetName := "Price List"
 
 	// Create a new sheet
@@ -62,32 +84,26 @@ func (p *PriceListGenerator) writePriceList(outputDir string, priceData []reposi
 		return fmt.Errorf("error creating output directory: %w", err)
 	}
 
-	// Custom number format for Indian numbering
-	inrFormat := "#,##,##0"
-	numberStyle, err := f.NewStyle(&excelize.Style{
-		CustomNumFmt: &inrFormat,
-		Border: []excelize.Border{
-			{Type: "left", Color: "000000", Style: 1},
-			{Type: "top", Color: "000000", Style: 1},
-			{Type: "bottom", Color: "000000", Style: 1},
-			{Type: "right", Color: "000000", Style: 1},
+	table := excel.Table{
+		Sheet: sheetName,
+		Columns: []excel.Column{
+			{Header: "Type"},
+			{Header: "Model"},
+			{Header: "Color"},
+			{Header: "Variant"},
+			{Header: "NLC", Type: excel.Number, NumFmt: excel.INR},
+			{Header: "MOP", Type: excel.Number, NumFmt: excel.INR},
+			{Header: "MRP", Type: excel.Number, NumFmt: excel.INR},
+			{Header: "Material Code"},
 		},
-	})
-	if err != nil {
-		return fmt.Errorf("failed to create number style: %w", err)
+		FreezeHeader: true,
+		AutoFilter:   true,
 	}
-
-	// Write headers
-	headers := []string{"Type", "Model", "Color", "Variant", "NLC", "MOP", "MRP", "Material Code"} // Adjust headers as needed
-	if err := excel.WriteHeaders(f, sheetName, headers); err != nil {
-		return err
-	}
-
-	// Write data rows
-	for rowIndex, item := range priceData {
+	rows := make([][]interface{}, 0, len(priceData))
+	for _, item := range priceData {
 		// Create a unique key based on item.Model, item.Color, and combined Storage and Memory
 		key := fmt.Sprintf("%s|%s|%s", item.Model, item.Color, (item.Storage + " " + item.Memory))
-		cellData := []interface{}{
+		rows = append(rows, []interface{}{
 			item.Type,
 			item.Model,
 			item.Color,
@@ -96,20 +112,12 @@ func (p *PriceListGenerator) writePriceList(outputDir string, priceData []reposi
 			item.Mop,
 			item.Mrp,
 			fmt.Sprintf("%d", materialCodeMap[strings.ToLower(key)]),
-		}
-
-		if err := excel.WriteRow(f, sheetName, rowIndex+2, cellData); err != nil {
-			return err
-		}
-
-		// Apply number style to price column
-		cell := fmt.Sprintf("C%d", rowIndex+2) // Assuming Price is in column C
-		if err := f.SetCellStyle(sheetName, cell, cell, numberStyle); err != nil {
-			return fmt.Errorf("error setting style for cell %s: %w", cell, err)
-		}
+		})
+	}
+	if err := result.Add(sheetName, &table, rows); err != nil {
+		return err
 	}
 
-	excel.AdjustColumnWidths(f, sheetName)
 	outputPath := filepath.Join(outputDir, "price_list.xlsx")
-	return f.SaveAs(outputPath)
+	return output.Save(ctx, result, outputPath)
 }
@@ -1,7 +1,9 @@
 package report
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
@@ -9,27 +11,44 @@ import (
 	"viking-reports/internal/repository"
 	"viking-reports/internal/utils"
 	"viking-reports/pkg/excel"
-
-	"github.com/xuri/excelize/v2"
+	"viking-reports/pkg/output"
 )
 
+func init() {
+	Register(Definition{
+		Name:        "ranorms",
+		Description: "Units each RA retailer needs to refill per model to meet its norms",
+		Inputs: func(cfg *config.Config) []Input {
+			return []Input{
+				{"Retailer Metadata", cfg.CommonFiles.TSEMapping, repository.RetailerMetadataSchema},
+				{"DealerInventory", cfg.ReportFiles.InventoryReport, repository.InventorySchema},
+			}
+		},
+		New: func(cfg *config.Config, opts ...Option) ReportGenerator {
+			return NewRANormsReportGenerator(cfg, opts...)
+		},
+	})
+}
+
 type RANormsReportGenerator struct {
 	cfg            *config.Config
 	inventoryRepo  repository.InventoryRepository
 	tseMappingRepo repository.TSEMappingRepository
+	logger         *slog.Logger
 }
 
-func NewRANormsReportGenerator(cfg *config.Config) *RANormsReportGenerator {
-
+func NewRANormsReportGenerator(cfg *config.Config, opts ...Option) *RANormsReportGenerator {
+	o := newOptions(cfg, "ranorms", opts)
 	return &RANormsReportGenerator{
 		cfg:            cfg,
-		inventoryRepo:  repository.NewSPUInventoryRepository(cfg.ReportFiles.InventoryReport),
-		tseMappingRepo: repository.NewExcelTSEMappingRepository(cfg.CommonFiles.TSEMapping),
+		inventoryRepo:  o.repos.Inventory,
+		tseMappingRepo: o.repos.TSEMapping,
+		logger:         o.logger,
 	}
 }
 
-func (g *RANormsReportGenerator) Generate() error {
-	fmt.Println("Generating Retailer Agreement (RA) Norms report...")
+func (g *RANormsReportGenerator) Generate(ctx context.Context) error {
+	g.logger.Info("generating Retailer Agreement (RA) norms report")
 	// Define the filter list as a map for easier comparison
 	modelsOfInterest := map[string]struct{}{
 		"C61":        {},
@@ -43,32 +62,26 @@ func (g *RANormsReportGenerator) Generate() error {
 		"GT 6T":      {},
 		"GT6":        {},
 	}
-	raRetailers, err := g.tseMappingRepo.GetRARetailersMap()
+	raRetailers, err := g.tseMappingRepo.GetRARetailersMap(ctx)
 	if err != nil {
 		return fmt.Errorf("error reading TSE mapping: %w", err)
 	}
-	retailerCodeToTSEMap, err := g.tseMappingRepo.GetRetailerCodeToTSEMap()
+	retailerCodeToTSEMap, err := g.tseMappingRepo.GetRetailerCodeToTSEMap(ctx)
 	if err != nil {
 		return fmt.Errorf("error reading TSE mapping: %w", err)
 	}
 
-	retailerCodeToNameMap, err := g.tseMappingRepo.GetRetailerCodeToNameMap()
+	retailerCodeToNameMap, err := g.tseMappingRepo.GetRetailerCodeToNameMap(ctx)
 	if err != nil {
 		return fmt.Errorf("error reading TSE mapping: %w", err)
 	}
-	raDealerInventory, err := g.inventoryRepo.ComputeRADealerSPUInventory(modelsOfInterest, raRetailers)
+	raDealerInventory, err := g.inventoryRepo.ComputeRADealerSPUInventory(ctx, modelsOfInterest, raRetailers)
 	if err != nil {
 		return fmt.Errorf("error reading inventory data: %w", err)
 	}
 
 	// Compute RA norms using the raRetailers map
-	fmt.Println("Start computation of RA norms report.")
-	fmt.Print("Identifying RA norms for ")
-	for model := range modelsOfInterest {
-		fmt.Print(model)
-		fmt.Print(" ")
-	}
-	fmt.Println()
+	g.logger.Info("identifying RA norms", "models", sortedModels(modelsOfInterest), "ra_retailers", len(raRetailers))
 	raNormsData, err := g.computeRANorms(raRetailers, raDealerInventory, modelsOfInterest)
 	if err != nil {
 		return fmt.Errorf("error computing RA norms: %w", err)
@@ -78,7 +91,7 @@ func (g *RANormsReportGenerator) Generate() error {
 	// Generate and save the Excel report
 	outputDir := utils.GenerateOutputPath(g.cfg.OutputDir, "ranorms_report")
 
-	if err := g.writeRANormsReport(raNormsData, retailerCodeToTSEMap, retailerCodeToNameMap, modelsOfInterest, outputDir); err != nil {
+	if err := g.writeRANormsReport(ctx, raNormsData, retailerCodeToTSEMap, retailerCodeToNameMap, modelsOfInterest, outputDir); err != nil {
 		return fmt.Errorf("error writing RA norms report: %w", err)
 	}
 
@@ -127,8 +140,9 @@ func (g *RANormsReportGenerator) computeRANorms(raRetailers map[string]int, raDe
 }
 
 // Write the RA Norms refill report to Excel
-func (g *RANormsReportGenerator) writeRANormsReport(raNormsData map[string]map[string]int, retailerCodeToTSEMap map[string]string, retailerCodeToNameMap map[string]string, modelsOfInterest map[string]struct{}, outputDir string) error {
-	f := excelize.NewFile()
+func (g *RANormsReportGenerator) writeRANormsReport(ctx context.Context, raNormsData map[string]map[string]int, retailerCodeToTSEMap map[string]string, retailerCodeToNameMap map[string]string, modelsOfInterest map[string]struct{}, outputDir string) error {
+	result := output.NewResult()
+	f := result.Workbook
 	sheetName := "RA Norms Report"
 	f.NewSheet(sheetName)
 	f.DeleteSheet("Sheet1")
@@ -137,35 +151,22 @@ func (g *RANormsReportGenerator) writeRANormsReport(raNormsData map[string]map[s
 		return fmt.Errorf("error creating output directory: %w", err)
 	}
 
-	// Define styles
-	headerStyle, _ := f.NewStyle(&excelize.Style{
-		Fill:   excelize.Fill{Type: "pattern", Color: []string{"FFFF00"}, Pattern: 1}, // Yellow background for header
-		Font:   &excelize.Font{Bold: true},
-		Border: createBorderStyle(),
-	})
-	refillCellStyle, _ := f.NewStyle(&excelize.Style{
-		Fill:   excelize.Fill{Type: "pattern", Color: []string{"FFCCCC"}, Pattern: 1}, // Light red for refill cells
-		Border: createBorderStyle(),
-	})
-	defaultCellStyle, _ := f.NewStyle(&excelize.Style{
-		Border: createBorderStyle(),
-	})
-
-	// Build headers
-	headers := []string{"TSE", "Dealer Name"}
-	for model := range modelsOfInterest {
-		headers = append(headers, model) // Add model names as headers
+	// Build headers: the models in name order, then the total
+	table := excel.Table{
+		Sheet:         sheetName,
+		Columns:       []excel.Column{{Header: "TSE"}, {Header: "Dealer Name"}},
+		FreezeHeader:  true,
+		FreezeColumns: 2,
+		AutoFilter:    true,
+		GroupBy:       "TSE",
+		Totals:        true,
 	}
-	headers = append(headers, "Total Refill") // Add total refill column
-	// Sort headers alphabetically
-	sort.Strings(headers[2:]) // Sort the ZSO models in headers
-
-	// Write headers with style
-	for col, header := range headers {
-		cell, _ := excelize.CoordinatesToCellName(col+1, 1)
-		f.SetCellValue(sheetName, cell, header)
-		f.SetCellStyle(sheetName, cell, cell, headerStyle)
+	models := sortedModels(modelsOfInterest)
+	refill := []excel.Rule{{Op: ">", Value: 0, Highlight: excel.Highlight{Fill: "FFCCCC"}}} // Light red for refill cells
+	for _, model := range models {
+		table.Columns = append(table.Columns, excel.Column{Header: model, Type: excel.Number, Sum: true, Rules: refill})
 	}
+	table.Columns = append(table.Columns, excel.Column{Header: "Total Refill", Type: excel.Number, Sum: true})
 
 	// Sort dealers by TSE name for organized reporting
 	var dealers []string
@@ -173,46 +174,48 @@ func (g *RANormsReportGenerator) writeRANormsReport(raNormsData map[string]map[s
 		dealers = append(dealers, dealer)
 	}
 	sort.Slice(dealers, func(i, j int) bool {
+		if retailerCodeToTSEMap[dealers[i]] == retailerCodeToTSEMap[dealers[j]] {
+			return dealers[i] < dealers[j]
+		}
 		return retailerCodeToTSEMap[dealers[i]] < retailerCodeToTSEMap[dealers[j]]
 	})
 
-	// Write RA norms refill data
-	row := 2
+	// Write RA norms refill data, leaving the models needing no refill blank
+	var rows [][]interface{}
 	for _, dealerCode := range dealers {
 		modelRefill := raNormsData[dealerCode]
-		tseCell, _ := excelize.CoordinatesToCellName(1, row)
-		f.SetCellValue(sheetName, tseCell, retailerCodeToTSEMap[dealerCode])
-		f.SetCellStyle(sheetName, tseCell, tseCell, defaultCellStyle)
-
-		dealerCell, _ := excelize.CoordinatesToCellName(2, row)
-		f.SetCellValue(sheetName, dealerCell, retailerCodeToNameMap[dealerCode])
-		f.SetCellStyle(sheetName, dealerCell, dealerCell, defaultCellStyle)
-
+		row := []interface{}{retailerCodeToTSEMap[dealerCode], retailerCodeToNameMap[dealerCode]}
 		totalRefill := 0
-		col := 3
-		for _, model := range headers[2 : len(headers)-1] { // Exclude "Total Refill"
-			cell, _ := excelize.CoordinatesToCellName(col, row)
+		for _, model := range models {
 			if requiredRefill, exists := modelRefill[model]; exists && requiredRefill > 0 {
-				f.SetCellValue(sheetName, cell, requiredRefill)
-				f.SetCellStyle(sheetName, cell, cell, refillCellStyle)
+				row = append(row, requiredRefill)
 				totalRefill += requiredRefill
 			} else {
-				f.SetCellStyle(sheetName, cell, cell, defaultCellStyle)
+				row = append(row, nil)
 			}
-			col++
 		}
-
-		// Set total refill value
-		totalCell, _ := excelize.CoordinatesToCellName(col, row)
-		f.SetCellValue(sheetName, totalCell, totalRefill)
-		f.SetCellStyle(sheetName, totalCell, totalCell, defaultCellStyle)
-		row++
+		rows = append(rows, append(row, totalRefill))
+	}
+	if err := result.Add(sheetName, &table, rows); err != nil {
+		return err
 	}
 
 	// Save report to output directory
 	outputPath := filepath.Join(outputDir, "ra_norms_report.xlsx")
-	excel.AdjustColumnWidths(f, sheetName)
-	fmt.Printf("RA report generated successfully: %s\n", outputDir)
-	return f.SaveAs(outputPath)
+	if err := output.Save(ctx, result, outputPath); err != nil {
+		return err
+	}
+	g.logger.Info("RA norms report generated", "output", outputDir)
+	return nil
+
+}
 
+// sortedModels lists the models of interest in a stable order for logging.
+func sortedModels(modelsOfInterest map[string]struct{}) []string {
+	models := make([]string, 0, len(modelsOfInterest))
+	for model := range modelsOfInterest {
+		models = append(models, model)
+	}
+	sort.Strings(models)
+	return models
 }
@@ -0,0 +1,128 @@
+package report
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"sync"
+	"viking-reports/internal/config"
+	"viking-reports/internal/repository"
+)
+
+// Input is an input file a report reads.
+type Input struct {
+	Name   string // short name, e.g. "MTD-SO"
+	Path   string
+	Schema repository.Schema // layout the report's repositories read
+}
+
+// Definition describes a report to the registry.
+type Definition struct {
+	Name        string
+	Description string
+	// Inputs lists the files the report reads under cfg.
+	Inputs func(cfg *config.Config) []Input
+	// DependsOn names the reports whose output this report reads, which
+	// must run before it.
+	DependsOn []string
+	// PerTSE reports whether the report writes a workbook per TSE, which
+	// Protection.Encrypt opens with that TSE's password.
+	PerTSE bool
+	// New builds the generator of the report.
+	New func(cfg *config.Config, opts ...Option) ReportGenerator
+}
+
+// MissingInputs returns the inputs of the report that do not exist.
+func (d Definition) MissingInputs(cfg *config.Config) []Input {
+	if d.Inputs == nil {
+		return nil
+	}
+	var missing []Input
+	for _, in := range d.Inputs(cfg) {
+		if _, err := os.Stat(in.Path); err != nil {
+			missing = append(missing, in)
+		}
+	}
+	return missing
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Definition)
+)
+
+// Register makes a report available by name to NewReportGenerator, the CLI
+// and the pipeline. Reports register themselves from an init function, so a
+// report in another package is added by importing that package. Register
+// panics if the name is empty, already registered, or the definition has no
+// constructor.
+func Register(def Definition) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+	if def.Name == "" || def.New == nil {
+		panic("report: Register needs a name and a constructor")
+	}
+	if _, dup := registry[def.Name]; dup {
+		panic("report: Register called twice for report " + def.Name)
+	}
+	registry[def.Name] = def
+}
+
+// Lookup returns the definition of the named report.
+func Lookup(name string) (Definition, bool) {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	def, ok := registry[name]
+	return def, ok
+}
+
+// Definitions returns every registered report, sorted by name.
+func Definitions() []Definition {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	defs := make([]Definition, 0, len(registry))
+	for _, def := range registry {
+		defs = append(defs, def)
+	}
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
+	return defs
+}
+
+// Plan returns the named reports together with the reports they depend on,
+// ordered so that every report comes after its dependencies. Independent
+// reports keep the order they were given in.
+func Plan(names ...string) ([]Definition, error) {
+	var plan []Definition
+	state := make(map[string]int) // 1 while visiting, 2 once planned
+	var visit func(name string, path []string) error
+	visit = func(name string, path []string) error {
+		switch state[name] {
+		case 1:
+			return fmt.Errorf("report dependency cycle: %v", append(path, name))
+		case 2:
+			return nil
+		}
+		def, ok := Lookup(name)
+		if !ok {
+			if len(path) > 0 {
+				return fmt.Errorf("unknown report %s, required by %s", name, path[len(path)-1])
+			}
+			return fmt.Errorf("unknown report type: %s", name)
+		}
+		state[name] = 1
+		for _, dep := range def.DependsOn {
+			if err := visit(dep, append(path, name)); err != nil {
+				return err
+			}
+		}
+		state[name] = 2
+		plan = append(plan, def)
+		return nil
+	}
+	for _, name := range names {
+		if err := visit(name, nil); err != nil {
+			return nil, err
+		}
+	}
+	return plan, nil
+}
@@ -0,0 +1,68 @@
+package report
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"viking-reports/internal/config"
+)
+
+type nopGenerator struct{}
+
+func (nopGenerator) Generate(context.Context) error { return nil }
+
+func registerTest(name string, dependsOn ...string) {
+	Register(Definition{
+		Name:      name,
+		DependsOn: dependsOn,
+		New:       func(*config.Config, ...Option) ReportGenerator { return nopGenerator{} },
+	})
+}
+
+func init() {
+	registerTest("test-base")
+	registerTest("test-middle", "test-base")
+	registerTest("test-top", "test-middle", "test-base")
+	registerTest("test-cycle-a", "test-cycle-b")
+	registerTest("test-cycle-b", "test-cycle-a")
+}
+
+func TestPlanOrdersDependenciesFirst(t *testing.T) {
+	plan, err := Plan("test-top", "growth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, def := range plan {
+		names = append(names, def.Name)
+	}
+	if got, want := strings.Join(names, " "), "test-base test-middle test-top growth"; got != want {
+		t.Errorf("plan = %s, want %s", got, want)
+	}
+}
+
+func TestPlanErrors(t *testing.T) {
+	if _, err := Plan("test-cycle-a"); err == nil || !strings.Contains(err.Error(), "cycle") {
+		t.Errorf("got %v, want a dependency cycle error", err)
+	}
+	if _, err := Plan("nope"); err == nil {
+		t.Error("expected an error for an unknown report")
+	}
+}
+
+func TestBuiltInReportsRegistered(t *testing.T) {
+	for _, name := range []string{"cogs", "credit", "growth", "pricelist", "ranorms", "salestarget", "zso"} {
+		def, ok := Lookup(name)
+		if !ok {
+			t.Errorf("%s is not registered", name)
+			continue
+		}
+		if def.Description == "" || def.Inputs == nil {
+			t.Errorf("%s has no description or inputs", name)
+		}
+	}
+	if _, err := Plan("cogs"); err != nil {
+		t.Errorf("planning cogs: %v", err)
+	}
+}
@@ -1,50 +1,68 @@
 package report
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"viking-reports/internal/config"
 	"viking-reports/internal/repository"
 	"viking-reports/internal/utils"
 	"viking-reports/pkg/excel"
-
-	"github.com/xuri/excelize/v2"
+	"viking-reports/pkg/output"
 )
 
 // ... existing code ...
 
+func init() {
+	Register(Definition{
+		Name:        "salestarget",
+		Description: "Monthly sales against target per TSE for smart phones, accessories and others",
+		Inputs: func(cfg *config.Config) []Input {
+			return []Input{
+				{"Sales", cfg.ReportFiles.SalesReport, repository.SalesRegisterSchema},
+				{"Retailer Metadata", cfg.CommonFiles.TSEMapping, repository.RetailerMetadataSchema},
+			}
+		},
+		New: func(cfg *config.Config, opts ...Option) ReportGenerator {
+			return NewSalesTargetGenerator(cfg, opts...)
+		},
+	})
+}
+
 type SalesTargetGenerator struct {
 	cfg *config.Config
 	//tseMappingRepo  repository.TSEMappingRepository
 	salesTargetRepo repository.SalesTargetRepository
 	tseMappingRepo  repository.TSEMappingRepository
+	logger          *slog.Logger
 }
 
-func NewSalesTargetGenerator(cfg *config.Config) *SalesTargetGenerator {
-	tseMappingRepo := repository.NewExcelTSEMappingRepository(cfg.CommonFiles.TSEMapping)
+func NewSalesTargetGenerator(cfg *config.Config, opts ...Option) *SalesTargetGenerator {
+	o := newOptions(cfg, "salestarget", opts)
 	return &SalesTargetGenerator{
 		cfg:             cfg,
-		salesTargetRepo: repository.NewExcelSalesTargetRepository(),
-		tseMappingRepo:  tseMappingRepo,
+		salesTargetRepo: o.repos.SalesTarget,
+		tseMappingRepo:  o.repos.TSEMapping,
+		logger:          o.logger,
 	}
 }
 
-func (s *SalesTargetGenerator) Generate() error {
-	fmt.Println("Generating sales target report for TSE.")
+func (s *SalesTargetGenerator) Generate(ctx context.Context) error {
+	s.logger.Info("generating sales target report for TSEs")
 
-	tseMap, _ := s.tseMappingRepo.GetRetailerCodeToTSEMap()
-
-	fileName := filepath.Base(s.cfg.ReportFiles.SalesReport) // {{ edit_1 }}
-	fmt.Printf("** Input: Fetching monthly sales (%s) from Tally **", fileName)
+	tseMap, err := s.tseMappingRepo.GetRetailerCodeToTSEMap(ctx)
+	if err != nil {
+		return fmt.Errorf("error reading TSE mapping: %w", err)
+	}
 
-	sales, err := s.salesTargetRepo.ReadSales(s.cfg.ReportFiles.SalesReport, tseMap)
+	sales, err := s.salesTargetRepo.ReadSales(ctx, s.cfg.ReportFiles.SalesReport, tseMap)
 	if err != nil {
 		return fmt.Errorf("error : %w", err)
 	}
-	fmt.Println()
-	fmt.Println("\n== Begin processing! ==")
 	// Create separate maps for SMART, ACCESSORIES, and others
 	// Change maps to slices
 	var smartPhoneSales []*repository.SalesData  // {{ edit_1 }}
@@ -64,7 +82,8 @@ func (s *SalesTargetGenerator) Generate() error {
 		}
 	}
 
-	reportFile := excel.NewFile()
+	result := output.NewResult()
+	reportFile := result.Workbook
 	outputDir := utils.GenerateOutputPath(s.cfg.OutputDir, "sales_report")
 	salesTargetSheet := "Sales Target"
 	// Create a new sheet
@@ -77,49 +96,63 @@ func (s *SalesTargetGenerator) Generate() error {
 		return fmt.Errorf("error creating output directory: %w", err)
 	}
 
+	// Each category's chart of target against achieved is drawn below the
+	// last, since the categories' tables are too short to hold them.
+	charts := make([]*excel.Placement, 3)
+	if at, ok := chartPlacement(s.cfg, "salestarget", 0, 0); ok {
+		if at.Cell == "" {
+			at.Cell = "A1"
+			if at.Sheet == "" {
+				at.Cell = "G1"
+			}
+		}
+		for i := range charts {
+			chart := at
+			charts[i] = &chart
+			if at, err = at.Below(); err != nil {
+				return fmt.Errorf("invalid chart cell %s: %w", chart.Cell, err)
+			}
+		}
+	}
+
 	// Invoke writeSalesReport for each category
-	fmt.Println("Write monthly sales of SMART PHONES")
 	// Create a map for TSE overall targets
 	smartPhoneTargets := map[string]int{
 		"Krishna": 2490,
 		"Sathish": 1900,
 		"Harish":  600,
 	}
-	if err := s.writeSalesTarget(reportFile, salesTargetSheet, smartPhoneSales, smartPhoneTargets, "SMART PHONES", 1); err != nil {
+	if err := s.writeSalesTarget(result, salesTargetSheet, smartPhoneSales, smartPhoneTargets, "SMART PHONES", 1, charts[0]); err != nil {
 		return fmt.Errorf("error writing smartphone sales report: %w", err)
 	}
-	fmt.Println()
-	fmt.Println("Write monthly sales of ACCESSORIES")
 	// Create a map for TSE overall targets
 	accessTarget := map[string]int{
 		"Krishna": 1000,
 		"Sathish": 800,
 		"Harish":  600,
 	}
-	if err := s.writeSalesTarget(reportFile, salesTargetSheet, accessoriesSales, accessTarget, "ACCESSORIES", 8); err != nil {
+	if err := s.writeSalesTarget(result, salesTargetSheet, accessoriesSales, accessTarget, "ACCESSORIES", 8, charts[1]); err != nil {
 		return fmt.Errorf("error writing accessories sales report: %w", err)
 	}
-	fmt.Println()
-	fmt.Println("Write monthly sales of OTHERS")
-	if err := s.writeSalesTarget(reportFile, salesTargetSheet, otherSales, accessTarget, "OTHERS", 15); err != nil {
+	if err := s.writeSalesTarget(result, salesTargetSheet, otherSales, accessTarget, "OTHERS", 15, charts[2]); err != nil {
 		return fmt.Errorf("error writing other sales report: %w", err)
 	}
 	excel.AdjustColumnWidths(reportFile, salesTargetSheet)
 	fileName1 := "sales_report.xlsx"
 	outputPath := filepath.Join(outputDir, fileName1)
-	reportFile.SaveAs(outputPath)
+	if err := output.Save(ctx, result, outputPath); err != nil {
+		return fmt.Errorf("error saving sales report: %w", err)
+	}
 
-	fmt.Println("== End processing! ==")
-	fmt.Println()
-	fmt.Printf("** Output: Sales report generated successfully in: %s ** \n", outputDir)
+	s.logger.Info("sales target report generated", "output", outputDir)
 	return nil
 }
 
-func (g *SalesTargetGenerator) writeSalesTarget(f *excelize.File, salesReportSheet string, sales []*repository.SalesData,
-	tseSalesTarget map[string]int, productType string, startRow int) error {
+func (g *SalesTargetGenerator) writeSalesTarget(result *output.Result, salesReportSheet string, sales []*repository.SalesData,
+	tseSalesTarget map[string]int, productType string, startRow int, chart *excel.Placement) error {
 
-	fmt.Printf("Compute and write overall targets for TSE for == %s ==\n", productType)
-	if err := excel.WriteHeadersIdx(f, salesReportSheet, []string{productType}, startRow, 5); err != nil {
+	g.logger.Info("writing monthly sales against target", "product_type", productType, "lines", len(sales))
+	if err := excel.WriteHeadersIdx(result.Workbook, salesReportSheet, []string{productType}, startRow, 5); err != nil {
 		return err
 	}
 
@@ -127,7 +160,7 @@ func (g *SalesTargetGenerator) writeSalesTarget(f *excelize.File, salesReportShe
 	targetHeaders := []string{"TSE", "Target: Overall", "Achieved", "Balance", "Balance %"}
 	// Write Overall Target
 
-	overallRow, err := g.writeTarget(sales, tseSalesTarget, f, salesReportSheet, targetHeaders, startRow)
+	overallRow, err := g.writeTarget(sales, tseSalesTarget, result, salesReportSheet, targetHeaders, startRow, productType, chart)
 	if err != nil {
 		return err
 	}
@@ -136,8 +169,8 @@ func (g *SalesTargetGenerator) writeSalesTarget(f *excelize.File, salesReportShe
 	return nil
 }
 
-func (*SalesTargetGenerator) writeTarget(sales []*repository.SalesData, target map[string]int, f *excelize.File,
-	salesReportSheet string, headers []string, startRow int) (int, error) {
+func (g *SalesTargetGenerator) writeTarget(sales []*repository.SalesData, target map[string]int, result *output.Result,
+	salesReportSheet string, headers []string, startRow int, title string, chart *excel.Placement) (int, error) {
 
 	salesAcheivedByTSE := make(map[string]*repository.SalesData)
 	for _, data := range sales {
@@ -159,78 +192,54 @@ func (*SalesTargetGenerator) writeTarget(sales []*repository.SalesData, target m
 		}
 	}
 
-	if err := excel.WriteHeadersIdx(f, salesReportSheet, headers, startRow, 0); err != nil {
-		return 0, err
+	always := func(fill string) func([]interface{}) excel.Highlight {
+		return func([]interface{}) excel.Highlight { return excel.Highlight{Fill: fill} }
 	}
-	targetRow := startRow + 1
-
-	greenStyle, _ := f.NewStyle(&excelize.Style{ // {{ edit_1 }}
-		Fill: excelize.Fill{Type: "pattern", Color: []string{"00FF00"}, Pattern: 1},
-		Border: []excelize.Border{
-			{Type: "left", Color: "000000", Style: 1},
-			{Type: "top", Color: "000000", Style: 1},
-			{Type: "bottom", Color: "000000", Style: 1},
-			{Type: "right", Color: "000000", Style: 1},
-		},
-	})
-	lightYellowStyle, _ := f.NewStyle(&excelize.Style{ // {{ edit_2 }}
-		Fill: excelize.Fill{Type: "pattern", Color: []string{"FFE5B4"}, Pattern: 1},
-		Border: []excelize.Border{
-			{Type: "left", Color: "000000", Style: 1},
-			{Type: "top", Color: "000000", Style: 1},
-			{Type: "bottom", Color: "000000", Style: 1},
-			{Type: "right", Color: "000000", Style: 1},
+	table := excel.Table{
+		Sheet:    salesReportSheet,
+		StartRow: startRow,
+		Columns: []excel.Column{
+			{Header: headers[0]},
+			{Header: headers[1], Type: excel.Number},
+			{Header: headers[2], Type: excel.Number, Highlight: always("00FF00")},
+			{Header: headers[3], Type: excel.Number, Highlight: always("FFE5B4")},
+			{Header: headers[4], Type: excel.Number, NumFmt: "0.00"},
 		},
-	})
-	fmt.Println(headers)
-	for _, data := range salesAcheivedByTSE {
-		// Print each entry to the console
-		fmt.Printf("TSE: %s, MTDS: %d, Value: %d\n", data.TSE, data.MTDS, data.Value) // {{ edit_1 }}
 	}
 
-	for _, data := range salesAcheivedByTSE {
+	// Write TSEs in name order
+	tses := make([]string, 0, len(salesAcheivedByTSE))
+	for tse := range salesAcheivedByTSE {
+		tses = append(tses, tse)
+	}
+	sort.Strings(tses)
+
+	var rows [][]interface{}
+	for _, tse := range tses {
+		data := salesAcheivedByTSE[tse]
+		g.logger.Debug("sales achieved against target", "tse", data.TSE, "mtds", data.MTDS, "value", data.Value)
 		tgt := target[data.TSE]
 		bal := target[data.TSE] - data.MTDS
 		balPct := (float64(bal) / float64(tgt)) * 100.00
 
-		tseCellData := []interface{}{
+		rows = append(rows, []interface{}{
 			data.TSE,
 			tgt,
 			data.MTDS,
 			bal,
 			balPct,
-		}
-		if err := excel.WriteRow(f, salesReportSheet, targetRow, tseCellData); err != nil {
-			return 0, err
-		}
-
-		inrFormat := "0.00"
-		numberStyle, _ := f.NewStyle(&excelize.Style{
-			CustomNumFmt: &inrFormat,
-			Border: []excelize.Border{
-				{Type: "left", Color: "000000", Style: 1},
-				{Type: "top", Color: "000000", Style: 1},
-				{Type: "bottom", Color: "000000", Style: 1},
-				{Type: "right", Color: "000000", Style: 1},
-			},
 		})
-		col := 4
-		cell := fmt.Sprintf("%s%d", string('A'+col), targetRow)
-		if err := f.SetCellStyle(salesReportSheet, cell, cell, numberStyle); err != nil {
-			return targetRow, fmt.Errorf("error setting style for cell %s: %w", cell, err)
-		}
-
-		// Set styles for Achieved and Balance columns
-		achievedCell := fmt.Sprintf("%s%d", string('A'+2), targetRow) // Achieved column
-		if err := f.SetCellStyle(salesReportSheet, achievedCell, achievedCell, greenStyle); err != nil {
-			return targetRow, fmt.Errorf("error setting style for cell %s: %w", achievedCell, err)
-		}
-
-		balanceCell := fmt.Sprintf("%s%d", string('A'+3), targetRow) // Balance column
-		if err := f.SetCellStyle(salesReportSheet, balanceCell, balanceCell, lightYellowStyle); err != nil {
-			return targetRow, fmt.Errorf("error setting style for cell %s: %w", balanceCell, err)
+	}
+	// The categories share a sheet, so their tables are named by title.
+	if err := result.Add(title, &table, rows); err != nil {
+		return 0, err
+	}
+	if chart != nil {
+		c := excel.Chart{Title: title, Categories: headers[0], Series: headers[1:3]}
+		if err := table.AddChart(result.Workbook, len(rows), c, *chart); err != nil {
+			return 0, err
 		}
-		targetRow++
 	}
+	targetRow := startRow + 1 + len(rows)
 	return targetRow, nil
 }
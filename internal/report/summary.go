@@ -0,0 +1,182 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"sync"
+	"viking-reports/pkg/excel"
+)
+
+// Status is the outcome of one unit of a report run.
+type Status string
+
+const (
+	Succeeded Status = "succeeded"
+	Failed    Status = "failed"
+	// Skipped units were not attempted because the run was stopped first.
+	Skipped Status = "skipped"
+	// Unchanged reports were not regenerated by an incremental run since
+	// their inputs had not changed.
+	Unchanged Status = "unchanged"
+)
+
+// UnitResult is the outcome of one unit of a report run, typically the
+// workbook of one TSE.
+type UnitResult struct {
+	Report string `json:"report"`
+	Unit   string `json:"unit"`
+	File   string `json:"file,omitempty"`
+	Status Status `json:"status"`
+	Error  string `json:"error,omitempty"`
+}
+
+// Summary collects the unit results of a run. It is safe for concurrent use.
+type Summary struct {
+	mu      sync.Mutex
+	results []UnitResult
+}
+
+// Record adds the outcome of a unit: succeeded if err is nil, failed
+// otherwise.
+func (s *Summary) Record(report, unit, file string, err error) {
+	result := UnitResult{Report: report, Unit: unit, File: file, Status: Succeeded}
+	if err != nil {
+		result.Status = Failed
+		result.Error = err.Error()
+	}
+	s.add(result)
+}
+
+// Skip records a unit that was not attempted.
+func (s *Summary) Skip(report, unit, reason string) {
+	s.add(UnitResult{Report: report, Unit: unit, Status: Skipped, Error: reason})
+}
+
+// Unchanged records a report an incremental run did not regenerate.
+func (s *Summary) Unchanged(report string) {
+	s.add(UnitResult{Report: report, Unit: report, Status: Unchanged})
+}
+
+func (s *Summary) add(result UnitResult) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.results = append(s.results, result)
+}
+
+// Results returns the recorded results in the order they were recorded.
+func (s *Summary) Results() []UnitResult {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]UnitResult(nil), s.results...)
+}
+
+// Count returns the number of units of the given report with the given
+// status; an empty report counts every report.
+func (s *Summary) Count(report string, status Status) int {
+	n := 0
+	for _, r := range s.Results() {
+		if (report == "" || r.Report == report) && r.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Err returns a *PartialFailureError listing the failed units of report, or
+// nil if none failed.
+func (s *Summary) Err(report string) error {
+	var failed []UnitResult
+	total := 0
+	for _, r := range s.Results() {
+		if r.Report != report {
+			continue
+		}
+		total++
+		if r.Status == Failed {
+			failed = append(failed, r)
+		}
+	}
+	if len(failed) == 0 {
+		return nil
+	}
+	return &PartialFailureError{Report: report, Failed: failed, Total: total}
+}
+
+// Log writes one record with the counts per status.
+func (s *Summary) Log(logger *slog.Logger) {
+	level := slog.LevelInfo
+	if s.Count("", Failed) > 0 {
+		level = slog.LevelError
+	}
+	logger.Log(context.Background(), level, "run summary",
+		string(Succeeded), s.Count("", Succeeded),
+		string(Failed), s.Count("", Failed),
+		string(Skipped), s.Count("", Skipped),
+		string(Unchanged), s.Count("", Unchanged))
+}
+
+// WriteFile writes the counts and every unit result as JSON.
+func (s *Summary) WriteFile(path string) error {
+	data, err := json.MarshalIndent(struct {
+		Succeeded int          `json:"succeeded"`
+		Failed    int          `json:"failed"`
+		Skipped   int          `json:"skipped"`
+		Unchanged int          `json:"unchanged"`
+		Units     []UnitResult `json:"units"`
+	}{s.Count("", Succeeded), s.Count("", Failed), s.Count("", Skipped), s.Count("", Unchanged), s.Results()}, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0o644); err != nil {
+		return fmt.Errorf("error writing run summary: %w", err)
+	}
+	return nil
+}
+
+// writeUnits writes each unit in order with write, which returns the file it
+// wrote, and records every outcome in summary. write is given a context
+// under which the workbooks it saves are meant for the unit, e.g. the TSE.
+// A unit that fails does not stop the others. Once ctx is done the remaining
+// units are recorded as skipped and ctx's error is returned; otherwise the
+// error is a *PartialFailureError if any unit failed.
+func writeUnits(ctx context.Context, summary *Summary, logger *slog.Logger, report string, units []string, write func(ctx context.Context, unit string) (string, error)) error {
+	for i, unit := range units {
+		err := ctx.Err()
+		var file string
+		if err == nil {
+			file, err = write(excel.ForRecipient(ctx, unit), unit)
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			for _, skipped := range units[i:] {
+				summary.Skip(report, skipped, ctxErr.Error())
+			}
+			return ctxErr
+		}
+		if err != nil {
+			logger.Error("error writing workbook, continuing with the next one", "unit", unit, "err", err)
+			file = ""
+		}
+		summary.Record(report, unit, file, err)
+	}
+	return summary.Err(report)
+}
+
+// PartialFailureError is returned by a generator that produced some of its
+// units but failed on others.
+type PartialFailureError struct {
+	Report string
+	Failed []UnitResult
+	Total  int
+}
+
+func (e *PartialFailureError) Error() string {
+	msgs := make([]string, len(e.Failed))
+	for i, r := range e.Failed {
+		msgs[i] = fmt.Sprintf("%s: %s", r.Unit, r.Error)
+	}
+	return fmt.Sprintf("%d of %d %s units failed: %s", len(e.Failed), e.Total, e.Report, strings.Join(msgs, "; "))
+}
@@ -0,0 +1,90 @@
+package report
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"viking-reports/internal/config"
+	"viking-reports/internal/repository"
+)
+
+// TestGrowthIsolatesFailedTSE checks that a TSE whose workbook cannot be
+// written does not stop the workbooks of the other TSEs.
+func TestGrowthIsolatesFailedTSE(t *testing.T) {
+	cfg := &config.Config{OutputDir: t.TempDir()}
+	cfg.ReportFiles.GrowthReport = config.GrowthReportFiles{MTDSO: "mtd-so", LMTDSO: "lmtd-so", MTDST: "mtd-st", LMTDST: "lmtd-st"}
+
+	today := time.Now()
+	sale := func(code string) repository.SaleEvent {
+		return repository.SaleEvent{DealerCode: code, DealerName: code, ActivateTime: today.Format("2006-01-02 15:04:05"), SPUName: "realme C61"}
+	}
+	events := []repository.SaleEvent{sale("R1"), sale("R2")}
+	repos := Repositories{
+		TSEMapping: repository.NewMemoryTSEMappingRepository([]repository.Retailer{
+			{Code: "R1", Name: "R1", TSE: "Krishna"},
+			// The slash makes the workbook path point into a missing folder.
+			{Code: "R2", Name: "R2", TSE: "North/Ravi"},
+		}),
+		Sales: &repository.MemorySalesRepository{
+			Events: map[string][]repository.SaleEvent{"mtd-so": events, "lmtd-so": events, "mtd-st": events, "lmtd-st": events},
+			Today:  today,
+		},
+	}
+
+	summary := &Summary{}
+	generator := NewGrowthReportGenerator(cfg, WithRepositories(repos), WithSummary(summary))
+	err := generator.Generate(context.Background())
+
+	var partial *PartialFailureError
+	if !errors.As(err, &partial) || len(partial.Failed) != 1 || partial.Failed[0].Unit != "North/Ravi" {
+		t.Fatalf("got %v, want a partial failure of North/Ravi", err)
+	}
+	dirs, _ := filepath.Glob(filepath.Join(cfg.OutputDir, "growth_report_*"))
+	if len(dirs) != 1 {
+		t.Fatalf("expected one growth_report_* folder, found %v", dirs)
+	}
+	if _, err := os.Stat(filepath.Join(dirs[0], "Krishna_growth_report.xlsx")); err != nil {
+		t.Errorf("Krishna's workbook was not written: %v", err)
+	}
+	if got := summary.Count("growth", Succeeded); got != 1 {
+		t.Errorf("succeeded = %d, want 1", got)
+	}
+	if got := summary.Count("growth", Failed); got != 1 {
+		t.Errorf("failed = %d, want 1", got)
+	}
+}
+
+func TestWriteUnitsSkipsAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	summary := &Summary{}
+
+	err := writeUnits(ctx, summary, discardLogger(), "credit", []string{"Harish", "Krishna", "Sathish"}, func(_ context.Context, unit string) (string, error) {
+		if unit == "Krishna" {
+			cancel()
+			return "", ctx.Err()
+		}
+		return unit + ".xlsx", nil
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	want := []UnitResult{
+		{Report: "credit", Unit: "Harish", File: "Harish.xlsx", Status: Succeeded},
+		{Report: "credit", Unit: "Krishna", Status: Skipped, Error: "context canceled"},
+		{Report: "credit", Unit: "Sathish", Status: Skipped, Error: "context canceled"},
+	}
+	got := summary.Results()
+	if len(got) != len(want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("result %d = %+v, want %+v", i, got[i], want[i])
+		}
+	}
+}
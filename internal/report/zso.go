@@ -1,7 +1,9 @@
 package report
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"os"
 	"path/filepath"
 	"sort"
@@ -10,29 +12,49 @@ import (
 	"viking-reports/internal/repository"
 	"viking-reports/internal/utils"
 	"viking-reports/pkg/excel"
+	"viking-reports/pkg/output"
 
 	"github.com/xuri/excelize/v2"
 )
 
+func init() {
+	Register(Definition{
+		Name:        "zso",
+		Description: "Zero stock orders: models a retailer sold in the last two months but has no stock of",
+		Inputs: func(cfg *config.Config) []Input {
+			return []Input{
+				{"DealerInventory", cfg.ReportFiles.InventoryReport, repository.InventorySchema},
+				{"L2M-SO", cfg.ReportFiles.GrowthReport.L2MSO, repository.SalesExportSchema},
+				{"Retailer Metadata", cfg.CommonFiles.TSEMapping, repository.RetailerMetadataSchema},
+			}
+		},
+		New: func(cfg *config.Config, opts ...Option) ReportGenerator {
+			return NewZSOReportGenerator(cfg, opts...)
+		},
+	})
+}
+
 type ZSOReportGenerator struct {
 	cfg            *config.Config
 	inventoryRepo  repository.InventoryRepository
 	salesRepo      repository.SalesRepository
 	tseMappingRepo repository.TSEMappingRepository
+	logger         *slog.Logger
 }
 
-func NewZSOReportGenerator(cfg *config.Config) *ZSOReportGenerator {
-
+func NewZSOReportGenerator(cfg *config.Config, opts ...Option) *ZSOReportGenerator {
+	o := newOptions(cfg, "zso", opts)
 	return &ZSOReportGenerator{
 		cfg:            cfg,
-		inventoryRepo:  repository.NewSPUInventoryRepository(cfg.ReportFiles.InventoryReport),
-		salesRepo:      repository.NewExcelSalesRepository(), // Use one of the file paths
-		tseMappingRepo: repository.NewExcelTSEMappingRepository(cfg.CommonFiles.TSEMapping),
+		inventoryRepo:  o.repos.Inventory,
+		salesRepo:      o.repos.Sales,
+		tseMappingRepo: o.repos.TSEMapping,
+		logger:         o.logger,
 	}
 }
 
-func (g *ZSOReportGenerator) Generate() error {
-	fmt.Println("Generating ZSO report...")
+func (g *ZSOReportGenerator) Generate(ctx context.Context) error {
+	g.logger.Info("generating ZSO report")
 	// Define the filter list as a map for easier comparison
 	modelsOfInterest := map[string]struct{}{
 		"C61":        {},
@@ -50,32 +72,28 @@ func (g *ZSOReportGenerator) Generate() error {
 		"P2 Pro":     {},
 	}
 	// Fetch inventory and sales data
-	fmt.Print("Input: Fetching per dealer per SPU current inventory count")
-	dealerSPUInventory, err := g.inventoryRepo.ComputeDealerSPUInventory(modelsOfInterest)
+	dealerSPUInventory, err := g.inventoryRepo.ComputeDealerSPUInventory(ctx, modelsOfInterest)
 	if err != nil {
 		return fmt.Errorf("error reading inventory data: %w", err)
 	}
 
-	fmt.Print("Input: Fetching per dealer per SPU last two months sell out (SO) count")
-	lmtdDealerSPUSales, err := g.salesRepo.GetDealerSPUSales(g.cfg.ReportFiles.GrowthReport.L2MSO, modelsOfInterest)
+	lmtdDealerSPUSales, err := g.salesRepo.GetDealerSPUSales(ctx, g.cfg.ReportFiles.GrowthReport.L2MSO, modelsOfInterest)
 	if err != nil {
 		return fmt.Errorf("error reading LMTD sales data: %w", err)
 	}
 
-	/*fmt.Print("Input: Fetching per dealer per SPU month to date sell out (SO) count")
-	mtdDealerSPUSales, err := g.salesRepo.GetDealerSPUSales(g.cfg.ReportFiles.GrowthReport.MTDSO, modelsOfInterest)
+	/*mtdDealerSPUSales, err := g.salesRepo.GetDealerSPUSales(ctx, g.cfg.ReportFiles.GrowthReport.MTDSO, modelsOfInterest)
 	if err != nil {
 		return fmt.Errorf("error reading MTD sales data: %w", err)
 	}*/
 
-	tseMapping, err := g.tseMappingRepo.GetRetailerNameToTSEMap("Dealer Name")
+	tseMapping, err := g.tseMappingRepo.GetRetailerNameToTSEMap(ctx, "Dealer Name")
 	if err != nil {
 		return fmt.Errorf("error reading TSE mapping: %w", err)
 	}
 	// Combine LMTD and MTD sales
 	allSales := lmtdDealerSPUSales // append(mapToSlice(lmtdDealerSPUSales), mapToSlice(mtdDealerSPUSales)...)
 
-	fmt.Println("Start computation of zso report.")
 	// Build inventory map
 	inventoryMap := make(map[string]int)
 	for _, inventory := range dealerSPUInventory {
@@ -83,12 +101,7 @@ func (g *ZSOReportGenerator) Generate() error {
 		inventoryMap[dealerSPUKey] = inventory.Count
 	}
 
-	fmt.Print("Identifying zso for ")
-	for model := range modelsOfInterest {
-		fmt.Print(model)
-		fmt.Print(" ")
-	}
-	fmt.Println()
+	g.logger.Info("identifying ZSO", "models", sortedModels(modelsOfInterest))
 	// Track ZSO data and relevant model names
 	zsoData := make(map[string]map[string]string)
 	zsoModelNames := make(map[string]struct{})
@@ -111,11 +124,11 @@ func (g *ZSOReportGenerator) Generate() error {
 
 	// Generate and save the Excel report
 	outputDir := utils.GenerateOutputPath(g.cfg.OutputDir, "zso_report")
-	err = g.writeZSOReport(zsoData, zsoModelNames, tseMapping, outputDir)
+	err = g.writeZSOReport(ctx, zsoData, zsoModelNames, tseMapping, outputDir)
 	if err != nil {
 		return fmt.Errorf("error writing ZSO report: %w", err)
 	}
-	fmt.Printf("ZSO report generated successfully: %s\n", outputDir)
+	g.logger.Info("ZSO report generated", "output", outputDir)
 
 	return nil
 }
@@ -129,8 +142,9 @@ func mapToSlice(dealerSalesMap map[string]*repository.DealerSPUSales) []reposito
 	return dealerSalesSlice
 }
 
-func (g *ZSOReportGenerator) writeZSOReport(zsoData map[string]map[string]string, zsoModelNames map[string]struct{}, tseMapping map[string]string, outputDir string) error {
-	f := excelize.NewFile()
+func (g *ZSOReportGenerator) writeZSOReport(ctx context.Context, zsoData map[string]map[string]string, zsoModelNames map[string]struct{}, tseMapping map[string]string, outputDir string) error {
+	result := output.NewResult()
+	f := result.Workbook
 	sheetName := "ZSO Report"
 	f.NewSheet(sheetName)
 	f.DeleteSheet("Sheet1")
@@ -139,98 +153,105 @@ func (g *ZSOReportGenerator) writeZSOReport(zsoData map[string]map[string]string
 		return fmt.Errorf("error creating output directory: %w", err)
 	}
 
-	// Define styles
-	headerStyle, _ := f.NewStyle(&excelize.Style{
-		Fill:   excelize.Fill{Type: "pattern", Color: []string{"FFFF00"}, Pattern: 1}, // Yellow background for header
-		Font:   &excelize.Font{Bold: true},
-		Border: createBorderStyle(),
-	})
-	zsoCellStyle, _ := f.NewStyle(&excelize.Style{
-		Fill:   excelize.Fill{Type: "pattern", Color: []string{"FF9999"}, Pattern: 1}, // Light red for ZSO cells
-		Border: createBorderStyle(),
-	})
-	defaultCellStyle, _ := f.NewStyle(&excelize.Style{
-		Border: createBorderStyle(),
-	})
-
-	// Build headers with ZSO model names only
-	headers := []string{"TSE", "Dealer Name"}
-
-	// Add ZSO models to headers only if they are present in filteredModels
+	// Build headers with ZSO model names only, sorted alphabetically
+	var models []string
 	for model := range zsoModelNames {
-		trimmedModel := strings.TrimSpace(model)
-		headers = append(headers, trimmedModel) // Add to headers if present
+		models = append(models, strings.TrimSpace(model))
 	}
+	sort.Strings(models)
 
-	// Sort headers alphabetically
-	sort.Strings(headers[2:]) // Sort the ZSO models in headers
-	// Add the "Total ZSO" header
-	headers = append(headers, "Total ZSO")
-
-	// Write headers with style
-	for col, header := range headers {
-		cell, _ := excelize.CoordinatesToCellName(col+1, 1)
-		f.SetCellValue(sheetName, cell, header)
-		f.SetCellStyle(sheetName, cell, cell, headerStyle)
+	table := excel.Table{
+		Sheet:         sheetName,
+		Columns:       []excel.Column{{Header: "TSE"}, {Header: "Dealer Name"}},
+		FreezeHeader:  true,
+		FreezeColumns: 2,
+		AutoFilter:    true,
+		GroupBy:       "TSE",
+		Totals:        true,
+	}
+	zso := []excel.Rule{{Op: "=", Text: "ZSO", Highlight: excel.Highlight{Fill: "FF9999"}}} // Light red for ZSO cells
+	for _, model := range models {
+		table.Columns = append(table.Columns, excel.Column{Header: model, Rules: zso})
 	}
+	table.Columns = append(table.Columns, excel.Column{Header: "Total ZSO", Type: excel.Number, Sum: true})
+
 	// Create a slice to hold dealers for sorting
 	var dealers []string
 	for dealer := range zsoData {
 		dealers = append(dealers, dealer)
 	}
-	// Sort dealers by TSE
+	// Sort dealers by TSE, then by name
 	sort.Slice(dealers, func(i, j int) bool {
+		if tseMapping[dealers[i]] == tseMapping[dealers[j]] {
+			return dealers[i] < dealers[j]
+		}
 		return tseMapping[dealers[i]] < tseMapping[dealers[j]]
 	})
 
-	// Write ZSO data
-	row := 2
+	var rows [][]interface{}
 	for _, dealer := range dealers {
-		models := zsoData[dealer]
+		dealerModels := zsoData[dealer]
 		// Only proceed if the dealer has ZSO entries
-		if len(models) == 0 {
-			fmt.Printf("Ignoring retailer: %s as their ZSO does not exist\n", dealer)
+		if len(dealerModels) == 0 {
+			g.logger.Debug("ignoring retailer without ZSO", "retailer", dealer)
 			continue // Skip dealers without ZSO
 		}
-		tseCell, _ := excelize.CoordinatesToCellName(1, row)
-		f.SetCellValue(sheetName, tseCell, tseMapping[dealer])
-		f.SetCellStyle(sheetName, tseCell, tseCell, defaultCellStyle)
-
-		dealerCell, _ := excelize.CoordinatesToCellName(2, row)
-		f.SetCellValue(sheetName, dealerCell, dealer)
-		f.SetCellStyle(sheetName, dealerCell, dealerCell, defaultCellStyle)
-
-		col := 3
+		row := []interface{}{tseMapping[dealer], dealer}
 		totalZSO := 0
-		for _, model := range headers[2 : len(headers)-1] {
-			cell, _ := excelize.CoordinatesToCellName(col, row)
-			if models[model] == "250" {
-				f.SetCellValue(sheetName, cell, "ZSO")
-				f.SetCellStyle(sheetName, cell, cell, zsoCellStyle)
+		for _, model := range models {
+			if dealerModels[model] == "250" {
+				row = append(row, "ZSO")
 				totalZSO++
 			} else {
-				f.SetCellStyle(sheetName, cell, cell, defaultCellStyle)
+				row = append(row, nil)
 			}
-			col++
 		}
-
-		totalCell, _ := excelize.CoordinatesToCellName(col, row)
-		f.SetCellValue(sheetName, totalCell, totalZSO)
-		f.SetCellStyle(sheetName, totalCell, totalCell, defaultCellStyle)
-		row++
+		rows = append(rows, append(row, totalZSO))
+	}
+	if err := result.Add(sheetName, &table, rows); err != nil {
+		return err
+	}
+	if wantsPivot(g.cfg, "zso") {
+		if err := writeZSOPivot(f, rows, models); err != nil {
+			return err
+		}
 	}
 
 	outputPath := filepath.Join(outputDir, "zso_report.xlsx")
-	excel.AdjustColumnWidths(f, sheetName)
-	return f.SaveAs(outputPath)
+	return output.Save(ctx, result, outputPath)
 }
 
-// Helper function to create border style
-func createBorderStyle() []excelize.Border {
-	return []excelize.Border{
-		{Type: "left", Color: "000000", Style: 1},
-		{Type: "top", Color: "000000", Style: 1},
-		{Type: "bottom", Color: "000000", Style: 1},
-		{Type: "right", Color: "000000", Style: 1},
+// writeZSOPivot adds a pivot of the ZSO of each model by TSE. A pivot needs
+// a column of models, so it reads a hidden sheet listing each ZSO of rows,
+// the rows of the ZSO sheet, on a line of its own.
+func writeZSOPivot(f *excelize.File, rows [][]interface{}, models []string) error {
+	const dataSheet = "ZSO Data"
+	if _, err := f.NewSheet(dataSheet); err != nil {
+		return fmt.Errorf("error creating new sheet: %w", err)
+	}
+	table := excel.Table{
+		Sheet: dataSheet,
+		Columns: []excel.Column{
+			{Header: "TSE"},
+			{Header: "Dealer Name"},
+			{Header: "Model"},
+			{Header: "ZSO", Type: excel.Number},
+		},
+	}
+	var zso [][]interface{}
+	for _, row := range rows {
+		for m, model := range models {
+			if row[2+m] == "ZSO" {
+				zso = append(zso, []interface{}{row[0], row[1], model, 1})
+			}
+		}
+	}
+	if err := table.Write(f, zso); err != nil {
+		return err
+	}
+	if err := f.SetSheetVisible(dataSheet, false); err != nil {
+		return err
 	}
+	pivot := excel.Pivot{Sheet: pivotSheet, Rows: []string{"TSE"}, Columns: []string{"Model"}, Data: []string{"ZSO"}}
+	return table.AddPivot(f, len(zso), pivot)
 }
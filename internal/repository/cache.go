@@ -0,0 +1,213 @@
+package repository
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cacheVersion is part of every cache key. Bump it whenever a cached record
+// type changes, so that records parsed by an older build are not decoded.
+const cacheVersion = "1"
+
+// Cache keeps the records parsed from input workbooks, keyed by the SHA-256
+// of the workbook. Within a run every file is parsed once, even when several
+// goroutines ask for it at the same time, and its records are shared by every
+// report reading it; they must not be modified. With a directory, the records
+// are also kept on disk so that a later run reading an unchanged file skips
+// parsing it; the records of password-protected workbooks are kept in memory
+// only, since they would be stored unencrypted. A nil *Cache caches nothing.
+// It is safe for concurrent use.
+type Cache struct {
+	dir string
+
+	mu      sync.Mutex
+	hashes  map[string]fileHash
+	entries map[string]*entry
+}
+
+// entry is the outcome of parsing a file, available once done is closed.
+type entry struct {
+	done  chan struct{}
+	value interface{}
+	err   error
+}
+
+// fileHash is the SHA-256 of a file as it was when hashed.
+type fileHash struct {
+	size    int64
+	modTime time.Time
+	sum     string
+}
+
+// NewCache returns a cache storing its entries in dir, or only in memory if
+// dir is empty.
+func NewCache(dir string) *Cache {
+	return &Cache{dir: dir, hashes: make(map[string]fileHash), entries: make(map[string]*entry)}
+}
+
+// Prune removes the entries on disk not used for longer than maxAge.
+func (c *Cache) Prune(maxAge time.Duration) error {
+	if c.dir == "" {
+		return nil
+	}
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+	cutoff := time.Now().Add(-maxAge)
+	for _, entry := range entries {
+		info, err := entry.Info()
+		if err != nil || info.ModTime().After(cutoff) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(c.dir, entry.Name())); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sum returns the SHA-256 of the file at path, hashing it again only if its
+// size or modification time changed since it was last hashed.
+func (c *Cache) sum(path string) (string, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return "", err
+	}
+	c.mu.Lock()
+	h, ok := c.hashes[path]
+	c.mu.Unlock()
+	if ok && h.size == info.Size() && h.modTime.Equal(info.ModTime()) {
+		return h.sum, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return "", err
+	}
+	h = fileHash{size: info.Size(), modTime: info.ModTime(), sum: hex.EncodeToString(hash.Sum(nil))}
+	c.mu.Lock()
+	c.hashes[path] = h
+	c.mu.Unlock()
+	return h.sum, nil
+}
+
+func entryKey(fileSum, kind string) string {
+	key := sha256.Sum256([]byte(cacheVersion + "\x00" + fileSum + "\x00" + kind))
+	return hex.EncodeToString(key[:])
+}
+
+// load returns the records of the given kind parsed from the file at path,
+// from the cache if the file is unchanged and from parse otherwise. The cache
+// is best effort: a file that cannot be hashed, or an entry that cannot be
+// read or written, only means the file is parsed. A failed parse is not
+// cached.
+func load[T any](c *Cache, path, kind string, parse func() (T, error)) (T, error) {
+	if c == nil {
+		return parse()
+	}
+	sum, err := c.sum(path)
+	if err != nil {
+		return parse()
+	}
+	key := entryKey(sum, kind)
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok {
+		c.mu.Unlock()
+		<-e.done
+		if e.err != nil {
+			var zero T
+			return zero, e.err
+		}
+		return e.value.(T), nil
+	}
+	e := &entry{done: make(chan struct{})}
+	c.entries[key] = e
+	c.mu.Unlock()
+
+	dir := c.dir
+	if encrypted(path) {
+		dir = ""
+	}
+	v, err := loadStored(dir, key, parse)
+	e.value, e.err = v, err
+	if err != nil {
+		c.mu.Lock()
+		delete(c.entries, key)
+		c.mu.Unlock()
+	}
+	close(e.done)
+	return v, err
+}
+
+// loadStored returns the records stored under key in dir, parsing and
+// storing them if they are not there.
+func loadStored[T any](dir, key string, parse func() (T, error)) (T, error) {
+	if dir == "" {
+		return parse()
+	}
+	entry := filepath.Join(dir, key+".gob")
+	if data, err := os.ReadFile(entry); err == nil {
+		var v T
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&v); err == nil {
+			now := time.Now()
+			os.Chtimes(entry, now, now) // keeps the entry from being pruned
+			return v, nil
+		}
+	}
+
+	v, err := parse()
+	if err != nil {
+		return v, err
+	}
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(v); err == nil {
+		writeEntry(entry, buf.Bytes())
+	}
+	return v, nil
+}
+
+// writeEntry writes a cache entry atomically, so that a concurrent reader
+// never decodes half an entry.
+func writeEntry(path string, data []byte) {
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".entry.*.tmp")
+	if err != nil {
+		return
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil && cerr == nil {
+		os.Rename(tmp.Name(), path)
+	}
+}
+
+// cached gives an Excel repository a cache of parsed records. The pipeline
+// sets it with SetCache on every run, so that reports share what was read;
+// the cache keeps the records on disk only in incremental runs.
+type cached struct {
+	cache *Cache
+}
+
+// SetCache sets the cache the repository keeps its parsed records in.
+func (c *cached) SetCache(cache *Cache) {
+	c.cache = cache
+}
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheReusesUnchangedFile(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "Received.xlsx")
+	if err := os.WriteFile(input, []byte("first"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	parses := 0
+	parse := func() ([]Receipt, error) {
+		parses++
+		return []Receipt{{PartyName: "One", Amount: float64(parses)}}, nil
+	}
+	read := func(c *Cache) []Receipt {
+		t.Helper()
+		receipts, err := load(c, input, "receipts", parse)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return receipts
+	}
+
+	read(NewCache(filepath.Join(dir, "cache")))
+	// A new cache, as in a later run, finds the records of the first.
+	if got := read(NewCache(filepath.Join(dir, "cache"))); parses != 1 || got[0].Amount != 1 {
+		t.Fatalf("parsed %d times, got %+v; want the cached records", parses, got)
+	}
+
+	later := time.Now().Add(time.Minute)
+	if err := os.WriteFile(input, []byte("second"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	os.Chtimes(input, later, later)
+	if got := read(NewCache(filepath.Join(dir, "cache"))); parses != 2 || got[0].Amount != 2 {
+		t.Fatalf("parsed %d times, got %+v; want the changed file parsed again", parses, got)
+	}
+
+	if read(nil); parses != 3 {
+		t.Errorf("a nil cache must always parse")
+	}
+}
+
+func TestCacheParsesOnceForConcurrentReaders(t *testing.T) {
+	input := filepath.Join(t.TempDir(), "Received.xlsx")
+	if err := os.WriteFile(input, []byte("receipts"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var parses atomic.Int32
+	release := make(chan struct{})
+	parse := func() ([]Receipt, error) {
+		parses.Add(1)
+		<-release
+		return []Receipt{{PartyName: "One", Amount: 1}}, nil
+	}
+
+	cache := NewCache("")
+	var wg sync.WaitGroup
+	results := make([][]Receipt, 8)
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			receipts, err := load(cache, input, "receipts", parse)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = receipts
+		}(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n := parses.Load(); n != 1 {
+		t.Errorf("parsed %d times, want once", n)
+	}
+	for i, receipts := range results {
+		if len(receipts) != 1 || &receipts[0] != &results[0][0] {
+			t.Errorf("reader %d got %+v, want the shared records", i, receipts)
+		}
+	}
+}
@@ -1,17 +1,18 @@
 package repository
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
 	"path/filepath"
 	"strconv"
 	"time"
 	"viking-reports/internal/utils"
-
-	"github.com/xuri/excelize/v2"
 )
 
 type ExcelCreditRepository struct {
+	logged
+	cached
 	filePath string
 }
 
@@ -35,7 +36,7 @@ func NewExcelCreditRepository(filePath string) *ExcelCreditRepository {
 	return &ExcelCreditRepository{filePath: filePath}
 }
 
-func (r *ExcelCreditRepository) GetCreditData() (map[string]*CreditData, error) {
+func (r *ExcelCreditRepository) GetCreditData(ctx context.Context) (map[string]*CreditData, error) {
 	today := time.Now().Format("2006-01-02")
 	creditData := make(map[string]*CreditData)
 
@@ -45,7 +46,7 @@ func (r *ExcelCreditRepository) GetCreditData() (map[string]*CreditData, error)
 	}
 
 	for _, file := range files {
-		f, err := excelize.OpenFile(file)
+		f, err := OpenWorkbook(ctx, file)
 		if err != nil {
 			return nil, fmt.Errorf("failed to open credit file %s: %w", file, err)
 		}
@@ -67,6 +68,9 @@ func (r *ExcelCreditRepository) GetCreditData() (map[string]*CreditData, error)
 		}
 
 		for _, row := range rows[1:] {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
 			retailerCode := row[retailerCodeIdx]
 			totalCredit := utils.ParseFloat(row[totalCreditIdx])
 
@@ -85,6 +89,73 @@ func (r *ExcelCreditRepository) GetCreditData() (map[string]*CreditData, error)
 }
 
 func (r *ExcelCreditRepository) AggregateCreditByRetailer(bills []Bill, tseMapping map[string]string, retailerNameToCodeMap map[string]string) map[string]map[string]interface{} {
+	return aggregateCreditByRetailer(bills, tseMapping, retailerNameToCodeMap)
+}
+
+func (r *ExcelCreditRepository) GetBills(ctx context.Context) ([]Bill, error) {
+	logger := r.log("credit", r.filePath)
+	logger.Info("fetching pending bills")
+	return loadBills(ctx, r.cache, r.filePath, logger)
+}
+
+func loadBills(ctx context.Context, cache *Cache, path string, logger *slog.Logger) ([]Bill, error) {
+	return load(cache, path, "bills", func() ([]Bill, error) {
+		return parseBills(ctx, path, logger)
+	})
+}
+
+func parseBills(ctx context.Context, path string, logger *slog.Logger) ([]Bill, error) {
+	f, err := OpenWorkbook(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open bills file: %w", err)
+	}
+	defer f.Close()
+
+	sheetName := f.GetSheetName(0)
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows: %w", err)
+	}
+
+	var bills []Bill
+	totalRows := len(rows)
+	for i, row := range rows[11:] {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		if len(row) < 6 || i+11 == totalRows-1 {
+			continue
+		}
+
+		date := row[0]
+		refNo := row[1]
+		retailerName := row[2]
+		pendingAmount, err := strconv.ParseFloat(row[3], 64)
+		if err != nil {
+			logger.Warn("skipping bill with invalid pending amount", "row", i+12, "err", err)
+			continue
+		}
+		dueDate := row[4]
+		ageOfBill, err := strconv.Atoi(row[5])
+		if err != nil {
+			logger.Warn("skipping bill with invalid age", "row", i+12, "err", err)
+			continue
+		}
+
+		bills = append(bills, Bill{
+			Date:          date,
+			RefNo:         refNo,
+			RetailerName:  retailerName,
+			PendingAmount: pendingAmount,
+			DueDate:       dueDate,
+			AgeOfBill:     ageOfBill,
+		})
+	}
+
+	return bills, nil
+}
+
+func aggregateCreditByRetailer(bills []Bill, tseMapping map[string]string, retailerNameToCodeMap map[string]string) map[string]map[string]interface{} {
 	aggregatedData := make(map[string]map[string]interface{})
 
 	// Step 1: Group bills by retailer name
@@ -134,53 +205,3 @@ func (r *ExcelCreditRepository) AggregateCreditByRetailer(bills []Bill, tseMappi
 	}
 	return aggregatedData
 }
-
-func (r *ExcelCreditRepository) GetBills() ([]Bill, error) {
-	fmt.Println("** Input: Fetching invoices of daily sales from Tally. **")
-
-	f, err := excelize.OpenFile(r.filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open bills file: %w", err)
-	}
-	defer f.Close()
-
-	sheetName := f.GetSheetName(0)
-	rows, err := f.GetRows(sheetName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get rows: %w", err)
-	}
-
-	var bills []Bill
-	totalRows := len(rows)
-	for i, row := range rows[11:] {
-		if len(row) < 6 || i+11 == totalRows-1 {
-			continue
-		}
-
-		date := row[0]
-		refNo := row[1]
-		retailerName := row[2]
-		pendingAmount, err := strconv.ParseFloat(row[3], 64)
-		if err != nil {
-			log.Printf("Error parsing pending amount: %v", err)
-			continue
-		}
-		dueDate := row[4]
-		ageOfBill, err := strconv.Atoi(row[5])
-		if err != nil {
-			log.Printf("Error parsing age of bill: %v", err)
-			continue
-		}
-
-		bills = append(bills, Bill{
-			Date:          date,
-			RefNo:         refNo,
-			RetailerName:  retailerName,
-			PendingAmount: pendingAmount,
-			DueDate:       dueDate,
-			AgeOfBill:     ageOfBill,
-		})
-	}
-
-	return bills, nil
-}
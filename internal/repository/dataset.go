@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"time"
+)
+
+// The functions below return the records the repositories parse from an
+// input, before any aggregation, for exporting them as they are. They share
+// cache with the repositories, so an input is parsed once per run.
+
+// Bills returns the pending bills of the Tally bills export at path.
+func Bills(ctx context.Context, cache *Cache, path string, logger *slog.Logger) ([]Bill, error) {
+	return loadBills(ctx, cache, path, logger.With("repository", "credit", "file", path))
+}
+
+// Receipts returns the amounts received of the Tally Received export at
+// path.
+func Receipts(ctx context.Context, cache *Cache, path string) ([]Receipt, error) {
+	return loadReceipts(ctx, cache, path)
+}
+
+// SaleEvents returns the activations or dispatches of the DMS sales export
+// at path.
+func SaleEvents(ctx context.Context, cache *Cache, path string) ([]SaleEvent, error) {
+	events, err := loadSaleEvents(ctx, cache, path)
+	return events.Rows, err
+}
+
+// InventoryUnits returns the stock units of the DMS inventory export at
+// path.
+func InventoryUnits(ctx context.Context, cache *Cache, path string) ([]InventoryUnit, error) {
+	units, err := loadUnits(ctx, cache, path)
+	return units.Rows, err
+}
+
+// PriceList returns the rows of the ZD price list at path.
+func PriceList(ctx context.Context, cache *Cache, path string, logger *slog.Logger) ([]PriceListRow, error) {
+	return loadPriceList(ctx, cache, path, logger.With("repository", "pricelist", "file", path))
+}
+
+// saleTimeLayouts are the formats of the activation times of sales exports.
+var saleTimeLayouts = []string{"2006-01-02 15:04:05", "2006-01-02"}
+
+// ParseBillDate parses a date of a Tally bill, such as "05-Mar-24", in local
+// time.
+func ParseBillDate(s string) (time.Time, bool) {
+	return parseTime(billDateLayouts, s)
+}
+
+// ParseSaleTime parses the activation time of a sale event in local time.
+func ParseSaleTime(s string) (time.Time, bool) {
+	return parseTime(saleTimeLayouts, s)
+}
+
+func parseTime(layouts []string, s string) (time.Time, bool) {
+	for _, layout := range layouts {
+		if t, err := time.ParseInLocation(layout, strings.TrimSpace(s), time.Local); err == nil {
+			return t, true
+		}
+	}
+	return time.Time{}, false
+}
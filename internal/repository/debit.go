@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"viking-reports/internal/utils"
+)
+
+// ExcelDebitRepository reads the amounts received from retailers (Received.xlsx
+// exported from Tally) over the last day.
+type ExcelDebitRepository struct {
+	logged
+	cached
+	filePath string
+}
+
+// Receipt is one amount received from a retailer.
+type Receipt struct {
+	PartyName string
+	Amount    float64
+}
+
+func NewExcelDebitRepository(filePath string) *ExcelDebitRepository {
+	return &ExcelDebitRepository{filePath: filePath}
+}
+
+// GetDebit returns the amount received from each retailer, keyed by the
+// retailer's Tally ledger name.
+func (r *ExcelDebitRepository) GetDebit(ctx context.Context) (map[string]float64, error) {
+	r.log("debit", r.filePath).Info("fetching amounts received from retailers")
+	receipts, err := loadReceipts(ctx, r.cache, r.filePath)
+	if err != nil {
+		return nil, err
+	}
+	return receivedByParty(receipts), nil
+}
+
+func loadReceipts(ctx context.Context, cache *Cache, path string) ([]Receipt, error) {
+	return load(cache, path, "receipts", func() ([]Receipt, error) {
+		return parseReceipts(ctx, path)
+	})
+}
+
+func parseReceipts(ctx context.Context, path string) ([]Receipt, error) {
+	f, err := OpenWorkbook(ctx, path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open received file: %w", err)
+	}
+	defer f.Close()
+
+	sheetName := f.GetSheetName(0)
+	rows, err := f.GetRows(sheetName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get rows: %w", err)
+	}
+
+	partyNameIdx, err := utils.GetColumnIndex(f, sheetName, "Party Name")
+	if err != nil {
+		return nil, err
+	}
+	amountIdx, err := utils.GetColumnIndex(f, sheetName, "Amount")
+	if err != nil {
+		return nil, err
+	}
+
+	var receipts []Receipt
+	for _, row := range rows[1:] {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		partyName := cellAt(row, partyNameIdx)
+		if partyName == "" {
+			continue
+		}
+		receipts = append(receipts, Receipt{PartyName: partyName, Amount: utils.ParseFloat(cellAt(row, amountIdx))})
+	}
+	return receipts, nil
+}
+
+func receivedByParty(receipts []Receipt) map[string]float64 {
+	received := make(map[string]float64)
+	for _, receipt := range receipts {
+		received[receipt.PartyName] += receipt.Amount
+	}
+	return received
+}
@@ -1,43 +1,48 @@
 package repository
 
+import "context"
+
 type TSEMappingRepository interface {
-	GetRARetailersMap() (map[string]int, error)
-	GetRetailerCodeToTSEMap() (map[string]string, error)
-	GetRetailerCodeToNameMap() (map[string]string, error)
-	GetRetailerNameToTSEMap(dealerNameHeader string) (map[string]string, error)
-	GetRetailerNameToCodeMap() (map[string]string, error)
+	GetRARetailersMap(ctx context.Context) (map[string]int, error)
+	GetRetailerCodeToTSEMap(ctx context.Context) (map[string]string, error)
+	GetRetailerCodeToNameMap(ctx context.Context) (map[string]string, error)
+	GetRetailerNameToTSEMap(ctx context.Context, dealerNameHeader string) (map[string]string, error)
+	GetRetailerNameToCodeMap(ctx context.Context) (map[string]string, error)
 }
 
 type ProductPriceRepository interface {
-	GetProductPrices() (map[string]float64, error)
+	GetProductPrices(ctx context.Context) (map[string]float64, error)
 }
 
 type InventoryRepository interface {
-	ComputeInventoryShortFall() (map[string]*InventoryShortFallRepo, error)
-	ComputeMaterialModelCount() (map[string]*ModelCountRepo, error)
-	ComputeDealerSPUInventory(modelsOfInterest map[string]struct{}) (map[string]*SPUInventoryCount, error)
-	ComputeRADealerSPUInventory(modelsOfInterest map[string]struct{}, raRetailers map[string]int) (map[string]*SPUInventoryCount, error)
+	ComputeInventoryShortFall(ctx context.Context) (map[string]*InventoryShortFallRepo, error)
+	// ComputeInventoryCost returns the cost of each retailer's stock by
+	// dealer code, without reading back the credit reports.
+	ComputeInventoryCost(ctx context.Context) (map[string]float64, error)
+	ComputeMaterialModelCount(ctx context.Context) (map[string]*ModelCountRepo, error)
+	ComputeDealerSPUInventory(ctx context.Context, modelsOfInterest map[string]struct{}) (map[string]*SPUInventoryCount, error)
+	ComputeRADealerSPUInventory(ctx context.Context, modelsOfInterest map[string]struct{}, raRetailers map[string]int) (map[string]*SPUInventoryCount, error)
 }
 
 type CreditRepository interface {
-	GetCreditData() (map[string]*CreditData, error)
-	GetBills() ([]Bill, error)
+	GetCreditData(ctx context.Context) (map[string]*CreditData, error)
+	GetBills(ctx context.Context) ([]Bill, error)
 	AggregateCreditByRetailer(bills []Bill, tseMapping map[string]string, retailerNameToCodeMap map[string]string) map[string]map[string]interface{}
 }
 
 type DebitRepository interface {
-	GetDebit() (map[string]float64, error)
+	GetDebit(ctx context.Context) (map[string]float64, error)
 }
 
 type SalesRepository interface {
-	GetSales(fileType string) (map[string]*SellData, error)
-	GetDealerSPUSales(salesFilePath string, modelsOfInterest map[string]struct{}) (map[string]*DealerSPUSales, error)
+	GetSales(ctx context.Context, fileType string) (map[string]*SellData, error)
+	GetDealerSPUSales(ctx context.Context, salesFilePath string, modelsOfInterest map[string]struct{}) (map[string]*DealerSPUSales, error)
 }
 
 type PriceListRepository interface {
-	GetPriceListData() ([]PriceListRow, error)
-	GetMaterialCodeMap() (map[string]int, error)
+	GetPriceListData(ctx context.Context) ([]PriceListRow, error)
+	GetMaterialCodeMap(ctx context.Context) (map[string]int, error)
 }
 type SalesTargetRepository interface {
-	ReadSales(fileType string, tseMap map[string]string) ([]*SalesData, error)
+	ReadSales(ctx context.Context, fileType string, tseMap map[string]string) ([]*SalesData, error)
 }
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -8,14 +9,30 @@ import (
 	"strings"
 	"time"
 	"viking-reports/internal/utils"
+	"viking-reports/pkg/excel"
 
 	"github.com/xuri/excelize/v2"
 )
 
 type ExcelInventoryRepository struct {
-	filePath   string
-	priceData  map[string]float64
-	tseMapping map[string]string
+	logged
+	cached
+	filePath       string
+	priceRepo      ProductPriceRepository
+	tseMappingRepo TSEMappingRepository
+	outputDir      string
+}
+
+// InventoryUnit is one stock unit (one row) of the DMS dealer inventory export.
+type InventoryUnit struct {
+	DealerCode   string
+	DealerName   string
+	AreaName     string
+	MaterialCode string
+	SPUName      string
+	Color        string
+	SKUSpec      string
+	ProductType  string
 }
 
 type InventoryShortFallRepo struct {
@@ -52,181 +69,116 @@ func NewSPUInventoryRepository(filePath string) *ExcelInventoryRepository {
 	}
 }
 
-func NewExcelInventoryRepository(filePath string, priceData map[string]float64, tseMapping map[string]string) *ExcelInventoryRepository {
+// NewExcelInventoryRepository returns an inventory repository that prices stock
+// with priceRepo, assigns TSEs with tseMappingRepo and reads today's credit
+// dues from the credit_reports_<date> folder the credit report writes under
+// outputDir.
+func NewExcelInventoryRepository(filePath string, priceRepo ProductPriceRepository, tseMappingRepo TSEMappingRepository, outputDir string) *ExcelInventoryRepository {
 	return &ExcelInventoryRepository{
-		filePath:   filePath,
-		priceData:  priceData,
-		tseMapping: tseMapping,
+		filePath:       filePath,
+		priceRepo:      priceRepo,
+		tseMappingRepo: tseMappingRepo,
+		outputDir:      outputDir,
 	}
 }
 
-func (r *ExcelInventoryRepository) ComputeDealerSPUInventory(modelsOfInterest map[string]struct{}) (map[string]*SPUInventoryCount, error) {
-	f, err := excelize.OpenFile(r.filePath)
+func (r *ExcelInventoryRepository) ComputeDealerSPUInventory(ctx context.Context, modelsOfInterest map[string]struct{}) (map[string]*SPUInventoryCount, error) {
+	r.log("inventory", r.filePath).Info("fetching today's stock per retailer and SPU")
+	units, err := r.readUnits(ctx, "SPU Name", "Dealer Code", "Dealer Name")
 	if err != nil {
-		return nil, fmt.Errorf("failed to open inventory file: %w", err)
+		return nil, err
 	}
-	defer f.Close()
-	fmt.Println("Fetching today's stock inventory data for each retailer.")
+	return dealerSPUInventory(units, modelsOfInterest), nil
+}
 
-	sheetName := f.GetSheetName(0)
-	rows, err := f.GetRows(sheetName)
+func (r *ExcelInventoryRepository) ComputeInventoryShortFall(ctx context.Context) (map[string]*InventoryShortFallRepo, error) {
+	r.log("inventory", r.filePath).Info("computing current inventory and shortfall of all retailers")
+	units, err := r.readUnits(ctx, "Material Code", "Dealer Code", "Dealer Name")
 	if err != nil {
-		return nil, fmt.Errorf("failed to get rows: %w", err)
+		return nil, err
 	}
 
-	spuNameIdx, err := utils.GetColumnIndex(f, sheetName, "SPU Name")
+	priceData, err := r.priceData(ctx)
 	if err != nil {
 		return nil, err
 	}
-	dealerCodeIdx, err := utils.GetColumnIndex(f, sheetName, "Dealer Code")
+	tseMapping, err := r.tseMapping(ctx)
 	if err != nil {
 		return nil, err
 	}
-	dealerNameIdx, err := utils.GetColumnIndex(f, sheetName, "Dealer Name")
+	retailerCodeToCreditMap, err := r.GetTotalCreditFromReports(ctx)
 	if err != nil {
 		return nil, err
 	}
-
-	dealerSPUInventory := make(map[string]*SPUInventoryCount)
-	for _, row := range rows[1:] {
-		rawSpuName := row[spuNameIdx]
-		spuName := strings.ReplaceAll(rawSpuName, "realme", "") // Remove "realme" from model
-		dealerCode := row[dealerCodeIdx]
-		dealerName := row[dealerNameIdx]
-
-		if spuName == "" || dealerCode == "" || dealerName == "" {
-			continue
-		}
-		trimmedSPU := strings.TrimSpace(spuName)
-		if modelsOfInterest != nil {
-			//Skip this SKU if its SPUName is not in modelsOfInterest
-			if _, exists := modelsOfInterest[trimmedSPU]; !exists { //
-				continue
-			}
-		}
-		// {{ edit_1 }}: Calculate quantity (QTY) for each retailer and SPU Name
-		if data, exists := dealerSPUInventory[dealerName+trimmedSPU]; exists {
-			data.Count += 1 // Increment count for existing SPU
-		} else {
-			dealerSPUInventory[dealerName+trimmedSPU] = &SPUInventoryCount{
-				DealerCode: dealerCode,
-				DealerName: dealerName,
-				SPUName:    trimmedSPU,
-				Count:      1, // Initialize count
-			}
-		}
-	}
-	return dealerSPUInventory, nil
+	return inventoryShortFall(units, priceData, tseMapping, retailerCodeToCreditMap), nil
 }
 
-func (r *ExcelInventoryRepository) ComputeInventoryShortFall() (map[string]*InventoryShortFallRepo, error) {
-	fmt.Println("Compute current inventory and shortfall for all retailers.")
-	f, err := excelize.OpenFile(r.filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open inventory file: %w", err)
-	}
-	defer f.Close()
-	fmt.Println("Fetching today's stock inventory data for each retailer.")
-
-	sheetName := f.GetSheetName(0)
-	rows, err := f.GetRows(sheetName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get rows: %w", err)
-	}
-
-	materialCodeIdx, err := utils.GetColumnIndex(f, sheetName, "Material Code")
-	if err != nil {
-		return nil, err
-	}
-	dealerCodeIdx, err := utils.GetColumnIndex(f, sheetName, "Dealer Code")
+func (r *ExcelInventoryRepository) ComputeInventoryCost(ctx context.Context) (map[string]float64, error) {
+	r.log("inventory", r.filePath).Info("computing current inventory cost of all retailers")
+	units, err := r.readUnits(ctx, "Material Code", "Dealer Code", "Dealer Name")
 	if err != nil {
 		return nil, err
 	}
-	dealerNameIdx, err := utils.GetColumnIndex(f, sheetName, "Dealer Name")
+	priceData, err := r.priceData(ctx)
 	if err != nil {
 		return nil, err
 	}
-
-	inventoryData := make(map[string]*InventoryShortFallRepo)
-	retailerCodeToCreditMap, _ := r.GetTotalCreditFromReports()
-	for _, row := range rows[1:] {
-		materialCode := row[materialCodeIdx]
-		dealerCode := row[dealerCodeIdx]
-		dealerName := row[dealerNameIdx]
-
-		if materialCode == "" || dealerCode == "" {
-			continue
-		}
-
-		netLandingCost := r.priceData[materialCode]
-		if data, exists := inventoryData[dealerCode]; exists {
-			data.TotalInventoryCost += netLandingCost
-		} else {
-			inventoryData[dealerCode] = &InventoryShortFallRepo{
-				DealerCode:         dealerCode,
-				DealerName:         dealerName,
-				TSE:                r.tseMapping[dealerCode],
-				TotalInventoryCost: netLandingCost,
-			}
-		}
-
-	}
-
-	// Update inventoryData with CostCreditDifference and TotalCredit
-	for _, data := range inventoryData {
-		data.TotalCreditDue = retailerCodeToCreditMap[data.DealerCode]
-		data.InventoryShortfall = data.TotalInventoryCost - data.TotalCreditDue
-	}
-
-	return inventoryData, nil
+	return inventoryCost(units, priceData), nil
 }
 
-func (r *ExcelInventoryRepository) GetTotalCreditFromReports() (map[string]float64, error) {
+// GetTotalCreditFromReports returns the total credit due of each retailer by
+// code, read back from today's credit reports. It fails if the credit report
+// has not run today: assuming nothing is due would overstate every
+// retailer's shortfall.
+func (r *ExcelInventoryRepository) GetTotalCreditFromReports(ctx context.Context) (map[string]float64, error) {
 	creditData := make(map[string]float64)
 	// Generate today's date in YYYY-MM-DD format
 	today := time.Now().Format("2006-01-02")
-	fmt.Printf("Fetching today's credit report for each retailer on %s\n", today) // Print key-value pairs
-	reportDir := fmt.Sprintf("../credit_report/credit_reports_%s", today)
+	reportDir := utils.GenerateOutputPath(r.outputDir, "credit_reports")
+	logger := r.log("inventory", reportDir)
+	logger.Info("fetching today's credit dues from the credit reports", "date", today)
+	if _, err := os.Stat(reportDir); os.IsNotExist(err) {
+		return nil, fmt.Errorf("no credit reports in %s: run the credit report first", reportDir)
+	}
 
 	// Read all .xlsx files in the directory
+	workbooks := 0
 	err := filepath.Walk(reportDir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
 		if !info.IsDir() && filepath.Ext(path) == ".xlsx" {
-			f, err := excelize.OpenFile(path)
+			workbooks++
+			f, err := OpenWorkbook(ctx, path)
 			if err != nil {
 				return fmt.Errorf("failed to open credit report file %s: %w", path, err)
 			}
 			defer f.Close()
 
 			sheetName := f.GetSheetName(0)
-			rows, err := f.GetRows(sheetName)
-			if err != nil {
-				return fmt.Errorf("failed to get rows from %s: %w", path, err)
+			// The first row contains the headers
+			if _, err := utils.GetColumnIndex(f, sheetName, "Retailer Code"); err != nil {
+				return err
 			}
-
-			// Assuming the first row contains headers
-			retailerCodeIdx, err := utils.GetColumnIndex(f, sheetName, "Retailer Code")
-			if err != nil {
+			if _, err := utils.GetColumnIndex(f, sheetName, "Total Credit(₹)"); err != nil {
 				return err
 			}
-			totalCreditIdx, err := utils.GetColumnIndex(f, sheetName, "Total Credit(₹)")
+			// The records leave out the total row. The raw values are
+			// read, not the amounts as displayed, rounded to the rupee.
+			records, err := excel.Records(f, sheetName, excelize.Options{RawCellValue: true})
 			if err != nil {
-				return err
+				return fmt.Errorf("failed to get rows from %s: %w", path, err)
 			}
 
-			for i, row := range rows[1:] {
-				if i == len(rows)-2 { // Skip the last row
-					break
-				}
-				if len(row) <= totalCreditIdx {
+			for _, record := range records {
+				retailerCode := record["Retailer Code"]
+				totalCredit := record["Total Credit(₹)"]
+				if totalCredit == "" {
 					continue
 				}
-				retailerCode := row[retailerCodeIdx]
-				totalCredit := row[totalCreditIdx]
-				totalCredit = strings.ReplaceAll(totalCredit, ",", "") // Remove commas
-
 				val, err := strconv.ParseFloat(totalCredit, 64) // Convert string to float64
 				if err != nil {
 					return fmt.Errorf("failed to parse total credit for retailer %s: %w", retailerCode, err)
@@ -241,70 +193,201 @@ func (r *ExcelInventoryRepository) GetTotalCreditFromReports() (map[string]float
 	if err != nil {
 		return nil, err
 	}
+	if workbooks == 0 {
+		return nil, fmt.Errorf("no credit reports in %s: run the credit report first", reportDir)
+	}
 
 	return creditData, nil
 }
 
-func (r *ExcelInventoryRepository) ComputeMaterialModelCount() (map[string]*ModelCountRepo, error) {
-	fmt.Printf("Computing material model count for all retailers using %s \n", r.filePath)
-	f, err := excelize.OpenFile(r.filePath)
+func (r *ExcelInventoryRepository) ComputeMaterialModelCount(ctx context.Context) (map[string]*ModelCountRepo, error) {
+	r.log("inventory", r.filePath).Info("computing material model count of all retailers")
+	units, err := r.readUnits(ctx, "Material Code", "Dealer Code", "Dealer Name", "SPU Name", "Color", "SKU Spec", "Product Type", "Area Name")
 	if err != nil {
-		return nil, fmt.Errorf("failed to open inventory file: %w", err)
+		return nil, err
 	}
-	defer f.Close()
-
-	sheetName := f.GetSheetName(0)
-	rows, err := f.GetRows(sheetName)
+	tseMapping, err := r.tseMapping(ctx)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get rows: %w", err)
+		return nil, err
 	}
+	return materialModelCount(units, tseMapping), nil
+}
 
-	materialCodeIdx, err := utils.GetColumnIndex(f, sheetName, "Material Code")
+func (r *ExcelInventoryRepository) ComputeRADealerSPUInventory(ctx context.Context, modelsOfInterest map[string]struct{}, raRetailers map[string]int) (map[string]*SPUInventoryCount, error) {
+	r.log("inventory", r.filePath).Info("fetching today's stock of RA retailers")
+	units, err := r.readUnits(ctx, "SPU Name", "Dealer Code", "Dealer Name")
 	if err != nil {
 		return nil, err
 	}
-	dealerCodeIdx, err := utils.GetColumnIndex(f, sheetName, "Dealer Code")
+	return raDealerSPUInventory(units, modelsOfInterest, raRetailers), nil
+}
+
+// inventoryColumns are the headers of the inventory export the repository
+// reads.
+var inventoryColumns = []string{"Dealer Code", "Dealer Name", "Area Name", "Material Code", "SPU Name", "Color", "SKU Spec", "Product Type"}
+
+// readUnits loads every stock unit of the inventory export. Only the given
+// columns are mandatory; any other known column that is missing is left blank.
+func (r *ExcelInventoryRepository) readUnits(ctx context.Context, required ...string) ([]InventoryUnit, error) {
+	units, err := loadUnits(ctx, r.cache, r.filePath)
 	if err != nil {
 		return nil, err
 	}
-	dealerNameIdx, err := utils.GetColumnIndex(f, sheetName, "Dealer Name")
+	return units.require(required...)
+}
+
+func loadUnits(ctx context.Context, cache *Cache, path string) (records[InventoryUnit], error) {
+	return load(cache, path, "inventory", func() (records[InventoryUnit], error) {
+		return parseUnits(ctx, path)
+	})
+}
+
+func parseUnits(ctx context.Context, path string) (records[InventoryUnit], error) {
+	var none records[InventoryUnit]
+	f, err := OpenWorkbook(ctx, path)
 	if err != nil {
-		return nil, err
+		return none, fmt.Errorf("failed to open inventory file: %w", err)
 	}
-	spuNameIdx, err := utils.GetColumnIndex(f, sheetName, "SPU Name")
+	defer f.Close()
+
+	sheetName := f.GetSheetName(0)
+	rows, err := f.GetRows(sheetName)
 	if err != nil {
-		return nil, err
+		return none, fmt.Errorf("failed to get rows: %w", err)
 	}
-	colorIdx, err := utils.GetColumnIndex(f, sheetName, "Color")
-	if err != nil {
-		return nil, err
+	if len(rows) == 0 {
+		return none, fmt.Errorf("no rows found in sheet")
 	}
-	skuSpecIdx, err := utils.GetColumnIndex(f, sheetName, "SKU Spec")
-	if err != nil {
-		return nil, err
+
+	idx, headers := columnIndices(f, sheetName, inventoryColumns...)
+	units := make([]InventoryUnit, 0, len(rows))
+	for _, row := range rows[1:] {
+		if err := ctx.Err(); err != nil {
+			return none, err
+		}
+		units = append(units, InventoryUnit{
+			DealerCode:   cellAt(row, idx["Dealer Code"]),
+			DealerName:   cellAt(row, idx["Dealer Name"]),
+			AreaName:     cellAt(row, idx["Area Name"]),
+			MaterialCode: cellAt(row, idx["Material Code"]),
+			SPUName:      cellAt(row, idx["SPU Name"]),
+			Color:        cellAt(row, idx["Color"]),
+			SKUSpec:      cellAt(row, idx["SKU Spec"]),
+			ProductType:  cellAt(row, idx["Product Type"]),
+		})
+	}
+	return records[InventoryUnit]{Rows: units, Headers: headers}, nil
+}
+
+func (r *ExcelInventoryRepository) priceData(ctx context.Context) (map[string]float64, error) {
+	if r.priceRepo == nil {
+		return map[string]float64{}, nil
 	}
-	productTypeIdx, err := utils.GetColumnIndex(f, sheetName, "Product Type")
+	priceData, err := r.priceRepo.GetProductPrices(ctx)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to read product prices: %w", err)
 	}
+	return priceData, nil
+}
 
-	distributorNameIdx, err := utils.GetColumnIndex(f, sheetName, "Area Name")
+func (r *ExcelInventoryRepository) tseMapping(ctx context.Context) (map[string]string, error) {
+	if r.tseMappingRepo == nil {
+		return map[string]string{}, nil
+	}
+	tseMapping, err := r.tseMappingRepo.GetRetailerCodeToTSEMap(ctx)
 	if err != nil {
-		return nil, err
+		return nil, fmt.Errorf("failed to read TSE mapping: %w", err)
+	}
+	return tseMapping, nil
+}
+
+func dealerSPUInventory(units []InventoryUnit, modelsOfInterest map[string]struct{}) map[string]*SPUInventoryCount {
+	dealerSPUInventory := make(map[string]*SPUInventoryCount)
+	for _, unit := range units {
+		spuName := strings.ReplaceAll(unit.SPUName, "realme", "") // Remove "realme" from model
+		dealerCode := unit.DealerCode
+		dealerName := unit.DealerName
+
+		if spuName == "" || dealerCode == "" || dealerName == "" {
+			continue
+		}
+		trimmedSPU := strings.TrimSpace(spuName)
+		if modelsOfInterest != nil {
+			//Skip this SKU if its SPUName is not in modelsOfInterest
+			if _, exists := modelsOfInterest[trimmedSPU]; !exists { //
+				continue
+			}
+		}
+		// Calculate quantity (QTY) for each retailer and SPU Name
+		if data, exists := dealerSPUInventory[dealerName+trimmedSPU]; exists {
+			data.Count += 1 // Increment count for existing SPU
+		} else {
+			dealerSPUInventory[dealerName+trimmedSPU] = &SPUInventoryCount{
+				DealerCode: dealerCode,
+				DealerName: dealerName,
+				SPUName:    trimmedSPU,
+				Count:      1, // Initialize count
+			}
+		}
+	}
+	return dealerSPUInventory
+}
+
+func inventoryShortFall(units []InventoryUnit, priceData map[string]float64, tseMapping map[string]string, retailerCodeToCreditMap map[string]float64) map[string]*InventoryShortFallRepo {
+	inventoryData := make(map[string]*InventoryShortFallRepo)
+	for _, unit := range units {
+		materialCode := unit.MaterialCode
+		dealerCode := unit.DealerCode
+
+		if materialCode == "" || dealerCode == "" {
+			continue
+		}
+
+		netLandingCost := priceData[materialCode]
+		if data, exists := inventoryData[dealerCode]; exists {
+			data.TotalInventoryCost += netLandingCost
+		} else {
+			inventoryData[dealerCode] = &InventoryShortFallRepo{
+				DealerCode:         dealerCode,
+				DealerName:         unit.DealerName,
+				TSE:                tseMapping[dealerCode],
+				TotalInventoryCost: netLandingCost,
+			}
+		}
+	}
+
+	// Update inventoryData with CostCreditDifference and TotalCredit
+	for _, data := range inventoryData {
+		data.TotalCreditDue = retailerCodeToCreditMap[data.DealerCode]
+		data.InventoryShortfall = data.TotalInventoryCost - data.TotalCreditDue
+	}
+	return inventoryData
+}
+
+func inventoryCost(units []InventoryUnit, priceData map[string]float64) map[string]float64 {
+	cost := make(map[string]float64)
+	for _, unit := range units {
+		if unit.MaterialCode == "" || unit.DealerCode == "" {
+			continue
+		}
+		cost[unit.DealerCode] += priceData[unit.MaterialCode]
 	}
+	return cost
+}
 
+func materialModelCount(units []InventoryUnit, tseMapping map[string]string) map[string]*ModelCountRepo {
 	materialCount := make(map[string]*ModelCountRepo)
-	for _, row := range rows[1:] {
-		materialCode := row[materialCodeIdx]
-		dealerCode := row[dealerCodeIdx]
-		dealerName := row[dealerNameIdx]
+	for _, unit := range units {
+		materialCode := unit.MaterialCode
+		dealerCode := unit.DealerCode
+		dealerName := unit.DealerName
 
 		if materialCode == "" {
 			continue
 		}
 
 		if dealerCode == "" {
-			dealerName = row[distributorNameIdx]
+			dealerName = unit.AreaName
 		}
 
 		if data, exists := materialCount[materialCode]; exists {
@@ -315,55 +398,26 @@ func (r *ExcelInventoryRepository) ComputeMaterialModelCount() (map[string]*Mode
 				DealerCode:   dealerCode,
 				DealerName:   dealerName,
 				MaterialCode: materialCodeInt,
-				SPUName:      row[spuNameIdx],
-				Color:        row[colorIdx],
-				SKUSpec:      row[skuSpecIdx],
-				ProductType:  row[productTypeIdx],
+				SPUName:      unit.SPUName,
+				Color:        unit.Color,
+				SKUSpec:      unit.SKUSpec,
+				ProductType:  unit.ProductType,
 				Count:        1, // Initialize count
-				TSE:          r.tseMapping[dealerCode],
+				TSE:          tseMapping[dealerCode],
 			}
 		}
 	}
-
-	return materialCount, nil
+	return materialCount
 }
 
-func (r *ExcelInventoryRepository) ComputeRADealerSPUInventory(modelsOfInterest map[string]struct{}, raRetailers map[string]int) (map[string]*SPUInventoryCount, error) {
-	fmt.Println("Input: Fetching Retailer Inventory from ", r.filePath)
-
-	f, err := excelize.OpenFile(r.filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open inventory file: %w", err)
-	}
-	defer f.Close()
-
-	sheetName := f.GetSheetName(0)
-	rows, err := f.GetRows(sheetName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get rows: %w", err)
-	}
-
-	spuNameIdx, err := utils.GetColumnIndex(f, sheetName, "SPU Name")
-	if err != nil {
-		return nil, err
-	}
-	dealerCodeIdx, err := utils.GetColumnIndex(f, sheetName, "Dealer Code")
-	if err != nil {
-		return nil, err
-	}
-	dealerNameIdx, err := utils.GetColumnIndex(f, sheetName, "Dealer Name")
-	if err != nil {
-		return nil, err
-	}
-
+func raDealerSPUInventory(units []InventoryUnit, modelsOfInterest map[string]struct{}, raRetailers map[string]int) map[string]*SPUInventoryCount {
 	// Initialize map to store inventory count for each RA retailer and SPU combination
 	dealerSPUInventory := make(map[string]*SPUInventoryCount)
 
-	for _, row := range rows[1:] {
-		rawSpuName := row[spuNameIdx]
-		spuName := strings.ReplaceAll(rawSpuName, "realme", "") // Remove "realme" from model
-		dealerCode := row[dealerCodeIdx]
-		dealerName := row[dealerNameIdx]
+	for _, unit := range units {
+		spuName := strings.ReplaceAll(unit.SPUName, "realme", "") // Remove "realme" from model
+		dealerCode := unit.DealerCode
+		dealerName := unit.DealerName
 
 		// Skip if necessary fields are empty
 		if spuName == "" || dealerCode == "" || dealerName == "" {
@@ -397,6 +451,5 @@ func (r *ExcelInventoryRepository) ComputeRADealerSPUInventory(modelsOfInterest
 			}
 		}
 	}
-
-	return dealerSPUInventory, nil
+	return dealerSPUInventory
 }
@@ -0,0 +1,24 @@
+package repository
+
+import "log/slog"
+
+// logged gives an Excel repository a logger. Report generators set it with
+// SetLogger so that every record also names the report being generated.
+type logged struct {
+	logger *slog.Logger
+}
+
+// SetLogger sets the logger the repository writes its records to.
+func (l *logged) SetLogger(logger *slog.Logger) {
+	l.logger = logger
+}
+
+// log returns the repository logger tagged with the repository name and the
+// input file it reads.
+func (l *logged) log(repository, file string) *slog.Logger {
+	logger := l.logger
+	if logger == nil {
+		logger = slog.Default()
+	}
+	return logger.With("repository", repository, "file", file)
+}
@@ -0,0 +1,220 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+)
+
+// The Memory* repositories below implement the repository interfaces on top
+// of plain Go values instead of Excel workbooks, so that reports can be
+// exercised without any input files. They share the computation of their
+// Excel counterparts; only the loading differs.
+
+// MemoryTSEMappingRepository serves the retailer master from memory.
+type MemoryTSEMappingRepository struct {
+	Retailers []Retailer
+}
+
+func NewMemoryTSEMappingRepository(retailers []Retailer) *MemoryTSEMappingRepository {
+	return &MemoryTSEMappingRepository{Retailers: retailers}
+}
+
+func (r *MemoryTSEMappingRepository) GetRARetailersMap(ctx context.Context) (map[string]int, error) {
+	return raRetailersMap(r.Retailers, slog.Default().With("repository", "memory_tse_mapping")), nil
+}
+
+func (r *MemoryTSEMappingRepository) GetRetailerCodeToTSEMap(ctx context.Context) (map[string]string, error) {
+	return retailerCodeToTSEMap(r.Retailers), nil
+}
+
+func (r *MemoryTSEMappingRepository) GetRetailerCodeToNameMap(ctx context.Context) (map[string]string, error) {
+	return retailerCodeToNameMap(r.Retailers), nil
+}
+
+func (r *MemoryTSEMappingRepository) GetRetailerNameToTSEMap(ctx context.Context, dealerNameHeader string) (map[string]string, error) {
+	return retailerNameToTSEMap(r.Retailers, dealerNameHeader)
+}
+
+func (r *MemoryTSEMappingRepository) GetRetailerNameToCodeMap(ctx context.Context) (map[string]string, error) {
+	return retailerNameToCodeMap(r.Retailers), nil
+}
+
+// MemoryProductPriceRepository serves net landing costs keyed by material code.
+type MemoryProductPriceRepository struct {
+	Prices map[string]float64
+}
+
+func NewMemoryProductPriceRepository(prices map[string]float64) *MemoryProductPriceRepository {
+	return &MemoryProductPriceRepository{Prices: prices}
+}
+
+func (r *MemoryProductPriceRepository) GetProductPrices(ctx context.Context) (map[string]float64, error) {
+	prices := make(map[string]float64, len(r.Prices))
+	for code, price := range r.Prices {
+		prices[code] = price
+	}
+	return prices, nil
+}
+
+// MemoryInventoryRepository computes inventory figures from in-memory stock
+// units. CreditDue stands in for the credit dues read back from today's
+// credit reports.
+type MemoryInventoryRepository struct {
+	Units      []InventoryUnit
+	Prices     map[string]float64
+	TSEMapping map[string]string
+	CreditDue  map[string]float64
+}
+
+func NewMemoryInventoryRepository(units []InventoryUnit) *MemoryInventoryRepository {
+	return &MemoryInventoryRepository{Units: units}
+}
+
+func (r *MemoryInventoryRepository) ComputeInventoryShortFall(ctx context.Context) (map[string]*InventoryShortFallRepo, error) {
+	return inventoryShortFall(r.Units, r.Prices, r.TSEMapping, r.CreditDue), nil
+}
+
+func (r *MemoryInventoryRepository) ComputeInventoryCost(ctx context.Context) (map[string]float64, error) {
+	return inventoryCost(r.Units, r.Prices), nil
+}
+
+func (r *MemoryInventoryRepository) ComputeMaterialModelCount(ctx context.Context) (map[string]*ModelCountRepo, error) {
+	return materialModelCount(r.Units, r.TSEMapping), nil
+}
+
+func (r *MemoryInventoryRepository) ComputeDealerSPUInventory(ctx context.Context, modelsOfInterest map[string]struct{}) (map[string]*SPUInventoryCount, error) {
+	return dealerSPUInventory(r.Units, modelsOfInterest), nil
+}
+
+func (r *MemoryInventoryRepository) ComputeRADealerSPUInventory(ctx context.Context, modelsOfInterest map[string]struct{}, raRetailers map[string]int) (map[string]*SPUInventoryCount, error) {
+	return raDealerSPUInventory(r.Units, modelsOfInterest, raRetailers), nil
+}
+
+// MemoryCreditRepository serves outstanding bills and per-retailer credit.
+type MemoryCreditRepository struct {
+	Bills  []Bill
+	Credit map[string]*CreditData
+}
+
+func NewMemoryCreditRepository(bills []Bill) *MemoryCreditRepository {
+	return &MemoryCreditRepository{Bills: bills}
+}
+
+func (r *MemoryCreditRepository) GetCreditData(ctx context.Context) (map[string]*CreditData, error) {
+	creditData := make(map[string]*CreditData, len(r.Credit))
+	for code, data := range r.Credit {
+		copied := *data
+		creditData[code] = &copied
+	}
+	return creditData, nil
+}
+
+func (r *MemoryCreditRepository) GetBills(ctx context.Context) ([]Bill, error) {
+	return append([]Bill(nil), r.Bills...), nil
+}
+
+func (r *MemoryCreditRepository) AggregateCreditByRetailer(bills []Bill, tseMapping map[string]string, retailerNameToCodeMap map[string]string) map[string]map[string]interface{} {
+	return aggregateCreditByRetailer(bills, tseMapping, retailerNameToCodeMap)
+}
+
+// MemoryDebitRepository serves the amount received from each retailer, keyed
+// by Tally ledger name.
+type MemoryDebitRepository struct {
+	Received map[string]float64
+}
+
+func NewMemoryDebitRepository(received map[string]float64) *MemoryDebitRepository {
+	return &MemoryDebitRepository{Received: received}
+}
+
+func (r *MemoryDebitRepository) GetDebit(ctx context.Context) (map[string]float64, error) {
+	received := make(map[string]float64, len(r.Received))
+	for name, amount := range r.Received {
+		received[name] = amount
+	}
+	return received, nil
+}
+
+// MemorySalesRepository serves sales events keyed by the file path the Excel
+// repository would have read them from. Today defaults to the current time
+// and bounds the month-to-date window of GetSales.
+type MemorySalesRepository struct {
+	Events map[string][]SaleEvent
+	Today  time.Time
+}
+
+func NewMemorySalesRepository(events map[string][]SaleEvent) *MemorySalesRepository {
+	return &MemorySalesRepository{Events: events}
+}
+
+func (r *MemorySalesRepository) GetSales(ctx context.Context, salesFilePath string) (map[string]*SellData, error) {
+	events, ok := r.Events[salesFilePath]
+	if !ok {
+		return nil, fmt.Errorf("failed to open sales file: %s not loaded", salesFilePath)
+	}
+	today := r.Today
+	if today.IsZero() {
+		today = time.Now()
+	}
+	return sellData(events, today), nil
+}
+
+func (r *MemorySalesRepository) GetDealerSPUSales(ctx context.Context, salesFilePath string, modelsOfInterest map[string]struct{}) (map[string]*DealerSPUSales, error) {
+	events, ok := r.Events[salesFilePath]
+	if !ok {
+		return nil, fmt.Errorf("failed to open sales file: %s not loaded", salesFilePath)
+	}
+	return dealerSPUSales(events, modelsOfInterest), nil
+}
+
+// MemoryPriceListRepository serves the zonal distributor price list and the
+// material codes of SKUs keyed by lower-cased "model|color|spec".
+type MemoryPriceListRepository struct {
+	Rows          []PriceListRow
+	MaterialCodes map[string]int
+}
+
+func NewMemoryPriceListRepository(rows []PriceListRow, materialCodes map[string]int) *MemoryPriceListRepository {
+	return &MemoryPriceListRepository{Rows: rows, MaterialCodes: materialCodes}
+}
+
+func (r *MemoryPriceListRepository) GetPriceListData(ctx context.Context) ([]PriceListRow, error) {
+	return append([]PriceListRow(nil), r.Rows...), nil
+}
+
+func (r *MemoryPriceListRepository) GetMaterialCodeMap(ctx context.Context) (map[string]int, error) {
+	materialCodes := make(map[string]int, len(r.MaterialCodes))
+	for key, code := range r.MaterialCodes {
+		materialCodes[key] = code
+	}
+	return materialCodes, nil
+}
+
+// MemorySalesTargetRepository serves Tally sales lines keyed by file path.
+// The TSE of each line is filled in from the mapping passed to ReadSales.
+type MemorySalesTargetRepository struct {
+	Sales map[string][]SalesData
+}
+
+func NewMemorySalesTargetRepository(sales map[string][]SalesData) *MemorySalesTargetRepository {
+	return &MemorySalesTargetRepository{Sales: sales}
+}
+
+func (r *MemorySalesTargetRepository) ReadSales(ctx context.Context, salesFilePath string, tseMap map[string]string) ([]*SalesData, error) {
+	lines, ok := r.Sales[salesFilePath]
+	if !ok {
+		return nil, fmt.Errorf("failed to open sales file: %s not loaded", salesFilePath)
+	}
+	sales := make([]*SalesData, 0, len(lines))
+	for _, line := range lines {
+		if line.DealerCode == "" {
+			continue
+		}
+		data := line
+		data.TSE = tseMap[line.DealerCode]
+		sales = append(sales, &data)
+	}
+	return sales, nil
+}
@@ -0,0 +1,315 @@
+package repository
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestMemoryTSEMappingRepository(t *testing.T) {
+	ctx := context.Background()
+	repo := NewMemoryTSEMappingRepository([]Retailer{
+		{Code: "R1", Name: "Alpha Mobiles", TallyName: "ALPHA MOBILES HUBLI", TSE: "Krishna", Type: "RA", CountOfRA: "2"},
+		{Code: "R2", Name: "Bharat Telecom", TSE: "Ravi", Type: "RA", CountOfRA: "many"},
+		{Code: "R3", Name: "Chetan Cell", TallyName: "CHETAN CELL", TSE: "Ravi", Type: "Retail", CountOfRA: "1"},
+		{Name: "No Code", TallyName: "NO CODE", TSE: "Ravi", Type: "RA", CountOfRA: "1"},
+	})
+
+	tests := []struct {
+		name string
+		get  func() (interface{}, error)
+		want interface{}
+	}{
+		{"code to TSE leaves out retailers without a code", func() (interface{}, error) { return repo.GetRetailerCodeToTSEMap(ctx) },
+			map[string]string{"R1": "Krishna", "R2": "Ravi", "R3": "Ravi"}},
+		{"code to name", func() (interface{}, error) { return repo.GetRetailerCodeToNameMap(ctx) },
+			map[string]string{"R1": "Alpha Mobiles", "R2": "Bharat Telecom", "R3": "Chetan Cell"}},
+		{"DMS name to TSE", func() (interface{}, error) { return repo.GetRetailerNameToTSEMap(ctx, "Dealer Name") },
+			map[string]string{"Alpha Mobiles": "Krishna", "Bharat Telecom": "Ravi", "Chetan Cell": "Ravi", "No Code": "Ravi"}},
+		{"Tally name to TSE leaves out retailers without one", func() (interface{}, error) { return repo.GetRetailerNameToTSEMap(ctx, "Tally Name(Dealer Name)") },
+			map[string]string{"ALPHA MOBILES HUBLI": "Krishna", "CHETAN CELL": "Ravi", "NO CODE": "Ravi"}},
+		{"Tally name to code", func() (interface{}, error) { return repo.GetRetailerNameToCodeMap(ctx) },
+			map[string]string{"ALPHA MOBILES HUBLI": "R1", "CHETAN CELL": "R3", "NO CODE": ""}},
+		{"RA retailers with a code and a valid count", func() (interface{}, error) { return repo.GetRARetailersMap(ctx) },
+			map[string]int{"R1": 2}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.get()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := repo.GetRetailerNameToTSEMap(ctx, "Retailer"); err == nil {
+		t.Error("want an error for an unknown dealer name column")
+	}
+}
+
+func TestMemoryInventoryRepository(t *testing.T) {
+	ctx := context.Background()
+	unit := func(code, name, material, spu string) InventoryUnit {
+		return InventoryUnit{DealerCode: code, DealerName: name, MaterialCode: material, SPUName: spu, ProductType: "mobile phone"}
+	}
+	repo := &MemoryInventoryRepository{
+		Units: []InventoryUnit{
+			unit("R1", "Alpha", "1001", "realme C61"),
+			unit("R1", "Alpha", "1001", "realme C61"),
+			unit("R1", "Alpha", "1002", "realme 12 Pro"),
+			unit("R2", "Bharat", "1002", "realme 12 Pro"),
+			unit("", "", "1003", "realme C63"),     // warehouse stock
+			unit("R2", "Bharat", "", "realme C63"), // no material code
+		},
+		Prices:     map[string]float64{"1001": 8000, "1002": 20000},
+		TSEMapping: map[string]string{"R1": "Krishna", "R2": "Ravi"},
+		CreditDue:  map[string]float64{"R1": 40000.5},
+	}
+
+	cost, err := repo.ComputeInventoryCost(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := map[string]float64{"R1": 36000, "R2": 20000}; !reflect.DeepEqual(cost, want) {
+		t.Errorf("cost = %v, want %v", cost, want)
+	}
+
+	shortfall, err := repo.ComputeInventoryShortFall(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := shortfall["R1"]; got == nil || got.TotalCreditDue != 40000.5 || got.InventoryShortfall != -4000.5 || got.TSE != "Krishna" {
+		t.Errorf("R1 shortfall = %+v", got)
+	}
+	if got := shortfall["R2"]; got == nil || got.TotalCreditDue != 0 || got.InventoryShortfall != 20000 {
+		t.Errorf("R2 shortfall = %+v", got)
+	}
+
+	counts, err := repo.ComputeMaterialModelCount(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := counts["1001"]; got == nil || got.Count != 2 || got.MaterialCode != 1001 {
+		t.Errorf("1001 count = %+v", got)
+	}
+	if got := counts["1003"]; got == nil || got.Count != 1 || got.DealerCode != "" {
+		t.Errorf("warehouse stock of 1003 = %+v", got)
+	}
+
+	tests := []struct {
+		name   string
+		models map[string]struct{}
+		ra     map[string]int
+		want   map[string]int // count by dealer code + model
+	}{
+		{"every model", nil, nil, map[string]int{"AlphaC61": 2, "Alpha12 Pro": 1, "Bharat12 Pro": 1, "BharatC63": 1}},
+		{"models of interest", map[string]struct{}{"C61": {}}, nil, map[string]int{"AlphaC61": 2}},
+		{"RA retailers", map[string]struct{}{"12 Pro": {}}, map[string]int{"R2": 1}, map[string]int{"R212 Pro": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var inventory map[string]*SPUInventoryCount
+			if tt.ra != nil {
+				inventory, err = repo.ComputeRADealerSPUInventory(ctx, tt.models, tt.ra)
+			} else {
+				inventory, err = repo.ComputeDealerSPUInventory(ctx, tt.models)
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]int, len(inventory))
+			for key, count := range inventory {
+				got[key] = count.Count
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMemoryCreditRepository(t *testing.T) {
+	bills := []Bill{
+		{RetailerName: "ALPHA", PendingAmount: 100, AgeOfBill: 0},
+		{RetailerName: "ALPHA", PendingAmount: 200, AgeOfBill: 7},
+		{RetailerName: "ALPHA", PendingAmount: 300, AgeOfBill: 14},
+		{RetailerName: "ALPHA", PendingAmount: 400, AgeOfBill: 20},
+		{RetailerName: "ALPHA", PendingAmount: 500, AgeOfBill: 30},
+		{RetailerName: "ALPHA", PendingAmount: 600, AgeOfBill: 31},
+		{RetailerName: "BHARAT", PendingAmount: 50.5, AgeOfBill: 21},
+	}
+	repo := NewMemoryCreditRepository(bills)
+	repo.Credit = map[string]*CreditData{"R1": {RetailerCode: "R1", TotalCredit: 2100}}
+
+	got, err := repo.GetBills(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	got[0].PendingAmount = 0
+	if repo.Bills[0].PendingAmount != 100 {
+		t.Error("GetBills must return a copy of the bills")
+	}
+	credit, err := repo.GetCreditData(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	credit["R1"].TotalCredit = 0
+	if repo.Credit["R1"].TotalCredit != 2100 {
+		t.Error("GetCreditData must return a copy of the credit")
+	}
+
+	aggregated := repo.AggregateCreditByRetailer(bills, map[string]string{"ALPHA": "Krishna"}, map[string]string{"ALPHA": "R1"})
+	tests := []struct {
+		retailer string
+		want     map[string]interface{}
+	}{
+		{"ALPHA", map[string]interface{}{
+			"Retailer Code": "R1", "Retailer Name": "ALPHA", "TSE": "Krishna",
+			"0-7 Days": 300.0, "8-14 Days": 300.0, "15-20 Days": 400.0, "21-30 Days": 500.0, "31+ Days": 600.0, "Total Credit": 2100.0,
+		}},
+		{"BHARAT", map[string]interface{}{
+			"Retailer Code": "", "Retailer Name": "BHARAT", "TSE": "",
+			"0-7 Days": 0.0, "8-14 Days": 0.0, "15-20 Days": 0.0, "21-30 Days": 50.5, "31+ Days": 0.0, "Total Credit": 50.5,
+		}},
+	}
+	for _, tt := range tests {
+		if !reflect.DeepEqual(aggregated[tt.retailer], tt.want) {
+			t.Errorf("%s = %v, want %v", tt.retailer, aggregated[tt.retailer], tt.want)
+		}
+	}
+}
+
+func TestMemorySalesRepository(t *testing.T) {
+	ctx := context.Background()
+	today := time.Date(2024, 3, 15, 18, 0, 0, 0, time.UTC)
+	event := func(code, at, spu, productType string) SaleEvent {
+		return SaleEvent{DealerCode: code, DealerName: "Dealer " + code, ActivateTime: at, SPUName: spu, ProductType: productType}
+	}
+	repo := &MemorySalesRepository{
+		Events: map[string][]SaleEvent{
+			"lmtd": {
+				event("R1", "2024-02-01 10:00:00", "realme C61", "mobile phone"),
+				event("R1", "2024-02-15 23:59:00", "realme C61", "mobile phone"),
+				event("R1", "2024-02-16 09:00:00", "realme C61", "mobile phone"), // after today's day of month
+				event("R2", "2024-02-10 09:00:00", "realme 12 Pro", "mobile phone"),
+				event("", "2024-02-10 09:00:00", "realme 12 Pro", "mobile phone"),
+				event("R2", "2024-02-11 09:00:00", "Buds T110", "accessory"),
+			},
+		},
+		Today: today,
+	}
+
+	sales, err := repo.GetSales(ctx, "lmtd")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := make(map[string]int, len(sales))
+	for code, data := range sales {
+		got[code] = data.MTDS
+	}
+	if want := map[string]int{"R1": 2, "R2": 2}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sales up to the 15th = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		name   string
+		models map[string]struct{}
+		want   map[string]int
+	}{
+		{"every mobile", nil, map[string]int{"Dealer R1C61": 3, "Dealer R212 Pro": 1}},
+		{"models of interest", map[string]struct{}{"12 Pro": {}}, map[string]int{"Dealer R212 Pro": 1}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spuSales, err := repo.GetDealerSPUSales(ctx, "lmtd", tt.models)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := make(map[string]int, len(spuSales))
+			for key, sale := range spuSales {
+				got[key] = sale.Count
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if _, err := repo.GetSales(ctx, "mtd"); err == nil {
+		t.Error("want an error for sales that were not loaded")
+	}
+	if _, err := repo.GetDealerSPUSales(ctx, "mtd", nil); err == nil {
+		t.Error("want an error for sales that were not loaded")
+	}
+}
+
+func TestMemorySalesTargetRepository(t *testing.T) {
+	repo := NewMemorySalesTargetRepository(map[string][]SalesData{
+		"sales": {
+			{DealerCode: "R1", DealerName: "Alpha", Value: 12000, ItemName: "realme C61"},
+			{DealerName: "Cash sale", Value: 500},
+			{DealerCode: "R9", DealerName: "Unmapped", Value: 800},
+		},
+	})
+	sales, err := repo.ReadSales(context.Background(), "sales", map[string]string{"R1": "Krishna"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(sales) != 2 || sales[0].TSE != "Krishna" || sales[1].TSE != "" {
+		t.Fatalf("got %+v, want the lines with a dealer code, with their TSEs", sales)
+	}
+	if repo.Sales["sales"][0].TSE != "" {
+		t.Error("ReadSales must not fill in the TSE of the stored lines")
+	}
+	if _, err := repo.ReadSales(context.Background(), "other", nil); err == nil {
+		t.Error("want an error for sales that were not loaded")
+	}
+}
+
+func TestMemoryRepositoriesReturnCopies(t *testing.T) {
+	ctx := context.Background()
+	prices := NewMemoryProductPriceRepository(map[string]float64{"1001": 8000})
+	debit := NewMemoryDebitRepository(map[string]float64{"ALPHA": 2500})
+	priceList := NewMemoryPriceListRepository([]PriceListRow{{Model: "C61", NLC: 7500}}, map[string]int{"c61|green|4+64": 1001})
+
+	tests := []struct {
+		name    string
+		mutate  func() error
+		changed func() bool
+	}{
+		{"product prices", func() error {
+			p, err := prices.GetProductPrices(ctx)
+			p["1001"] = 0
+			return err
+		}, func() bool { return prices.Prices["1001"] != 8000 }},
+		{"receipts", func() error {
+			d, err := debit.GetDebit(ctx)
+			d["ALPHA"] = 0
+			return err
+		}, func() bool { return debit.Received["ALPHA"] != 2500 }},
+		{"price list", func() error {
+			rows, err := priceList.GetPriceListData(ctx)
+			rows[0].NLC = 0
+			return err
+		}, func() bool { return priceList.Rows[0].NLC != 7500 }},
+		{"material codes", func() error {
+			codes, err := priceList.GetMaterialCodeMap(ctx)
+			codes["c61|green|4+64"] = 0
+			return err
+		}, func() bool { return priceList.MaterialCodes["c61|green|4+64"] != 1001 }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.mutate(); err != nil {
+				t.Fatal(err)
+			}
+			if tt.changed() {
+				t.Error("changing the returned values changed the repository")
+			}
+		})
+	}
+}
@@ -1,15 +1,17 @@
 package repository
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"strconv"
 	"strings"
 	"viking-reports/internal/utils"
-
-	"github.com/xuri/excelize/v2"
 )
 
 type ExcelPriceListRepository struct {
+	logged
+	cached
 	zdPriceList         string
 	realMeInventoryList string
 }
@@ -40,62 +42,45 @@ func NewExcelPriceListRepository(filePath string, inventoryReportPath string) *E
 	return &ExcelPriceListRepository{zdPriceList: filePath, realMeInventoryList: inventoryReportPath}
 }
 
-func (r *ExcelPriceListRepository) GetMaterialCodeMap() (map[string]int, error) {
-	fmt.Printf("\nCompute material code for SKUs using %s\n", r.realMeInventoryList)
-
-	f, err := excelize.OpenFile(r.realMeInventoryList)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open inventory file: %w", err)
-	}
-	defer f.Close()
-	fmt.Println("Fetching today's stock inventory data for each retailer.")
-
-	sheetName := f.GetSheetName(0)
-	rows, err := f.GetRows(sheetName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get rows: %w", err)
-	}
-
-	materialCodeIdx, err := utils.GetColumnIndex(f, sheetName, "Material Code")
+func (r *ExcelPriceListRepository) GetMaterialCodeMap(ctx context.Context) (map[string]int, error) {
+	r.log("pricelist", r.realMeInventoryList).Info("computing material codes of SKUs from today's inventory")
+	units, err := loadUnits(ctx, r.cache, r.realMeInventoryList)
 	if err != nil {
 		return nil, err
 	}
-	spuNameIdx, err := utils.GetColumnIndex(f, sheetName, "SPU Name")
+	rows, err := units.require("Material Code", "SPU Name", "Color", "SKU Spec")
 	if err != nil {
 		return nil, err
 	}
-	colorIdx, err := utils.GetColumnIndex(f, sheetName, "Color")
-	if err != nil {
-		return nil, err
-	}
-	skuSpecIdx, err := utils.GetColumnIndex(f, sheetName, "SKU Spec")
-	if err != nil {
-		return nil, err
-	}
-
-	// Create a map to store unique Material Codes
-	materialCodeMap := make(map[string]int)
-	for _, row := range rows[1:] { // Skip header row
-		if len(row) >= 4 { // Ensure we have enough columns
-			spuName := row[spuNameIdx]
-			color := row[colorIdx]
-			skuSpec := row[skuSpecIdx]
-			materialCode, _ := strconv.Atoi(row[materialCodeIdx])
+	return materialCodeMap(rows), nil
+}
 
-			// Create a unique key based on SPU Name, Color, and SKU Spec
-			key := fmt.Sprintf("%s|%s|%s", spuName, color, skuSpec)
-			// Store the Material Code in the map
-			materialCodeMap[strings.ToLower(key)] = materialCode
-		}
+// materialCodeMap maps the lower-cased "SPU Name|Color|SKU Spec" of each
+// unit to its material code.
+func materialCodeMap(units []InventoryUnit) map[string]int {
+	codes := make(map[string]int)
+	for _, unit := range units {
+		materialCode, _ := strconv.Atoi(unit.MaterialCode)
+		key := fmt.Sprintf("%s|%s|%s", unit.SPUName, unit.Color, unit.SKUSpec)
+		codes[strings.ToLower(key)] = materialCode
 	}
+	return codes
+}
 
-	// Return the map and results
-	return materialCodeMap, nil
+func (r *ExcelPriceListRepository) GetPriceListData(ctx context.Context) ([]PriceListRow, error) {
+	logger := r.log("pricelist", r.zdPriceList)
+	logger.Info("reading the zonal distributor price list")
+	return loadPriceList(ctx, r.cache, r.zdPriceList, logger)
 }
 
-func (r *ExcelPriceListRepository) GetPriceListData() ([]PriceListRow, error) {
-	fmt.Println("Read the price list given by zonal distributor from ", r.zdPriceList)
-	f, err := excelize.OpenFile(r.zdPriceList)
+func loadPriceList(ctx context.Context, cache *Cache, path string, logger *slog.Logger) ([]PriceListRow, error) {
+	return load(cache, path, "price_list", func() ([]PriceListRow, error) {
+		return parsePriceList(ctx, path, logger)
+	})
+}
+
+func parsePriceList(ctx context.Context, path string, logger *slog.Logger) ([]PriceListRow, error) {
+	f, err := OpenWorkbook(ctx, path)
 	if err != nil {
 		return nil, err
 	}
@@ -112,47 +97,42 @@ func (r *ExcelPriceListRepository) GetPriceListData() ([]PriceListRow, error) {
 	var typeIndx, modelIndx, colorIdx, capacityIdx, dlrPriceIdx, mopIdx, mrpIdx int // Track indices
 	headerRow := 1
 	for i, row := range rows {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 
 		if i == headerRow {
 			// Skip header
-			// Log the headers for debugging
-			fmt.Println("Headers found:", row)
+			logger.Debug("price list headers found", "headers", row)
 
 			// Set up column indices from the second row (headers)
 			var err error
 			typeIndx, err = utils.GetHeaderIndex(f, sheetName, "TYPE", headerRow)
 			if err != nil {
-				fmt.Println("Error retrieving index for TYPE:", err)
 				return nil, err
 			}
 			modelIndx, err = utils.GetHeaderIndex(f, sheetName, "Model", headerRow)
 			if err != nil {
-				fmt.Println("Error retrieving index for Model:", err)
 				return nil, err
 			}
 			colorIdx, err = utils.GetHeaderIndex(f, sheetName, "COLOURS", headerRow)
 			if err != nil {
-				fmt.Println("Error retrieving index for COLOURS:", err)
 				return nil, err
 			}
 			capacityIdx, err = utils.GetHeaderIndex(f, sheetName, "Variant", headerRow) // Added index for capacity
 			if err != nil {
-				fmt.Println("Error retrieving index for Variant:", err)
 				return nil, err
 			}
 			dlrPriceIdx, err = utils.GetHeaderIndex(f, sheetName, "DLR PRICE", headerRow)
 			if err != nil {
-				fmt.Println("Error retrieving index for DLR PRICE:", err)
 				return nil, err
 			}
 			mopIdx, err = utils.GetHeaderIndex(f, sheetName, "MOP", headerRow)
 			if err != nil {
-				fmt.Println("Error retrieving index for MOP:", err)
 				return nil, err
 			}
 			mrpIdx, err = utils.GetHeaderIndex(f, sheetName, "MRP", headerRow)
 			if err != nil {
-				fmt.Println("Error retrieving index for MRP:", err)
 				return nil, err
 			}
 			// Skip to the next iteration to start processing data rows
@@ -1,14 +1,15 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"viking-reports/internal/utils"
-
-	"github.com/xuri/excelize/v2"
 )
 
 type ExcelProductPriceRepository struct {
+	logged
+	cached
 	filePath string
 }
 
@@ -16,8 +17,19 @@ func NewExcelProductPriceRepository(filePath string) *ExcelProductPriceRepositor
 	return &ExcelProductPriceRepository{filePath: filePath}
 }
 
-func (r *ExcelProductPriceRepository) GetProductPrices() (map[string]float64, error) {
-	f, err := excelize.OpenFile(r.filePath)
+func (r *ExcelProductPriceRepository) GetProductPrices(ctx context.Context) (map[string]float64, error) {
+	r.log("product_price", r.filePath).Info("fetching net landing cost of each material code")
+	return loadProductPrices(ctx, r.cache, r.filePath)
+}
+
+func loadProductPrices(ctx context.Context, cache *Cache, path string) (map[string]float64, error) {
+	return load(cache, path, "product_prices", func() (map[string]float64, error) {
+		return parseProductPrices(ctx, path)
+	})
+}
+
+func parseProductPrices(ctx context.Context, path string) (map[string]float64, error) {
+	f, err := OpenWorkbook(ctx, path)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open price list file: %w", err)
 	}
@@ -40,6 +52,9 @@ func (r *ExcelProductPriceRepository) GetProductPrices() (map[string]float64, er
 
 	priceData := make(map[string]float64)
 	for _, row := range rows[1:] {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		productCode := row[productCodeIdx]
 		priceStr := row[priceIdx]
 
@@ -1,15 +1,16 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"strings"
 	"time"
 	"viking-reports/internal/utils"
-
-	"github.com/xuri/excelize/v2"
 )
 
 type ExcelSalesRepository struct {
+	logged
+	cached
 }
 
 type GrowthData struct {
@@ -30,6 +31,15 @@ type SellData struct {
 	Date       string
 }
 
+// SaleEvent is one activation (SO) or dispatch (ST) row of a DMS sales export.
+type SaleEvent struct {
+	DealerCode   string
+	DealerName   string
+	ActivateTime string
+	SPUName      string
+	ProductType  string
+}
+
 type DealerSPUSales struct {
 	DealerCode string
 	DealerName string
@@ -41,53 +51,107 @@ func NewExcelSalesRepository() *ExcelSalesRepository {
 	return &ExcelSalesRepository{}
 }
 
-func (r *ExcelSalesRepository) GetSales(salesFilePath string) (map[string]*SellData, error) {
-	fmt.Printf(" from %s \n", salesFilePath)
-	f, err := excelize.OpenFile(salesFilePath)
+func (r *ExcelSalesRepository) GetSales(ctx context.Context, salesFilePath string) (map[string]*SellData, error) {
+	r.log("sales", salesFilePath).Info("fetching sales per retailer")
+	events, err := readSaleEvents(ctx, r.cache, salesFilePath, "Activate Time")
 	if err != nil {
-		return nil, fmt.Errorf("failed to open sales file: %w", err)
+		return nil, err
 	}
-	defer f.Close()
+	return sellData(events, time.Now()), nil
+}
 
-	sheetName := f.GetSheetName(0)
-	rows, err := f.GetRows(sheetName)
+func (r *ExcelSalesRepository) GetDealerSPUSales(ctx context.Context, salesFilePath string, modelsOfInterest map[string]struct{}) (map[string]*DealerSPUSales, error) {
+	r.log("sales", salesFilePath).Info("fetching sales per retailer and SPU")
+	events, err := readSaleEvents(ctx, r.cache, salesFilePath, "SPU Name", "Product Type")
 	if err != nil {
-		return nil, fmt.Errorf("failed to get rows: %w", err)
+		return nil, err
 	}
+	return dealerSPUSales(events, modelsOfInterest), nil
+}
+
+// saleEventColumns lists the accepted headers of each SaleEvent field. SO
+// exports use "Dealer Code" style headers while ST exports use "toDealerCode".
+var saleEventColumns = map[string][]string{
+	"Dealer Code":   {"Dealer Code", "toDealerCode"},
+	"Dealer Name":   {"Dealer Name", "toDealerName"},
+	"Activate Time": {"Activate Time", "activateTime"},
+	"SPU Name":      {"SPU Name"},
+	"Product Type":  {"Product Type"},
+}
 
-	dealerCodeIdx, err := utils.GetColumnIndex(f, sheetName, "Dealer Code")
+// readSaleEvents loads every row of a sales export. Dealer code and name are
+// always required, along with the given extra fields.
+func readSaleEvents(ctx context.Context, cache *Cache, salesFilePath string, required ...string) ([]SaleEvent, error) {
+	events, err := loadSaleEvents(ctx, cache, salesFilePath)
 	if err != nil {
-		// Try with "toDealerCode" if "Dealer Code" is not found
-		dealerCodeIdx, err = utils.GetColumnIndex(f, sheetName, "toDealerCode")
-		if err != nil {
-			return nil, err
-		}
+		return nil, err
 	}
-	dealerNameIdx, err := utils.GetColumnIndex(f, sheetName, "Dealer Name")
+	return events.require(append([]string{"Dealer Code", "Dealer Name"}, required...)...)
+}
+
+func loadSaleEvents(ctx context.Context, cache *Cache, path string) (records[SaleEvent], error) {
+	return load(cache, path, "sale_events", func() (records[SaleEvent], error) {
+		return parseSaleEvents(ctx, path)
+	})
+}
+
+// parseSaleEvents loads a sales export. The fields found are named by their
+// SaleEvent field, e.g. "Dealer Code" also when the header is "toDealerCode".
+func parseSaleEvents(ctx context.Context, path string) (records[SaleEvent], error) {
+	var none records[SaleEvent]
+	f, err := OpenWorkbook(ctx, path)
 	if err != nil {
-		// Try with "toDealerName" if "Dealer Name" is not found
-		dealerNameIdx, err = utils.GetColumnIndex(f, sheetName, "toDealerName")
-		if err != nil {
-			return nil, err
-		}
+		return none, fmt.Errorf("failed to open sales file: %w", err)
 	}
-	activateTimeIdx, err := utils.GetColumnIndex(f, sheetName, "Activate Time")
+	defer f.Close()
+
+	sheetName := f.GetSheetName(0)
+	rows, err := f.GetRows(sheetName)
 	if err != nil {
-		// Try with "activateTime" if "Activate Time" is not found
-		activateTimeIdx, err = utils.GetColumnIndex(f, sheetName, "activateTime")
-		if err != nil {
-			return nil, err
+		return none, fmt.Errorf("failed to get rows: %w", err)
+	}
+	if len(rows) == 0 {
+		return none, fmt.Errorf("no rows found in sheet")
+	}
+
+	idx := make(map[string]int, len(saleEventColumns))
+	var found []string
+	for field, headers := range saleEventColumns {
+		idx[field] = -1
+		for _, header := range headers {
+			if i, err := utils.GetColumnIndex(f, sheetName, header); err == nil {
+				idx[field] = i
+				found = append(found, field)
+				break
+			}
 		}
 	}
 
-	sellData := make(map[string]*SellData)
+	events := make([]SaleEvent, 0, len(rows))
 	for _, row := range rows[1:] {
-		dealerCode := row[dealerCodeIdx]
-		dealerName := row[dealerNameIdx]
-		activationTime := row[activateTimeIdx]
+		if err := ctx.Err(); err != nil {
+			return none, err
+		}
+		events = append(events, SaleEvent{
+			DealerCode:   cellAt(row, idx["Dealer Code"]),
+			DealerName:   cellAt(row, idx["Dealer Name"]),
+			ActivateTime: cellAt(row, idx["Activate Time"]),
+			SPUName:      cellAt(row, idx["SPU Name"]),
+			ProductType:  cellAt(row, idx["Product Type"]),
+		})
+	}
+	return records[SaleEvent]{Rows: events, Headers: found}, nil
+}
+
+// sellData counts the sales of each dealer up to today's day of the month, so
+// that last month's export only covers the same span as this month's.
+func sellData(events []SaleEvent, today time.Time) map[string]*SellData {
+	sellData := make(map[string]*SellData)
+	for _, event := range events {
+		dealerCode := event.DealerCode
+		activationTime := event.ActivateTime
 
 		// Filter lmtdSOData to keep only records with Date less than today
-		today := time.Now()
 		todayDay := today.Day()
 		saleDate, _ := time.Parse("2006-01-02 15:04:05", activationTime)
 		saleDay := saleDate.Day() // Extract day from activation time
@@ -101,57 +165,23 @@ func (r *ExcelSalesRepository) GetSales(salesFilePath string) (map[string]*SellD
 		} else {
 			sellData[dealerCode] = &SellData{
 				DealerCode: dealerCode,
-				DealerName: dealerName,
+				DealerName: event.DealerName,
 				Date:       activationTime,
 				MTDS:       1,
 			}
 		}
-
 	}
-
-	return sellData, nil
+	return sellData
 }
 
-func (r *ExcelSalesRepository) GetDealerSPUSales(salesFilePath string, modelsOfInterest map[string]struct{}) (map[string]*DealerSPUSales, error) {
-	fmt.Printf(" from %s \n", salesFilePath)
-	f, err := excelize.OpenFile(salesFilePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open sales file: %w", err)
-	}
-	defer f.Close()
-
-	sheetName := f.GetSheetName(0)
-	rows, err := f.GetRows(sheetName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get rows: %w", err)
-	}
-
-	spuNameIdx, err := utils.GetColumnIndex(f, sheetName, "SPU Name")
-	if err != nil {
-		return nil, err
-	}
-	dealerCodeIdx, err := utils.GetColumnIndex(f, sheetName, "Dealer Code")
-	if err != nil {
-		return nil, err
-	}
-	dealerNameIdx, err := utils.GetColumnIndex(f, sheetName, "Dealer Name")
-	if err != nil {
-		return nil, err
-	}
-	productTypeIdx, err := utils.GetColumnIndex(f, sheetName, "Product Type")
-	if err != nil {
-		return nil, err
-	}
-
+func dealerSPUSales(events []SaleEvent, modelsOfInterest map[string]struct{}) map[string]*DealerSPUSales {
 	dealerSPUSales := make(map[string]*DealerSPUSales)
-	for _, row := range rows[1:] {
-		rawSpuName := row[spuNameIdx]
-		spuName := strings.ReplaceAll(rawSpuName, "realme", "") // Remove "realme" from model
-		dealerCode := row[dealerCodeIdx]
-		dealerName := row[dealerNameIdx]
-		productType := row[productTypeIdx]
+	for _, event := range events {
+		spuName := strings.ReplaceAll(event.SPUName, "realme", "") // Remove "realme" from model
+		dealerCode := event.DealerCode
+		dealerName := event.DealerName
 
-		if spuName == "" || dealerCode == "" || dealerName == "" || !strings.Contains(productType, "mobile") {
+		if spuName == "" || dealerCode == "" || dealerName == "" || !strings.Contains(event.ProductType, "mobile") {
 			continue
 		}
 		trimmedSPU := strings.TrimSpace(spuName)
@@ -165,7 +195,7 @@ func (r *ExcelSalesRepository) GetDealerSPUSales(salesFilePath string, modelsOfI
 		if data, exists := dealerSPUSales[dealerName+trimmedSPU]; exists {
 			data.Count += 1 // Increment count for existing SPU
 		} else {
-			dealerSPUSales[dealerName+spuName] = &DealerSPUSales{
+			dealerSPUSales[dealerName+trimmedSPU] = &DealerSPUSales{
 				DealerCode: dealerCode,
 				DealerName: dealerName,
 				SPUName:    trimmedSPU,
@@ -173,5 +203,5 @@ func (r *ExcelSalesRepository) GetDealerSPUSales(salesFilePath string, modelsOfI
 			}
 		}
 	}
-	return dealerSPUSales, nil
+	return dealerSPUSales
 }
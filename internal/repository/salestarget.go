@@ -1,11 +1,10 @@
 package repository
 
 import (
+	"context"
 	"fmt"
 	"strconv"
 	"viking-reports/internal/utils"
-
-	"github.com/xuri/excelize/v2"
 )
 
 type SalesData struct {
@@ -18,14 +17,39 @@ type SalesData struct {
 }
 
 type ExcelSalesTargetRepository struct {
+	logged
+	cached
 }
 
 func NewExcelSalesTargetRepository() *ExcelSalesTargetRepository {
 	return &ExcelSalesTargetRepository{}
 }
 
-func (r *ExcelSalesTargetRepository) ReadSales(salesFilePath string, tseMap map[string]string) ([]*SalesData, error) {
-	f, err := excelize.OpenFile(salesFilePath)
+func (r *ExcelSalesTargetRepository) ReadSales(ctx context.Context, salesFilePath string, tseMap map[string]string) ([]*SalesData, error) {
+	r.log("salestarget", salesFilePath).Info("fetching monthly sales from Tally")
+	rows, err := loadTallySales(ctx, r.cache, salesFilePath)
+	if err != nil {
+		return nil, err
+	}
+	// The rows are shared, so each gets its TSE on a copy.
+	sales := make([]*SalesData, len(rows))
+	for i := range rows {
+		row := rows[i]
+		row.TSE = tseMap[row.DealerCode]
+		sales[i] = &row
+	}
+	return sales, nil
+}
+
+func loadTallySales(ctx context.Context, cache *Cache, path string) ([]SalesData, error) {
+	return load(cache, path, "tally_sales", func() ([]SalesData, error) {
+		return parseTallySales(ctx, path)
+	})
+}
+
+// parseTallySales loads the sales register rows, without their TSE.
+func parseTallySales(ctx context.Context, salesFilePath string) ([]SalesData, error) {
+	f, err := OpenWorkbook(ctx, salesFilePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open sales file: %w", err)
 	}
@@ -56,8 +80,11 @@ func (r *ExcelSalesTargetRepository) ReadSales(salesFilePath string, tseMap map[
 		return nil, err
 	}
 
-	sales := make([]*SalesData, 0)
+	sales := make([]SalesData, 0)
 	for _, row := range rows[9:] {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
 		dealerCode := row[dealerCodeIdx]
 		dealerName := row[dealerNameIdx]
 		itemName := row[itemNameIdx]
@@ -72,12 +99,11 @@ func (r *ExcelSalesTargetRepository) ReadSales(salesFilePath string, tseMap map[
 		}
 
 		// Create SalesData object and add to slice
-		sales = append(sales, &SalesData{
+		sales = append(sales, SalesData{
 			DealerCode: dealerCode,
 			DealerName: dealerName,
 			MTDS:       1, // Set MTDS to 1 for each entry
 			Value:      int(amount),
-			TSE:        tseMap[dealerCode],
 			ItemName:   itemName,
 		})
 
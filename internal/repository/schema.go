@@ -0,0 +1,187 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"strings"
+	"time"
+	"viking-reports/internal/utils"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Schema describes the layout the Excel repositories expect of an input
+// workbook. Every repository reads the first sheet.
+type Schema struct {
+	// HeaderRow is the zero-based index of the row holding the headers.
+	HeaderRow int
+	// FirstRow is the zero-based index of the first data row. Zero means the
+	// row after HeaderRow.
+	FirstRow int
+	// Footer is the number of trailing rows that are not data, such as the
+	// total row of Tally exports.
+	Footer int
+	// Headers lists the headers the repositories look up. A header that is
+	// accepted under several names lists all of them.
+	Headers [][]string
+	// Key names a column whose values identify a row and should be unique.
+	Key string
+
+	// kind names the records the repositories parse from the input; see Warm.
+	kind string
+}
+
+// Schemas of the input workbooks.
+var (
+	RetailerMetadataSchema = Schema{Headers: headers(retailerColumns...), Key: "Dealer Code", kind: "retailers"}
+	// Bills are read by position: the Tally export has eleven title and
+	// header rows and a closing total row.
+	BillsSchema         = Schema{HeaderRow: 9, FirstRow: 11, Footer: 1, kind: "bills"}
+	ReceivedSchema      = Schema{Headers: headers("Party Name", "Amount"), kind: "receipts"}
+	SalesExportSchema   = Schema{Headers: saleEventHeaders(), kind: "sale_events"}
+	InventorySchema     = Schema{Headers: headers(inventoryColumns...), kind: "inventory"}
+	ProductPriceSchema  = Schema{Headers: headers("Material Code", "NLC"), Key: "Material Code", kind: "product_prices"}
+	ZDPriceListSchema   = Schema{HeaderRow: 1, Headers: headers("TYPE", "Model", "COLOURS", "Variant", "DLR PRICE", "MOP", "MRP"), kind: "price_list"}
+	SalesRegisterSchema = Schema{HeaderRow: 9, Headers: headers("Retailer Code", "Party Name", "Amount ", "Item Name"), kind: "tally_sales"}
+)
+
+func headers(names ...string) [][]string {
+	h := make([][]string, len(names))
+	for i, name := range names {
+		h[i] = []string{name}
+	}
+	return h
+}
+
+func saleEventHeaders() [][]string {
+	var h [][]string
+	for _, field := range []string{"Dealer Code", "Dealer Name", "Activate Time", "SPU Name", "Product Type"} {
+		h = append(h, saleEventColumns[field])
+	}
+	return h
+}
+
+// MissingHeaders returns the headers of the schema that are not found on the
+// header row of the sheet, looked up the way the repositories do.
+func (s Schema) MissingHeaders(f *excelize.File, sheetName string) []string {
+	var missing []string
+	for _, names := range s.Headers {
+		found := false
+		for _, name := range names {
+			if _, err := s.index(f, sheetName, name); err == nil {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, strings.Join(names, " or "))
+		}
+	}
+	return missing
+}
+
+// KeyIndex returns the column index of the key header.
+func (s Schema) KeyIndex(f *excelize.File, sheetName string) (int, error) {
+	return s.index(f, sheetName, s.Key)
+}
+
+// DataRows returns the data rows of the sheet's rows.
+func (s Schema) DataRows(rows [][]string) [][]string {
+	first := s.FirstRow
+	if first == 0 {
+		first = s.HeaderRow + 1
+	}
+	last := len(rows) - s.Footer
+	if first >= last {
+		return nil
+	}
+	return rows[first:last]
+}
+
+// Warm parses the input at path, laid out as schema, into cache, so that the
+// repositories reading it later share the parsed records instead of opening
+// the file again.
+func Warm(ctx context.Context, cache *Cache, path string, schema Schema, logger *slog.Logger) error {
+	logger = logger.With("file", path)
+	var err error
+	switch schema.kind {
+	case "retailers":
+		_, err = loadRetailers(ctx, cache, path)
+	case "bills":
+		_, err = loadBills(ctx, cache, path, logger.With("repository", "credit"))
+	case "receipts":
+		_, err = loadReceipts(ctx, cache, path)
+	case "sale_events":
+		_, err = loadSaleEvents(ctx, cache, path)
+	case "inventory":
+		_, err = loadUnits(ctx, cache, path)
+	case "product_prices":
+		_, err = loadProductPrices(ctx, cache, path)
+	case "price_list":
+		_, err = loadPriceList(ctx, cache, path, logger.With("repository", "pricelist"))
+	case "tally_sales":
+		_, err = loadTallySales(ctx, cache, path)
+	default:
+		return fmt.Errorf("no repository reads %s", path)
+	}
+	return err
+}
+
+// billDateLayouts are the date formats of Tally's bill dates.
+var billDateLayouts = []string{"02-Jan-06", "2-Jan-06", "02-Jan-2006", "2-Jan-2006", "2006-01-02", "02-01-2006"}
+
+// LatestDate returns the day of the latest transaction in the input at path,
+// laid out as schema, and whether the input has dated rows at all: the bills
+// and the sales exports do.
+func LatestDate(ctx context.Context, cache *Cache, path string, schema Schema, logger *slog.Logger) (time.Time, bool, error) {
+	var (
+		dates   []string
+		layouts []string
+	)
+	switch schema.kind {
+	case "bills":
+		bills, err := loadBills(ctx, cache, path, logger.With("repository", "credit", "file", path))
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		for _, bill := range bills {
+			dates = append(dates, bill.Date)
+		}
+		layouts = billDateLayouts
+	case "sale_events":
+		events, err := loadSaleEvents(ctx, cache, path)
+		if err != nil {
+			return time.Time{}, false, err
+		}
+		rows, err := events.require("Activate Time")
+		if err != nil {
+			return time.Time{}, false, nil
+		}
+		for _, event := range rows {
+			dates = append(dates, event.ActivateTime)
+		}
+		layouts = saleTimeLayouts
+	default:
+		return time.Time{}, false, nil
+	}
+
+	var latest time.Time
+	for _, date := range dates {
+		if day, ok := parseTime(layouts, date); ok && day.After(latest) {
+			latest = day
+		}
+	}
+	if latest.IsZero() {
+		return latest, false, nil
+	}
+	y, m, d := latest.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local), true, nil
+}
+
+func (s Schema) index(f *excelize.File, sheetName, name string) (int, error) {
+	if s.HeaderRow == 0 {
+		return utils.GetColumnIndex(f, sheetName, name)
+	}
+	return utils.GetHeaderIndex(f, sheetName, name, s.HeaderRow)
+}
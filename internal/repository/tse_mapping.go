@@ -1,14 +1,28 @@
 package repository
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
 	"strconv"
 	"viking-reports/internal/utils"
 
 	"github.com/xuri/excelize/v2"
 )
 
+// Retailer is one row of the retailer master (Retailer Metadata.xlsx).
+type Retailer struct {
+	Code      string
+	Name      string
+	TallyName string
+	TSE       string
+	Type      string
+	CountOfRA string
+}
+
 type ExcelTSEMappingRepository struct {
+	logged
+	cached
 	filePath string
 }
 
@@ -16,206 +30,224 @@ func NewExcelTSEMappingRepository(filePath string) *ExcelTSEMappingRepository {
 	return &ExcelTSEMappingRepository{filePath: filePath}
 }
 
-func (r *ExcelTSEMappingRepository) GetRetailerCodeToTSEMap() (map[string]string, error) {
-	fmt.Println("Input: Fetching retailer code to TSE name map from ", r.filePath)
+func (r *ExcelTSEMappingRepository) GetRetailerCodeToTSEMap(ctx context.Context) (map[string]string, error) {
+	r.log("tse_mapping", r.filePath).Info("fetching retailer code to TSE map")
+	retailers, err := r.readRetailers(ctx, "Dealer Code", "TSE Name")
+	if err != nil {
+		return nil, err
+	}
+	return retailerCodeToTSEMap(retailers), nil
+}
 
-	f, err := excelize.OpenFile(r.filePath)
+func (r *ExcelTSEMappingRepository) GetRetailerCodeToNameMap(ctx context.Context) (map[string]string, error) {
+	r.log("tse_mapping", r.filePath).Info("fetching retailer code to name map")
+	retailers, err := r.readRetailers(ctx, "Dealer Code", "Dealer Name")
 	if err != nil {
-		return nil, fmt.Errorf("failed to open TSE mapping file: %w", err)
+		return nil, err
 	}
-	defer f.Close()
+	return retailerCodeToNameMap(retailers), nil
+}
 
-	sheetName := f.GetSheetName(0)
-	rows, err := f.GetRows(sheetName)
+func (r *ExcelTSEMappingRepository) GetRetailerNameToTSEMap(ctx context.Context, dealerNameHeader string) (map[string]string, error) {
+	r.log("tse_mapping", r.filePath).Info("fetching retailer name to TSE map", "header", dealerNameHeader)
+	retailers, err := r.readRetailers(ctx, dealerNameHeader, "TSE Name")
 	if err != nil {
-		return nil, fmt.Errorf("failed to get rows: %w", err)
+		return nil, err
 	}
+	return retailerNameToTSEMap(retailers, dealerNameHeader)
+}
 
-	dealerCodeIdx, err := utils.GetColumnIndex(f, sheetName, "Dealer Code")
+func (r *ExcelTSEMappingRepository) GetRetailerNameToCodeMap(ctx context.Context) (map[string]string, error) {
+	r.log("tse_mapping", r.filePath).Info("fetching retailer name to code map")
+	retailers, err := r.readRetailers(ctx, "Tally Name(Dealer Name)", "Dealer Code")
 	if err != nil {
 		return nil, err
 	}
-	tseNameIdx, err := utils.GetColumnIndex(f, sheetName, "TSE Name")
+	return retailerNameToCodeMap(retailers), nil
+}
+
+func (r *ExcelTSEMappingRepository) GetRARetailersMap(ctx context.Context) (map[string]int, error) {
+	r.log("tse_mapping", r.filePath).Info("fetching RA retailers")
+	retailers, err := r.readRetailers(ctx, "Dealer Code", "Type", "Count of RA")
 	if err != nil {
 		return nil, err
 	}
+	return raRetailersMap(retailers, r.log("tse_mapping", r.filePath)), nil
+}
 
-	tseMapping := make(map[string]string)
-	for _, row := range rows[1:] {
-		dealerCode := row[dealerCodeIdx]
-		tseName := row[tseNameIdx]
+// retailerColumns are the headers of the retailer master the repository reads.
+var retailerColumns = []string{"Dealer Code", "Dealer Name", "Tally Name(Dealer Name)", "TSE Name", "Type", "Count of RA"}
 
-		if dealerCode == "" {
-			continue
-		}
-		tseMapping[dealerCode] = tseName
+// readRetailers loads every row of the retailer master. Only the given
+// columns are mandatory; any other known column that is missing is left blank.
+func (r *ExcelTSEMappingRepository) readRetailers(ctx context.Context, required ...string) ([]Retailer, error) {
+	retailers, err := loadRetailers(ctx, r.cache, r.filePath)
+	if err != nil {
+		return nil, err
 	}
-
-	return tseMapping, nil
+	return retailers.require(required...)
 }
 
-func (r *ExcelTSEMappingRepository) GetRetailerCodeToNameMap() (map[string]string, error) {
-	fmt.Println("Input: Fetching retailer code to TSE name map from ", r.filePath)
+func loadRetailers(ctx context.Context, cache *Cache, path string) (records[Retailer], error) {
+	return load(cache, path, "retailers", func() (records[Retailer], error) {
+		return parseRetailers(ctx, path)
+	})
+}
 
-	f, err := excelize.OpenFile(r.filePath)
+func parseRetailers(ctx context.Context, path string) (records[Retailer], error) {
+	var none records[Retailer]
+	f, err := OpenWorkbook(ctx, path)
 	if err != nil {
-		return nil, fmt.Errorf("failed to open TSE mapping file: %w", err)
+		return none, fmt.Errorf("failed to open TSE mapping file: %w", err)
 	}
 	defer f.Close()
 
 	sheetName := f.GetSheetName(0)
 	rows, err := f.GetRows(sheetName)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get rows: %w", err)
-	}
-
-	dealerCodeIdx, err := utils.GetColumnIndex(f, sheetName, "Dealer Code")
-	if err != nil {
-		return nil, err
+		return none, fmt.Errorf("failed to get rows: %w", err)
 	}
-	dealerNameIdx, err := utils.GetColumnIndex(f, sheetName, "Dealer Name")
-	if err != nil {
-		return nil, err
+	if len(rows) == 0 {
+		return none, fmt.Errorf("no rows found in sheet")
 	}
 
-	codeToDealerMap := make(map[string]string)
+	idx, headers := columnIndices(f, sheetName, retailerColumns...)
+	var retailers []Retailer
 	for _, row := range rows[1:] {
-		dealerCode := row[dealerCodeIdx]
-		dealerName := row[dealerNameIdx]
+		if err := ctx.Err(); err != nil {
+			return none, err
+		}
+		retailers = append(retailers, Retailer{
+			Code:      cellAt(row, idx["Dealer Code"]),
+			Name:      cellAt(row, idx["Dealer Name"]),
+			TallyName: cellAt(row, idx["Tally Name(Dealer Name)"]),
+			TSE:       cellAt(row, idx["TSE Name"]),
+			Type:      cellAt(row, idx["Type"]),
+			CountOfRA: cellAt(row, idx["Count of RA"]),
+		})
+	}
+	return records[Retailer]{Rows: retailers, Headers: headers}, nil
+}
 
-		if dealerCode == "" {
+func retailerCodeToTSEMap(retailers []Retailer) map[string]string {
+	tseMapping := make(map[string]string)
+	for _, retailer := range retailers {
+		if retailer.Code == "" {
 			continue
 		}
-		codeToDealerMap[dealerCode] = dealerName
+		tseMapping[retailer.Code] = retailer.TSE
 	}
-
-	return codeToDealerMap, nil
+	return tseMapping
 }
 
-func (r *ExcelTSEMappingRepository) GetRetailerNameToTSEMap(dealerNameHeader string) (map[string]string, error) {
-	fmt.Println("Input: Fetching retailer name to TSE name map from metadata ", r.filePath)
-	f, err := excelize.OpenFile(r.filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open TSE mapping file: %w", err)
-	}
-	defer f.Close()
-
-	sheetName := f.GetSheetName(0)
-	rows, err := f.GetRows(sheetName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get rows: %w", err)
-	}
-
-	dealerNameIdx, err := utils.GetColumnIndex(f, sheetName, dealerNameHeader)
-	if err != nil {
-		return nil, err
-	}
-	tseNameIdx, err := utils.GetColumnIndex(f, sheetName, "TSE Name")
-	if err != nil {
-		return nil, err
+func retailerCodeToNameMap(retailers []Retailer) map[string]string {
+	codeToDealerMap := make(map[string]string)
+	for _, retailer := range retailers {
+		if retailer.Code == "" {
+			continue
+		}
+		codeToDealerMap[retailer.Code] = retailer.Name
 	}
+	return codeToDealerMap
+}
 
+// retailerNameToTSEMap keys the TSE assignment by either the DMS dealer name
+// ("Dealer Name") or the Tally ledger name ("Tally Name(Dealer Name)").
+func retailerNameToTSEMap(retailers []Retailer, dealerNameHeader string) (map[string]string, error) {
 	tseMapping := make(map[string]string)
-	for _, row := range rows[1:] {
-		dealerName := row[dealerNameIdx]
-		tseName := row[tseNameIdx]
+	for _, retailer := range retailers {
+		var dealerName string
+		switch dealerNameHeader {
+		case "Dealer Name":
+			dealerName = retailer.Name
+		case "Tally Name(Dealer Name)":
+			dealerName = retailer.TallyName
+		default:
+			return nil, fmt.Errorf("unsupported dealer name column %s", dealerNameHeader)
+		}
 
 		if dealerName == "" {
 			continue
 		}
-		tseMapping[dealerName] = tseName
+		tseMapping[dealerName] = retailer.TSE
 	}
-
 	return tseMapping, nil
 }
 
-func (r *ExcelTSEMappingRepository) GetRetailerNameToCodeMap() (map[string]string, error) {
-	fmt.Println("Input: Fetching retailer name to retailer code map from metadata ", r.filePath)
-
-	f, err := excelize.OpenFile(r.filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open TSE mapping file: %w", err)
-	}
-	defer f.Close()
-
-	sheetName := f.GetSheetName(0)
-	rows, err := f.GetRows(sheetName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get rows: %w", err)
-	}
-
-	dealerNameIdx, err := utils.GetColumnIndex(f, sheetName, "Tally Name(Dealer Name)")
-	if err != nil {
-		return nil, err
-	}
-	retailerCodeIdx, err := utils.GetColumnIndex(f, sheetName, "Dealer Code")
-	if err != nil {
-		return nil, err
-	}
-
+func retailerNameToCodeMap(retailers []Retailer) map[string]string {
 	retailerNameToCodeMap := make(map[string]string)
-	for _, row := range rows[1:] {
-		dealerName := row[dealerNameIdx]
-		retailerCode := row[retailerCodeIdx]
-
-		if dealerName == "" {
+	for _, retailer := range retailers {
+		if retailer.TallyName == "" {
 			continue
 		}
-		retailerNameToCodeMap[dealerName] = retailerCode
+		retailerNameToCodeMap[retailer.TallyName] = retailer.Code
 	}
-
-	return retailerNameToCodeMap, nil
+	return retailerNameToCodeMap
 }
 
-func (r *ExcelTSEMappingRepository) GetRARetailersMap() (map[string]int, error) {
-	fmt.Println("Input: Fetching RA retailers from metadata ", r.filePath)
-
-	f, err := excelize.OpenFile(r.filePath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to open TSE mapping file: %w", err)
-	}
-	defer f.Close()
-
-	sheetName := f.GetSheetName(0)
-	rows, err := f.GetRows(sheetName)
-	if err != nil {
-		return nil, fmt.Errorf("failed to get rows: %w", err)
-	}
-
-	dealerCodeIdx, err := utils.GetColumnIndex(f, sheetName, "Dealer Code")
-	if err != nil {
-		return nil, err
-	}
-	typeIdx, err := utils.GetColumnIndex(f, sheetName, "Type")
-	if err != nil {
-		return nil, err
-	}
-	countOfRAIdx, err := utils.GetColumnIndex(f, sheetName, "Count of RA")
-	if err != nil {
-		return nil, err
+func raRetailersMap(retailers []Retailer, logger *slog.Logger) map[string]int {
+	raRetailers := make(map[string]int)
+	for _, retailer := range retailers {
+		// Only include RA retailers
+		if retailer.Type == "RA" && retailer.Code != "" {
+			countRA, err := strconv.Atoi(retailer.CountOfRA)
+			if err != nil {
+				logger.Warn("invalid count of RA, skipping retailer", "retailer", retailer.Code, "count", retailer.CountOfRA)
+				continue
+			}
+			raRetailers[retailer.Code] = countRA
+		}
 	}
+	return raRetailers
+}
 
-	raRetailers := make(map[string]int)
-	for _, row := range rows[1:] {
-		// Check if the row has enough columns
-		if len(row) <= countOfRAIdx || len(row) <= typeIdx || len(row) <= dealerCodeIdx {
-			continue
+// columnIndices resolves the position of each known header on the first row
+// and returns the headers found. Missing headers map to -1.
+func columnIndices(f *excelize.File, sheetName string, known ...string) (map[string]int, []string) {
+	idx := make(map[string]int, len(known))
+	var found []string
+	for _, name := range known {
+		i, err := utils.GetColumnIndex(f, sheetName, name)
+		if err != nil {
+			i = -1
+		} else {
+			found = append(found, name)
 		}
+		idx[name] = i
+	}
+	return idx, found
+}
 
-		dealerCode := row[dealerCodeIdx]
-		retailerType := row[typeIdx]
-		countOfRAIdxStr := row[countOfRAIdx]
+// records are the rows parsed from an input, with the known headers it has.
+// Records are cached and shared between reports, so their rows must not be
+// modified.
+type records[T any] struct {
+	Rows    []T
+	Headers []string
+}
 
-		// Only include RA retailers
-		if retailerType == "RA" && dealerCode != "" {
-			countRA, err := strconv.Atoi(countOfRAIdxStr)
-			if err != nil {
-				fmt.Printf("Warning: Invalid count of RA for retailer %s, skipping...\n", dealerCode)
-				continue
+// require returns the rows if every one of the headers was found.
+func (r records[T]) require(headers ...string) ([]T, error) {
+	for _, name := range headers {
+		found := false
+		for _, h := range r.Headers {
+			if h == name {
+				found = true
+				break
 			}
-			raRetailers[dealerCode] = countRA
+		}
+		if !found {
+			return nil, fmt.Errorf("column %s not found", name)
 		}
 	}
+	return r.Rows, nil
+}
 
-	return raRetailers, nil
+// cellAt returns the cell at idx, or "" when the row is shorter than that
+// (excelize trims trailing empty cells) or the column was not found.
+func cellAt(row []string, idx int) string {
+	if idx < 0 || idx >= len(row) {
+		return ""
+	}
+	return row[idx]
 }
@@ -0,0 +1,80 @@
+package repository
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var (
+	// ErrPasswordRequired is returned for a protected workbook opened
+	// without a password.
+	ErrPasswordRequired = errors.New("workbook is password-protected and no password is configured")
+	// ErrWrongPassword is returned for a protected workbook opened with the
+	// wrong password.
+	ErrWrongPassword = errors.New("wrong password for protected workbook")
+)
+
+// oleSignature starts the compound files Excel saves encrypted workbooks in.
+var oleSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+type passwordsKey struct{}
+
+type fallbackPasswordsKey struct{}
+
+// WithPasswords returns a context under which the repositories open the
+// workbook at each path of passwords with its password.
+func WithPasswords(ctx context.Context, passwords map[string]string) context.Context {
+	return context.WithValue(ctx, passwordsKey{}, passwords)
+}
+
+// WithFallbackPasswords returns a context under which the repositories try
+// passwords in turn on protected workbooks without a password of their own,
+// such as the protected workbooks of a report read back by another.
+func WithFallbackPasswords(ctx context.Context, passwords []string) context.Context {
+	return context.WithValue(ctx, fallbackPasswordsKey{}, passwords)
+}
+
+// OpenWorkbook opens the workbook at path, with its password if ctx has
+// one. A protected workbook opened without its password fails with
+// ErrPasswordRequired or ErrWrongPassword.
+func OpenWorkbook(ctx context.Context, path string) (*excelize.File, error) {
+	passwords, _ := ctx.Value(passwordsKey{}).(map[string]string)
+	password := passwords[path]
+	f, err := excelize.OpenFile(path, excelize.Options{Password: password})
+	if err != nil && password == "" && encrypted(path) {
+		fallbacks, _ := ctx.Value(fallbackPasswordsKey{}).([]string)
+		for _, password := range fallbacks {
+			if f, err := excelize.OpenFile(path, excelize.Options{Password: password}); err == nil {
+				return f, nil
+			}
+		}
+		if len(fallbacks) > 0 {
+			return nil, ErrWrongPassword
+		}
+	}
+	switch {
+	case errors.Is(err, excelize.ErrWorkbookPassword):
+		return nil, ErrWrongPassword
+	case err != nil && password == "" && encrypted(path):
+		return nil, ErrPasswordRequired
+	}
+	return f, err
+}
+
+func encrypted(path string) bool {
+	file, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+	head := make([]byte, len(oleSignature))
+	if _, err := io.ReadFull(file, head); err != nil {
+		return false
+	}
+	return bytes.Equal(head, oleSignature)
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestProtectedWorkbook(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "Received.xlsx")
+	f := excelize.NewFile()
+	f.SetSheetRow("Sheet1", "A1", &[]interface{}{"Party Name", "Amount"})
+	f.SetSheetRow("Sheet1", "A2", &[]interface{}{"Sri Balaji Mobiles", 25000})
+	if err := f.SaveAs(path, excelize.Options{Password: "tally"}); err != nil {
+		t.Fatal(err)
+	}
+	repo := NewExcelDebitRepository(path)
+
+	if _, err := repo.GetDebit(context.Background()); !errors.Is(err, ErrPasswordRequired) {
+		t.Errorf("without a password: got %v, want ErrPasswordRequired", err)
+	}
+	wrong := WithPasswords(context.Background(), map[string]string{path: "accounts"})
+	if _, err := repo.GetDebit(wrong); !errors.Is(err, ErrWrongPassword) {
+		t.Errorf("with the wrong password: got %v, want ErrWrongPassword", err)
+	}
+	right := WithPasswords(context.Background(), map[string]string{path: "tally"})
+	debit, err := repo.GetDebit(right)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if debit["Sri Balaji Mobiles"] != 25000 {
+		t.Errorf("got %v", debit)
+	}
+
+	// The passwords of our own outputs are tried on workbooks without one.
+	fallback := WithFallbackPasswords(context.Background(), []string{"ravi", "tally"})
+	if _, err := repo.GetDebit(fallback); err != nil {
+		t.Errorf("with fallback passwords: %v", err)
+	}
+
+	// Its records are not written to disk, where they would be readable
+	// without the password.
+	cacheDir := filepath.Join(t.TempDir(), "cache")
+	repo.SetCache(NewCache(cacheDir))
+	if _, err := repo.GetDebit(right); err != nil {
+		t.Fatal(err)
+	}
+	if entries, err := os.ReadDir(cacheDir); !os.IsNotExist(err) || len(entries) > 0 {
+		t.Errorf("cache dir holds %d entries (err %v), want none", len(entries), err)
+	}
+}
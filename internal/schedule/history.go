@@ -0,0 +1,91 @@
+package schedule
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"viking-reports/internal/report"
+)
+
+// HistoryFile is the file, in the incremental state directory of the output
+// directory, the scheduled runs are recorded in, one JSON object per line.
+const HistoryFile = "history.jsonl"
+
+// Statuses of a scheduled run besides report.Succeeded and report.Failed.
+const (
+	// Holiday runs were not made since the day is in the holiday calendar.
+	Holiday report.Status = "holiday"
+	// NotReady runs gave up because input files were still missing.
+	NotReady report.Status = "not ready"
+	// Interrupted runs were stopped with the process.
+	Interrupted report.Status = "interrupted"
+)
+
+// Run is one scheduled run in the history.
+type Run struct {
+	Scheduled time.Time     `json:"scheduled"`
+	Started   time.Time     `json:"started"`
+	Finished  time.Time     `json:"finished"`
+	Attempts  int           `json:"attempts"`
+	Status    report.Status `json:"status"`
+	Succeeded int           `json:"succeeded"`
+	Failed    int           `json:"failed"`
+	Skipped   int           `json:"skipped"`
+	Unchanged int           `json:"unchanged"`
+	Error     string        `json:"error,omitempty"`
+}
+
+func (r *Run) count(summary *report.Summary) {
+	r.Succeeded = summary.Count("", report.Succeeded)
+	r.Failed = summary.Count("", report.Failed)
+	r.Skipped = summary.Count("", report.Skipped)
+	r.Unchanged = summary.Count("", report.Unchanged)
+}
+
+// AppendHistory adds run to the end of the history at path.
+func AppendHistory(path string, run Run) error {
+	data, err := json.Marshal(run)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// ReadHistory returns the runs recorded at path, oldest first. A missing
+// history has no runs.
+func ReadHistory(path string) ([]Run, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var runs []Run
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		var run Run
+		if err := json.Unmarshal(scanner.Bytes(), &run); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, n, err)
+		}
+		runs = append(runs, run)
+	}
+	return runs, scanner.Err()
+}
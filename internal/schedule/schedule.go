@@ -0,0 +1,226 @@
+// Package schedule runs the reports as a long-lived process: every day at
+// the configured times, except on the holidays of a calendar, retrying while
+// the input files have not arrived yet, and keeping a history of the runs.
+package schedule
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	_ "time/tzdata" // time zones on hosts without a zoneinfo database
+
+	"viking-reports/internal/config"
+	"viking-reports/internal/pipeline"
+	"viking-reports/internal/report"
+)
+
+// RunFunc generates the scheduled reports once, recording their outcome in
+// summary.
+type RunFunc func(ctx context.Context, summary *report.Summary) error
+
+// Scheduler triggers a RunFunc at the times of a config.Schedule.
+type Scheduler struct {
+	logger     *slog.Logger
+	run        RunFunc
+	times      []clock
+	loc        *time.Location
+	holidays   Holidays
+	retryEvery time.Duration
+	retryFor   time.Duration
+	history    string
+
+	// now and sleep are replaced in tests.
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// clock is a time of day.
+type clock struct {
+	hour, minute int
+}
+
+// New returns a scheduler calling run at the times of sched and appending
+// each run to the history file at history.
+func New(sched config.Schedule, history string, logger *slog.Logger, run RunFunc) (*Scheduler, error) {
+	if len(sched.Times) == 0 {
+		return nil, errors.New("no times to run at in the schedule")
+	}
+	s := &Scheduler{
+		logger:     logger,
+		run:        run,
+		retryEvery: sched.RetryEvery.Duration,
+		retryFor:   sched.RetryFor.Duration,
+		history:    history,
+		now:        time.Now,
+		sleep:      sleep,
+	}
+	for _, t := range sched.Times {
+		parsed, err := time.Parse("15:04", t)
+		if err != nil {
+			return nil, fmt.Errorf("invalid schedule time %q, want HH:MM: %w", t, err)
+		}
+		s.times = append(s.times, clock{parsed.Hour(), parsed.Minute()})
+	}
+	sort.Slice(s.times, func(i, j int) bool {
+		if s.times[i].hour == s.times[j].hour {
+			return s.times[i].minute < s.times[j].minute
+		}
+		return s.times[i].hour < s.times[j].hour
+	})
+
+	var err error
+	if s.loc, err = time.LoadLocation(sched.TimeZone); err != nil {
+		return nil, fmt.Errorf("invalid schedule time zone: %w", err)
+	}
+	if s.holidays, err = LoadHolidays(sched.Holidays); err != nil {
+		return nil, err
+	}
+	if s.retryEvery <= 0 {
+		s.retryFor = 0
+	}
+	return s, nil
+}
+
+// Serve runs the reports at every scheduled time until ctx is done.
+func (s *Scheduler) Serve(ctx context.Context) error {
+	s.logger.Info("serving scheduled runs", "holidays", len(s.holidays))
+	for {
+		next := s.Next(s.now())
+		s.logger.Info("waiting for the next scheduled run", "at", next.Format(time.RFC3339))
+		if err := s.sleep(ctx, next.Sub(s.now())); err != nil {
+			return nil
+		}
+		run := s.runScheduled(ctx, next)
+		if err := AppendHistory(s.history, run); err != nil {
+			s.logger.Error("failed to record the run in the history", "err", err)
+		}
+		if ctx.Err() != nil {
+			return nil
+		}
+	}
+}
+
+// Next returns the first scheduled time after t.
+func (s *Scheduler) Next(t time.Time) time.Time {
+	local := t.In(s.loc)
+	for day := 0; ; day++ {
+		y, m, d := local.AddDate(0, 0, day).Date()
+		for _, c := range s.times {
+			at := time.Date(y, m, d, c.hour, c.minute, 0, 0, s.loc)
+			if at.After(t) {
+				return at
+			}
+		}
+	}
+}
+
+// runScheduled makes the run due at scheduled, unless it is a holiday,
+// retrying while inputs are missing.
+func (s *Scheduler) runScheduled(ctx context.Context, scheduled time.Time) Run {
+	run := Run{Scheduled: scheduled}
+	if name, ok := s.holidays.On(scheduled); ok {
+		s.logger.Info("not running on a holiday", "holiday", name)
+		run.Status = Holiday
+		run.Error = name
+		return run
+	}
+
+	run.Started = s.now()
+	deadline := scheduled.Add(s.retryFor)
+	for {
+		run.Attempts++
+		summary := &report.Summary{}
+		err := s.run(ctx, summary)
+		run.Finished = s.now()
+		run.count(summary)
+
+		var missing *pipeline.MissingInputsError
+		switch {
+		case ctx.Err() != nil:
+			run.Status = Interrupted
+		case errors.As(err, &missing) && s.now().Add(s.retryEvery).Before(deadline):
+			s.logger.Warn("inputs not ready, retrying later", "err", err, "retry_in", s.retryEvery, "attempt", run.Attempts)
+			if s.sleep(ctx, s.retryEvery) != nil {
+				run.Status = Interrupted
+				return run
+			}
+			continue
+		case errors.As(err, &missing):
+			run.Status = NotReady
+		case err != nil || run.Failed > 0:
+			run.Status = report.Failed
+		default:
+			run.Status = report.Succeeded
+		}
+		if err != nil {
+			run.Error = err.Error()
+		}
+		s.logger.Info("scheduled run finished", "status", run.Status, "attempts", run.Attempts)
+		return run
+	}
+}
+
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Holidays maps a day, as YYYY-MM-DD, to the name of the holiday.
+type Holidays map[string]string
+
+// LoadHolidays reads a holiday calendar: one YYYY-MM-DD date per line,
+// optionally followed by the holiday's name. Blank lines and lines starting
+// with # are ignored. A missing file has no holidays.
+func LoadHolidays(path string) (Holidays, error) {
+	holidays := make(Holidays)
+	if path == "" {
+		return holidays, nil
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return holidays, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read holidays: %w", err)
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		day, name, _ := strings.Cut(line, " ")
+		if _, err := time.Parse("2006-01-02", day); err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid date %q, want YYYY-MM-DD", path, n, day)
+		}
+		name = strings.TrimSpace(name)
+		if name == "" {
+			name = "holiday"
+		}
+		holidays[day] = name
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read holidays: %w", err)
+	}
+	return holidays, nil
+}
+
+// On returns the name of the holiday on the day of t, in t's location.
+func (h Holidays) On(t time.Time) (string, bool) {
+	name, ok := h[t.Format("2006-01-02")]
+	return name, ok
+}
@@ -0,0 +1,130 @@
+package schedule
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"viking-reports/internal/config"
+	"viking-reports/internal/pipeline"
+	"viking-reports/internal/report"
+)
+
+func newScheduler(t *testing.T, holidays string, run RunFunc) *Scheduler {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "holidays.txt")
+	if err := os.WriteFile(path, []byte(holidays), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	sched := config.Schedule{
+		Times:      []string{"18:00", "08:30"},
+		TimeZone:   "Asia/Kolkata",
+		Holidays:   path,
+		RetryEvery: config.Duration{Duration: 10 * time.Minute},
+		RetryFor:   config.Duration{Duration: 30 * time.Minute},
+	}
+	s, err := New(sched, filepath.Join(dir, HistoryFile), slog.New(slog.NewTextHandler(io.Discard, nil)), run)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestNext(t *testing.T) {
+	s := newScheduler(t, "", nil)
+	ist := s.loc
+	tests := []struct {
+		now, want time.Time
+	}{
+		{time.Date(2026, 10, 19, 7, 0, 0, 0, ist), time.Date(2026, 10, 19, 8, 30, 0, 0, ist)},
+		{time.Date(2026, 10, 19, 8, 30, 0, 0, ist), time.Date(2026, 10, 19, 18, 0, 0, 0, ist)},
+		{time.Date(2026, 10, 19, 19, 0, 0, 0, ist), time.Date(2026, 10, 20, 8, 30, 0, 0, ist)},
+		// 03:30 UTC is 09:00 IST.
+		{time.Date(2026, 10, 19, 3, 30, 0, 0, time.UTC), time.Date(2026, 10, 19, 18, 0, 0, 0, ist)},
+	}
+	for _, tt := range tests {
+		if got := s.Next(tt.now); !got.Equal(tt.want) {
+			t.Errorf("Next(%s) = %s, want %s", tt.now, got, tt.want)
+		}
+	}
+}
+
+func TestLoadHolidays(t *testing.T) {
+	s := newScheduler(t, "# 2026\n2026-10-20 Diwali\n\n2026-11-05\n", nil)
+	if name, ok := s.holidays.On(time.Date(2026, 10, 20, 8, 30, 0, 0, s.loc)); !ok || name != "Diwali" {
+		t.Errorf("20 Oct: got %q, %v; want Diwali", name, ok)
+	}
+	if name, ok := s.holidays.On(time.Date(2026, 11, 5, 8, 30, 0, 0, s.loc)); !ok || name != "holiday" {
+		t.Errorf("5 Nov: got %q, %v; want an unnamed holiday", name, ok)
+	}
+	if _, ok := s.holidays.On(time.Date(2026, 10, 21, 8, 30, 0, 0, s.loc)); ok {
+		t.Errorf("21 Oct is not a holiday")
+	}
+
+	path := filepath.Join(t.TempDir(), "holidays.txt")
+	os.WriteFile(path, []byte("20/10/2026 Diwali\n"), 0o644)
+	if _, err := LoadHolidays(path); err == nil {
+		t.Errorf("want an error for a date not in YYYY-MM-DD form")
+	}
+}
+
+func TestRunScheduledRetriesUntilInputsReady(t *testing.T) {
+	attempts := 0
+	run := func(ctx context.Context, summary *report.Summary) error {
+		attempts++
+		if attempts < 3 {
+			return &pipeline.MissingInputsError{Reports: []string{"credit"}}
+		}
+		summary.Record("credit", "TSE 1", "", nil)
+		return nil
+	}
+	s := newScheduler(t, "2026-10-20 Diwali\n", run)
+	now := time.Date(2026, 10, 19, 8, 30, 0, 0, s.loc)
+	s.now = func() time.Time { return now }
+	s.sleep = func(ctx context.Context, d time.Duration) error {
+		now = now.Add(d)
+		return nil
+	}
+
+	got := s.runScheduled(context.Background(), now)
+	if got.Status != report.Succeeded || got.Attempts != 3 || got.Succeeded != 1 {
+		t.Errorf("got %+v, want success on the third attempt", got)
+	}
+
+	// Missing inputs past RetryFor give up.
+	attempts = -10
+	got = s.runScheduled(context.Background(), now)
+	if got.Status != NotReady || got.Attempts != 3 {
+		t.Errorf("got %+v, want not ready after three attempts", got)
+	}
+
+	holiday := s.runScheduled(context.Background(), time.Date(2026, 10, 20, 8, 30, 0, 0, s.loc))
+	if holiday.Status != Holiday || holiday.Error != "Diwali" || holiday.Attempts != 0 {
+		t.Errorf("got %+v, want Diwali skipped", holiday)
+	}
+}
+
+func TestHistory(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", HistoryFile)
+	if runs, err := ReadHistory(path); err != nil || len(runs) != 0 {
+		t.Fatalf("missing history: got %v, %v", runs, err)
+	}
+	day := time.Date(2026, 10, 19, 8, 30, 0, 0, time.UTC)
+	for _, run := range []Run{{Scheduled: day, Status: report.Succeeded, Attempts: 1}, {Scheduled: day.AddDate(0, 0, 1), Status: Holiday}} {
+		if err := AppendHistory(path, run); err != nil {
+			t.Fatal(err)
+		}
+	}
+	runs, err := ReadHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(runs) != 2 || runs[0].Status != report.Succeeded || runs[1].Status != Holiday || !runs[1].Scheduled.Equal(day.AddDate(0, 0, 1)) {
+		t.Errorf("got %+v", runs)
+	}
+}
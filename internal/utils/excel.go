@@ -38,8 +38,8 @@ func GetHeaderIndex(f *excelize.File, sheetName, columnName string, headerRow in
 	if err != nil {
 		return -1, fmt.Errorf("failed to read rows: %w", err)
 	}
-	if len(rows) == 0 {
-		return -1, fmt.Errorf("no rows found in sheet")
+	if len(rows) <= headerRow {
+		return -1, fmt.Errorf("no header row %d in sheet", headerRow+1)
 	}
 	for i, col := range rows[headerRow] {
 		if col == columnName {
@@ -0,0 +1,213 @@
+// Package validate checks the input workbooks of the reports before a run:
+// that each file exists and is recent, and that its sheet has the headers and
+// rows the repositories read.
+package validate
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"viking-reports/internal/config"
+	"viking-reports/internal/report"
+	"viking-reports/internal/repository"
+)
+
+// maxListed bounds how many duplicate keys an anomaly lists.
+const maxListed = 5
+
+// Result is the outcome of checking one input file.
+type Result struct {
+	Name           string    `json:"name"`
+	Path           string    `json:"path"`
+	Reports        []string  `json:"reports"`
+	Exists         bool      `json:"exists"`
+	Modified       time.Time `json:"modified"`
+	Fresh          bool      `json:"fresh"`
+	Sheet          string    `json:"sheet,omitempty"`
+	MissingHeaders []string  `json:"missing_headers,omitempty"`
+	Rows           int       `json:"rows"`
+	Anomalies      []string  `json:"anomalies,omitempty"`
+	Error          string    `json:"error,omitempty"`
+}
+
+// OK reports whether the input passed every check.
+func (r Result) OK() bool {
+	return r.Exists && r.Fresh && r.Sheet != "" && len(r.MissingHeaders) == 0 &&
+		len(r.Anomalies) == 0 && r.Error == ""
+}
+
+// Report is the outcome of checking every input of a set of reports.
+type Report struct {
+	CheckedAt time.Time       `json:"checked_at"`
+	MaxAge    config.Duration `json:"max_age"`
+	Inputs    []Result        `json:"inputs"`
+	OK        bool            `json:"ok"`
+}
+
+// Check checks each distinct input file of the reports. A file older than
+// maxAge is stale; a zero maxAge accepts any age.
+func Check(ctx context.Context, cfg *config.Config, defs []report.Definition, maxAge time.Duration, now time.Time) (*Report, error) {
+	var inputs []report.Input
+	reports := make(map[string][]string)
+	for _, def := range defs {
+		if def.Inputs == nil {
+			continue
+		}
+		for _, in := range def.Inputs(cfg) {
+			if _, seen := reports[in.Path]; !seen {
+				inputs = append(inputs, in)
+			}
+			reports[in.Path] = append(reports[in.Path], def.Name)
+		}
+	}
+
+	rep := &Report{CheckedAt: now, MaxAge: config.Duration{Duration: maxAge}, OK: true}
+	for _, in := range inputs {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result := checkInput(ctx, in, maxAge, now)
+		result.Reports = reports[in.Path]
+		rep.OK = rep.OK && result.OK()
+		rep.Inputs = append(rep.Inputs, result)
+	}
+	return rep, nil
+}
+
+func checkInput(ctx context.Context, in report.Input, maxAge time.Duration, now time.Time) Result {
+	result := Result{Name: in.Name, Path: in.Path}
+
+	info, err := os.Stat(in.Path)
+	if err != nil {
+		result.Error = err.Error()
+		return result
+	}
+	result.Exists = true
+	result.Modified = info.ModTime()
+	result.Fresh = maxAge == 0 || now.Sub(info.ModTime()) <= maxAge
+
+	f, err := repository.OpenWorkbook(ctx, in.Path)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to open workbook: %v", err)
+		return result
+	}
+	defer f.Close()
+
+	result.Sheet = f.GetSheetName(0)
+	if result.Sheet == "" {
+		return result
+	}
+	rows, err := f.GetRows(result.Sheet)
+	if err != nil {
+		result.Error = fmt.Sprintf("failed to get rows: %v", err)
+		return result
+	}
+
+	result.MissingHeaders = in.Schema.MissingHeaders(f, result.Sheet)
+	data := in.Schema.DataRows(rows)
+	result.Rows = len(data)
+	if len(data) == 0 {
+		result.Anomalies = append(result.Anomalies, "no data rows")
+	}
+	if in.Schema.Key != "" {
+		if keyIdx, err := in.Schema.KeyIndex(f, result.Sheet); err == nil {
+			result.Anomalies = append(result.Anomalies, keyAnomalies(in.Schema.Key, keyIdx, data)...)
+		}
+	}
+	return result
+}
+
+// keyAnomalies reports blank and repeated values of the key column.
+func keyAnomalies(key string, idx int, rows [][]string) []string {
+	counts := make(map[string]int)
+	blank := 0
+	for _, row := range rows {
+		value := ""
+		if idx < len(row) {
+			value = strings.TrimSpace(row[idx])
+		}
+		if value == "" {
+			blank++
+			continue
+		}
+		counts[value]++
+	}
+
+	var anomalies []string
+	if blank > 0 {
+		anomalies = append(anomalies, fmt.Sprintf("%d rows without a %s", blank, key))
+	}
+	var dups []string
+	for value, n := range counts {
+		if n > 1 {
+			dups = append(dups, fmt.Sprintf("%s (%d rows)", value, n))
+		}
+	}
+	if len(dups) > 0 {
+		sort.Strings(dups)
+		listed := dups
+		if len(listed) > maxListed {
+			listed = append(listed[:maxListed:maxListed], fmt.Sprintf("and %d more", len(dups)-maxListed))
+		}
+		anomalies = append(anomalies, fmt.Sprintf("duplicate %s: %s", key, strings.Join(listed, ", ")))
+	}
+	return anomalies
+}
+
+// WriteChecklist writes the report as a readable checklist.
+func (r *Report) WriteChecklist(w io.Writer) error {
+	var b strings.Builder
+	for _, in := range r.Inputs {
+		mark := "ok  "
+		if !in.OK() {
+			mark = "FAIL"
+		}
+		fmt.Fprintf(&b, "[%s] %s (%s) used by %s\n", mark, in.Name, in.Path, strings.Join(in.Reports, ", "))
+		if !in.Exists {
+			fmt.Fprintf(&b, "       missing: %s\n", in.Error)
+			continue
+		}
+		age := r.CheckedAt.Sub(in.Modified).Round(time.Minute)
+		if in.Fresh {
+			fmt.Fprintf(&b, "       modified %s ago\n", age)
+		} else {
+			fmt.Fprintf(&b, "       stale: modified %s ago, older than %s\n", age, r.MaxAge)
+		}
+		if in.Error != "" {
+			fmt.Fprintf(&b, "       error: %s\n", in.Error)
+			continue
+		}
+		if in.Sheet == "" {
+			fmt.Fprintf(&b, "       no sheet found\n")
+			continue
+		}
+		fmt.Fprintf(&b, "       sheet %q, %d rows\n", in.Sheet, in.Rows)
+		for _, header := range in.MissingHeaders {
+			fmt.Fprintf(&b, "       missing header: %s\n", header)
+		}
+		for _, anomaly := range in.Anomalies {
+			fmt.Fprintf(&b, "       %s\n", anomaly)
+		}
+	}
+	failed := 0
+	for _, in := range r.Inputs {
+		if !in.OK() {
+			failed++
+		}
+	}
+	fmt.Fprintf(&b, "%d of %d inputs passed\n", len(r.Inputs)-failed, len(r.Inputs))
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
@@ -0,0 +1,121 @@
+package validate
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"viking-reports/internal/config"
+	"viking-reports/internal/fixtures"
+	"viking-reports/internal/report"
+	"viking-reports/internal/repository"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func loadConfig(t *testing.T, dataDir string) *config.Config {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "viking.json")
+	body := `{"DataDir": "` + filepath.ToSlash(dataDir) + `", "OutputDir": "` + filepath.ToSlash(t.TempDir()) + `"}`
+	if err := os.WriteFile(path, []byte(body), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("VIKING_CONFIG", path)
+	cfg, err := config.Load()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cfg
+}
+
+func TestCheckGeneratedInputs(t *testing.T) {
+	dir := t.TempDir()
+	if err := fixtures.Generate(dir, fixtures.Options{Retailers: 12, TSEs: 2, Seed: 3}); err != nil {
+		t.Fatal(err)
+	}
+	cfg := loadConfig(t, dir)
+
+	rep, err := Check(context.Background(), cfg, report.Definitions(), time.Hour, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !rep.OK {
+		var b strings.Builder
+		rep.WriteChecklist(&b)
+		t.Fatalf("generated inputs failed validation:\n%s", b.String())
+	}
+	if len(rep.Inputs) != 12 {
+		t.Errorf("checked %d inputs, want 12 distinct files", len(rep.Inputs))
+	}
+}
+
+func TestCheckFindsProblems(t *testing.T) {
+	dir := t.TempDir()
+	if err := fixtures.Generate(dir, fixtures.Options{Retailers: 12, TSEs: 2, Seed: 3}); err != nil {
+		t.Fatal(err)
+	}
+	cfg := loadConfig(t, dir)
+	now := time.Now()
+
+	// Two retailers share a dealer code.
+	if err := fixtures.WriteRetailerMetadata(cfg.CommonFiles.TSEMapping, []repository.Retailer{
+		{Code: "D1", Name: "One", TSE: "Asha"},
+		{Code: "D1", Name: "One again", TSE: "Asha"},
+		{Code: "D2", Name: "Two", TSE: "Ravi"},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	// The receipts export lost its Amount column.
+	f := excelize.NewFile()
+	f.SetSheetRow("Sheet1", "A1", &[]interface{}{"Party Name"})
+	f.SetSheetRow("Sheet1", "A2", &[]interface{}{"One"})
+	if err := f.SaveAs(cfg.ReportFiles.DebitReport.Debits); err != nil {
+		t.Fatal(err)
+	}
+	// Yesterday's inventory export.
+	old := now.Add(-30 * time.Hour)
+	if err := os.Chtimes(cfg.ReportFiles.InventoryReport, old, old); err != nil {
+		t.Fatal(err)
+	}
+	// An empty sales register and no ZD price list.
+	if err := fixtures.WriteTallySales(cfg.ReportFiles.SalesReport, nil); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(cfg.ReportFiles.PriceListFile); err != nil {
+		t.Fatal(err)
+	}
+
+	rep, err := Check(context.Background(), cfg, report.Definitions(), 24*time.Hour, now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rep.OK {
+		t.Fatal("validation passed despite broken inputs")
+	}
+	results := make(map[string]Result)
+	for _, r := range rep.Inputs {
+		results[r.Name] = r
+	}
+
+	if got := results["Retailer Metadata"].Anomalies; len(got) != 1 || !strings.Contains(got[0], "duplicate Dealer Code: D1 (2 rows)") {
+		t.Errorf("metadata anomalies = %q", got)
+	}
+	if got := results["Received"].MissingHeaders; len(got) != 1 || got[0] != "Amount" {
+		t.Errorf("received missing headers = %q", got)
+	}
+	if r := results["DealerInventory"]; r.Fresh || !r.Exists {
+		t.Errorf("inventory = %+v, want an existing stale file", r)
+	}
+	if r := results["Sales"]; r.Rows != 0 || len(r.Anomalies) != 1 || len(r.MissingHeaders) != 0 {
+		t.Errorf("sales = %+v, want no rows and nothing else wrong", r)
+	}
+	if r := results["ZD PRICE LIST"]; r.Exists || r.OK() {
+		t.Errorf("price list = %+v, want missing", r)
+	}
+	if r := results["MTD-SO"]; !r.OK() {
+		t.Errorf("MTD-SO = %+v, want ok", r)
+	}
+}
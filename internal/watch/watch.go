@@ -0,0 +1,191 @@
+// Package watch regenerates reports as their input files arrive in the data
+// folder. It polls the input files, which works the same on every platform
+// and on network shares, and waits for changed files to settle before
+// regenerating only the reports that read them.
+package watch
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"time"
+
+	"viking-reports/internal/config"
+	"viking-reports/internal/report"
+)
+
+// RunFunc regenerates the reports of plan, which is in dependency order.
+type RunFunc func(ctx context.Context, plan []report.Definition) error
+
+// Watcher polls the input files of a set of reports.
+type Watcher struct {
+	cfg      *config.Config
+	logger   *slog.Logger
+	plan     []report.Definition
+	interval time.Duration
+	settle   time.Duration
+	run      RunFunc
+
+	files      map[string]fileState
+	pending    map[string]bool
+	lastChange time.Time
+
+	// now is replaced in tests.
+	now func() time.Time
+}
+
+// fileState is what a poll saw of an input file.
+type fileState struct {
+	size    int64
+	modTime time.Time
+}
+
+// New returns a watcher of the inputs of the reports of plan, as returned
+// by report.Plan, calling run when some of them changed. It returns an error
+// if the watch interval is not positive or the settle time is negative.
+func New(cfg *config.Config, plan []report.Definition, logger *slog.Logger, run RunFunc) (*Watcher, error) {
+	if cfg.Watch.Interval.Duration <= 0 {
+		return nil, fmt.Errorf("invalid watch interval %s, want more than 0s", cfg.Watch.Interval)
+	}
+	if cfg.Watch.Settle.Duration < 0 {
+		return nil, fmt.Errorf("invalid watch settle time %s, want 0s or more", cfg.Watch.Settle)
+	}
+	return &Watcher{
+		cfg:      cfg,
+		logger:   logger,
+		plan:     plan,
+		interval: cfg.Watch.Interval.Duration,
+		settle:   cfg.Watch.Settle.Duration,
+		run:      run,
+		files:    make(map[string]fileState),
+		pending:  make(map[string]bool),
+		now:      time.Now,
+	}, nil
+}
+
+// Watch polls the input files until ctx is done. Files already there when
+// it starts do not trigger a run.
+func (w *Watcher) Watch(ctx context.Context) error {
+	w.scan()
+	w.logger.Info("watching input files", "dir", w.cfg.DataDir, "files", len(w.paths()), "settle", w.settle)
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+			w.poll(ctx)
+		}
+	}
+}
+
+// poll notes the files changed since the last poll and, once the changes
+// have settled, regenerates the reports reading them.
+func (w *Watcher) poll(ctx context.Context) {
+	now := w.now()
+	for _, path := range w.scan() {
+		if !w.pending[path] {
+			w.logger.Info("input file changed, waiting for it to settle", "file", path)
+		}
+		w.pending[path] = true
+		w.lastChange = now
+	}
+	if len(w.pending) == 0 || now.Sub(w.lastChange) < w.settle {
+		return
+	}
+
+	changed := make([]string, 0, len(w.pending))
+	for path := range w.pending {
+		changed = append(changed, path)
+	}
+	sort.Strings(changed)
+	w.pending = make(map[string]bool)
+
+	plan, waiting := w.affected(changed)
+	if len(waiting) > 0 {
+		w.logger.Info("not regenerating reports until their other inputs arrive", "files", changed, "reports", waiting)
+	}
+	if len(plan) == 0 {
+		return
+	}
+	names := make([]string, len(plan))
+	for i, def := range plan {
+		names[i] = def.Name
+	}
+	w.logger.Info("input files changed, regenerating reports", "files", changed, "reports", names)
+	if err := w.run(ctx, plan); err != nil {
+		w.logger.Error("failed to regenerate reports", "reports", names, "err", err)
+	}
+}
+
+// scan records the size and modification time of every input file and
+// returns the files that appeared or changed since the last scan.
+func (w *Watcher) scan() []string {
+	var changed []string
+	for _, path := range w.paths() {
+		info, err := os.Stat(path)
+		if err != nil {
+			delete(w.files, path)
+			continue
+		}
+		state := fileState{size: info.Size(), modTime: info.ModTime()}
+		if last, ok := w.files[path]; !ok || last.size != state.size || !last.modTime.Equal(state.modTime) {
+			changed = append(changed, path)
+		}
+		w.files[path] = state
+	}
+	return changed
+}
+
+// paths returns the distinct input files of the watched reports.
+func (w *Watcher) paths() []string {
+	var paths []string
+	seen := make(map[string]bool)
+	for _, def := range w.plan {
+		if def.Inputs == nil {
+			continue
+		}
+		for _, in := range def.Inputs(w.cfg) {
+			if !seen[in.Path] {
+				seen[in.Path] = true
+				paths = append(paths, in.Path)
+			}
+		}
+	}
+	return paths
+}
+
+// affected returns, in dependency order, the reports reading one of the
+// changed files and the reports depending on them. Reports that still lack
+// an input, or depend on such a report, are returned by name as waiting.
+func (w *Watcher) affected(changed []string) (plan []report.Definition, waiting []string) {
+	isChanged := make(map[string]bool, len(changed))
+	for _, path := range changed {
+		isChanged[path] = true
+	}
+	affected := make(map[string]bool)
+	blocked := make(map[string]bool)
+	for _, def := range w.plan {
+		if def.Inputs != nil {
+			for _, in := range def.Inputs(w.cfg) {
+				affected[def.Name] = affected[def.Name] || isChanged[in.Path]
+			}
+		}
+		blocked[def.Name] = len(def.MissingInputs(w.cfg)) > 0
+		for _, dep := range def.DependsOn {
+			affected[def.Name] = affected[def.Name] || affected[dep]
+			blocked[def.Name] = blocked[def.Name] || blocked[dep]
+		}
+		switch {
+		case !affected[def.Name]:
+		case blocked[def.Name]:
+			waiting = append(waiting, def.Name)
+		default:
+			plan = append(plan, def)
+		}
+	}
+	return plan, waiting
+}
@@ -0,0 +1,117 @@
+package watch
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"viking-reports/internal/config"
+	"viking-reports/internal/report"
+)
+
+func TestWatcherRegeneratesAffectedReportsOnceSettled(t *testing.T) {
+	dir := t.TempDir()
+	file := func(name string) string { return filepath.Join(dir, name) }
+	inputs := func(names ...string) func(*config.Config) []report.Input {
+		return func(*config.Config) []report.Input {
+			var in []report.Input
+			for _, name := range names {
+				in = append(in, report.Input{Name: name, Path: file(name)})
+			}
+			return in
+		}
+	}
+	plan := []report.Definition{
+		{Name: "credit", Inputs: inputs("Bills.xlsx", "Received.xlsx")},
+		{Name: "cogs", Inputs: inputs("DealerInventory.xlsx"), DependsOn: []string{"credit"}},
+		{Name: "growth", Inputs: inputs("MTD-SO.xlsx")},
+		{Name: "zso", Inputs: inputs("DealerInventory.xlsx", "L2M-SO.xlsx")},
+	}
+	write := func(name, content string, at time.Time) {
+		t.Helper()
+		if err := os.WriteFile(file(name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		os.Chtimes(file(name), at, at)
+	}
+
+	start := time.Now()
+	for _, name := range []string{"Bills.xlsx", "Received.xlsx", "DealerInventory.xlsx", "MTD-SO.xlsx"} {
+		write(name, "yesterday", start.Add(-24*time.Hour))
+	}
+
+	var runs [][]string
+	cfg := &config.Config{DataDir: dir, Watch: config.Watch{
+		Interval: config.Duration{Duration: 5 * time.Second},
+		Settle:   config.Duration{Duration: 30 * time.Second},
+	}}
+	w, err := New(cfg, plan, slog.New(slog.NewTextHandler(io.Discard, nil)), func(ctx context.Context, plan []report.Definition) error {
+		var names []string
+		for _, def := range plan {
+			names = append(names, def.Name)
+		}
+		runs = append(runs, names)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := start
+	w.now = func() time.Time { return now }
+	poll := func(after time.Duration) {
+		now = now.Add(after)
+		w.poll(context.Background())
+	}
+	w.scan()
+
+	// The bills arrive while the receipts are still being copied.
+	write("Bills.xlsx", "today", now)
+	write("Received.xlsx", "tod", now)
+	poll(5 * time.Second)
+	write("Received.xlsx", "today", now)
+	poll(20 * time.Second)
+	poll(20 * time.Second)
+	if len(runs) != 0 {
+		t.Fatalf("ran %v before the files settled", runs)
+	}
+	poll(15 * time.Second)
+	if want := [][]string{{"credit", "cogs"}}; !reflect.DeepEqual(runs, want) {
+		t.Fatalf("got runs %v, want %v", runs, want)
+	}
+	poll(time.Minute)
+	if len(runs) != 1 {
+		t.Fatalf("ran again without changes: %v", runs)
+	}
+
+	// zso still lacks L2M-SO.xlsx.
+	write("DealerInventory.xlsx", "today", now)
+	poll(5 * time.Second)
+	poll(time.Minute)
+	if want := []string{"cogs"}; !reflect.DeepEqual(runs[1], want) {
+		t.Fatalf("got run %v, want %v", runs[1], want)
+	}
+}
+
+func TestNewRejectsInvalidDurations(t *testing.T) {
+	seconds := func(n int) config.Duration { return config.Duration{Duration: time.Duration(n) * time.Second} }
+	for _, tc := range []struct {
+		watch config.Watch
+		valid bool
+	}{
+		{config.Watch{Interval: seconds(5), Settle: seconds(30)}, true},
+		{config.Watch{Interval: seconds(5)}, true},
+		{config.Watch{Settle: seconds(30)}, false},
+		{config.Watch{Interval: seconds(-5), Settle: seconds(30)}, false},
+		{config.Watch{Interval: seconds(5), Settle: seconds(-1)}, false},
+	} {
+		_, err := New(&config.Config{Watch: tc.watch}, nil, slog.New(slog.NewTextHandler(io.Discard, nil)), nil)
+		if (err == nil) != tc.valid {
+			t.Errorf("interval %s, settle %s: got %v", tc.watch.Interval, tc.watch.Settle, err)
+		}
+	}
+}
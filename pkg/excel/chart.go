@@ -0,0 +1,141 @@
+package excel
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Chart is a native Excel bar chart of a Table, with a bar for each row in
+// each of the Series columns. The bars read the cells of the table, so they
+// follow the values when the sheet is edited.
+type Chart struct {
+	Title string
+	// Categories is the header of the column labelling the bars, such as
+	// "Dealer Name".
+	Categories string
+	// Series are the headers of the columns charted.
+	Series []string
+	// Horizontal draws bars rather than columns, which suits long labels
+	// such as retailer names.
+	Horizontal bool
+	// Stacked stacks the series of a row into a single bar.
+	Stacked bool
+}
+
+// Placement is where a chart is drawn.
+type Placement struct {
+	// Sheet is the sheet to draw the chart on; empty draws it on the
+	// table's sheet. A sheet that does not exist is created.
+	Sheet string
+	// Cell is the top-left cell of the chart; empty places it on the
+	// table's sheet two columns to the right of the table, level with its
+	// headers, and at A1 on another sheet.
+	Cell string
+	// Width and Height are the size of the chart in pixels; 0 uses
+	// excelize's default of 480 by 260.
+	Width, Height uint
+}
+
+// rowHeight is the height of a row of the default height, in pixels.
+const rowHeight = 20
+
+// Below returns the placement of a chart below one drawn at p, with a row
+// between them. The Cell of p must be set.
+func (p Placement) Below() (Placement, error) {
+	col, row, err := excelize.CellNameToCoordinates(p.Cell)
+	if err != nil {
+		return p, err
+	}
+	height := p.Height
+	if height == 0 {
+		height = 260
+	}
+	next := p
+	next.Cell, err = excelize.CoordinatesToCellName(col, row+int(height+rowHeight-1)/rowHeight+1)
+	return next, err
+}
+
+// AddChart draws chart at the n rows of t, which must have been written to
+// f. The total rows of t are left out, so t must not be grouped.
+func (t *Table) AddChart(f *excelize.File, n int, chart Chart, at Placement) error {
+	if t.GroupBy != "" {
+		return fmt.Errorf("cannot chart table %s grouped by %s", t.Sheet, t.GroupBy)
+	}
+	if n == 0 || len(chart.Series) == 0 {
+		return nil
+	}
+	first := t.StartRow
+	if first == 0 {
+		first = 1
+	}
+	// The references name the series by their header cells and fix the
+	// cells, as Excel does.
+	sheetRef := "'" + strings.ReplaceAll(t.Sheet, "'", "''") + "'!"
+	column := func(header string) (string, error) {
+		c := t.column(header)
+		if c == 0 {
+			return "", fmt.Errorf("chart %q of %s has no column %s", chart.Title, t.Sheet, header)
+		}
+		name, _ := excelize.ColumnNumberToName(c)
+		return name, nil
+	}
+	name, err := column(chart.Categories)
+	if err != nil {
+		return err
+	}
+	categories := fmt.Sprintf("%s$%s$%d:$%s$%d", sheetRef, name, first+1, name, first+n)
+	series := make([]excelize.ChartSeries, 0, len(chart.Series))
+	for _, header := range chart.Series {
+		name, err := column(header)
+		if err != nil {
+			return err
+		}
+		series = append(series, excelize.ChartSeries{
+			Name:       fmt.Sprintf("%s$%s$%d", sheetRef, name, first),
+			Categories: categories,
+			Values:     fmt.Sprintf("%s$%s$%d:$%s$%d", sheetRef, name, first+1, name, first+n),
+		})
+	}
+
+	typ := excelize.Col
+	switch {
+	case chart.Horizontal && chart.Stacked:
+		typ = excelize.BarStacked
+	case chart.Horizontal:
+		typ = excelize.Bar
+	case chart.Stacked:
+		typ = excelize.ColStacked
+	}
+	sheet, cell := at.Sheet, at.Cell
+	if sheet == "" {
+		sheet = t.Sheet
+	}
+	if cell == "" {
+		cell = "A1"
+		if sheet == t.Sheet {
+			cell, _ = excelize.CoordinatesToCellName(len(t.Columns)+2, first)
+		}
+	}
+	if index, _ := f.GetSheetIndex(sheet); index == -1 {
+		if _, err := f.NewSheet(sheet); err != nil {
+			return fmt.Errorf("failed to create chart sheet %s: %w", sheet, err)
+		}
+	}
+
+	// Each series is one colour, told apart by the legend.
+	varyColors := false
+	err = f.AddChart(sheet, cell, &excelize.Chart{
+		Type:       typ,
+		Series:     series,
+		Title:      []excelize.RichTextRun{{Text: chart.Title}},
+		Dimension:  excelize.ChartDimension{Width: at.Width, Height: at.Height},
+		Legend:     excelize.ChartLegend{Position: "bottom"},
+		VaryColors: &varyColors,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add chart %q to %s: %w", chart.Title, sheet, err)
+	}
+	return nil
+}
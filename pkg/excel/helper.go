@@ -12,57 +12,33 @@ func NewFile() *excelize.File {
 }
 
 // WriteHeaders writes the headers to the Excel sheet
+//
+// Deprecated: use Table, which also writes the rows.
 func WriteHeaders(f *excelize.File, sheetName string, headers []string) error {
-	headerStyle, err := f.NewStyle(&excelize.Style{
-		Fill: excelize.Fill{Type: "pattern", Color: []string{"FFFF00"}, Pattern: 1},
-		Font: &excelize.Font{Bold: true},
-		Border: []excelize.Border{
-			{Type: "left", Color: "000000", Style: 1},
-			{Type: "top", Color: "000000", Style: 1},
-			{Type: "bottom", Color: "000000", Style: 1},
-			{Type: "right", Color: "000000", Style: 1},
-		},
-	})
-	if err != nil {
-		return fmt.Errorf("failed to create header style: %w", err)
-	}
-
-	for i, header := range headers {
-		cell := fmt.Sprintf("%s%d", string('A'+i), 1)
-		f.SetCellValue(sheetName, cell, header)
-		f.SetCellStyle(sheetName, cell, cell, headerStyle)
-	}
-
-	return nil
+	return WriteHeadersIdx(f, sheetName, headers, 1, 0)
 }
 
-// WriteHeaders writes the headers to the Excel sheet
+// WriteHeadersIdx writes the headers to row headerIdx of the Excel sheet,
+// merging the first mergeCols cells of the row if mergeCols is not 0.
 func WriteHeadersIdx(f *excelize.File, sheetName string, headers []string, headerIdx int, mergeCols int) error {
-	headerStyle, err := f.NewStyle(&excelize.Style{
-		Fill: excelize.Fill{Type: "pattern", Color: []string{"FFFF00"}, Pattern: 1},
-		Font: &excelize.Font{Bold: true},
-		Border: []excelize.Border{
-			{Type: "left", Color: "000000", Style: 1},
-			{Type: "top", Color: "000000", Style: 1},
-			{Type: "bottom", Color: "000000", Style: 1},
-			{Type: "right", Color: "000000", Style: 1},
-		},
-	})
+	style, err := f.NewStyle(&headerStyle)
 	if err != nil {
 		return fmt.Errorf("failed to create header style: %w", err)
 	}
 
 	for i, header := range headers {
-		cell := fmt.Sprintf("%s%d", string('A'+i), headerIdx)
+		cell, _ := excelize.CoordinatesToCellName(i+1, headerIdx)
 		f.SetCellValue(sheetName, cell, header)
 		if mergeCols == 0 {
-			f.SetCellStyle(sheetName, cell, cell, headerStyle)
+			f.SetCellStyle(sheetName, cell, cell, style)
 		}
 	}
 
 	// Merge columns if mergeCols is greater than 0
 	if mergeCols > 0 {
-		f.MergeCell(sheetName, "A"+fmt.Sprintf("%d", headerIdx), string(rune('A'+mergeCols-1))+fmt.Sprintf("%d", headerIdx))
+		first, _ := excelize.CoordinatesToCellName(1, headerIdx)
+		last, _ := excelize.CoordinatesToCellName(mergeCols, headerIdx)
+		f.MergeCell(sheetName, first, last)
 		// Set alignment for the merged cell
 		alignStyle, err := f.NewStyle(&excelize.Style{
 			Fill: excelize.Fill{Type: "pattern", Color: []string{"FFFF00"}, Pattern: 1},
@@ -81,7 +57,7 @@ func WriteHeadersIdx(f *excelize.File, sheetName string, headers []string, heade
 		if err != nil {
 			return fmt.Errorf("failed to create alignment style: %w", err)
 		}
-		f.SetCellStyle(sheetName, "A"+fmt.Sprintf("%d", headerIdx), string(rune('A'+mergeCols-1))+fmt.Sprintf("%d", headerIdx), alignStyle)
+		f.SetCellStyle(sheetName, first, last, alignStyle)
 
 	}
 
@@ -89,18 +65,14 @@ func WriteHeadersIdx(f *excelize.File, sheetName string, headers []string, heade
 }
 
 // WriteRow writes a row of data to the Excel sheet
+//
+// Deprecated: use Table, which creates its styles once rather than for
+// every row.
 func WriteRow(f *excelize.File, sheetName string, rowIndex int, data []interface{}) error {
-	borderStyle, _ := f.NewStyle(&excelize.Style{
-		Border: []excelize.Border{
-			{Type: "left", Color: "000000", Style: 1},
-			{Type: "top", Color: "000000", Style: 1},
-			{Type: "bottom", Color: "000000", Style: 1},
-			{Type: "right", Color: "000000", Style: 1},
-		},
-	})
+	borderStyle, _ := f.NewStyle(&excelize.Style{Border: border})
 
 	for i, value := range data {
-		cell := fmt.Sprintf("%c%d", 'A'+i, rowIndex)
+		cell, _ := excelize.CoordinatesToCellName(i+1, rowIndex)
 		f.SetCellValue(sheetName, cell, value)
 		if err := f.SetCellStyle(sheetName, cell, cell, borderStyle); err != nil {
 			return fmt.Errorf("error setting style for cell %s: %w", cell, err)
@@ -139,6 +111,7 @@ func AdjustColumnWidths(f *excelize.File, sheetName string) {
 		// Reduce the width by 10%
 		finalWidth := float64(maxWidth) * 0.9
 
-		f.SetColWidth(sheetName, string(rune('A'+i)), string(rune('A'+i)), finalWidth+2)
+		name, _ := excelize.ColumnNumberToName(i + 1)
+		f.SetColWidth(sheetName, name, name, finalWidth+2)
 	}
 }
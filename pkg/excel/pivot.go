@@ -0,0 +1,95 @@
+package excel
+
+import (
+	"fmt"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Pivot is a pivot table of the rows of a Table, summing some of its
+// columns by the values of others, such as the count of each model by TSE.
+// Excel computes the pivot when the workbook is opened, and the reader can
+// rearrange its fields from there.
+type Pivot struct {
+	// Sheet is the sheet of the pivot table; it is created if it does not
+	// exist.
+	Sheet string
+	// Rows and Columns are the headers of the columns whose values label
+	// the rows and columns of the pivot, such as "TSE" and "SPU Name".
+	Rows, Columns []string
+	// Data are the headers of the columns summed. Several Data columns
+	// are laid side by side, as when Columns is empty.
+	Data []string
+}
+
+// AddPivot adds pivot of the n rows of t, which must have been written to
+// f. The total rows of t are left out, so t must not be grouped.
+func (t *Table) AddPivot(f *excelize.File, n int, pivot Pivot) error {
+	if t.GroupBy != "" {
+		return fmt.Errorf("cannot pivot table %s grouped by %s", t.Sheet, t.GroupBy)
+	}
+	if n == 0 {
+		return nil
+	}
+	fields := func(headers []string, subtotal string) ([]excelize.PivotTableField, error) {
+		var fields []excelize.PivotTableField
+		for i, header := range headers {
+			if t.column(header) == 0 {
+				return nil, fmt.Errorf("pivot %s of %s has no column %s", pivot.Sheet, t.Sheet, header)
+			}
+			field := excelize.PivotTableField{Data: header}
+			if subtotal != "" {
+				// Excel rejects a data field named as a column.
+				field.Subtotal, field.Name = subtotal, subtotal+" of "+header
+			} else {
+				// Every row field but the innermost is subtotalled.
+				field.DefaultSubtotal = i < len(headers)-1
+			}
+			fields = append(fields, field)
+		}
+		return fields, nil
+	}
+	rows, err := fields(pivot.Rows, "")
+	if err != nil {
+		return err
+	}
+	columns, err := fields(pivot.Columns, "")
+	if err != nil {
+		return err
+	}
+	data, err := fields(pivot.Data, "Sum")
+	if err != nil {
+		return err
+	}
+
+	if index, _ := f.GetSheetIndex(pivot.Sheet); index == -1 {
+		if _, err := f.NewSheet(pivot.Sheet); err != nil {
+			return fmt.Errorf("failed to create pivot sheet %s: %w", pivot.Sheet, err)
+		}
+	}
+	first := t.StartRow
+	if first == 0 {
+		first = 1
+	}
+	// The pivot starts at A3, as Excel places it, leaving room for filters.
+	// Its extent is a guess that Excel corrects when it computes the pivot.
+	bottomRight, _ := excelize.CoordinatesToCellName(len(rows)+max(1, len(data)), n+4)
+	err = f.AddPivotTable(&excelize.PivotTableOptions{
+		DataRange:           t.Sheet + "!" + t.Range(first, n),
+		PivotTableRange:     pivot.Sheet + "!A3:" + bottomRight,
+		Rows:                rows,
+		Columns:             columns,
+		Data:                data,
+		RowGrandTotals:      true,
+		ColGrandTotals:      true,
+		ShowDrill:           true,
+		ShowRowHeaders:      true,
+		ShowColHeaders:      true,
+		ShowLastColumn:      true,
+		PivotTableStyleName: "PivotStyleLight16",
+	})
+	if err != nil {
+		return fmt.Errorf("failed to add pivot %s of %s: %w", pivot.Sheet, t.Sheet, err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package excel
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Protection is how Save protects a workbook.
+type Protection struct {
+	// Password is needed to open the workbook; empty leaves it unencrypted.
+	Password string
+	// LockSheets protects every sheet against edits. Selecting, sorting
+	// and filtering stay allowed; SheetPassword is needed to unprotect.
+	LockSheets    bool
+	SheetPassword string
+	// Editable names, by header, the columns left editable in locked
+	// sheets, such as a remarks column.
+	Editable []string
+}
+
+// Protect returns how to protect a workbook meant for recipient, or nil to
+// leave it unprotected. recipient is empty for workbooks not meant for one
+// person.
+type Protect func(recipient string) (*Protection, error)
+
+type protectKey struct{}
+
+type recipientKey struct{}
+
+// WithProtection returns a context under which Save protects workbooks as
+// protect says.
+func WithProtection(ctx context.Context, protect Protect) context.Context {
+	return context.WithValue(ctx, protectKey{}, protect)
+}
+
+// ForRecipient returns a context under which the workbooks saved are meant
+// for recipient, e.g. the TSE a per-TSE workbook is sent to.
+func ForRecipient(ctx context.Context, recipient string) context.Context {
+	return context.WithValue(ctx, recipientKey{}, recipient)
+}
+
+// Protected reports whether Save protects the workbooks saved under ctx.
+func Protected(ctx context.Context) bool {
+	_, ok := ctx.Value(protectKey{}).(Protect)
+	return ok
+}
+
+// protection returns how to protect the workbooks saved under ctx.
+func protection(ctx context.Context) (*Protection, error) {
+	protect, ok := ctx.Value(protectKey{}).(Protect)
+	if !ok {
+		return nil, nil
+	}
+	recipient, _ := ctx.Value(recipientKey{}).(string)
+	return protect(recipient)
+}
+
+// lockSheets protects every sheet of f, leaving the Editable columns
+// unlocked below their header.
+func lockSheets(f *excelize.File, p *Protection) error {
+	editable := make(map[string]bool, len(p.Editable))
+	for _, header := range p.Editable {
+		editable[strings.TrimSpace(header)] = true
+	}
+	for _, sheet := range f.GetSheetList() {
+		if len(editable) > 0 {
+			if err := unlockColumns(f, sheet, editable); err != nil {
+				return err
+			}
+		}
+		err := f.ProtectSheet(sheet, &excelize.SheetProtectionOptions{
+			AlgorithmName:       "SHA-512",
+			Password:            p.SheetPassword,
+			SelectLockedCells:   true,
+			SelectUnlockedCells: true,
+			AutoFilter:          true,
+			Sort:                true,
+			FormatColumns:       true,
+			PivotTables:         true,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to protect sheet %s: %w", sheet, err)
+		}
+	}
+	return nil
+}
+
+// headerRows bounds how far down a sheet an editable column's header is
+// looked for.
+const headerRows = 10
+
+// unlockColumns unlocks the cells below the editable headers of sheet,
+// keeping the rest of their style.
+func unlockColumns(f *excelize.File, sheet string, editable map[string]bool) error {
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		return err
+	}
+	unlocked := make(map[int]int) // style → its unlocked copy
+	for r := 0; r < len(rows) && r < headerRows; r++ {
+		for c, value := range rows[r] {
+			if !editable[strings.TrimSpace(value)] {
+				continue
+			}
+			for below := r + 2; below <= len(rows); below++ {
+				cell, _ := excelize.CoordinatesToCellName(c+1, below)
+				style, err := f.GetCellStyle(sheet, cell)
+				if err != nil {
+					return err
+				}
+				if _, ok := unlocked[style]; !ok {
+					s, err := f.GetStyle(style)
+					if err != nil {
+						return err
+					}
+					s.Protection = &excelize.Protection{Locked: false}
+					if unlocked[style], err = f.NewStyle(s); err != nil {
+						return err
+					}
+				}
+				if err := f.SetCellStyle(sheet, cell, cell, unlocked[style]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,55 @@
+package excel
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestSaveProtected(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "report.xlsx")
+	f := NewFile()
+	f.SetSheetRow("Sheet1", "A1", &[]interface{}{"Dealer Code", "Balance", "Remarks"})
+	f.SetSheetRow("Sheet1", "A2", &[]interface{}{"D001", 1200, ""})
+
+	var recipients []string
+	ctx := WithProtection(context.Background(), func(recipient string) (*Protection, error) {
+		recipients = append(recipients, recipient)
+		return &Protection{Password: "secret-" + recipient, LockSheets: true, SheetPassword: "lock", Editable: []string{"Remarks"}}, nil
+	})
+	if err := Save(ForRecipient(ctx, "Ravi"), f, path); err != nil {
+		t.Fatal(err)
+	}
+	if len(recipients) != 1 || recipients[0] != "Ravi" {
+		t.Errorf("got recipients %v", recipients)
+	}
+
+	if _, err := excelize.OpenFile(path); err == nil {
+		t.Fatalf("opened the workbook without its password")
+	}
+	saved, err := excelize.OpenFile(path, excelize.Options{Password: "secret-Ravi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer saved.Close()
+	locked := func(cell string) bool {
+		t.Helper()
+		id, _ := saved.GetCellStyle("Sheet1", cell)
+		style, err := saved.GetStyle(id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return style.Protection == nil || style.Protection.Locked
+	}
+	if locked("C2") {
+		t.Errorf("the remarks are locked")
+	}
+	if !locked("B2") || !locked("C1") {
+		t.Errorf("the balance or the remarks header is editable")
+	}
+	if err := saved.UnprotectSheet("Sheet1", "wrong"); err == nil {
+		t.Errorf("unprotected the sheet with a wrong password")
+	}
+}
@@ -0,0 +1,80 @@
+package excel
+
+import (
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Records returns the rows of the table on sheet, whose headers are in the
+// first row, as maps from header to cell value. The total and subtotal rows
+// of a table written by Table are left out, by their outline level: the
+// rows outlined deepest are the data. A table without data rows has its
+// total row alone, which is told apart by its SUBTOTAL formulas or its
+// label. Blank rows are left out too.
+func Records(f *excelize.File, sheet string, opts ...excelize.Options) ([]map[string]string, error) {
+	rows, err := f.GetRows(sheet, opts...)
+	if err != nil || len(rows) == 0 {
+		return nil, err
+	}
+	headers := rows[0]
+
+	levels := make([]uint8, len(rows))
+	var deepest uint8
+	for r := 1; r < len(rows); r++ {
+		if levels[r], err = f.GetRowOutlineLevel(sheet, r+1); err != nil {
+			return nil, err
+		}
+		deepest = max(deepest, levels[r])
+	}
+
+	last := len(rows)
+	if deepest == 0 && last > 1 {
+		total, err := totalRow(f, sheet, last, rows[last-1])
+		if err != nil {
+			return nil, err
+		}
+		if total {
+			last--
+		}
+	}
+
+	var records []map[string]string
+	for r := 1; r < last; r++ {
+		if levels[r] != deepest || strings.Join(rows[r], "") == "" {
+			continue
+		}
+		record := make(map[string]string, len(headers))
+		for c, header := range headers {
+			if c < len(rows[r]) {
+				record[header] = rows[r][c]
+			}
+		}
+		records = append(records, record)
+	}
+	return records, nil
+}
+
+// totalRow reports whether values, the row numbered row of sheet, is the
+// total row Table writes below the rows: it totals with SUBTOTAL formulas,
+// or, without Sum columns, holds only its label.
+func totalRow(f *excelize.File, sheet string, row int, values []string) (bool, error) {
+	var filled []string
+	for c, value := range values {
+		cell, err := excelize.CoordinatesToCellName(c+1, row)
+		if err != nil {
+			return false, err
+		}
+		formula, err := f.GetCellFormula(sheet, cell)
+		if err != nil {
+			return false, err
+		}
+		if strings.HasPrefix(formula, "SUBTOTAL(") {
+			return true, nil
+		}
+		if value != "" {
+			filled = append(filled, value)
+		}
+	}
+	return len(filled) == 1 && (filled[0] == "Total" || filled[0] == "Grand Total"), nil
+}
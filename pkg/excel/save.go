@@ -0,0 +1,88 @@
+package excel
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// BeforeSave is called by Save with each workbook and the path it is about to
+// be saved to. An error stops the save.
+type BeforeSave func(f *excelize.File, path string) error
+
+type beforeSaveKey struct{}
+
+// WithBeforeSave returns a context under which Save calls hook before
+// writing any workbook. It lets a caller stamp or record every workbook a
+// report writes without the report knowing. Hooks already set in ctx run
+// first.
+func WithBeforeSave(ctx context.Context, hook BeforeSave) context.Context {
+	if outer, ok := ctx.Value(beforeSaveKey{}).(BeforeSave); ok {
+		inner := hook
+		hook = func(f *excelize.File, path string) error {
+			if err := outer(f, path); err != nil {
+				return err
+			}
+			return inner(f, path)
+		}
+	}
+	return context.WithValue(ctx, beforeSaveKey{}, hook)
+}
+
+// Save writes the workbook to path atomically: it is written to a temporary
+// file next to path and renamed into place only once complete, so an
+// interrupted or failed run never leaves a half-saved workbook behind. Save
+// does nothing if ctx is already done, calls the BeforeSave hook of ctx if
+// there is one, and protects the workbook as the Protect of ctx says.
+func Save(ctx context.Context, f *excelize.File, path string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if hook, ok := ctx.Value(beforeSaveKey{}).(BeforeSave); ok {
+		if err := hook(f, path); err != nil {
+			return fmt.Errorf("failed to prepare workbook %s: %w", path, err)
+		}
+	}
+	var opts []excelize.Options
+	p, err := protection(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to protect workbook %s: %w", path, err)
+	}
+	if p != nil && p.LockSheets {
+		if err := lockSheets(f, p); err != nil {
+			return fmt.Errorf("failed to protect workbook %s: %w", path, err)
+		}
+	}
+	if p != nil && p.Password != "" {
+		opts = append(opts, excelize.Options{Password: p.Password})
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary workbook: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to create temporary workbook: %w", err)
+	}
+
+	if _, err := f.WriteTo(tmp, opts...); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write workbook %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write workbook %s: %w", path, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save workbook %s: %w", path, err)
+	}
+	return nil
+}
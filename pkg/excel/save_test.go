@@ -0,0 +1,59 @@
+package excel
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "report.xlsx")
+	f := NewFile()
+	f.SetCellValue("Sheet1", "A1", "Dealer Code")
+
+	if err := Save(context.Background(), f, path); err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 1 || entries[0].Name() != "report.xlsx" {
+		t.Errorf("expected only report.xlsx in the folder, found %v", entries)
+	}
+}
+
+func TestSaveCancelled(t *testing.T) {
+	dir := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := Save(ctx, NewFile(), filepath.Join(dir, "report.xlsx"))
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("got %v, want context.Canceled", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("cancelled save left files behind: %v", entries)
+	}
+}
+
+func TestSaveRunsHooksInOrder(t *testing.T) {
+	var calls []string
+	hook := func(name string) BeforeSave {
+		return func(f *excelize.File, path string) error {
+			calls = append(calls, name)
+			return nil
+		}
+	}
+	ctx := WithBeforeSave(context.Background(), hook("manifest"))
+	ctx = WithBeforeSave(ctx, hook("banner"))
+
+	if err := Save(ctx, NewFile(), filepath.Join(t.TempDir(), "report.xlsx")); err != nil {
+		t.Fatal(err)
+	}
+	if len(calls) != 2 || calls[0] != "manifest" || calls[1] != "banner" {
+		t.Errorf("got hooks %v, want manifest then banner", calls)
+	}
+}
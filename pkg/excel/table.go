@@ -0,0 +1,577 @@
+package excel
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// ColumnType is the kind of values a column holds.
+type ColumnType int
+
+const (
+	// Text values are written as they are.
+	Text ColumnType = iota
+	// Number values are written as numbers, in the column's NumFmt.
+	Number
+	// Percent values are fractions, shown as percentages by default.
+	Percent
+	// Date values are time.Time, shown as dates by default.
+	Date
+)
+
+// defaultNumFmt is the number format of a column type without its own.
+var defaultNumFmt = map[ColumnType]string{
+	Percent: "0%",
+	Date:    "dd-mmm-yyyy",
+}
+
+// INR is the number format of rupee amounts, with Indian digit grouping.
+const INR = "#,##,##0"
+
+// Highlight is the conditional style of a cell; the zero Highlight leaves
+// the cell in its column's style.
+type Highlight struct {
+	// Fill is the RGB background colour, e.g. "FF9999".
+	Fill string
+	Bold bool
+}
+
+// Column defines a column of a Table.
+type Column struct {
+	Header string
+	Type   ColumnType
+	// NumFmt is the number format of the values, e.g. INR; empty uses the
+	// default of Type.
+	NumFmt string
+	// Width is the width of the column in characters; 0 fits the column to
+	// its header and values.
+	Width float64
+	// Highlight returns the style of the column's cell in row, e.g. red for
+	// a negative shortfall. It is given the whole row so that a cell can be
+	// highlighted by another column's value.
+	Highlight func(row []interface{}) Highlight
+	// Rules colour the cells as Excel conditional formats, which follow
+	// the values when the sheet is edited or sorted. The first rule a cell
+	// meets applies.
+	Rules []Rule
+	// Sum totals the column in the total rows of the table.
+	Sum bool
+}
+
+// Rule is a conditional format highlighting the cells of a column whose
+// value, or the value of column Of in the same row, compares to Value by Op,
+// or to Text if it is set.
+type Rule struct {
+	// Of is the header of the column tested; empty tests the cell itself.
+	Of string
+	// Op is one of "<", "<=", ">", ">=", "=" and "<>".
+	Op    string
+	Value float64
+	// Text, if set, is compared instead of Value, e.g. "ZSO" with Op "=".
+	Text      string
+	Highlight Highlight
+}
+
+// Table writes rows under a header row, one value per Column, with every
+// cell bordered and styled by its column. Styles are created once per
+// table, and columns beyond Z are named as Excel does (AA, AB, ...).
+type Table struct {
+	Sheet   string
+	Columns []Column
+	// StartRow is the row of the headers; 0 means the first row.
+	StartRow int
+	// FreezeHeader keeps the header row, and the first FreezeColumns
+	// columns, in view while scrolling.
+	FreezeHeader  bool
+	FreezeColumns int
+	// AutoFilter adds filter buttons to the headers.
+	AutoFilter bool
+	// GroupBy is the header of a column, such as "TSE", by which Write
+	// sorts the rows, in place and keeping the order of the rows of a
+	// group; each group is followed by a subtotal row.
+	GroupBy string
+	// Totals adds a grand total row below the rows.
+	//
+	// Total rows total the Sum columns with SUBTOTAL formulas, which leave
+	// out the rows hidden by a filter and, for the grand total, the
+	// subtotals. As with Excel's Data > Subtotal, the rows are outlined one
+	// level below their total rows, which is how Records tells them apart.
+	Totals bool
+	// Stream writes the sheet through excelize's StreamWriter, which keeps
+	// memory flat for sheets of tens of thousands of rows. The sheet must
+	// not have been written to, and StartRow must be the first row of
+	// anything written to it later.
+	Stream bool
+}
+
+// headerStyle is the style of table headers, as written by WriteHeaders.
+var headerStyle = excelize.Style{
+	Fill:   excelize.Fill{Type: "pattern", Color: []string{"FFFF00"}, Pattern: 1},
+	Font:   &excelize.Font{Bold: true},
+	Border: border,
+}
+
+var border = []excelize.Border{
+	{Type: "left", Color: "000000", Style: 1},
+	{Type: "top", Color: "000000", Style: 1},
+	{Type: "bottom", Color: "000000", Style: 1},
+	{Type: "right", Color: "000000", Style: 1},
+}
+
+// line is a row of the sheet as laid out by Table.layout.
+type line struct {
+	values []interface{}
+	// row is the row given to Write, or nil for a total row.
+	row []interface{}
+	// formulas are the formulas of a total row, by column.
+	formulas map[int]string
+	level    int
+}
+
+// layout returns the lines of the rows written below the headers in row
+// first, with their total rows.
+func (t *Table) layout(rows [][]interface{}, first int) ([]line, error) {
+	group := 0
+	if t.GroupBy != "" {
+		if group = t.column(t.GroupBy); group == 0 {
+			return nil, fmt.Errorf("table %s has no column %s to group by", t.Sheet, t.GroupBy)
+		}
+	}
+	if group > 0 {
+		sort.SliceStable(rows, func(i, j int) bool {
+			return fmt.Sprint(rows[i][group-1]) < fmt.Sprint(rows[j][group-1])
+		})
+	}
+	level := 0
+	switch {
+	case group > 0:
+		level = 2
+	case t.Totals:
+		level = 1
+	}
+
+	var lines []line
+	total := func(label string, labelCol, top, bottom, level int) {
+		l := line{values: make([]interface{}, len(t.Columns)), formulas: make(map[int]string), level: level}
+		l.values[labelCol-1] = label
+		for c, col := range t.Columns {
+			if !col.Sum {
+				continue
+			}
+			name, _ := excelize.ColumnNumberToName(c + 1)
+			l.formulas[c] = fmt.Sprintf("SUBTOTAL(109,%s%d:%s%d)", name, top, name, bottom)
+		}
+		lines = append(lines, l)
+	}
+	start := first + 1
+	for i, row := range rows {
+		if group > 0 && i > 0 && fmt.Sprint(rows[i-1][group-1]) != fmt.Sprint(row[group-1]) {
+			total(subtotalLabel(rows[i-1][group-1]), group, start, first+len(lines), 1)
+			start = first + len(lines) + 1
+		}
+		lines = append(lines, line{values: t.values(row), row: row, level: level})
+	}
+	if group > 0 && len(rows) > 0 {
+		total(subtotalLabel(rows[len(rows)-1][group-1]), group, start, first+len(lines), 1)
+	}
+	if t.Totals {
+		label, labelCol := "Total", 1
+		if group > 0 {
+			label, labelCol = "Grand Total", group
+		}
+		total(label, labelCol, first+1, max(first+len(lines), first+1), 0)
+	}
+	return lines, nil
+}
+
+// subtotalLabel labels the subtotal of the rows grouped by value.
+func subtotalLabel(value interface{}) string {
+	if s := fmt.Sprint(value); value != nil && s != "" {
+		return s + " Total"
+	}
+	return "(blank) Total"
+}
+
+// Write writes the headers and rows of t to f.
+func (t *Table) Write(f *excelize.File, rows [][]interface{}) error {
+	if len(t.Columns) == 0 {
+		return fmt.Errorf("table %s has no columns", t.Sheet)
+	}
+	styles := tableStyles{f: f, ids: make(map[styleKey]int)}
+	header, err := f.NewStyle(&headerStyle)
+	if err != nil {
+		return fmt.Errorf("failed to create header style: %w", err)
+	}
+	first := t.StartRow
+	if first == 0 {
+		first = 1
+	}
+	lines, err := t.layout(rows, first)
+	if err != nil {
+		return err
+	}
+
+	if t.Stream {
+		err = t.stream(f, lines, first, header, &styles)
+	} else {
+		err = t.write(f, lines, first, header, &styles)
+	}
+	if err != nil {
+		return err
+	}
+
+	// The rules cover the rows alone, and the filter the rows and
+	// subtotals, not the grand total.
+	body := len(lines)
+	if t.Totals {
+		body--
+	}
+	if err := t.conditionalFormats(f, first, lines); err != nil {
+		return err
+	}
+	if t.AutoFilter && len(t.Columns) > 0 {
+		if err := f.AutoFilter(t.Sheet, t.Range(first, body), nil); err != nil {
+			return fmt.Errorf("failed to add autofilter to %s: %w", t.Sheet, err)
+		}
+	}
+	return nil
+}
+
+// Range returns the cell range of a table with n rows whose headers are in
+// row first, e.g. "A1:L42".
+func (t *Table) Range(first, n int) string {
+	topLeft, _ := excelize.CoordinatesToCellName(1, first)
+	bottomRight, _ := excelize.CoordinatesToCellName(len(t.Columns), first+n)
+	return topLeft + ":" + bottomRight
+}
+
+// conditionalFormats adds the Rules of the columns over the data rows of
+// lines, written below the headers in row first. Total rows are left out,
+// each run of rows between them getting rules of its own.
+func (t *Table) conditionalFormats(f *excelize.File, first int, lines []line) error {
+	// runs are the first and last rows of each run of data rows.
+	var runs [][2]int
+	for i, l := range lines {
+		row := first + 1 + i
+		if l.row == nil {
+			continue
+		}
+		if n := len(runs); n > 0 && runs[n-1][1] == row-1 {
+			runs[n-1][1] = row
+		} else {
+			runs = append(runs, [2]int{row, row})
+		}
+	}
+	if len(runs) == 0 {
+		return nil
+	}
+	for c, col := range t.Columns {
+		if len(col.Rules) == 0 {
+			continue
+		}
+		tested := make([]string, len(col.Rules))
+		formats := make([]int, len(col.Rules))
+		for i, rule := range col.Rules {
+			column := c + 1
+			if rule.Of != "" {
+				if column = t.column(rule.Of); column == 0 {
+					return fmt.Errorf("rule of column %s tests unknown column %s", col.Header, rule.Of)
+				}
+			}
+			tested[i], _ = excelize.ColumnNumberToName(column)
+			format, err := f.NewConditionalStyle(highlightStyle(rule.Highlight))
+			if err != nil {
+				return fmt.Errorf("failed to create conditional style: %w", err)
+			}
+			formats[i] = format
+		}
+		for _, run := range runs {
+			opts := make([]excelize.ConditionalFormatOptions, 0, len(col.Rules))
+			for i, rule := range col.Rules {
+				// The formula is relative to the first cell of the range,
+				// the column fixed so that it tests the same column in
+				// every cell.
+				cell := "$" + tested[i] + strconv.Itoa(run[0])
+				criteria := fmt.Sprintf("AND(ISNUMBER(%s),%s%s%s)", cell, cell, rule.Op, strconv.FormatFloat(rule.Value, 'f', -1, 64))
+				if rule.Text != "" {
+					criteria = fmt.Sprintf(`%s%s"%s"`, cell, rule.Op, strings.ReplaceAll(rule.Text, `"`, `""`))
+				}
+				opts = append(opts, excelize.ConditionalFormatOptions{
+					Type:       "formula",
+					Criteria:   criteria,
+					Format:     formats[i],
+					StopIfTrue: true,
+				})
+			}
+			top, _ := excelize.CoordinatesToCellName(c+1, run[0])
+			bottom, _ := excelize.CoordinatesToCellName(c+1, run[1])
+			if err := f.SetConditionalFormat(t.Sheet, top+":"+bottom, opts); err != nil {
+				return fmt.Errorf("failed to add conditional format to %s: %w", col.Header, err)
+			}
+		}
+	}
+	return nil
+}
+
+// column returns the number of the column with header, or 0.
+func (t *Table) column(header string) int {
+	for c, col := range t.Columns {
+		if col.Header == header {
+			return c + 1
+		}
+	}
+	return 0
+}
+
+// style returns the style of column c in l.
+func (t *Table) style(l line, c int, styles *tableStyles) (int, error) {
+	col := t.Columns[c]
+	switch {
+	case l.row == nil:
+		return styles.get(col, Highlight{Bold: true})
+	case col.Highlight != nil:
+		return styles.get(col, col.Highlight(l.row))
+	}
+	return styles.get(col, Highlight{})
+}
+
+func (t *Table) write(f *excelize.File, lines []line, first, header int, styles *tableStyles) error {
+	for c, col := range t.Columns {
+		cell, _ := excelize.CoordinatesToCellName(c+1, first)
+		if err := f.SetCellValue(t.Sheet, cell, col.Header); err != nil {
+			return err
+		}
+	}
+	last, _ := excelize.CoordinatesToCellName(len(t.Columns), first)
+	start, _ := excelize.CoordinatesToCellName(1, first)
+	if err := f.SetCellStyle(t.Sheet, start, last, header); err != nil {
+		return err
+	}
+
+	for i, l := range lines {
+		row := first + i + 1
+		cell, _ := excelize.CoordinatesToCellName(1, row)
+		if err := f.SetSheetRow(t.Sheet, cell, &l.values); err != nil {
+			return fmt.Errorf("error writing row %d of %s: %w", row, t.Sheet, err)
+		}
+		for c, formula := range l.formulas {
+			cell, _ := excelize.CoordinatesToCellName(c+1, row)
+			if err := f.SetCellFormula(t.Sheet, cell, formula); err != nil {
+				return fmt.Errorf("error writing total of %s: %w", t.Sheet, err)
+			}
+		}
+		if l.level > 0 {
+			if err := f.SetRowOutlineLevel(t.Sheet, row, uint8(l.level)); err != nil {
+				return err
+			}
+		}
+	}
+	for c, col := range t.Columns {
+		// Style the whole column, then the highlighted cells and totals.
+		if len(lines) > 0 {
+			style, err := styles.get(col, Highlight{})
+			if err != nil {
+				return err
+			}
+			top, _ := excelize.CoordinatesToCellName(c+1, first+1)
+			bottom, _ := excelize.CoordinatesToCellName(c+1, first+len(lines))
+			if err := f.SetCellStyle(t.Sheet, top, bottom, style); err != nil {
+				return err
+			}
+		}
+		for i, l := range lines {
+			if l.row != nil && col.Highlight == nil {
+				continue
+			}
+			style, err := t.style(l, c, styles)
+			if err != nil {
+				return err
+			}
+			cell, _ := excelize.CoordinatesToCellName(c+1, first+i+1)
+			if err := f.SetCellStyle(t.Sheet, cell, cell, style); err != nil {
+				return fmt.Errorf("error setting style for cell %s: %w", cell, err)
+			}
+		}
+	}
+
+	for c, width := range t.widths(lines) {
+		name, _ := excelize.ColumnNumberToName(c + 1)
+		if err := f.SetColWidth(t.Sheet, name, name, width); err != nil {
+			return err
+		}
+	}
+	if t.FreezeHeader {
+		if err := f.SetPanes(t.Sheet, t.panes(first)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Table) stream(f *excelize.File, lines []line, first, header int, styles *tableStyles) error {
+	sw, err := f.NewStreamWriter(t.Sheet)
+	if err != nil {
+		return fmt.Errorf("failed to stream sheet %s: %w", t.Sheet, err)
+	}
+	if t.FreezeHeader {
+		if err := sw.SetPanes(t.panes(first)); err != nil {
+			return err
+		}
+	}
+	for c, width := range t.widths(lines) {
+		if err := sw.SetColWidth(c+1, c+1, width); err != nil {
+			return err
+		}
+	}
+
+	cells := make([]interface{}, len(t.Columns))
+	for c, col := range t.Columns {
+		cells[c] = excelize.Cell{StyleID: header, Value: col.Header}
+	}
+	cell, _ := excelize.CoordinatesToCellName(1, first)
+	if err := sw.SetRow(cell, cells); err != nil {
+		return err
+	}
+	for i, l := range lines {
+		cells := make([]interface{}, len(t.Columns))
+		for c := range t.Columns {
+			style, err := t.style(l, c, styles)
+			if err != nil {
+				return err
+			}
+			cells[c] = excelize.Cell{StyleID: style, Value: l.values[c], Formula: l.formulas[c]}
+		}
+		row := first + i + 1
+		cell, _ := excelize.CoordinatesToCellName(1, row)
+		if err := sw.SetRow(cell, cells, excelize.RowOpts{OutlineLevel: l.level}); err != nil {
+			return fmt.Errorf("error writing row %d of %s: %w", row, t.Sheet, err)
+		}
+	}
+	return sw.Flush()
+}
+
+// values returns the cells of row, one per column, with Text columns
+// written as text.
+func (t *Table) values(row []interface{}) []interface{} {
+	values := make([]interface{}, len(t.Columns))
+	for c, col := range t.Columns {
+		if c >= len(row) || row[c] == nil {
+			continue
+		}
+		v := row[c]
+		if col.Type == Text {
+			if _, ok := v.(string); !ok {
+				v = fmt.Sprint(v)
+			}
+		}
+		values[c] = v
+	}
+	return values
+}
+
+func (t *Table) panes(first int) *excelize.Panes {
+	topLeft, _ := excelize.CoordinatesToCellName(t.FreezeColumns+1, first+1)
+	pane := "bottomLeft"
+	if t.FreezeColumns > 0 {
+		pane = "bottomRight"
+	}
+	return &excelize.Panes{
+		Freeze:      true,
+		XSplit:      t.FreezeColumns,
+		YSplit:      first,
+		TopLeftCell: topLeft,
+		ActivePane:  pane,
+	}
+}
+
+// widths returns the width of each column, fitting those without one to
+// their values as AdjustColumnWidths does.
+func (t *Table) widths(lines []line) []float64 {
+	widths := make([]float64, len(t.Columns))
+	for c, col := range t.Columns {
+		if col.Width > 0 {
+			widths[c] = col.Width
+			continue
+		}
+		longest := utf8.RuneCountInString(col.Header)
+		for _, l := range lines {
+			longest = max(longest, displayWidth(l.values[c], col))
+		}
+		widths[c] = float64(longest)*0.9 + 2
+	}
+	return widths
+}
+
+// displayWidth estimates how many characters v takes once formatted.
+func displayWidth(v interface{}, col Column) int {
+	switch v := v.(type) {
+	case nil:
+		return 0
+	case string:
+		return utf8.RuneCountInString(v)
+	case time.Time:
+		return len(v.Format("02-Jan-2006"))
+	case float64:
+		if col.Type == Percent {
+			return len(strconv.Itoa(int(math.Round(v*100)))) + 1
+		}
+		digits := len(strconv.FormatFloat(math.Round(v), 'f', 0, 64))
+		if col.NumFmt == INR {
+			digits += digits / 2 // the separators
+		}
+		return digits
+	default:
+		return len(fmt.Sprint(v))
+	}
+}
+
+type styleKey struct {
+	numFmt    string
+	highlight Highlight
+}
+
+// tableStyles creates each style of a table once.
+type tableStyles struct {
+	f   *excelize.File
+	ids map[styleKey]int
+}
+
+func (s *tableStyles) get(col Column, h Highlight) (int, error) {
+	numFmt := col.NumFmt
+	if numFmt == "" {
+		numFmt = defaultNumFmt[col.Type]
+	}
+	key := styleKey{numFmt, h}
+	if id, ok := s.ids[key]; ok {
+		return id, nil
+	}
+	style := highlightStyle(h)
+	style.Border = border
+	if numFmt != "" {
+		style.CustomNumFmt = &numFmt
+	}
+	id, err := s.f.NewStyle(style)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create cell style: %w", err)
+	}
+	s.ids[key] = id
+	return id, nil
+}
+
+func highlightStyle(h Highlight) *excelize.Style {
+	style := &excelize.Style{}
+	if h.Fill != "" {
+		style.Fill = excelize.Fill{Type: "pattern", Color: []string{h.Fill}, Pattern: 1}
+	}
+	if h.Bold {
+		style.Font = &excelize.Font{Bold: true}
+	}
+	return style
+}
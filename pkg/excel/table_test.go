@@ -0,0 +1,322 @@
+package excel
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func TestTable(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stream=%v", stream), func(t *testing.T) {
+			f := NewFile()
+			table := Table{
+				Sheet:         "Sheet1",
+				Columns:       []Column{{Header: "Dealer Name"}},
+				FreezeHeader:  true,
+				FreezeColumns: 1,
+				AutoFilter:    true,
+				Totals:        true,
+				Stream:        stream,
+			}
+			// One column per model runs past Z.
+			for i := 0; i < 30; i++ {
+				col := len(table.Columns)
+				table.Columns = append(table.Columns, Column{
+					Header: fmt.Sprintf("Model %d", i),
+					Type:   Number,
+					NumFmt: INR,
+					Sum:    true,
+					Highlight: func(row []interface{}) Highlight {
+						if row[col] == 0 {
+							return Highlight{Fill: "FF9999"}
+						}
+						return Highlight{}
+					},
+				})
+			}
+			var rows [][]interface{}
+			for r := 0; r < 3; r++ {
+				row := []interface{}{fmt.Sprintf("Dealer %d", r)}
+				for i := 0; i < 30; i++ {
+					row = append(row, (r+i)%3)
+				}
+				rows = append(rows, row)
+			}
+			if err := table.Write(f, rows); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "table.xlsx")
+			if err := f.SaveAs(path); err != nil {
+				t.Fatal(err)
+			}
+
+			saved, err := excelize.OpenFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer saved.Close()
+			for cell, want := range map[string]string{"A1": "Dealer Name", "AA1": "Model 25", "AE1": "Model 29", "AE4": "1", "A5": "Total"} {
+				if got, _ := saved.GetCellValue("Sheet1", cell, excelize.Options{RawCellValue: true}); got != want {
+					t.Errorf("%s = %q, want %q", cell, got, want)
+				}
+			}
+			plain, _ := saved.GetCellStyle("Sheet1", "AE4")
+			other, _ := saved.GetCellStyle("Sheet1", "B3")
+			red, _ := saved.GetCellStyle("Sheet1", "AE3")
+			if plain != other || plain == red {
+				t.Errorf("got styles %d, %d and highlighted %d; want the plain cells to share one", plain, other, red)
+			}
+			if style, _ := saved.GetStyle(red); len(style.Fill.Color) == 0 || style.Fill.Color[0] != "FF9999" {
+				t.Errorf("AE3 not highlighted: %+v", style.Fill)
+			}
+			if panes, _ := saved.GetPanes("Sheet1"); !panes.Freeze || panes.TopLeftCell != "B2" {
+				t.Errorf("got panes %+v, want frozen at B2", panes)
+			}
+			filtered := false
+			for _, name := range saved.GetDefinedName() {
+				filtered = filtered || name.RefersTo == "'Sheet1'!$A$1:$AE$4"
+			}
+			if !filtered {
+				t.Errorf("no autofilter over the rows: %+v", saved.GetDefinedName())
+			}
+		})
+	}
+}
+
+func TestTableRules(t *testing.T) {
+	f := NewFile()
+	red := Highlight{Fill: "FF9999"}
+	table := Table{
+		Sheet: "Sheet1",
+		Columns: []Column{
+			{Header: "Dealer Name"},
+			{Header: "Credit", Type: Number, Rules: []Rule{{Of: "Shortfall", Op: "<", Value: 0, Highlight: red}}},
+			{Header: "Shortfall", Type: Number, Rules: []Rule{{Op: "<", Value: -1000.5, Highlight: red}, {Op: "<", Value: 0, Highlight: Highlight{Fill: "FFFF00"}}}},
+			{Header: "A15", Rules: []Rule{{Op: "=", Text: "ZSO", Highlight: red}}},
+		},
+		StartRow: 3,
+		Totals:   true,
+	}
+	if err := table.Write(f, [][]interface{}{{"Alpha", 2000, -2000, "ZSO"}, {"Bharat", 1000, 1500, nil}}); err != nil {
+		t.Fatal(err)
+	}
+	formats, err := f.GetConditionalFormats("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string][]string{
+		"B4:B5": {"AND(ISNUMBER($C4),$C4<0)"},
+		"C4:C5": {"AND(ISNUMBER($C4),$C4<-1000.5)", "AND(ISNUMBER($C4),$C4<0)"},
+		"D4:D5": {`$D4="ZSO"`},
+	}
+	if len(formats) != len(want) {
+		t.Fatalf("got conditional formats on %v", formats)
+	}
+	for ref, criteria := range want {
+		if len(formats[ref]) != len(criteria) {
+			t.Fatalf("%s: got %+v", ref, formats[ref])
+		}
+		for i, c := range criteria {
+			if got := formats[ref][i]; got.Criteria != c || !got.StopIfTrue {
+				t.Errorf("%s rule %d: got %q, want %q stopping there", ref, i, got.Criteria, c)
+			}
+		}
+	}
+
+	table.Columns[1].Rules[0].Of = "Balance"
+	if err := table.Write(NewFile(), nil); err != nil {
+		t.Errorf("an empty table has no cells to format: %v", err)
+	}
+	if err := table.Write(NewFile(), [][]interface{}{{"Alpha", 1, 1, nil}}); err == nil {
+		t.Errorf("want an error for a rule testing an unknown column")
+	}
+}
+
+func TestTableTotals(t *testing.T) {
+	for _, stream := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stream=%v", stream), func(t *testing.T) {
+			f := NewFile()
+			table := Table{
+				Sheet: "Sheet1",
+				Columns: []Column{{Header: "TSE"}, {Header: "Dealer Name"}, {Header: "Credit", Type: Number, Sum: true,
+					Rules: []Rule{{Op: ">", Value: 1500, Highlight: Highlight{Fill: "FF9999"}}}}},
+				GroupBy: "TSE",
+				Totals:  true,
+				Stream:  stream,
+			}
+			// Out of order: Write sorts the rows into one group per TSE.
+			rows := [][]interface{}{
+				{"Krishna", "Alpha Mobiles", 2000},
+				{"Sathish", "City Cellular", 500},
+				{"Krishna", "Bharat Telecom", 1000},
+			}
+			if err := table.Write(f, rows); err != nil {
+				t.Fatal(err)
+			}
+			path := filepath.Join(t.TempDir(), "table.xlsx")
+			if err := f.SaveAs(path); err != nil {
+				t.Fatal(err)
+			}
+			saved, err := excelize.OpenFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer saved.Close()
+
+			for cell, want := range map[string]string{
+				"A4": "Krishna Total", "C4": "SUBTOTAL(109,C2:C3)",
+				"A6": "Sathish Total", "C6": "SUBTOTAL(109,C5:C5)",
+				"A7": "Grand Total", "C7": "SUBTOTAL(109,C2:C6)",
+			} {
+				got, _ := saved.GetCellFormula("Sheet1", cell)
+				if got == "" {
+					got, _ = saved.GetCellValue("Sheet1", cell)
+				}
+				if got != want {
+					t.Errorf("%s = %q, want %q", cell, got, want)
+				}
+			}
+
+			// The subtotals are not coloured as the rows are. Streamed
+			// sheets do not keep conditional formats.
+			if !stream {
+				formats, err := saved.GetConditionalFormats("Sheet1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				ranges := make([]string, 0, len(formats))
+				for ref := range formats {
+					ranges = append(ranges, ref)
+				}
+				sort.Strings(ranges)
+				if want := []string{"C2:C3", "C5:C5"}; !reflect.DeepEqual(ranges, want) {
+					t.Errorf("conditional formats cover %v, want %v", ranges, want)
+				}
+			}
+
+			records, err := Records(saved, "Sheet1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(records) != len(rows) {
+				t.Fatalf("got records %v, want the %d rows without totals", records, len(rows))
+			}
+			for i, record := range records {
+				if record["Dealer Name"] != rows[i][1] || record["Credit"] != fmt.Sprint(rows[i][2]) {
+					t.Errorf("record %d = %v, want %v", i, record, rows[i])
+				}
+			}
+		})
+	}
+	if err := (&Table{Sheet: "Sheet1", Totals: true}).Write(NewFile(), nil); err == nil {
+		t.Errorf("want an error for a table without columns")
+	}
+}
+
+func TestRecordsOfEmptyTable(t *testing.T) {
+	for _, table := range []Table{
+		{Sheet: "Sheet1", Columns: []Column{{Header: "Retailer"}, {Header: "Credit", Type: Number, Sum: true}}, Totals: true},
+		{Sheet: "Sheet1", Columns: []Column{{Header: "TSE"}, {Header: "Credit", Type: Number, Sum: true}}, GroupBy: "TSE", Totals: true},
+		{Sheet: "Sheet1", Columns: []Column{{Header: "Retailer"}, {Header: "Model"}}, Totals: true},
+	} {
+		f := NewFile()
+		if err := table.Write(f, nil); err != nil {
+			t.Fatal(err)
+		}
+		records, err := Records(f, "Sheet1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != 0 {
+			t.Errorf("%v: got records %v, want none from the total row", table.Columns, records)
+		}
+	}
+
+	// Without totals, every row is data.
+	f := NewFile()
+	table := Table{Sheet: "Sheet1", Columns: []Column{{Header: "Retailer"}}}
+	if err := table.Write(f, [][]interface{}{{"Alpha"}, {"Bharat"}}); err != nil {
+		t.Fatal(err)
+	}
+	if records, err := Records(f, "Sheet1"); err != nil || len(records) != 2 {
+		t.Errorf("got records %v, err %v, want both rows", records, err)
+	}
+}
+
+func TestTableChart(t *testing.T) {
+	f := NewFile()
+	table := Table{
+		Sheet: "Sheet1",
+		Columns: []Column{
+			{Header: "Dealer"},
+			{Header: "MTD", Type: Number, Sum: true},
+			{Header: "LMTD", Type: Number, Sum: true},
+		},
+		Totals: true,
+	}
+	rows := [][]interface{}{{"Sri Balaji", 12, 10}, {"Kaveri", 4, 9}}
+	if err := table.Write(f, rows); err != nil {
+		t.Fatal(err)
+	}
+	chart := Chart{Title: "MTD vs LMTD", Categories: "Dealer", Series: []string{"MTD", "LMTD"}}
+	if err := table.AddChart(f, len(rows), chart, Placement{Sheet: "Charts"}); err != nil {
+		t.Fatal(err)
+	}
+	if index, _ := f.GetSheetIndex("Charts"); index == -1 {
+		t.Error("the chart sheet was not created")
+	}
+	chart.Series = []string{"Growth"}
+	if err := table.AddChart(f, len(rows), chart, Placement{}); err == nil {
+		t.Error("charting an unknown column: got no error")
+	}
+
+	below, err := Placement{Cell: "G2", Height: 300}.Below()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if below.Cell != "G18" {
+		t.Errorf("below G2: got %s, want G18", below.Cell)
+	}
+}
+
+func TestTablePivot(t *testing.T) {
+	f := NewFile()
+	table := Table{
+		Sheet: "Sheet1",
+		Columns: []Column{
+			{Header: "TSE"},
+			{Header: "Model"},
+			{Header: "Count", Type: Number, Sum: true},
+		},
+		Totals: true,
+	}
+	rows := [][]interface{}{{"Harish", "C63", 3}, {"Harish", "P1 5G", 1}, {"Krishna", "C63", 2}}
+	if err := table.Write(f, rows); err != nil {
+		t.Fatal(err)
+	}
+	pivot := Pivot{Sheet: "Pivot", Rows: []string{"TSE"}, Columns: []string{"Model"}, Data: []string{"Count"}}
+	if err := table.AddPivot(f, len(rows), pivot); err != nil {
+		t.Fatal(err)
+	}
+	if index, _ := f.GetSheetIndex("Pivot"); index == -1 {
+		t.Error("the pivot sheet was not created")
+	}
+	path := filepath.Join(t.TempDir(), "pivot.xlsx")
+	if err := f.SaveAs(path); err != nil {
+		t.Fatal(err)
+	}
+
+	pivot.Data = []string{"Units"}
+	if err := table.AddPivot(f, len(rows), pivot); err == nil {
+		t.Error("pivoting an unknown column: got no error")
+	}
+	table.GroupBy = "TSE"
+	if err := table.AddPivot(f, len(rows), Pivot{Sheet: "Pivot"}); err == nil {
+		t.Error("pivoting a grouped table: got no error")
+	}
+}
@@ -0,0 +1,115 @@
+package output
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// CSV writes each table of a result to a CSV file with a header row:
+// base.csv for a result of one table, and base_<table>.csv, such as
+// sales_report_smart_phones.csv, for a result of several. A result generated
+// from out-of-date inputs also has base_STALE_DATA.txt listing the stale
+// inputs, since CSV has nowhere to say so.
+type CSV struct{}
+
+func (CSV) Format() string { return "csv" }
+
+func (CSV) Write(ctx context.Context, base string, result *Result) ([]string, error) {
+	var paths []string
+	marker := base + "_STALE_DATA.txt"
+	if reasons := stale(ctx); reasons != nil {
+		err := writeFile(ctx, marker, func(f *os.File) error {
+			_, err := fmt.Fprintf(f, "STALE DATA: this report was generated from out-of-date input files\n\n%s\n", strings.Join(reasons, "\n"))
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, marker)
+	} else if err := os.Remove(marker); err != nil && !os.IsNotExist(err) {
+		// A marker left by an earlier stale run must not outlive it.
+		return nil, fmt.Errorf("failed to remove %s: %w", marker, err)
+	}
+	for _, table := range result.Tables {
+		path := base + ".csv"
+		if len(result.Tables) > 1 {
+			path = base + "_" + fileName(table.Name) + ".csv"
+		}
+		err := writeFile(ctx, path, func(f *os.File) error {
+			w := csv.NewWriter(f)
+			header := make([]string, len(table.Columns))
+			for i, column := range table.Columns {
+				header[i] = column.Name
+			}
+			if err := w.Write(header); err != nil {
+				return err
+			}
+			for _, row := range table.Rows {
+				record := make([]string, len(table.Columns))
+				for i := range record {
+					if i < len(row) {
+						record[i] = text(row[i])
+					}
+				}
+				if err := w.Write(record); err != nil {
+					return err
+				}
+			}
+			w.Flush()
+			return w.Error()
+		})
+		if err != nil {
+			return nil, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+// text formats a value of a table as a CSV field. Numbers are written in
+// full, without the grouping of the workbooks, and values that are not
+// numbers, such as a percentage of a zero target, are left empty.
+func text(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return ""
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case float32:
+		return text(float64(v))
+	case time.Time:
+		return v.Format("2006-01-02")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// fileName returns name in lower case with every run of characters other
+// than letters and digits replaced by an underscore, e.g. "smart_phones" for
+// "SMART PHONES".
+func fileName(name string) string {
+	var b strings.Builder
+	underscore := false
+	for _, r := range strings.ToLower(name) {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			b.WriteRune(r)
+			underscore = false
+		} else if !underscore && b.Len() > 0 {
+			b.WriteByte('_')
+			underscore = true
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
@@ -0,0 +1,111 @@
+package output
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"time"
+)
+
+// JSON writes a result as one JSON document, base.json, holding its tables
+// in order, each with its columns and its rows as objects keyed by column:
+//
+//	{"tables": [{"name": "Growth Report", "columns": [{"name": "TSE", "type": "text"}, ...],
+//	  "rows": [{"TSE": "Krishna", ...}, ...]}]}
+//
+// A result generated from out-of-date inputs also has "stale", the list of
+// the stale inputs, before its tables.
+type JSON struct{}
+
+func (JSON) Format() string { return "json" }
+
+func (JSON) Write(ctx context.Context, base string, result *Result) ([]string, error) {
+	doc := jsonResult{Stale: stale(ctx), Tables: make([]jsonTable, 0, len(result.Tables))}
+	for _, table := range result.Tables {
+		t := jsonTable{Name: table.Name, Columns: table.Columns, Rows: make([]jsonRow, 0, len(table.Rows))}
+		if t.Columns == nil {
+			t.Columns = []Column{}
+		}
+		for _, row := range table.Rows {
+			t.Rows = append(t.Rows, jsonRow{columns: table.Columns, values: row})
+		}
+		doc.Tables = append(doc.Tables, t)
+	}
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	path := base + ".json"
+	err = writeFile(ctx, path, func(f *os.File) error {
+		_, err := f.Write(append(data, '\n'))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return []string{path}, nil
+}
+
+type jsonResult struct {
+	Stale  []string    `json:"stale,omitempty"`
+	Tables []jsonTable `json:"tables"`
+}
+
+type jsonTable struct {
+	Name    string    `json:"name"`
+	Columns []Column  `json:"columns"`
+	Rows    []jsonRow `json:"rows"`
+}
+
+// jsonRow is a row of a table, marshalled as an object whose keys are in
+// the order of the columns.
+type jsonRow struct {
+	columns []Column
+	values  []interface{}
+}
+
+func (r jsonRow) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, column := range r.columns {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		key, err := json.Marshal(column.Name)
+		if err != nil {
+			return nil, err
+		}
+		var v interface{}
+		if i < len(r.values) {
+			v = jsonValue(r.values[i])
+		}
+		value, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+		b.Write(key)
+		b.WriteByte(':')
+		b.Write(value)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// jsonValue returns v as it is written to JSON: dates as YYYY-MM-DD, and
+// values that are not numbers, such as a percentage of a zero target, as
+// null.
+func jsonValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) {
+			return nil
+		}
+	case float32:
+		return jsonValue(float64(v))
+	case time.Time:
+		return v.Format("2006-01-02")
+	}
+	return v
+}
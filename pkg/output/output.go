@@ -0,0 +1,227 @@
+// Package output writes the results of reports in the formats asked for:
+// Excel workbooks, CSV files and JSON documents.
+//
+// A report builds a Result, the tables it computed, as it lays them out in a
+// workbook, and saves it with Save, which hands it to the ReportWriter of
+// every format of the context.
+package output
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"viking-reports/pkg/excel"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Column is a column of a Table.
+type Column struct {
+	Name string `json:"name"`
+	// Type is "text", "number", "percent" (a fraction) or "date".
+	Type string `json:"type"`
+}
+
+// Table is a table of a Result: rows of values, one per column. Values are
+// strings, numbers, time.Time or nil. Total rows are not part of a table.
+type Table struct {
+	Name    string
+	Columns []Column
+	Rows    [][]interface{}
+}
+
+// Result is what a report computed, whatever the format it is written in.
+type Result struct {
+	Tables []*Table
+	// Workbook is the result laid out as a workbook, with the styles,
+	// charts and pivots of the report, which the xlsx writer saves. A
+	// result without one is written to a workbook with a sheet per table.
+	Workbook *excelize.File
+}
+
+// NewResult returns an empty result with an empty workbook to lay it out in.
+func NewResult() *Result {
+	return &Result{Workbook: excel.NewFile()}
+}
+
+// Add writes rows to the workbook of r as t and adds them to r as the table
+// name.
+func (r *Result) Add(name string, t *excel.Table, rows [][]interface{}) error {
+	if err := t.Write(r.Workbook, rows); err != nil {
+		return err
+	}
+	table := &Table{Name: name, Rows: rows}
+	for _, column := range t.Columns {
+		table.Columns = append(table.Columns, Column{Name: column.Header, Type: columnType[column.Type]})
+	}
+	r.Tables = append(r.Tables, table)
+	return nil
+}
+
+var columnType = map[excel.ColumnType]string{
+	excel.Text:    "text",
+	excel.Number:  "number",
+	excel.Percent: "percent",
+	excel.Date:    "date",
+}
+
+// ReportWriter writes results in one format.
+type ReportWriter interface {
+	// Format is the name of the format, which is also the extension of its
+	// files, e.g. "csv".
+	Format() string
+	// Write writes result to the files of base, its path without the
+	// extension, and returns their paths.
+	Write(ctx context.Context, base string, result *Result) ([]string, error)
+}
+
+// Writers are the writers of the known formats, by name.
+var Writers = map[string]ReportWriter{
+	"xlsx": XLSX{},
+	"csv":  CSV{},
+	"json": JSON{},
+}
+
+// XLSXFormat is the format results are written in by default.
+const XLSXFormat = "xlsx"
+
+// ParseFormats parses a comma-separated list of formats, such as
+// "xlsx,csv".
+func ParseFormats(list string) ([]string, error) {
+	var formats []string
+	seen := make(map[string]bool)
+	for _, format := range strings.Split(list, ",") {
+		format = strings.ToLower(strings.TrimSpace(format))
+		if format == "" || seen[format] {
+			continue
+		}
+		if _, ok := Writers[format]; !ok {
+			return nil, fmt.Errorf("unknown output format %q, want xlsx, csv or json", format)
+		}
+		seen[format] = true
+		formats = append(formats, format)
+	}
+	if len(formats) == 0 {
+		return nil, fmt.Errorf("no output format in %q", list)
+	}
+	return formats, nil
+}
+
+type formatsKey struct{}
+
+// WithFormats returns a context under which Save writes results in formats,
+// which must be names of Writers.
+func WithFormats(ctx context.Context, formats []string) context.Context {
+	return context.WithValue(ctx, formatsKey{}, formats)
+}
+
+// Formats returns the formats of ctx; xlsx alone if it has none.
+func Formats(ctx context.Context) []string {
+	if formats, ok := ctx.Value(formatsKey{}).([]string); ok && len(formats) > 0 {
+		return formats
+	}
+	return []string{XLSXFormat}
+}
+
+// Written is called by Save with the path of each file written.
+type Written func(path string)
+
+type writtenKey struct{}
+
+// WithWritten returns a context under which Save calls hook with every file
+// it writes, whatever its format. Hooks already set in ctx are called first.
+func WithWritten(ctx context.Context, hook Written) context.Context {
+	if outer, ok := ctx.Value(writtenKey{}).(Written); ok {
+		inner := hook
+		hook = func(path string) {
+			outer(path)
+			inner(path)
+		}
+	}
+	return context.WithValue(ctx, writtenKey{}, hook)
+}
+
+type staleKey struct{}
+
+// WithStale returns a context under which Save marks the results it writes
+// in other formats than xlsx as generated from out-of-date inputs, for
+// reasons. Workbooks are marked by a BeforeSave hook instead; see
+// excel.WithBeforeSave.
+func WithStale(ctx context.Context, reasons []string) context.Context {
+	return context.WithValue(ctx, staleKey{}, reasons)
+}
+
+// stale returns the reasons the results saved under ctx are stale, or nil if
+// they are not.
+func stale(ctx context.Context) []string {
+	reasons, _ := ctx.Value(staleKey{}).([]string)
+	return reasons
+}
+
+// Save writes result in every format of ctx, next to path, which is the
+// path of its workbook: a report saved to growth_report.xlsx is written as
+// CSV to growth_report.csv. Protected workbooks, see excel.WithProtection,
+// are only written as xlsx, the one format that can be protected.
+func Save(ctx context.Context, result *Result, path string) error {
+	base := strings.TrimSuffix(path, filepath.Ext(path))
+	hook, _ := ctx.Value(writtenKey{}).(Written)
+	formats := Formats(ctx)
+	if excel.Protected(ctx) {
+		for _, format := range formats {
+			if format != XLSXFormat {
+				return fmt.Errorf("%s is protected and cannot be written as %s", path, format)
+			}
+		}
+	}
+	for _, format := range formats {
+		writer, ok := Writers[format]
+		if !ok {
+			return fmt.Errorf("unknown output format %q", format)
+		}
+		paths, err := writer.Write(ctx, base, result)
+		if err != nil {
+			return err
+		}
+		if hook != nil {
+			for _, p := range paths {
+				hook(p)
+			}
+		}
+	}
+	return nil
+}
+
+// writeFile writes path atomically, as excel.Save writes workbooks, with
+// the contents written by write.
+func writeFile(ctx context.Context, path string, write func(f *os.File) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(tmp.Name()) // no-op once renamed
+
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to save %s: %w", path, err)
+	}
+	return nil
+}
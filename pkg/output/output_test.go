@@ -0,0 +1,217 @@
+package output
+
+import (
+	"context"
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"viking-reports/pkg/excel"
+
+	"github.com/xuri/excelize/v2"
+)
+
+func growthResult(t *testing.T) *Result {
+	t.Helper()
+	result := NewResult()
+	table := excel.Table{
+		Sheet: "Sheet1",
+		Columns: []excel.Column{
+			{Header: "Dealer Name"},
+			{Header: "MTD SO", Type: excel.Number, Sum: true},
+			{Header: "Growth SO %", Type: excel.Percent},
+		},
+		Totals: true,
+	}
+	rows := [][]interface{}{
+		{"Alpha Mobiles, Hubli", 3, 0.5},
+		{"Bharat Telecom", 2, math.Inf(1)},
+	}
+	if err := result.Add("Growth", &table, rows); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+func TestSave(t *testing.T) {
+	dir := t.TempDir()
+	var written []string
+	ctx := WithFormats(context.Background(), []string{"xlsx", "csv", "json"})
+	ctx = WithWritten(ctx, func(path string) { written = append(written, filepath.Base(path)) })
+	if err := Save(ctx, growthResult(t), filepath.Join(dir, "growth_report.xlsx")); err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"growth_report.xlsx", "growth_report.csv", "growth_report.json"}; !reflect.DeepEqual(written, want) {
+		t.Errorf("wrote %v, want %v", written, want)
+	}
+
+	csv, err := os.ReadFile(filepath.Join(dir, "growth_report.csv"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// No total row, and no value for the infinite growth.
+	if want := "Dealer Name,MTD SO,Growth SO %\n\"Alpha Mobiles, Hubli\",3,0.5\nBharat Telecom,2,\n"; string(csv) != want {
+		t.Errorf("CSV = %q, want %q", csv, want)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "growth_report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct {
+		Tables []struct {
+			Name    string
+			Columns []Column
+			Rows    []map[string]interface{}
+		}
+	}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+	if len(doc.Tables) != 1 || doc.Tables[0].Name != "Growth" || len(doc.Tables[0].Rows) != 2 {
+		t.Fatalf("JSON = %s", data)
+	}
+	if got := doc.Tables[0].Columns[2]; got != (Column{Name: "Growth SO %", Type: "percent"}) {
+		t.Errorf("column = %v", got)
+	}
+	row := doc.Tables[0].Rows[1]
+	if row["MTD SO"] != 2.0 || row["Growth SO %"] != nil {
+		t.Errorf("row = %v", row)
+	}
+}
+
+func TestSaveStale(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "growth_report.xlsx")
+	marker := filepath.Join(dir, "growth_report_STALE_DATA.txt")
+	ctx := WithFormats(context.Background(), []string{"csv", "json"})
+	stale := WithStale(ctx, []string{"MTD-SO (MTD-SO.xlsx) has no transaction after 12-Oct-2026, more than 72h0m0s ago"})
+	if err := Save(stale, growthResult(t), path); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(marker); err != nil || !strings.Contains(string(data), "MTD-SO (MTD-SO.xlsx)") {
+		t.Errorf("stale marker = %q, %v", data, err)
+	}
+	data, err := os.ReadFile(filepath.Join(dir, "growth_report.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var doc struct{ Stale []string }
+	if err := json.Unmarshal(data, &doc); err != nil || len(doc.Stale) != 1 {
+		t.Errorf("JSON stale = %v, %v", doc.Stale, err)
+	}
+
+	// A fresh run replaces the stale results, marker included.
+	if err := Save(ctx, growthResult(t), path); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Errorf("stale marker left by a fresh run: %v", err)
+	}
+}
+
+func TestSaveProtected(t *testing.T) {
+	dir := t.TempDir()
+	ctx := WithFormats(context.Background(), []string{"xlsx", "csv"})
+	ctx = excel.WithProtection(ctx, func(string) (*excel.Protection, error) {
+		return &excel.Protection{LockSheets: true}, nil
+	})
+	if err := Save(ctx, growthResult(t), filepath.Join(dir, "credit_report.xlsx")); err == nil {
+		t.Error("protected result written as CSV")
+	}
+	if files, _ := filepath.Glob(filepath.Join(dir, "*")); len(files) != 0 {
+		t.Errorf("refused save wrote %v", files)
+	}
+}
+
+func TestCSVTables(t *testing.T) {
+	result := &Result{Tables: []*Table{
+		{Name: "SMART PHONES", Columns: []Column{{Name: "TSE", Type: "text"}}},
+		{Name: "ACCESSORIES", Columns: []Column{{Name: "TSE", Type: "text"}}},
+	}}
+	base := filepath.Join(t.TempDir(), "sales_report")
+	paths, err := CSV{}.Write(context.Background(), base, result)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{base + "_smart_phones.csv", base + "_accessories.csv"}; !reflect.DeepEqual(paths, want) {
+		t.Errorf("paths = %v, want %v", paths, want)
+	}
+}
+
+func TestXLSXWithoutWorkbook(t *testing.T) {
+	result := &Result{Tables: []*Table{
+		{Name: "Growth", Columns: []Column{{Name: "Dealer Name", Type: "text"}, {Name: "MTD SO", Type: "number"}}, Rows: [][]interface{}{{"Alpha Mobiles", 3}}},
+	}}
+	base := filepath.Join(t.TempDir(), "growth_report")
+	if _, err := (XLSX{}).Write(context.Background(), base, result); err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenFile(base + ".xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	rows, err := f.GetRows("Growth")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := [][]string{{"Dealer Name", "MTD SO"}, {"Alpha Mobiles", "3"}}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v, want %v", rows, want)
+	}
+}
+
+func TestXLSXWithoutWorkbookTables(t *testing.T) {
+	columns := []Column{{Name: "Model", Type: "text"}, {Name: "Units", Type: "number"}}
+	result := &Result{Tables: []*Table{
+		{Name: "SMART PHONES", Columns: columns, Rows: [][]interface{}{{"A15", 4}}},
+		{Name: "ACCESSORIES", Columns: columns, Rows: [][]interface{}{{"Charger", 9}, {"Case", 2}}},
+		{Name: "ACCESSORIES", Columns: columns, Rows: [][]interface{}{{"Cable", 1}}},
+	}}
+	base := filepath.Join(t.TempDir(), "sales_report")
+	if _, err := (XLSX{}).Write(context.Background(), base, result); err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenFile(base + ".xlsx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	want := map[string][][]string{
+		"SMART PHONES":  {{"Model", "Units"}, {"A15", "4"}},
+		"ACCESSORIES":   {{"Model", "Units"}, {"Charger", "9"}, {"Case", "2"}},
+		"ACCESSORIES 3": {{"Model", "Units"}, {"Cable", "1"}},
+	}
+	if sheets := f.GetSheetList(); len(sheets) != len(want) {
+		t.Errorf("sheets = %v, want one per table", sheets)
+	}
+	for sheet, wantRows := range want {
+		rows, err := f.GetRows(sheet)
+		if err != nil {
+			t.Errorf("sheet %s: %v", sheet, err)
+			continue
+		}
+		if !reflect.DeepEqual(rows, wantRows) {
+			t.Errorf("sheet %s rows = %v, want %v", sheet, rows, wantRows)
+		}
+	}
+}
+
+func TestParseFormats(t *testing.T) {
+	formats, err := ParseFormats(" CSV, json,csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"csv", "json"}; !reflect.DeepEqual(formats, want) {
+		t.Errorf("formats = %v, want %v", formats, want)
+	}
+	for _, list := range []string{"", "xlsx,pdf"} {
+		if _, err := ParseFormats(list); err == nil {
+			t.Errorf("want an error for %q", list)
+		}
+	}
+}
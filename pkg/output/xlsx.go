@@ -0,0 +1,66 @@
+package output
+
+import (
+	"context"
+	"fmt"
+
+	"viking-reports/pkg/excel"
+)
+
+// XLSX writes results as Excel workbooks, base.xlsx, through excel.Save, so
+// that they are protected and stamped as the context says.
+type XLSX struct{}
+
+func (XLSX) Format() string { return XLSXFormat }
+
+func (XLSX) Write(ctx context.Context, base string, result *Result) ([]string, error) {
+	f := result.Workbook
+	if f == nil {
+		f = excel.NewFile()
+		seen := make(map[string]bool)
+		for i, table := range result.Tables {
+			sheet := sheetName(table.Name, i)
+			if seen[sheet] {
+				sheet = sheetName(fmt.Sprintf("%s %d", table.Name, i+1), i)
+			}
+			seen[sheet] = true
+			if i == 0 {
+				if err := f.SetSheetName("Sheet1", sheet); err != nil {
+					return nil, err
+				}
+			} else if _, err := f.NewSheet(sheet); err != nil {
+				return nil, err
+			}
+			t := excel.Table{Sheet: sheet, FreezeHeader: true, AutoFilter: true}
+			for _, column := range table.Columns {
+				t.Columns = append(t.Columns, excel.Column{Header: column.Name, Type: sheetType[column.Type]})
+			}
+			if err := t.Write(f, table.Rows); err != nil {
+				return nil, err
+			}
+		}
+	}
+	path := base + ".xlsx"
+	if err := excel.Save(ctx, f, path); err != nil {
+		return nil, err
+	}
+	return []string{path}, nil
+}
+
+var sheetType = map[string]excel.ColumnType{
+	"number":  excel.Number,
+	"percent": excel.Percent,
+	"date":    excel.Date,
+}
+
+// sheetName returns the name of the sheet of the i-th table, name cut to
+// the 31 characters Excel allows.
+func sheetName(name string, i int) string {
+	if name == "" {
+		return fmt.Sprintf("Table %d", i+1)
+	}
+	if runes := []rune(name); len(runes) > 31 {
+		return string(runes[:31])
+	}
+	return name
+}